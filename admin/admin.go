@@ -0,0 +1,249 @@
+// Package admin provides an http.Handler for inspecting and purging a cache.Cache, for operators
+// who need to see what is cached and why it is stale. It serves JSON:
+//
+//	GET    /            statistics of the cache
+//	GET    /keys        the visible keys, with their TTLs; ?prefix= narrows the list
+//	GET    /keys/{key}  when the entry for a key was created, its hits, expiry and TTL
+//	DELETE /keys/{key}  deletes the entry for a key
+//	DELETE /tags/{tag}  invalidates the entries with a tag
+//
+// Only keys and tags that start with one of the prefixes given to WithPrefixes are visible, so that
+// entries holding sensitive data can be kept out of reach. Keys that are not strings are shown as
+// formatted by fmt.Sprint, and those stored through a cache.Namespace as the namespace's name and
+// the key separated by a colon. To serve the handler under a path, use http.StripPrefix.
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mrmorphic/cache"
+)
+
+// Handler is an http.Handler for inspecting and purging a cache. It is created with New.
+type Handler struct {
+	c        *cache.Cache
+	prefixes []string
+}
+
+// Option configures a Handler.
+type Option func(*Handler)
+
+// WithPrefixes allows the keys and tags that start with any of the given prefixes to be inspected
+// and purged. An empty prefix allows everything. Without this option no keys or tags are visible,
+// and only the cache's statistics are served.
+func WithPrefixes(prefixes ...string) Option {
+	return func(h *Handler) {
+		h.prefixes = append(h.prefixes, prefixes...)
+	}
+}
+
+// New returns a Handler for the given cache.
+func New(c *cache.Cache, opts ...Option) *Handler {
+	h := &Handler{c: c}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+	var handle func(http.ResponseWriter, *http.Request, string)
+	var name string
+	switch {
+	case path == "/" && r.Method == http.MethodGet:
+		handle = h.stats
+	case path == "/keys" && r.Method == http.MethodGet:
+		handle = h.keys
+	case strings.HasPrefix(path, "/keys/") && r.Method == http.MethodGet:
+		handle, name = h.entry, strings.TrimPrefix(path, "/keys/")
+	case strings.HasPrefix(path, "/keys/") && r.Method == http.MethodDelete:
+		handle, name = h.deleteKey, strings.TrimPrefix(path, "/keys/")
+	case strings.HasPrefix(path, "/tags/") && r.Method == http.MethodDelete:
+		handle, name = h.deleteTag, strings.TrimPrefix(path, "/tags/")
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	handle(w, r, name)
+}
+
+// allowed reports whether a key or tag may be inspected or purged.
+func (h *Handler) allowed(name string) bool {
+	for _, p := range h.prefixes {
+		if strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// keyName returns how a key is shown, and matched against the prefixes.
+func keyName(key interface{}) string {
+	switch k := key.(type) {
+	case string:
+		return k
+	case cache.NamespacedKey:
+		return k.Namespace + ":" + keyName(k.Key)
+	}
+	return fmt.Sprint(key)
+}
+
+// find returns the key in the cache shown as name, if it is visible.
+func (h *Handler) find(name string) (interface{}, bool) {
+	if !h.allowed(name) {
+		return nil, false
+	}
+	if _, ok := h.c.Inspect(name); ok {
+		return name, true
+	}
+	for _, key := range h.c.Keys() {
+		if keyName(key) == name {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+type statsResponse struct {
+	Hits            uint64  `json:"hits"`
+	Misses          uint64  `json:"misses"`
+	HitRatio        float64 `json:"hit_ratio"`
+	Evictions       uint64  `json:"evictions"`
+	Expirations     uint64  `json:"expirations"`
+	Refreshes       uint64  `json:"refreshes"`
+	RefreshErrors   uint64  `json:"refresh_errors"`
+	Entries         int     `json:"entries"`
+	Bytes           int64   `json:"bytes"`
+	Loads           uint64  `json:"loads"`
+	AverageLoadTime string  `json:"average_load_time"`
+}
+
+func (h *Handler) stats(w http.ResponseWriter, r *http.Request, _ string) {
+	st := h.c.Stats()
+	writeJSON(w, statsResponse{
+		Hits:            st.Hits,
+		Misses:          st.Misses,
+		HitRatio:        st.HitRatio(),
+		Evictions:       st.Evictions,
+		Expirations:     st.Expirations,
+		Refreshes:       st.Refreshes,
+		RefreshErrors:   st.RefreshErrors,
+		Entries:         st.Entries,
+		Bytes:           st.Bytes,
+		Loads:           st.Loads,
+		AverageLoadTime: st.AverageLoadTime.String(),
+	})
+}
+
+type keyResponse struct {
+	Key string `json:"key"`
+	TTL string `json:"ttl,omitempty"`
+}
+
+func (h *Handler) keys(w http.ResponseWriter, r *http.Request, _ string) {
+	prefix := r.URL.Query().Get("prefix")
+	keys := []keyResponse{}
+	for _, key := range h.c.Keys() {
+		name := keyName(key)
+		if !strings.HasPrefix(name, prefix) || !h.allowed(name) {
+			continue
+		}
+		ttl, ok := h.c.TTL(key)
+		if !ok {
+			continue
+		}
+		keys = append(keys, keyResponse{Key: name, TTL: formatTTL(ttl)})
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Key < keys[j].Key
+	})
+	writeJSON(w, keys)
+}
+
+// formatTTL returns how a TTL is shown, which is empty for entries that never expire.
+func formatTTL(ttl time.Duration) string {
+	if ttl == cache.NoExpiry {
+		return ""
+	}
+	return ttl.String()
+}
+
+type entryResponse struct {
+	Key          string     `json:"key"`
+	Created      time.Time  `json:"created"`
+	Refreshed    *time.Time `json:"refreshed,omitempty"`
+	LastAccessed *time.Time `json:"last_accessed,omitempty"`
+	Hits         uint64     `json:"hits"`
+	Expiry       *time.Time `json:"expiry,omitempty"`
+	TTL          string     `json:"ttl,omitempty"`
+	Size         int64      `json:"size"`
+	Perpetual    bool       `json:"perpetual"`
+	Negative     bool       `json:"negative"`
+	Tags         []string   `json:"tags,omitempty"`
+}
+
+// optionalTime returns a pointer to t, or nil if it is zero, so that it is omitted.
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func (h *Handler) entry(w http.ResponseWriter, r *http.Request, name string) {
+	key, ok := h.find(name)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	info, ok := h.c.Inspect(key)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	ttl, _ := h.c.TTL(key)
+	writeJSON(w, entryResponse{
+		Key:          name,
+		Created:      info.Created,
+		Refreshed:    optionalTime(info.Refreshed),
+		LastAccessed: optionalTime(info.LastAccessed),
+		Hits:         info.Hits,
+		Expiry:       optionalTime(info.Expiry),
+		TTL:          formatTTL(ttl),
+		Size:         info.Size,
+		Perpetual:    info.Perpetual,
+		Negative:     info.Negative,
+		Tags:         info.Tags,
+	})
+}
+
+func (h *Handler) deleteKey(w http.ResponseWriter, r *http.Request, name string) {
+	key, ok := h.find(name)
+	if !ok || !h.c.Delete(key) {
+		http.NotFound(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) deleteTag(w http.ResponseWriter, r *http.Request, tag string) {
+	if !h.allowed(tag) {
+		http.NotFound(w, r)
+		return
+	}
+	h.c.InvalidateTag(tag)
+	w.WriteHeader(http.StatusNoContent)
+}
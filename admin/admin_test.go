@@ -0,0 +1,75 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mrmorphic/cache"
+)
+
+func do(h http.Handler, method, url string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, url, nil))
+	return w
+}
+
+func TestStats(t *testing.T) {
+	c := cache.NewCache()
+	defer c.Free()
+	c.Store("user:1", "fred", time.Minute)
+	c.Get("user:1")
+	h := New(c)
+
+	var st statsResponse
+	if err := json.Unmarshal(do(h, "GET", "/").Body.Bytes(), &st); err != nil {
+		t.Fatalf("Expected the stats as JSON, but got %v", err)
+	}
+	if st.Hits != 1 || st.Entries != 1 {
+		t.Errorf("Expected 1 hit and 1 entry, but got %+v", st)
+	}
+	// no prefixes are allowed, so no keys are visible
+	if body := do(h, "GET", "/keys").Body.String(); body != "[]\n" {
+		t.Errorf("Did not expect keys to be visible without prefixes, but got %s", body)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	c := cache.NewCache()
+	defer c.Free()
+	c.Store("user:1", "fred", time.Minute, cache.WithTags("users"))
+	c.Store("user:2", "jim", cache.NoExpiry)
+	c.Store("secret:1", "hunter2", time.Minute)
+	c.Namespace("user").Store(3, "sheila", time.Minute)
+	h := New(c, WithPrefixes("user"))
+
+	var keys []keyResponse
+	json.Unmarshal(do(h, "GET", "/keys").Body.Bytes(), &keys)
+	if len(keys) != 3 || keys[0].Key != "user:1" || keys[1].Key != "user:2" || keys[2].Key != "user:3" {
+		t.Errorf("Expected the keys starting with 'user', but got %+v", keys)
+	}
+	if keys[0].TTL == "" || keys[1].TTL != "" {
+		t.Errorf("Expected a TTL only for the key that expires, but got %+v", keys)
+	}
+
+	var entry entryResponse
+	json.Unmarshal(do(h, "GET", "/keys/user:1").Body.Bytes(), &entry)
+	if entry.Key != "user:1" || entry.Expiry == nil || len(entry.Tags) != 1 {
+		t.Errorf("Expected the details of key 'user:1', but got %+v", entry)
+	}
+	if w := do(h, "GET", "/keys/secret:1"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected a key outside the prefixes to be hidden, but got status %d", w.Code)
+	}
+
+	if w := do(h, "DELETE", "/keys/user:3"); w.Code != http.StatusNoContent || c.Namespace("user").Get(3) != nil {
+		t.Errorf("Expected the namespaced key to be deleted, but got status %d", w.Code)
+	}
+	if w := do(h, "DELETE", "/keys/secret:1"); w.Code != http.StatusNotFound || c.Get("secret:1") == nil {
+		t.Errorf("Did not expect a key outside the prefixes to be deleted, but got status %d", w.Code)
+	}
+	if w := do(h, "DELETE", "/tags/users"); w.Code != http.StatusNoContent || c.Get("user:1") != nil {
+		t.Errorf("Expected the tag to be invalidated, but got status %d", w.Code)
+	}
+}
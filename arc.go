@@ -0,0 +1,125 @@
+package cache
+
+import "container/list"
+
+// arc is an EvictionPolicy implementing the Adaptive Replacement Cache algorithm. Entries that have
+// been used once are in t1, and entries used more than once are in t2, each in least recently used
+// order. The keys of entries recently evicted from each are remembered in the ghost lists b1 and b2.
+// A key stored again while it is in b1 suggests t1 is too small, and increases the target size p
+// of t1; one in b2 reduces it. Victims come from t1 while it is larger than p, and otherwise from
+// t2. The cache's size in entries isn't fixed, as it is limited in bytes, so the number of resident
+// entries is used in its place.
+type arc struct {
+	t1, t2, b1, b2 *list.List
+	elements       map[interface{}]*list.Element
+	p              int
+
+	// the key last returned by Victim, which is moved to a ghost list when it is removed.
+	evicting interface{}
+}
+
+// arcNode is a key tracked by arc, and the list it is in.
+type arcNode struct {
+	key  interface{}
+	list *list.List
+}
+
+func newARC() *arc {
+	return &arc{t1: list.New(), t2: list.New(), b1: list.New(), b2: list.New(), elements: make(map[interface{}]*list.Element)}
+}
+
+// size returns the number of resident entries.
+func (p *arc) size() int {
+	return p.t1.Len() + p.t2.Len()
+}
+
+// push adds a key to the front of a list.
+func (p *arc) push(key interface{}, to *list.List) {
+	p.elements[key] = to.PushFront(&arcNode{key: key, list: to})
+}
+
+// drop removes a key from whichever list it is in.
+func (p *arc) drop(el *list.Element) {
+	node := el.Value.(*arcNode)
+	node.list.Remove(el)
+	delete(p.elements, node.key)
+}
+
+// trim forgets the oldest ghosts while there are more in a list than there are resident entries.
+func (p *arc) trim(ghosts *list.List) {
+	for ghosts.Len() > max(1, p.size()) {
+		p.drop(ghosts.Back())
+	}
+}
+
+func (p *arc) OnStore(key interface{}) {
+	if el := p.elements[key]; el != nil {
+		switch el.Value.(*arcNode).list {
+		case p.b1:
+			p.p = min(p.size(), p.p+max(p.b2.Len()/p.b1.Len(), 1))
+			p.drop(el)
+			p.push(key, p.t2)
+			return
+		case p.b2:
+			p.p = max(0, p.p-max(p.b1.Len()/p.b2.Len(), 1))
+			p.drop(el)
+			p.push(key, p.t2)
+			return
+		default:
+			p.drop(el)
+		}
+	}
+	p.push(key, p.t1)
+}
+
+func (p *arc) OnGet(key interface{}) {
+	el := p.elements[key]
+	if el == nil {
+		return
+	}
+	switch el.Value.(*arcNode).list {
+	case p.t1:
+		p.drop(el)
+		p.push(key, p.t2)
+	case p.t2:
+		p.t2.MoveToFront(el)
+	}
+}
+
+func (p *arc) OnRemove(key interface{}) {
+	el := p.elements[key]
+	if el == nil {
+		return
+	}
+	from := el.Value.(*arcNode).list
+	if from != p.t1 && from != p.t2 {
+		return
+	}
+	p.drop(el)
+	if key != p.evicting {
+		// removed for another reason than eviction, so there is nothing to learn from it
+		return
+	}
+	p.evicting = nil
+	if from == p.t1 {
+		p.push(key, p.b1)
+		p.trim(p.b1)
+	} else {
+		p.push(key, p.b2)
+		p.trim(p.b2)
+	}
+}
+
+func (p *arc) Victim() (interface{}, bool) {
+	var el *list.Element
+	if p.t1.Len() > 0 && (p.t1.Len() > p.p || p.t2.Len() == 0) {
+		el = p.t1.Back()
+	} else {
+		el = p.t2.Back()
+	}
+	if el == nil {
+		return nil, false
+	}
+	p.evicting = el.Value.(*arcNode).key
+	return p.evicting, true
+}
@@ -0,0 +1,32 @@
+package cache
+
+import "testing"
+
+func TestARC(t *testing.T) {
+	if hot := hotKeysAfterScan(ARC); hot < 90 {
+		t.Errorf("Expected ARC to keep most of the frequently used keys, but it kept %d of 100", hot)
+	}
+}
+
+func TestARCGhostHit(t *testing.T) {
+	p := newARC()
+	p.OnStore("a")
+	p.OnStore("b")
+	if key, _ := p.Victim(); key != "a" {
+		t.Fatalf("Expected 'a' to be the first victim, but got '%v'", key)
+	}
+	p.OnRemove("a")
+
+	// storing a again while it is remembered in b1 makes t1's target larger, and puts it in t2
+	p.OnStore("a")
+	if p.p != 1 {
+		t.Errorf("Expected a ghost hit in b1 to increase the target size of t1 to 1, but it is %d", p.p)
+	}
+	if p.t2.Len() != 1 || p.t2.Front().Value.(*arcNode).key != "a" {
+		t.Errorf("Expected 'a' to be in t2 after a ghost hit")
+	}
+	// t1 is now within its target size, so the next victim comes from t2
+	if key, _ := p.Victim(); key != "a" {
+		t.Errorf("Expected 'a' to be the next victim, but got '%v'", key)
+	}
+}
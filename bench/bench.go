@@ -0,0 +1,186 @@
+// Package bench measures the performance of a cache.Cache under synthetic workloads. A Scenario
+// describes a workload: how the cache is configured, how many keys are used and how often they are
+// read rather than stored. Run runs a scenario from several goroutines for a while and reports the
+// throughput and hit ratio. The same workloads are run by the package's benchmarks, and by the
+// cachebench command.
+package bench
+
+import (
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mrmorphic/cache"
+)
+
+// Scenario is a workload to run against a cache.
+type Scenario struct {
+	// Name identifies the scenario in results.
+	Name string
+
+	// Shards is the number of shards the cache is created with. If it is 0 the cache's default is
+	// used.
+	Shards int
+
+	// Entries is the number of distinct keys used. The keys are the ints from 0 to Entries-1, and
+	// are all stored before the workload starts.
+	Entries int
+
+	// Reads is the fraction of operations that are Gets; the rest are Stores. 1 is a read-only
+	// workload and 0 a write-only one.
+	Reads float64
+
+	// Skewed has keys chosen from a Zipf distribution, so that a few keys are used much more than
+	// the rest, rather than uniformly.
+	Skewed bool
+
+	// MaxBytes is passed to SetMaxBytes once the keys are stored, if it is not 0.
+	MaxBytes int64
+
+	// Options are passed to cache.NewCache.
+	Options []cache.Option
+}
+
+// Scenarios is a set of scenarios covering read-heavy, write-heavy and mixed workloads over small
+// and large key sets.
+var Scenarios = []Scenario{
+	{Name: "get", Entries: 1000, Reads: 1},
+	{Name: "get-large", Entries: 1000000, Reads: 1},
+	{Name: "store", Entries: 1000, Reads: 0},
+	{Name: "mixed", Entries: 1000, Reads: 0.9},
+	{Name: "mixed-skewed", Entries: 100000, Reads: 0.9, Skewed: true},
+	{Name: "mixed-one-shard", Shards: 1, Entries: 1000, Reads: 0.9},
+	{Name: "mixed-evicting", Entries: 100000, Reads: 0.9, Skewed: true, MaxBytes: 1 << 20},
+	{Name: "mixed-syncmap", Entries: 1000, Reads: 0.9, Options: []cache.Option{cache.WithSyncMap()}},
+}
+
+// Lookup returns the scenario in Scenarios with the given name, or false if there is none.
+func Lookup(name string) (Scenario, bool) {
+	for _, s := range Scenarios {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return Scenario{}, false
+}
+
+// NewCache returns a cache configured for the scenario, with all its keys stored.
+func (s Scenario) NewCache() *cache.Cache {
+	opts := s.Options
+	if s.Shards > 0 {
+		opts = append(opts[:len(opts):len(opts)], cache.WithShards(s.Shards))
+	}
+	c := cache.NewCache(opts...)
+	for i := 0; i < s.Entries; i++ {
+		c.Store(i, i, cache.NoExpiry)
+	}
+	if s.MaxBytes != 0 {
+		c.SetMaxBytes(s.MaxBytes)
+	}
+	return c
+}
+
+// Worker runs the operations of a scenario from a single goroutine. Each goroutine needs its own
+// Worker, as they each have their own source of random numbers.
+type Worker struct {
+	c     *cache.Cache
+	s     Scenario
+	rand  *rand.Rand
+	zipf  *rand.Zipf
+	reads uint64
+}
+
+// NewWorker returns a Worker running the scenario against a cache returned by NewCache.
+func (s Scenario) NewWorker(c *cache.Cache) *Worker {
+	w := &Worker{c: c, s: s, rand: rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))}
+	w.reads = uint64(s.Reads * (1 << 32))
+	if s.Skewed && s.Entries > 1 {
+		w.zipf = rand.NewZipf(w.rand, 1.1, 1, uint64(s.Entries-1))
+	}
+	return w
+}
+
+// Do runs one operation.
+func (w *Worker) Do() {
+	var key int
+	if w.zipf != nil {
+		key = int(w.zipf.Uint64())
+	} else if w.s.Entries > 0 {
+		key = w.rand.IntN(w.s.Entries)
+	}
+	if uint64(w.rand.Uint32()) < w.reads {
+		w.c.Get(key)
+		return
+	}
+	w.c.Store(key, key, cache.NoExpiry)
+}
+
+// Result is the outcome of running a scenario.
+type Result struct {
+	Scenario   string
+	Goroutines int
+	Duration   time.Duration
+	Ops        uint64
+	Gets       uint64
+	Hits       uint64
+}
+
+// OpsPerSecond returns the number of operations run per second.
+func (r Result) OpsPerSecond() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.Ops) / r.Duration.Seconds()
+}
+
+// HitRatio returns the fraction of Gets that found their key, or 0 if there were none.
+func (r Result) HitRatio() float64 {
+	if r.Gets == 0 {
+		return 0
+	}
+	return float64(r.Hits) / float64(r.Gets)
+}
+
+// Run runs a scenario from the given number of goroutines for the given duration, against a new
+// cache which is freed afterwards.
+func Run(s Scenario, goroutines int, duration time.Duration) Result {
+	if goroutines < 1 {
+		goroutines = 1
+	}
+	c := s.NewCache()
+	defer c.Free()
+	before := c.Stats()
+
+	var stop atomic.Bool
+	var ops atomic.Uint64
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(w *Worker) {
+			defer wg.Done()
+			var n uint64
+			for !stop.Load() {
+				w.Do()
+				n++
+			}
+			ops.Add(n)
+		}(s.NewWorker(c))
+	}
+	time.Sleep(duration)
+	stop.Store(true)
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	after := c.Stats()
+	hits, misses := after.Hits-before.Hits, after.Misses-before.Misses
+	return Result{
+		Scenario:   s.Name,
+		Goroutines: goroutines,
+		Duration:   elapsed,
+		Ops:        ops.Load(),
+		Gets:       hits + misses,
+		Hits:       hits,
+	}
+}
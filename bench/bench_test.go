@@ -0,0 +1,106 @@
+package bench
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mrmorphic/cache"
+)
+
+// benchmark runs a scenario with b.RunParallel.
+func benchmark(b *testing.B, s Scenario) {
+	c := s.NewCache()
+	defer c.Free()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		w := s.NewWorker(c)
+		for pb.Next() {
+			w.Do()
+		}
+	})
+}
+
+func BenchmarkScenarios(b *testing.B) {
+	for _, s := range Scenarios {
+		b.Run(s.Name, func(b *testing.B) {
+			benchmark(b, s)
+		})
+	}
+}
+
+func BenchmarkGet(b *testing.B) {
+	for _, shards := range []int{1, 4, 16, 64} {
+		for _, entries := range []int{100, 10000, 1000000} {
+			b.Run(fmt.Sprintf("shards=%d/entries=%d", shards, entries), func(b *testing.B) {
+				benchmark(b, Scenario{Shards: shards, Entries: entries, Reads: 1})
+			})
+		}
+	}
+}
+
+func BenchmarkStore(b *testing.B) {
+	for _, shards := range []int{1, 4, 16, 64} {
+		for _, entries := range []int{100, 10000, 1000000} {
+			b.Run(fmt.Sprintf("shards=%d/entries=%d", shards, entries), func(b *testing.B) {
+				benchmark(b, Scenario{Shards: shards, Entries: entries, Reads: 0})
+			})
+		}
+	}
+}
+
+func BenchmarkMixed(b *testing.B) {
+	for _, reads := range []float64{0.5, 0.9, 0.99} {
+		for _, syncMap := range []bool{false, true} {
+			s := Scenario{Entries: 10000, Reads: reads, Skewed: true}
+			if syncMap {
+				s.Options = []cache.Option{cache.WithSyncMap()}
+			}
+			b.Run(fmt.Sprintf("reads=%v/syncmap=%v", reads, syncMap), func(b *testing.B) {
+				benchmark(b, s)
+			})
+		}
+	}
+}
+
+func BenchmarkEviction(b *testing.B) {
+	modes := []struct {
+		name string
+		mode cache.EvictionMode
+	}{
+		{"lru", cache.LRU},
+		{"lfu", cache.LFU},
+		{"tinylfu", cache.TinyLFU},
+		{"arc", cache.ARC},
+		{"fifo", cache.FIFO},
+		{"random", cache.Random},
+	}
+	for _, m := range modes {
+		b.Run(m.name, func(b *testing.B) {
+			benchmark(b, Scenario{
+				Entries:  100000,
+				Reads:    0.9,
+				Skewed:   true,
+				MaxBytes: 1 << 20,
+				Options:  []cache.Option{cache.WithEviction(m.mode)},
+			})
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	s, ok := Lookup("mixed")
+	if !ok {
+		t.Fatalf("Expected scenario 'mixed' to exist")
+	}
+	r := Run(s, 4, 20*time.Millisecond)
+	if r.Ops == 0 || r.Gets == 0 {
+		t.Errorf("Expected operations to be run, but got %d operations and %d gets", r.Ops, r.Gets)
+	}
+	if r.HitRatio() != 1 {
+		t.Errorf("Expected every get to hit, but the hit ratio was %v", r.HitRatio())
+	}
+	if r.Gets > r.Ops {
+		t.Errorf("Expected at most %d gets, but got %d", r.Ops, r.Gets)
+	}
+}
@@ -0,0 +1,59 @@
+// Command cachebench runs the workloads of the bench package against a cache and prints their
+// throughput, to compare configurations or check for regressions without writing a benchmark.
+//
+// Usage:
+//
+//	cachebench [-duration 1s] [-goroutines N] [-shards N] [scenario ...]
+//
+// With no scenarios, all of them are run. -list prints the names of the scenarios.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mrmorphic/cache/bench"
+)
+
+func main() {
+	duration := flag.Duration("duration", time.Second, "how long to run each scenario")
+	goroutines := flag.Int("goroutines", runtime.GOMAXPROCS(0), "number of goroutines running each scenario")
+	shards := flag.Int("shards", 0, "number of shards, overriding the scenario's")
+	list := flag.Bool("list", false, "list the scenarios and exit")
+	flag.Parse()
+
+	if *list {
+		for _, s := range bench.Scenarios {
+			fmt.Println(s.Name)
+		}
+		return
+	}
+
+	scenarios := bench.Scenarios
+	if flag.NArg() > 0 {
+		scenarios = nil
+		for _, name := range flag.Args() {
+			s, ok := bench.Lookup(name)
+			if !ok {
+				fmt.Fprintf(os.Stderr, "cachebench: unknown scenario %q\n", name)
+				os.Exit(2)
+			}
+			scenarios = append(scenarios, s)
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "scenario\tgoroutines\tops\tops/s\thit ratio\t")
+	for _, s := range scenarios {
+		if *shards > 0 {
+			s.Shards = *shards
+		}
+		r := bench.Run(s, *goroutines, *duration)
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.0f\t%.3f\t\n", r.Scenario, r.Goroutines, r.Ops, r.OpsPerSecond(), r.HitRatio())
+	}
+	w.Flush()
+}
@@ -0,0 +1,245 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+)
+
+// InvalidationKind is the kind of change described by an Invalidation.
+type InvalidationKind int
+
+const (
+	// KeyInvalidation deletes a single key.
+	KeyInvalidation InvalidationKind = iota
+
+	// TagInvalidation deletes the entries with a tag.
+	TagInvalidation
+
+	// NamespaceInvalidation deletes the entries in a namespace.
+	NamespaceInvalidation
+
+	// ClearInvalidation deletes every entry.
+	ClearInvalidation
+
+	// PrefixInvalidation deletes the entries in a subtree of string keys.
+	PrefixInvalidation
+)
+
+// Invalidation describes a deletion made in one cache that should be made in its peers. Only string
+// keys can be sent between processes, so deleting keys of other types is not propagated.
+type Invalidation struct {
+	Kind InvalidationKind `json:"kind"`
+
+	// Name is the key, tag, namespace or path invalidated. For a KeyInvalidation of a key in a
+	// namespace, Namespace is the name of the namespace.
+	Name      string `json:"name,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+
+	// Origin identifies the cache that published the invalidation, so that it can ignore its own.
+	Origin string `json:"origin"`
+}
+
+// Bus broadcasts invalidations between caches, typically in different processes. A cache attached
+// to a bus with AttachBus publishes its deletions to the bus, and applies those published by its
+// peers.
+type Bus interface {
+	// Publish sends an invalidation to every subscriber, including those in the same process.
+	Publish(inv Invalidation) error
+
+	// Subscribe calls fn for each invalidation published to the bus, until the returned function is
+	// called.
+	Subscribe(fn func(Invalidation)) (unsubscribe func(), err error)
+}
+
+// busAttachment is a bus a cache is attached to.
+type busAttachment struct {
+	bus         Bus
+	origin      string
+	unsubscribe func()
+}
+
+// AttachBus connects the cache to a bus. From then on Delete, DeleteAndGet, DeleteMulti,
+// InvalidateTag, InvalidateNamespace, DeletePrefix and Clear are published to the bus, and the
+// same operations published by other caches on the bus are applied to this one. Entries removed
+// for any other reason, such as expiry, are not published. The cache is detached from the bus by
+// Free.
+func (c *Cache) AttachBus(bus Bus) error {
+	if c.closed.Load() {
+		return ErrClosed
+	}
+	origin := make([]byte, 8)
+	rand.Read(origin)
+	a := &busAttachment{bus: bus, origin: hex.EncodeToString(origin)}
+	unsubscribe, err := bus.Subscribe(func(inv Invalidation) {
+		if inv.Origin != a.origin {
+			c.applyInvalidation(inv)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	a.unsubscribe = unsubscribe
+	if old := c.bus.Swap(a); old != nil {
+		old.unsubscribe()
+	}
+	return nil
+}
+
+// detachBus disconnects the cache from its bus, if it has one.
+func (c *Cache) detachBus() {
+	if a := c.bus.Swap(nil); a != nil {
+		a.unsubscribe()
+	}
+}
+
+// publish sends an invalidation to the cache's bus, if it has one. Errors are ignored; peers that
+// miss an invalidation still see the entry expire.
+func (c *Cache) publish(inv Invalidation) {
+	if a := c.bus.Load(); a != nil {
+		inv.Origin = a.origin
+		a.bus.Publish(inv)
+	}
+}
+
+// publishKey publishes the deletion of a key, if it is one that can be sent to peers.
+func (c *Cache) publishKey(key interface{}) {
+	if c.bus.Load() == nil {
+		return
+	}
+	switch k := key.(type) {
+	case string:
+		c.publish(Invalidation{Kind: KeyInvalidation, Name: k})
+	case NamespacedKey:
+		if name, ok := k.Key.(string); ok {
+			c.publish(Invalidation{Kind: KeyInvalidation, Name: name, Namespace: k.Namespace})
+		}
+	}
+}
+
+// applyInvalidation makes a deletion published by a peer, without publishing it again.
+func (c *Cache) applyInvalidation(inv Invalidation) {
+	switch inv.Kind {
+	case KeyInvalidation:
+		if inv.Namespace != "" {
+			c.deleteAndGet(NamespacedKey{Namespace: inv.Namespace, Key: inv.Name})
+		} else {
+			c.deleteAndGet(inv.Name)
+		}
+	case TagInvalidation:
+		c.invalidateTag(inv.Name)
+	case NamespaceInvalidation:
+		c.invalidateNamespace(inv.Name)
+	case ClearInvalidation:
+		c.clear()
+	case PrefixInvalidation:
+		c.deletePrefix(inv.Name)
+	}
+}
+
+// MemoryBus is a Bus for caches in the same process. It is mostly useful for tests, and for
+// applications that keep several caches of the same data.
+type MemoryBus struct {
+	mu          sync.Mutex
+	subscribers map[int]func(Invalidation)
+	next        int
+}
+
+// NewMemoryBus returns a new MemoryBus with no subscribers.
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{subscribers: make(map[int]func(Invalidation))}
+}
+
+// Publish implements Bus. Subscribers are called synchronously.
+func (b *MemoryBus) Publish(inv Invalidation) error {
+	b.mu.Lock()
+	subscribers := make([]func(Invalidation), 0, len(b.subscribers))
+	for _, fn := range b.subscribers {
+		subscribers = append(subscribers, fn)
+	}
+	b.mu.Unlock()
+	for _, fn := range subscribers {
+		fn(inv)
+	}
+	return nil
+}
+
+// Subscribe implements Bus.
+func (b *MemoryBus) Subscribe(fn func(Invalidation)) (func(), error) {
+	b.mu.Lock()
+	id := b.next
+	b.next++
+	b.subscribers[id] = fn
+	b.mu.Unlock()
+	return func() {
+		b.mu.Lock()
+		delete(b.subscribers, id)
+		b.mu.Unlock()
+	}, nil
+}
+
+// PubSub is the part of a Redis client used by RedisBus. It is small enough to adapt any client
+// library to; with github.com/redis/go-redis, for example:
+//
+//	type goRedis struct{ *redis.Client }
+//
+//	func (r goRedis) Publish(ctx context.Context, channel, message string) error {
+//		return r.Client.Publish(ctx, channel, message).Err()
+//	}
+//
+//	func (r goRedis) Subscribe(ctx context.Context, channel string) (<-chan string, func() error) {
+//		ps := r.Client.Subscribe(ctx, channel)
+//		ch := make(chan string)
+//		go func() {
+//			defer close(ch)
+//			for msg := range ps.Channel() {
+//				ch <- msg.Payload
+//			}
+//		}()
+//		return ch, ps.Close
+//	}
+type PubSub interface {
+	Publish(ctx context.Context, channel, message string) error
+	Subscribe(ctx context.Context, channel string) (messages <-chan string, close func() error)
+}
+
+// RedisBus is a Bus that uses Redis pub/sub, so that caches in different processes can invalidate
+// each other's entries. Invalidations are sent as JSON on a single channel.
+type RedisBus struct {
+	client  PubSub
+	channel string
+}
+
+// NewRedisBus returns a RedisBus that publishes and subscribes on the given channel.
+func NewRedisBus(client PubSub, channel string) *RedisBus {
+	return &RedisBus{client: client, channel: channel}
+}
+
+// Publish implements Bus.
+func (b *RedisBus) Publish(inv Invalidation) error {
+	msg, err := json.Marshal(inv)
+	if err != nil {
+		return err
+	}
+	return b.client.Publish(context.Background(), b.channel, string(msg))
+}
+
+// Subscribe implements Bus. Messages that can't be decoded are ignored.
+func (b *RedisBus) Subscribe(fn func(Invalidation)) (func(), error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	messages, closeSub := b.client.Subscribe(ctx, b.channel)
+	go func() {
+		for msg := range messages {
+			var inv Invalidation
+			if json.Unmarshal([]byte(msg), &inv) == nil {
+				fn(inv)
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		closeSub()
+	}, nil
+}
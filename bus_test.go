@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryBus(t *testing.T) {
+	bus := NewMemoryBus()
+	a, b := NewCache(), NewCache()
+	a.AttachBus(bus)
+	b.AttachBus(bus)
+
+	for _, c := range []*Cache{a, b} {
+		c.Store("key", "value", time.Minute)
+		c.StoreTagged("tagged", "value", time.Minute, "page5")
+		c.Namespace("menus").Store("main", "value", time.Minute)
+		c.Store(5, "value", time.Minute)
+	}
+
+	a.Delete("key")
+	a.InvalidateTag("page5")
+	a.Namespace("menus").Delete("main")
+	a.Delete(5)
+
+	for _, key := range []interface{}{"key", "tagged", NamespacedKey{"menus", "main"}} {
+		if v := b.Get(key); v != nil {
+			t.Errorf("Expected cache key '%v' to be deleted from the peer, but has value '%v'", key, v)
+		}
+	}
+
+	// only string keys are sent to peers
+	if v := b.Get(5); v == nil {
+		t.Errorf("Did not expect non-string cache key 5 to be deleted from the peer")
+	}
+
+	b.Free()
+	a.Clear()
+	if v := b.Get(5); v == nil {
+		t.Errorf("Did not expect a freed cache to receive invalidations")
+	}
+}
+
+// fakePubSub is a PubSub that delivers messages to subscribers in the same process.
+type fakePubSub struct {
+	mu   sync.Mutex
+	subs []chan string
+}
+
+func (f *fakePubSub) Publish(ctx context.Context, channel, message string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, ch := range f.subs {
+		ch <- message
+	}
+	return nil
+}
+
+func (f *fakePubSub) Subscribe(ctx context.Context, channel string) (<-chan string, func() error) {
+	ch := make(chan string, 10)
+	f.mu.Lock()
+	f.subs = append(f.subs, ch)
+	f.mu.Unlock()
+	return ch, func() error { return nil }
+}
+
+func TestRedisBus(t *testing.T) {
+	ps := &fakePubSub{}
+	a, b := NewCache(), NewCache()
+	a.AttachBus(NewRedisBus(ps, "invalidations"))
+	b.AttachBus(NewRedisBus(ps, "invalidations"))
+
+	b.Store("key", "value", time.Minute)
+	a.Delete("key")
+
+	// messages are delivered asynchronously
+	deadline := time.Now().Add(time.Second)
+	for b.Get("key") != nil && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if v := b.Get("key"); v != nil {
+		t.Errorf("Expected cache key 'key' to be deleted from the peer, but has value '%v'", v)
+	}
+}
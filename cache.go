@@ -3,43 +3,122 @@ package cache
 // A simple cache mechanism for testing performance. This is intended to be used for global state
 // across requests, such as SiteConfig. The caller is responsible for re-adding values if the cache
 // is missed.
-// This is a very naive caching mechanism. It is only suitable for small caches at this point,
-// as the expiry locks the set while checking
-
-// Ideas to try here include:
-//  * add to cache with a function that can be called in a goroutine to refresh the value on
-//    expiry. set-and-forget. Duration still specified. This is actually important for permanently cycled
-//	  caches like SiteTree, so that we can replace the value before invaliding the new one; otherwise multiple
-//    requests will race to re-populate it, causing an unnecessary load spike.
-//  * add to cache with a policy expiry function. The cache will poll the policy expiry functions.
 
 import (
+	"context"
+	"errors"
+	"hash/maphash"
+	"log/slog"
+	"reflect"
+	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
+	"weak"
 )
 
 // ValueGenerator is any function that when called generates a value. Used in perpetual cache entries.
 type ValueGenerator func() interface{}
 
+// ValueGeneratorE is a function that generates a value for a perpetual cache entry, and that can
+// fail. What happens when it fails is determined by the entry's FailurePolicy.
+type ValueGeneratorE func() (interface{}, error)
+
+// ContextValueGenerator is a function that generates a value for a perpetual cache entry, and that
+// can fail. It should return promptly with the context's error if the context is cancelled.
+type ContextValueGenerator func(ctx context.Context) (interface{}, error)
+
 // Cache represents a cache instance. The cache will in general contain a number of elements. Each Cache
 // operates independently, and can be used safely across multiple go-routines.
 type Cache struct {
-	// mutex to safely handle changes to the cache across goroutines.
-	sync.Mutex
+	// the entries are spread over the shards by the hash of their key.
+	shards []*shard
+	seed   maphash.Seed
+
+	// the Store given to WithStore, or nil if each shard keeps its values in memory.
+	store Store
+
+	// configuration set by options passed to NewCache.
+	shardCount    int
+	sweepInterval time.Duration
+	defaultTTL    time.Duration
+	clock         Clock
+	staleReads    bool
+	checkKeys     bool
+	syncMap       bool
+	costFunc      CostFunc
+	logger        *slog.Logger
+	slowGenerator time.Duration
+	compression   *compression
+	valueCodec    Codec
+	cloner        func(interface{}) interface{}
+	eviction      EvictionMode
+	newPolicy     func() EvictionPolicy
+	jitter        float64
+	codec         Codec
+
+	// the pool regenerating perpetual entries, if WithRefreshWorkers was given.
+	refreshWorkers int
+	pool           *refreshPool
+
+	// how many entries Warm loads at once, if WithWarmConcurrency was given.
+	warmConcurrency int
+
+	// the limit on how often expired perpetual entries are regenerated, if WithRefreshRate was
+	// given.
+	limiter *tokenBucket
+
+	// the number of entries checked in each round of sampled expiry, if WithSampledExpiry was
+	// given.
+	expirySamples int
+
+	// the heap size past which entries are shed, and the fraction shed, if WithMemoryPressure was
+	// given.
+	pressureThreshold uint64
+	pressureFraction  float64
+
+	// the file given to WithSnapshotFile and how often it is saved, if set.
+	snapshotPath     string
+	snapshotInterval time.Duration
 
-	// the map of entries.
-	entries map[interface{}]*CacheEntry
+	// the context passed to generators when perpetual entries are regenerated. It is cancelled
+	// by Free.
+	ctx    context.Context
+	cancel context.CancelFunc
 
-	ticker *time.Ticker
-	quit   chan bool
+	// the regenerations of perpetual entries in progress, which Shutdown waits for.
+	regenerations regenerations
+
+	// the bus the cache is attached to with AttachBus, if any.
+	bus atomic.Pointer[busAttachment]
+
+	// the entries that depend on each key.
+	deps *dependencies
+
+	// statistics returned by Stats.
+	counters counters
+
+	// sends events to the channel returned by Events. It is shared with the shards, and so must
+	// not refer to the cache, or the cache would never be finalized.
+	events *events
+
+	// the channels returned by Watch, WatchPrefix and WatchFunc, also shared with the shards.
+	watches *watches
+
+	// functions added with OnEvict, called when entries are removed.
+	hookMu     sync.Mutex
+	evictHooks []EvictFunc
+
+	// closed by Close to stop the sweep.
+	closed atomic.Bool
+	quit   chan struct{}
 }
 
-// CacheEntry represents the value of an entry in the cache. It primarily holds the current value, but also
-// when the entry expires. CacheEntry instances can be perpetual or not. Perpetual cache entries have a
-// ValueGenerator, a function that generates a refreshed value when called. Non-perpetual cache entries
-// are deleted from the cache on expiry.
+// CacheEntry represents an entry in the cache. It holds when the entry expires and how it behaves;
+// the current value is kept in the cache's Store. CacheEntry instances can be perpetual or not.
+// Perpetual cache entries have a ValueGenerator, a function that generates a refreshed value when
+// called. Non-perpetual cache entries are deleted from the cache on expiry.
 type CacheEntry struct {
-	value  interface{}
 	expiry time.Time
 
 	// Indicates if this is a perpetual entry (true) or not (false). Perpetual entries must also
@@ -47,35 +126,293 @@ type CacheEntry struct {
 	perpetual bool
 
 	// for perpetual cache entries, this is the function used to refresh the value on expiry.
-	fn ValueGenerator
+	// Generators of other types are wrapped to this type.
+	fn ContextValueGenerator
+
+	// for perpetual cache entries, the longest a single call to fn may take before its context
+	// is cancelled. Zero means no limit.
+	timeout time.Duration
+
+	// for perpetual cache entries, what to do when fn fails, the backoff for the Retry policy and
+	// the circuit breaker, the number of consecutive failures, and the number of them that opens
+	// the breaker, if set by WithCircuitBreaker.
+	policy     FailurePolicy
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	failures   int
+	breaker    int
+
+	// for perpetual cache entries, true if the entry's regeneration was deferred by the limit set
+	// with WithRefreshRate, and it has a token to be regenerated when it next expires.
+	reserved bool
 
 	// for perpetual cache enties, this is the lifetime so we can keep re-generating.
 	lifetime time.Duration
+
+	// for perpetual cache entries set with WithStaleWhileRevalidate, async is true and maxStale
+	// is how long past expiry the old value may be returned.
+	async    bool
+	maxStale time.Duration
+
+	// for perpetual cache entries set with WithRefreshAhead, the fraction of the lifetime after
+	// which the entry is regenerated, or zero to regenerate at the end of the lifetime.
+	refreshAhead float64
+
+	// true while a perpetual entry is paused by Pause, in which case it is not in the expiry heap.
+	paused bool
+
+	// non-nil while a perpetual entry is being regenerated in the background, and closed when
+	// the regeneration completes.
+	refreshing chan struct{}
+
+	// true once the entry's value has been invalidated by InvalidateAndRefresh, until it is
+	// replaced. For perpetual entries, rerun is true if InvalidateAndRefresh was called while the
+	// entry was being regenerated, so that it is regenerated again once that completes.
+	invalidated bool
+	rerun       bool
+
+	// the key the entry is stored under, and its index in the shard's expiry heap, which is -1
+	// when it is not in the heap. With sampled expiry, sample is instead its position in the
+	// entries to sample plus one, or zero when it is not one of them.
+	key    interface{}
+	index  int
+	sample int
+
+	// called when the entry is removed from the cache, if set by WithOnEvict.
+	onEvict EvictFunc
+
+	// for entries stored with StoreWithPolicy, the function polled by the sweep to decide whether
+	// the entry has expired.
+	expiryPolicy ExpiryPolicy
+
+	// tags set by WithTags, by which the entry can be invalidated.
+	tags []string
+
+	// true for entries stored with StoreNegative, which record that the key has no value.
+	negative bool
+
+	// for entries loaded by LoadFrom that were perpetual when saved, true until StorePerpetual
+	// takes over the loaded value.
+	warm bool
+
+	// when the entry was stored, and for perpetual entries when the value was last regenerated.
+	created   time.Time
+	refreshed time.Time
+
+	// how many times the entry has been retrieved, and when it last was in Unix nanoseconds. These
+	// are updated atomically, as entries are mostly retrieved without the shard locked for writing.
+	hits     atomic.Uint64
+	accessed atomic.Int64
+
+	// the version of the entry's value, which increases each time a value is stored for its key.
+	version uint64
+
+	// the priority of the entry for eviction, set by WithPriority.
+	priority Priority
+
+	// the type the value was stored with by StoreTyped, or nil.
+	typ reflect.Type
+
+	// the keys of the entries this entry depends on, set by WithDependencies.
+	dependsOn []interface{}
+
+	// the soft lifetime set by WithSoftTTL, and the time after which the entry is stale, or zero if
+	// it doesn't have one.
+	softTTL    time.Duration
+	softExpiry time.Time
+
+	// for entries with sliding expiration, the idle timeout set by WithIdleTimeout, and the time
+	// past which the expiry can't be extended, or zero if there is no limit.
+	idle     time.Duration
+	deadline time.Time
+
+	// estimated size of the value in bytes. sizeHint is set by WithSize, and if non-zero takes
+	// precedence over the estimate.
+	size     int64
+	sizeHint int64
+}
+
+// EntryOption is an option that can be passed when storing a value in the cache, to alter how
+// that entry behaves.
+type EntryOption func(*CacheEntry)
+
+// WithSize gives the size in bytes of the value being stored. This is used for byte-bounded
+// eviction (see SetMaxBytes), and overrides the size the cache would otherwise estimate, or the
+// cost given by the cache's CostFunc.
+func WithSize(bytes int64) EntryOption {
+	return func(e *CacheEntry) {
+		e.sizeHint = bytes
+	}
 }
 
-// NewCache returns a new, initialised Cache instance.
-func NewCache() *Cache {
-	c := &Cache{}
-	c.entries = make(map[interface{}]*CacheEntry)
+// Sizer can be implemented by values stored in the cache to report their approximate size
+// in bytes.
+type Sizer interface {
+	Size() int
+}
+
+// CostFunc returns the cost of storing a value in the cache, which is counted against the limit
+// set by SetMaxBytes in place of the value's estimated size. See WithCostFunc.
+type CostFunc func(key, value interface{}) int64
+
+// WithCostFunc weights the entries of the cache by the cost returned by fn, rather than by their
+// estimated size, so that eviction keeps the total cost within the limit set by SetMaxBytes. The
+// cost can be the exact size of values the cache can't estimate well, such as large query
+// results, or in other units entirely, in which case the limit is in the same units. A size given
+// with WithSize still takes precedence. fn is called with the shard locked, so it must be quick and
+// must not use the cache.
+func WithCostFunc(fn CostFunc) Option {
+	return func(c *Cache) {
+		c.costFunc = fn
+	}
+}
+
+// NewCache returns a new, initialised Cache instance, configured by the given options.
+func NewCache(opts ...Option) *Cache {
+	c := &Cache{
+		seed:          maphash.MakeSeed(),
+		shardCount:    defaultShards(),
+		sweepInterval: time.Second,
+		clock:         systemClock{},
+		codec:         GobCodec{},
+		events:        &events{sample: 1},
+		watches:       &watches{},
+		slowGenerator: defaultSlowGenerator,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.events.clock = c.clock
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	c.deps = newDependencies()
 
+	c.shards = make([]*shard, c.shardCount)
+	for i := range c.shards {
+		c.shards[i] = newShard(c.store, c.deps, c.policyFactory())
+		c.shards[i].views = c.newViews()
+		c.shards[i].costFunc = c.costFunc
+		c.shards[i].events = c.events
+		c.shards[i].watches = c.watches
+		c.shards[i].logger = c.logger
+		c.shards[i].expiries.sampling = c.expirySamples
+		if c.store == nil {
+			c.shards[i].compression = c.compression
+			c.shards[i].valueCodec = c.valueCodec
+		}
+	}
+
+	if c.refreshWorkers > 0 {
+		c.pool = newRefreshPool(c, c.refreshWorkers)
+	}
 	c.startTimer()
+	if c.snapshotPath != "" {
+		c.startSnapshots()
+	}
+	runtime.SetFinalizer(c, (*Cache).Free)
 
 	return c
 }
 
+// NewShardedCache returns a new, initialised Cache instance with the given number of shards. It is
+// equivalent to NewCache(WithShards(shards)).
+func NewShardedCache(shards int) *Cache {
+	return NewCache(WithShards(shards))
+}
+
+// shardFor returns the shard that holds the given key.
+func (c *Cache) shardFor(key interface{}) *shard {
+	if c.checkKeys {
+		if err := CheckKey(key); err != nil {
+			panic(err)
+		}
+	}
+	if len(c.shards) == 1 {
+		return c.shards[0]
+	}
+	return c.shards[maphash.Comparable(c.seed, key)%uint64(len(c.shards))]
+}
+
+// ErrClosed is returned by methods of a cache that has been freed with Free or Close. Methods that
+// store entries and can't return an error panic with it instead.
+var ErrClosed = errors.New("cache: cache is closed")
+
 // Free is required to cleanup before a cache is deleted. This ensures that the timer that invalidates
-// cache entries is stopped.
+// cache entries is stopped, and cancels the context of any ContextValueGenerator that is running.
+// If WithSnapshotFile was given, a final snapshot is saved. It is safe to call Free more than once.
+// Shutdown also waits for the perpetual entries being regenerated, rather than cancelling them.
+// A cache that becomes unreachable without being freed is freed when it is garbage collected,
+// unless it is attached to a bus, but that may be much later, so Free should still be called.
 func (c *Cache) Free() {
-	c.quit <- true
+	c.Close()
+}
+
+// Close is Free, returning the error from saving the final snapshot if WithSnapshotFile was given,
+// or ErrClosed if the cache was already closed.
+func (c *Cache) Close() error {
+	if !c.closed.CompareAndSwap(false, true) {
+		return ErrClosed
+	}
+	runtime.SetFinalizer(c, nil)
+	var err error
+	if c.snapshotPath != "" {
+		err = c.saveSnapshot()
+	}
+	c.detachBus()
+	c.cancel()
+	c.watches.close()
+	if c.pool != nil {
+		c.pool.close()
+	}
+	close(c.quit)
+	return err
 }
 
 // Store a key/value pair in the cache, with the specified lifetime. On expiry, the cache entry
-// is just deleted from the cache.
-func (c *Cache) Store(key interface{}, value interface{}, lifetime time.Duration) {
-	entry := &CacheEntry{value: value, expiry: time.Now().Add(lifetime), perpetual: false}
-	c.Lock()
-	c.entries[key] = entry
-	c.Unlock()
+// is just deleted from the cache. A lifetime of DefaultLifetime uses the cache's default lifetime,
+// and a lifetime of NoExpiry stores an entry that never expires.
+func (c *Cache) Store(key interface{}, value interface{}, lifetime time.Duration, opts ...EntryOption) {
+	entry := c.newEntry(lifetime, opts)
+	s := c.shardFor(key)
+	s.Lock()
+	s.add(key, entry, value)
+	c.unlock(s)
+}
+
+// StoreNegative records that there is no value for a key, for the specified lifetime. Get returns
+// nil for the key as it does for a miss, while Lookup reports that the key was found and is
+// negative. This lets callers stop repeating lookups of records that don't exist.
+func (c *Cache) StoreNegative(key interface{}, lifetime time.Duration, opts ...EntryOption) {
+	entry := c.newEntry(lifetime, opts)
+	entry.negative = true
+	s := c.shardFor(key)
+	s.Lock()
+	s.add(key, entry, nil)
+	c.unlock(s)
+}
+
+// newEntry returns a non-perpetual entry with the given lifetime and options.
+func (c *Cache) newEntry(lifetime time.Duration, opts []EntryOption) *CacheEntry {
+	if c.closed.Load() {
+		panic(ErrClosed)
+	}
+	if lifetime < 0 {
+		lifetime = c.defaultTTL
+	}
+	now := c.clock.Now()
+	lifetime = c.jittered(lifetime)
+	entry := &CacheEntry{expiry: expiryAt(now, lifetime), perpetual: false, created: now, index: -1}
+	if lifetime != NoExpiry {
+		c.counters.lifetimes.observe(lifetime)
+	}
+	for _, opt := range opts {
+		opt(entry)
+	}
+	if entry.idle > 0 {
+		entry.deadline = entry.expiry
+		entry.slide(now)
+	}
+	entry.soften(now)
+	return entry
 }
 
 // Store a key/value pair in the cache, where the value comes from a function. On expiry, after
@@ -84,76 +421,381 @@ func (c *Cache) Store(key interface{}, value interface{}, lifetime time.Duration
 // entry will either get the old value or the new value, but will not each attempt to regenerate
 // the entry. These cache entries can be deleted using Delete. Otherwise they remain for the duration
 // of the cache and the program.
-func (c *Cache) StorePerpetual(key interface{}, fn ValueGenerator, lifetime time.Duration) {
-	entry := &CacheEntry{fn: fn, expiry: time.Now().Add(lifetime), lifetime: lifetime, perpetual: true}
-	entry.value = fn()
-	c.Lock()
-	c.entries[key] = entry
-	c.Unlock()
+func (c *Cache) StorePerpetual(key interface{}, fn ValueGenerator, lifetime time.Duration, opts ...EntryOption) {
+	gen := func(context.Context) (interface{}, error) {
+		return fn(), nil
+	}
+	if err := c.storePerpetual(c.ctx, key, gen, lifetime, opts); err != nil {
+		// the generator can't fail, so the cache must be closed
+		panic(err)
+	}
+}
+
+// StorePerpetualE is like StorePerpetual, but the value comes from a ValueGeneratorE, which can
+// fail. If generating the initial value fails the error is returned and nothing is stored. If
+// regeneration fails the entry's FailurePolicy is applied.
+func (c *Cache) StorePerpetualE(key interface{}, fn ValueGeneratorE, lifetime time.Duration, opts ...EntryOption) error {
+	gen := func(context.Context) (interface{}, error) {
+		return fn()
+	}
+	return c.storePerpetual(c.ctx, key, gen, lifetime, opts)
+}
+
+// StorePerpetualContext is like StorePerpetual, but the value comes from a ContextValueGenerator.
+// The initial value is generated using the given context, and if that fails the error is returned
+// and nothing is stored. When the entry is regenerated, the generator is passed a context that is
+// cancelled when the cache is freed, and that has a deadline if WithGeneratorTimeout was given.
+// If regeneration fails the entry's FailurePolicy is applied.
+func (c *Cache) StorePerpetualContext(ctx context.Context, key interface{}, fn ContextValueGenerator, lifetime time.Duration, opts ...EntryOption) error {
+	return c.storePerpetual(ctx, key, fn, lifetime, opts)
+}
+
+// storePerpetual generates the initial value of a perpetual entry and stores it. If LoadFrom
+// loaded a value for the key that has not expired, that is used as the initial value instead.
+func (c *Cache) storePerpetual(ctx context.Context, key interface{}, fn ContextValueGenerator, lifetime time.Duration, opts []EntryOption) error {
+	if c.closed.Load() {
+		return ErrClosed
+	}
+	entry := &CacheEntry{key: key, fn: fn, lifetime: lifetime, perpetual: true, index: -1}
+	for _, opt := range opts {
+		opt(entry)
+	}
+	s := c.shardFor(key)
+	s.Lock()
+	if value, expiry, ok := c.takeWarm(s, key); ok {
+		entry.created = c.clock.Now()
+		entry.refreshed = entry.created
+		entry.expiry = expiry
+		s.add(key, entry, value)
+		c.unlock(s)
+		return nil
+	}
+	s.Unlock()
+	value, err := c.generate(ctx, entry)
+	if err != nil {
+		return err
+	}
+	now := c.clock.Now()
+	entry.created, entry.refreshed = now, now
+	entry.expiry = now.Add(entry.ahead(c.jittered(lifetime)))
+	s.Lock()
+	s.add(key, entry, value)
+	c.unlock(s)
+	return nil
 }
 
 // Delete a cache entry by key. This can be used to eject a value before the lifetime duration,
-// or delete a recurring entry such as those added with StorePerpetual
-func (c *Cache) Delete(key interface{}) {
-	delete(c.entries, key)
+// or delete a recurring entry such as those added with StorePerpetual. Returns true if there was
+// an entry for the key that had not expired.
+func (c *Cache) Delete(key interface{}) bool {
+	_, ok := c.DeleteAndGet(key)
+	return ok
+}
+
+// DeleteAndGet deletes a cache entry by key like Delete, and returns the value it had and true, or
+// nil and false if there was no entry for the key that had not expired.
+func (c *Cache) DeleteAndGet(key interface{}) (interface{}, bool) {
+	value, ok := c.deleteAndGet(key)
+	c.publishKey(key)
+	return value, ok
+}
+
+// deleteAndGet is DeleteAndGet without publishing the deletion to the cache's bus.
+func (c *Cache) deleteAndGet(key interface{}) (interface{}, bool) {
+	s := c.shardFor(key)
+	s.Lock()
+	defer c.unlock(s)
+	entry := s.entries[key]
+	if entry == nil {
+		return nil, false
+	}
+	value := s.remove(entry, ReasonDeleted)
+	if entry.expiredAt(c.clock.Now()) {
+		return nil, false
+	}
+	return value, true
+}
+
+// Clear deletes every entry in the cache, including perpetual entries, which are no longer
+// regenerated. All the shards are locked together, so no other goroutine sees the cache part way
+// through being cleared.
+func (c *Cache) Clear() {
+	c.clear()
+	c.publish(Invalidation{Kind: ClearInvalidation})
+}
+
+// clear is Clear without publishing to the cache's bus.
+func (c *Cache) clear() {
+	c.lockAll()
+	for _, s := range c.shards {
+		for _, entry := range s.entries {
+			s.remove(entry, ReasonDeleted)
+		}
+	}
+	c.unlockAll()
 }
 
-// Retrieve a value from the cache given it's key. Returns nil if there is no value.
+// Reset deletes every entry in the cache that is not perpetual, and marks perpetual entries as
+// expired so that they are regenerated by the next sweep. Perpetual entries keep their current
+// values until they are regenerated. Like Clear, this happens atomically.
+func (c *Cache) Reset() {
+	now := c.clock.Now()
+	c.lockAll()
+	for _, s := range c.shards {
+		for _, entry := range s.entries {
+			if !entry.perpetual {
+				s.remove(entry, ReasonDeleted)
+			} else if entry.index >= 0 {
+				entry.expiry = now
+				s.expiries.reschedule(entry)
+			}
+		}
+	}
+	c.unlockAll()
+}
+
+// lockAll locks every shard, always in the same order so that concurrent calls can't deadlock.
+func (c *Cache) lockAll() {
+	for _, s := range c.shards {
+		s.Lock()
+	}
+}
+
+// unlockAll unlocks every shard locked by lockAll, calling eviction hooks as unlock does.
+func (c *Cache) unlockAll() {
+	for _, s := range c.shards {
+		c.unlock(s)
+	}
+}
+
+// Retrieve a value from the cache given it's key. Returns nil if there is no value. An entry that
+// has passed its expiry is removed here if the sweep has not got to it yet, unless the cache was
+// created with WithStaleReads.
 func (c *Cache) Get(key interface{}) interface{} {
-	c.Lock()
-	entry := c.entries[key]
-	c.Unlock()
+	value, _, _ := c.Lookup(key)
+	return value
+}
+
+// GetOK is like Get, but also returns whether there was an entry for the key, so that a stored
+// nil value can be told apart from a miss. Entries stored with StoreNegative are found, with a nil
+// value; use Lookup to tell them apart.
+func (c *Cache) GetOK(key interface{}) (interface{}, bool) {
+	value, found, _ := c.Lookup(key)
+	return value, found
+}
+
+// Lookup is like Get, but also returns whether there was an entry for the key, so a stored nil
+// value can be told apart from a miss, and whether the entry was stored with StoreNegative.
+func (c *Cache) Lookup(key interface{}) (value interface{}, found, negative bool) {
+	value, found, negative = c.find(key)
+	return c.clone(value), found, negative
+}
+
+// find is Lookup without cloning the value.
+func (c *Cache) find(key interface{}) (value interface{}, found, negative bool) {
+	s := c.shardFor(key)
+	if value, negative, ok := c.loadView(s, key); ok {
+		return value, true, negative
+	}
+	if value, found, negative, ok := c.peek(s, key); ok {
+		return value, found, negative
+	}
+	s.Lock()
+	defer c.unlock(s)
+	now := c.clock.Now()
+	entry := c.lookup(s, key, now)
 	if entry == nil {
+		return nil, false, false
+	}
+	if entry.perpetual && entry.tooStale(now) {
+		done := c.refresh(s, entry)
+		c.unlock(s)
+		<-done
+		s.Lock()
+		if s.entries[key] != entry {
+			return nil, false, false
+		}
+	}
+	return s.value(entry), true, entry.negative
+}
+
+// peek is Lookup using only the shard's read lock, which is possible unless the entry has expired
+// and must be removed, has sliding expiry, or must be waited for while it is regenerated. It returns
+// false if Lookup must take the write lock instead.
+func (c *Cache) peek(s *shard, key interface{}) (value interface{}, found, negative, ok bool) {
+	now := c.clock.Now()
+	s.RLock()
+	defer s.RUnlock()
+	entry := s.entries[key]
+	switch {
+	case entry == nil:
+		c.countMiss(key)
+		return nil, false, false, true
+	case !c.staleReads && entry.expiredAt(now), entry.idle > 0, entry.perpetual && entry.tooStale(now):
+		return nil, false, false, false
+	}
+	c.countHit(key)
+	c.countFirstHit(entry, now)
+	if !entry.perpetual {
+		s.recordRead(key)
+	}
+	return s.value(entry), true, entry.negative, true
+}
+
+// lookup returns the entry for a key, or nil if there is none or it has expired, and records the
+// hit or miss. The caller must hold the shard's lock.
+func (c *Cache) lookup(s *shard, key interface{}, now time.Time) *CacheEntry {
+	entry := s.entries[key]
+	if entry == nil {
+		c.countMiss(key)
 		return nil
 	}
-	return entry.value
+	if !c.staleReads && entry.expiredAt(now) {
+		s.remove(entry, ReasonExpired)
+		c.countMiss(key)
+		return nil
+	}
+	c.countHit(key)
+	c.countFirstHit(entry, now)
+	if s.evictable(entry) {
+		s.policy.OnGet(key)
+	}
+	if entry.slide(now) && entry.index >= 0 {
+		s.expiries.reschedule(entry)
+	}
+	return entry
+}
+
+// SetMaxBytes sets a limit on the estimated total size of the values in the cache, in bytes.
+// When the total exceeds the limit, the least recently used entries are evicted until it no
+// longer does. Perpetual entries and those pinned with Pin count towards the total but are never
+// evicted. A limit of 0 removes the limit. The limit is divided evenly between the cache's shards,
+// so entries may be evicted before the total for the whole cache reaches it.
+//
+// The size of each value is taken from WithSize if it was given when the value was stored, from
+// the cache's CostFunc if it has one, or from the value's Size method if it implements Sizer.
+// Otherwise it is estimated from the type of the value, which is only accurate for strings, byte
+// slices and values without pointers.
+func (c *Cache) SetMaxBytes(limit int64) {
+	perShard := (limit + int64(len(c.shards)) - 1) / int64(len(c.shards))
+	for _, s := range c.shards {
+		s.Lock()
+		s.maxBytes = perShard
+		s.evictBytes()
+		c.unlock(s)
+	}
 }
 
-// start up a 1 second ping to expiry cache entries past their expiry.
-// @todo parameterise the cache ping, in milliseconds, with 1 second default.
+// estimateSize returns the approximate size of a value in bytes.
+func estimateSize(value interface{}) int64 {
+	switch v := value.(type) {
+	case nil:
+		return 0
+	case Sizer:
+		return int64(v.Size())
+	case string:
+		return int64(len(v))
+	case []byte:
+		return int64(len(v))
+	}
+	return int64(reflect.TypeOf(value).Size())
+}
+
+// start up a ping, every sweep interval, to expire cache entries past their expiry. The goroutine
+// only holds a weak pointer to the cache, so that a cache that is not freed can still be garbage
+// collected, at which point its finalizer stops the goroutine.
 func (c *Cache) startTimer() {
-	c.ticker = time.NewTicker(time.Second)
-	c.quit = make(chan bool)
-	go func() {
-		for {
-			select {
-			case <-c.ticker.C:
-				n := time.Now().UnixNano()
-				c.Lock()
-				for k, v := range c.entries {
-					e := v.expiry.UnixNano()
-					if e <= n {
-						c.expire(k, v)
-					}
-				}
-				c.Unlock()
-			case <-c.quit:
-				c.ticker.Stop()
+	c.quit = make(chan struct{})
+	go sweepLoop(weak.Make(c), c.clock.NewTicker(c.sweepInterval), c.quit)
+}
+
+// Sweep expires the entries that are past their expiry and regenerates the perpetual entries that
+// are due, as the cache does every sweep interval, returning once it has done so. Entries that are
+// regenerated in the background are only started. It is mostly useful in tests using a FakeClock,
+// which can move the clock and then sweep rather than wait for the sweep to run.
+func (c *Cache) Sweep() {
+	for _, s := range c.shards {
+		c.sweep(s)
+	}
+}
+
+// sweepLoop sweeps the shards of a cache on each tick, until quit is closed or the cache has been
+// garbage collected.
+func sweepLoop(wc weak.Pointer[Cache], ticker Ticker, quit chan struct{}) {
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C():
+			c := wc.Value()
+			if c == nil {
 				return
 			}
+			start := time.Now()
+			due := 0
+			for _, s := range c.shards {
+				due += c.sweep(s)
+			}
+			c.logSwept(time.Since(start), due)
+			c.checkPressure()
+		case <-quit:
+			return
 		}
-	}()
+	}
+}
+
+// sweep expires the entries in a shard that are past their expiry, and returns how many there
+// were. The due entries are taken off the expiry heap first and then expired individually, so the
+// shard is not locked while perpetual entries are regenerated. With sampled expiry, the other
+// entries are then sampled.
+func (c *Cache) sweep(s *shard) int {
+	n := c.clock.Now().UnixNano()
+	var due []*CacheEntry
+	s.Lock()
+	for v := s.expiries.popDue(n); v != nil; v = s.expiries.popDue(n) {
+		due = append(due, v)
+	}
+	s.Unlock()
+	for _, v := range due {
+		c.expire(s, v)
+	}
+	c.pollPolicies(s)
+	if s.expiries.sampling > 0 {
+		return len(due) + c.sweepSamples(s)
+	}
+	return len(due)
 }
 
 // Handle expiry of a cache entry. If it is not perpetual, just remove it from the cache.
-// If it is perpetual, execute the function to regenerate a new value.
-func (c *Cache) expire(key interface{}, entry *CacheEntry) {
+// If it is perpetual, execute the function to regenerate a new value, in the background if
+// the entry was stored with WithStaleWhileRevalidate or the cache has refresh workers, unless
+// the limit set by WithRefreshRate defers it.
+func (c *Cache) expire(s *shard, entry *CacheEntry) {
 	if entry.perpetual {
-		// entry is perpetual, so evaluate the function for a new value.
-		nv := entry.fn()
-
-		// Replace the value atomically
-		c.Lock()
-
-		// store the new value
-		entry.value = nv
-
-		// recompute the expiry
-		entry.expiry = time.Now().Add(entry.lifetime)
-
-		c.Unlock()
+		if c.deferRefresh(s, entry) {
+			return
+		}
+		if entry.async || c.pool != nil {
+			s.Lock()
+			c.refresh(s, entry)
+			s.Unlock()
+		} else {
+			// mark it as being regenerated, as refresh does, so that a Refresh or
+			// InvalidateAndRefresh in the meantime waits for it or reruns it after it
+			s.Lock()
+			if s.entries[entry.key] != entry || entry.refreshing != nil {
+				s.Unlock()
+				return
+			}
+			entry.refreshing = make(chan struct{})
+			s.Unlock()
+			c.regenerate(s, entry)
+		}
 	} else {
-		// not perpetual, just delete it.
-		c.Delete(key)
+		// not perpetual, just delete it, unless it has been replaced since the sweep found it.
+		s.Lock()
+		if s.entries[entry.key] == entry {
+			s.remove(entry, ReasonExpired)
+		}
+		c.unlock(s)
 	}
 }
@@ -1,6 +1,8 @@
 package cache
 
 import (
+	"runtime"
+	"sync"
 	"testing"
 	"time"
 )
@@ -27,13 +29,14 @@ func TestSaveCache(t *testing.T) {
 }
 
 func TestExpiry(t *testing.T) {
-	cache := NewCache()
+	clock := NewFakeClock(time.Now())
+	cache := NewCache(WithClock(clock))
+	defer cache.Free()
 	key := "Key2"
 	value := "Value2"
-	seconds := time.Duration(1)
 
 	// set a key with short expiry
-	cache.Store(key, value, time.Second*seconds)
+	cache.Store(key, value, time.Second)
 
 	// test we can retrieve it immediately
 	v := cache.Get(key)
@@ -41,8 +44,8 @@ func TestExpiry(t *testing.T) {
 		t.Errorf("Expected cache key '%s' to have value '%s', but got '%s'", key, value, v)
 	}
 
-	// wait past expiry, giving a couple of extra seconds
-	time.Sleep(time.Second * (seconds + 1))
+	// move past expiry
+	clock.Add(time.Second)
 
 	// test it's gone
 	v = cache.Get(key)
@@ -50,3 +53,364 @@ func TestExpiry(t *testing.T) {
 		t.Errorf("Did not expect cache key '%s' to be still set after expiry, but has value '%s'", key, v)
 	}
 }
+
+type sized int
+
+func (s sized) Size() int {
+	return int(s)
+}
+
+func TestMaxBytes(t *testing.T) {
+	cache := NewShardedCache(1)
+	cache.SetMaxBytes(100)
+
+	cache.Store("a", "0123456789", time.Second*30, WithSize(40))
+	cache.Store("b", sized(40), time.Second*30)
+
+	// touch a so that b is the least recently used
+	cache.Get("a")
+
+	// c takes the total over the limit, so b should be evicted
+	cache.Store("c", make([]byte, 30), time.Second*30)
+
+	if v := cache.Get("b"); v != nil {
+		t.Errorf("Expected cache key 'b' to be evicted, but has value '%v'", v)
+	}
+	if v := cache.Get("a"); v == nil {
+		t.Errorf("Expected cache key 'a' to have a value, but returned nil")
+	}
+	if v := cache.Get("c"); v == nil {
+		t.Errorf("Expected cache key 'c' to have a value, but returned nil")
+	}
+
+	// lowering the limit evicts a, which is now the least recently used
+	cache.SetMaxBytes(50)
+	if v := cache.Get("a"); v != nil {
+		t.Errorf("Expected cache key 'a' to be evicted, but has value '%v'", v)
+	}
+}
+
+func TestCostFunc(t *testing.T) {
+	// weigh each result by its number of rows
+	cache := NewCache(WithShards(1), WithCostFunc(func(key, value interface{}) int64 {
+		return int64(len(value.([]string)))
+	}))
+	defer cache.Free()
+	cache.SetMaxBytes(12)
+
+	cache.Store("small", []string{"a", "b"}, time.Minute)
+	cache.Store("explicit", []string{"a"}, time.Minute, WithSize(3))
+	if st := cache.Stats(); st.Bytes != 5 {
+		t.Errorf("Expected a total cost of 5, but got %d", st.Bytes)
+	}
+
+	// the big result takes the total to 13, so the least recently used entry is evicted
+	cache.Store("big", make([]string, 8), time.Minute)
+	if v := cache.Get("small"); v != nil {
+		t.Errorf("Expected cache key 'small' to be evicted, but has value '%v'", v)
+	}
+	if st := cache.Stats(); st.Bytes != 11 || st.Entries != 2 {
+		t.Errorf("Expected 2 entries with a total cost of 11, but got %d with %d", st.Entries, st.Bytes)
+	}
+}
+
+func TestShardedConcurrentAccess(t *testing.T) {
+	cache := NewShardedCache(8)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				cache.Store(g*1000+i, i, time.Second*30)
+				if v := cache.Get(g*1000 + i); v == nil || v.(int) != i {
+					t.Errorf("Expected cache key %d to have value %d, but got '%v'", g*1000+i, i, v)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func TestExpiryBeforeSweep(t *testing.T) {
+	cache := NewCache()
+	key := "Key3"
+
+	cache.Store(key, "Value3", time.Millisecond*50)
+	time.Sleep(time.Millisecond * 100)
+
+	// the sweep runs every second, so this relies on Get checking the expiry itself
+	if v := cache.Get(key); v != nil {
+		t.Errorf("Did not expect cache key '%s' to be still set after expiry, but has value '%s'", key, v)
+	}
+}
+
+func TestStaleReads(t *testing.T) {
+	cache := NewCache(WithStaleReads())
+	key := "Key5"
+	value := "Value5"
+
+	cache.Store(key, value, time.Millisecond*50)
+	time.Sleep(time.Millisecond * 100)
+
+	// the sweep has not run yet, so the expired value is still returned
+	if v := cache.Get(key); v == nil || v.(string) != value {
+		t.Errorf("Expected cache key '%s' to have stale value '%s', but got '%v'", key, value, v)
+	}
+}
+
+func TestPerpetual(t *testing.T) {
+	cache := NewCache()
+	key := "Perpetual"
+	var mu sync.Mutex
+	n := 0
+
+	cache.StorePerpetual(key, func() interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		n++
+		return n
+	}, time.Second)
+
+	if v := cache.Get(key); v == nil || v.(int) != 1 {
+		t.Errorf("Expected cache key '%s' to have value 1, but got '%v'", key, v)
+	}
+
+	// wait past expiry, giving the sweep time to regenerate the value
+	time.Sleep(time.Millisecond * 2500)
+
+	if v := cache.Get(key); v == nil || v.(int) < 2 {
+		t.Errorf("Expected cache key '%s' to have been regenerated, but got '%v'", key, v)
+	}
+}
+
+func TestOptions(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cache := NewCache(WithSweepInterval(time.Millisecond*10), WithDefaultTTL(time.Minute), WithClock(clock))
+	key := "Key4"
+
+	cache.Store(key, "Value4", DefaultLifetime)
+
+	// the system clock has moved on, but the entry expires by the cache's clock
+	time.Sleep(time.Millisecond * 50)
+	if v := cache.Get(key); v == nil {
+		t.Errorf("Expected cache key '%s' to have a value, but returned nil", key)
+	}
+
+	// move past the default lifetime and give the sweep a chance to run
+	clock.Add(time.Minute * 2)
+	time.Sleep(time.Millisecond * 50)
+	if v := cache.Get(key); v != nil {
+		t.Errorf("Did not expect cache key '%s' to be still set after expiry, but has value '%s'", key, v)
+	}
+}
+
+func TestClear(t *testing.T) {
+	cache := NewCache()
+	cache.Store("a", 1, time.Minute)
+	cache.StorePerpetual("b", func() interface{} { return 2 }, time.Minute)
+
+	cache.Clear()
+
+	if v := cache.Get("a"); v != nil {
+		t.Errorf("Expected cache key 'a' to be cleared, but has value '%v'", v)
+	}
+	if v := cache.Get("b"); v != nil {
+		t.Errorf("Expected perpetual cache key 'b' to be cleared, but has value '%v'", v)
+	}
+}
+
+func TestReset(t *testing.T) {
+	cache := NewCache(WithSweepInterval(time.Millisecond * 10))
+	var mu sync.Mutex
+	n := 0
+
+	cache.Store("a", 1, time.Minute)
+	cache.StorePerpetual("b", func() interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		n++
+		return n
+	}, time.Minute)
+
+	cache.Reset()
+
+	if v := cache.Get("a"); v != nil {
+		t.Errorf("Expected cache key 'a' to be cleared, but has value '%v'", v)
+	}
+	time.Sleep(time.Millisecond * 50)
+	if v := cache.Get("b"); v == nil || v.(int) != 2 {
+		t.Errorf("Expected perpetual cache key 'b' to be regenerated, but got '%v'", v)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	cache := NewCache()
+	cache.Store("a", 1, time.Minute)
+	cache.Store("b", 2, time.Minute)
+
+	if !cache.Delete("a") {
+		t.Errorf("Expected Delete to report that cache key 'a' existed")
+	}
+	if cache.Delete("a") {
+		t.Errorf("Did not expect Delete to report that deleted cache key 'a' existed")
+	}
+
+	if v, ok := cache.DeleteAndGet("b"); !ok || v != 2 {
+		t.Errorf("Expected DeleteAndGet to return value 2 for cache key 'b', but got '%v' (%v)", v, ok)
+	}
+	if v := cache.Get("b"); v != nil {
+		t.Errorf("Expected cache key 'b' to be deleted, but has value '%v'", v)
+	}
+}
+
+func TestStoreNegative(t *testing.T) {
+	cache := NewCache()
+	defer cache.Free()
+
+	cache.Store("Nil", nil, time.Minute)
+	cache.StoreNegative("Missing", time.Minute)
+
+	if v, found, negative := cache.Lookup("Nil"); v != nil || !found || negative {
+		t.Errorf("Expected a stored nil to be found and not negative, but got '%v', %v, %v", v, found, negative)
+	}
+	if v, found, negative := cache.Lookup("Missing"); v != nil || !found || !negative {
+		t.Errorf("Expected a negative entry to be found and negative, but got '%v', %v, %v", v, found, negative)
+	}
+	if v, found, negative := cache.Lookup("Other"); v != nil || found || negative {
+		t.Errorf("Expected a miss to be neither found nor negative, but got '%v', %v, %v", v, found, negative)
+	}
+	if v := cache.Get("Missing"); v != nil {
+		t.Errorf("Expected Get of a negative entry to return nil, but got '%v'", v)
+	}
+}
+
+func TestGetOK(t *testing.T) {
+	cache := NewCache()
+	defer cache.Free()
+
+	cache.Store("Nil", nil, time.Minute)
+	if v, ok := cache.GetOK("Nil"); v != nil || !ok {
+		t.Errorf("Expected a stored nil to be found, but got '%v', %v", v, ok)
+	}
+	if v, ok := cache.GetOK("Missing"); v != nil || ok {
+		t.Errorf("Did not expect a missing key to be found, but got '%v', %v", v, ok)
+	}
+}
+
+func TestTTLJitter(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cache := NewCache(WithClock(clock), WithTTLJitter(0.1))
+	defer cache.Free()
+
+	ttls := make(map[time.Duration]bool)
+	for i := 0; i < 20; i++ {
+		cache.Store(i, i, time.Minute)
+		ttl, _ := cache.TTL(i)
+		if ttl < 54*time.Second || ttl > 66*time.Second {
+			t.Errorf("Expected a jittered TTL within 10%% of a minute, but got %v", ttl)
+		}
+		ttls[ttl] = true
+	}
+	if len(ttls) < 2 {
+		t.Errorf("Expected the TTLs of entries stored at the same time to differ, but they were all %v", ttls)
+	}
+
+	// a lifetime jittered by the full fraction never becomes NoExpiry
+	full := NewCache(WithTTLJitter(2))
+	defer full.Free()
+	for i := 0; i < 1000; i++ {
+		if d := full.jittered(time.Second); d <= 0 || d > 2*time.Second {
+			t.Fatalf("Expected a lifetime jittered by a fraction of 1 to be positive and at most 2s, but got %v", d)
+		}
+	}
+}
+
+func TestClose(t *testing.T) {
+	cache := NewCache()
+	cache.Store("Key", "Value", time.Minute)
+	if err := cache.Close(); err != nil {
+		t.Errorf("Expected Close to succeed, but got %v", err)
+	}
+	if err := cache.Close(); err != ErrClosed {
+		t.Errorf("Expected closing again to return ErrClosed, but got %v", err)
+	}
+	cache.Free()
+
+	if err := cache.StorePerpetualE("Key", func() (interface{}, error) { return 1, nil }, time.Minute); err != ErrClosed {
+		t.Errorf("Expected StorePerpetualE to return ErrClosed after Close, but got %v", err)
+	}
+	if _, err := cache.Increment("Counter", 1, time.Minute); err != ErrClosed {
+		t.Errorf("Expected Increment to return ErrClosed after Close, but got %v", err)
+	}
+	defer func() {
+		if r := recover(); r != ErrClosed {
+			t.Errorf("Expected Store to panic with ErrClosed after Close, but got %v", r)
+		}
+	}()
+	cache.Store("Key", "Value", time.Minute)
+}
+
+func TestFinalizer(t *testing.T) {
+	before := runtime.NumGoroutine()
+	func() {
+		cache := NewCache(WithSweepInterval(time.Millisecond), WithRefreshWorkers(4))
+		cache.Store("Key", "Value", time.Minute)
+	}()
+	for i := 0; i < 100 && runtime.NumGoroutine() > before; i++ {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("Expected the goroutines of a cache that was not freed to stop once it was collected, but there are %d more", n-before)
+	}
+}
+
+func TestConcurrentReads(t *testing.T) {
+	cache := NewCache(WithShards(2))
+	defer cache.Free()
+	cache.SetMaxBytes(1 << 20)
+	for i := 0; i < 100; i++ {
+		cache.Store(i, i, time.Minute)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := (g*7 + i) % 100
+				if v := cache.Get(key); v != key {
+					t.Errorf("Expected cache key %d to have value %d, but got '%v'", key, key, v)
+					return
+				}
+				if i%100 == 0 {
+					cache.Store(key, key, time.Minute)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	if st := cache.Stats(); st.Hits != 8000 {
+		t.Errorf("Expected 8000 hits, but got %d", st.Hits)
+	}
+}
+
+func TestSweep(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cache := NewCache(WithClock(clock), WithSweepInterval(time.Hour))
+	defer cache.Free()
+
+	cache.Store("Key", "value", time.Minute)
+	cache.StorePerpetual("Perpetual", counter(0), time.Minute)
+	clock.Add(time.Minute)
+	cache.Sweep()
+	if n := cache.Len(); n != 1 {
+		t.Errorf("Expected Sweep to expire the entry before the next sweep, but there are %d entries", n)
+	}
+	if v := cache.Get("Perpetual"); v != 2 {
+		t.Errorf("Expected Sweep to regenerate the perpetual entry, but got '%v'", v)
+	}
+}
@@ -0,0 +1,174 @@
+// Package cachetest helps applications unit test how they use a cache.Cache, deterministically and
+// without sleeping. A Harness is a cache whose clock only moves when the test advances it, along
+// with assertions about what the cache holds: that a key is a hit or a miss, that a miss is loaded
+// by the code under test, and that values are no staler than they should be. A Counter counts the
+// calls of generators and load functions, to check that values are served from the cache rather
+// than generated again.
+package cachetest
+
+import (
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mrmorphic/cache"
+)
+
+// Harness is a cache.Cache using a FakeClock, created with New. Its assertions report failures to
+// the test it was created for with Errorf, so a test carries on after one fails, as it would with
+// its own checks.
+type Harness struct {
+	*cache.Cache
+
+	// Clock is the cache's clock, which only moves when Advance, or the clock's own Add or Set, is
+	// called.
+	Clock *cache.FakeClock
+
+	t testing.TB
+}
+
+// New returns a Harness for a test, with a cache created with the options and a FakeClock, which is
+// closed when the test ends. Options given to set the clock are overridden.
+func New(t testing.TB, opts ...cache.Option) *Harness {
+	clock := cache.NewFakeClock(time.Now())
+	c := cache.NewCache(append(opts, cache.WithClock(clock))...)
+	t.Cleanup(c.Free)
+	return &Harness{Cache: c, Clock: clock, t: t}
+}
+
+// Advance moves the clock forward by d, and then sweeps the cache, so that when it returns the
+// entries that have reached the end of their lifetime have been removed, and the perpetual entries
+// due to be regenerated have been, unless they are regenerated in the background.
+func (h *Harness) Advance(d time.Duration) {
+	h.Clock.Add(d)
+	h.Sweep()
+}
+
+// AssertHit checks that there is a value for the key, equal to want as compared by
+// reflect.DeepEqual. It returns true if there is.
+func (h *Harness) AssertHit(key interface{}, want interface{}) bool {
+	h.t.Helper()
+	value, ok := h.GetOK(key)
+	switch {
+	case !ok:
+		h.t.Errorf("Expected cache key '%v' to be a hit with '%v', but it missed", key, want)
+		return false
+	case !reflect.DeepEqual(value, want):
+		h.t.Errorf("Expected cache key '%v' to be a hit with '%v', but got '%v'", key, want, value)
+		return false
+	}
+	return true
+}
+
+// AssertMiss checks that there is no value for the key. It returns true if there isn't.
+func (h *Harness) AssertMiss(key interface{}) bool {
+	h.t.Helper()
+	if value, ok := h.GetOK(key); ok {
+		h.t.Errorf("Expected cache key '%v' to miss, but got '%v'", key, value)
+		return false
+	}
+	return true
+}
+
+// AssertMissThenLoad checks that there is no value for the key, calls load, which should be the code
+// under test that fills the cache on a miss, and then checks that the key is a hit with want. It
+// returns true if both checks pass; load is not called if the key was already a hit.
+func (h *Harness) AssertMissThenLoad(key interface{}, load func(), want interface{}) bool {
+	h.t.Helper()
+	if !h.AssertMiss(key) {
+		return false
+	}
+	load()
+	return h.AssertHit(key, want)
+}
+
+// AssertMaxStaleness checks that the value for the key was stored, or for a perpetual entry last
+// generated, no more than max ago by the cache's clock, and that it is not stale: it has not passed
+// the soft lifetime given by cache.WithSoftTTL or been invalidated by InvalidateAndRefresh. It
+// returns true if so.
+func (h *Harness) AssertMaxStaleness(key interface{}, max time.Duration) bool {
+	h.t.Helper()
+	info, ok := h.Inspect(key)
+	if !ok {
+		h.t.Errorf("Expected cache key '%v' to be at most %v old, but it missed", key, max)
+		return false
+	}
+	return h.checkStaleness(key, info, max)
+}
+
+// AssertCacheMaxStaleness is AssertMaxStaleness for every entry in the cache, reporting each entry
+// that is too stale. It returns true if none are.
+func (h *Harness) AssertCacheMaxStaleness(max time.Duration) bool {
+	h.t.Helper()
+	fresh := true
+	for _, key := range h.Keys() {
+		if info, ok := h.Inspect(key); ok && !h.checkStaleness(key, info, max) {
+			fresh = false
+		}
+	}
+	return fresh
+}
+
+// checkStaleness reports an entry that is stale or older than max.
+func (h *Harness) checkStaleness(key interface{}, info cache.EntryInfo, max time.Duration) bool {
+	h.t.Helper()
+	if info.Stale {
+		h.t.Errorf("Expected cache key '%v' to be fresh, but it is stale", key)
+		return false
+	}
+	stored := info.Created
+	if !info.Refreshed.IsZero() {
+		stored = info.Refreshed
+	}
+	if age := h.Clock.Now().Sub(stored); age > max {
+		h.t.Errorf("Expected cache key '%v' to be at most %v old, but it is %v old", key, max, age)
+		return false
+	}
+	return true
+}
+
+// Counter counts the calls of the generators and load functions it wraps. The zero value is ready
+// to use, and is safe for concurrent use.
+type Counter struct {
+	n atomic.Int64
+}
+
+// Generator returns a generator that counts its calls and then calls fn.
+func (ct *Counter) Generator(fn cache.ValueGenerator) cache.ValueGenerator {
+	return func() interface{} {
+		ct.n.Add(1)
+		return fn()
+	}
+}
+
+// GeneratorE returns a generator that counts its calls and then calls fn.
+func (ct *Counter) GeneratorE(fn cache.ValueGeneratorE) cache.ValueGeneratorE {
+	return func() (interface{}, error) {
+		ct.n.Add(1)
+		return fn()
+	}
+}
+
+// Loader returns a load function, for a cache.LoaderCache, that counts its calls and then calls fn.
+func (ct *Counter) Loader(fn cache.LoadFunc) cache.LoadFunc {
+	return func(key interface{}) (interface{}, error) {
+		ct.n.Add(1)
+		return fn(key)
+	}
+}
+
+// Calls returns the number of calls counted so far.
+func (ct *Counter) Calls() int {
+	return int(ct.n.Load())
+}
+
+// AssertCalls checks that want calls have been counted. It returns true if they have.
+func (ct *Counter) AssertCalls(t testing.TB, want int) bool {
+	t.Helper()
+	if n := ct.Calls(); n != want {
+		t.Errorf("Expected %d calls, but there were %d", want, n)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,94 @@
+package cachetest
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mrmorphic/cache"
+)
+
+// recorder is a testing.TB that records the failures reported to it, rather than failing the test.
+type recorder struct {
+	testing.TB
+	failures []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func TestHarness(t *testing.T) {
+	h := New(t)
+	var loads Counter
+	load := loads.Generator(func() interface{} { return "value" })
+	get := func() interface{} {
+		if v, ok := h.GetOK("Key"); ok {
+			return v
+		}
+		v := load()
+		h.Store("Key", v, time.Minute)
+		return v
+	}
+
+	h.AssertMissThenLoad("Key", func() { get() }, "value")
+	get()
+	loads.AssertCalls(t, 1)
+
+	h.Advance(30 * time.Second)
+	h.AssertHit("Key", "value")
+	h.AssertMaxStaleness("Key", 30*time.Second)
+
+	h.Advance(30 * time.Second)
+	h.AssertMiss("Key")
+	get()
+	loads.AssertCalls(t, 2)
+}
+
+func TestHarnessPerpetual(t *testing.T) {
+	h := New(t, cache.WithSweepInterval(time.Hour))
+	var calls Counter
+	n := 0
+	h.StorePerpetual("Key", calls.Generator(func() interface{} {
+		n++
+		return n
+	}), time.Minute)
+
+	// the sweep is run by Advance, however long the sweep interval
+	h.Advance(time.Minute)
+	h.AssertHit("Key", 2)
+	calls.AssertCalls(t, 2)
+	h.AssertCacheMaxStaleness(0)
+}
+
+func TestHarnessFailures(t *testing.T) {
+	r := &recorder{TB: t}
+	h := New(r)
+	h.Store("Key", "value", time.Hour)
+	h.Store("Other", "other", time.Hour)
+
+	if h.AssertHit("Key", "other") || h.AssertHit("Missing", "value") {
+		t.Errorf("Expected AssertHit to fail for a different value and a miss")
+	}
+	if h.AssertMiss("Key") {
+		t.Errorf("Expected AssertMiss to fail for a hit")
+	}
+	loaded := false
+	if h.AssertMissThenLoad("Key", func() { loaded = true }, "value") || loaded {
+		t.Errorf("Expected AssertMissThenLoad to fail without loading for a hit")
+	}
+
+	h.Advance(time.Minute)
+	if h.AssertMaxStaleness("Key", 30*time.Second) || h.AssertCacheMaxStaleness(30*time.Second) {
+		t.Errorf("Expected the staleness assertions to fail for an entry a minute old")
+	}
+	var calls Counter
+	if calls.AssertCalls(r, 1) {
+		t.Errorf("Expected AssertCalls to fail with no calls")
+	}
+	if len(r.failures) != 8 {
+		t.Errorf("Expected 8 failures to be reported, but got %d: %v", len(r.failures), r.failures)
+	}
+}
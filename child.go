@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// Child is a lightweight overlay on a Cache for the duration of a request. Reads fall through to
+// the parent cache for keys the child doesn't have, while values stored in the child are kept in
+// the child alone, so a request can memoize what it computes without adding it to the shared cache.
+// Deleting a key in the child hides the parent's value from the child without deleting it from the
+// parent. A child is created with Cache.NewChild, is safe for concurrent use by the goroutines
+// serving a request, and is simply dropped, or cleared with Discard, when the request ends.
+type Child struct {
+	parent *Cache
+
+	mu      sync.Mutex
+	entries map[interface{}]childEntry
+}
+
+// childEntry is a value stored in a Child, or a key deleted in it.
+type childEntry struct {
+	value   interface{}
+	expiry  time.Time
+	deleted bool
+}
+
+// NewChild returns an empty Child of the cache.
+func (c *Cache) NewChild() *Child {
+	return &Child{parent: c}
+}
+
+// Parent returns the cache the child reads through to.
+func (ch *Child) Parent() *Cache {
+	return ch.parent
+}
+
+// Store stores a value in the child, with the specified lifetime, which is measured by the parent's
+// clock. A lifetime of DefaultLifetime, or any other negative duration, uses the parent's default
+// lifetime, and NoExpiry keeps the value until the child is discarded. The parent is not changed.
+func (ch *Child) Store(key interface{}, value interface{}, lifetime time.Duration) {
+	if lifetime < 0 {
+		lifetime = ch.parent.defaultTTL
+	}
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	ch.set(key, childEntry{value: value, expiry: expiryAt(ch.parent.clock.Now(), lifetime)})
+}
+
+// set stores an entry in the child. The caller must hold the lock.
+func (ch *Child) set(key interface{}, entry childEntry) {
+	if ch.entries == nil {
+		ch.entries = make(map[interface{}]childEntry)
+	}
+	ch.entries[key] = entry
+}
+
+// Get returns the value stored in the child for a key, or if there is none, the parent's value.
+// It returns nil if neither has a value, or if the key has been deleted in the child.
+func (ch *Child) Get(key interface{}) interface{} {
+	value, _ := ch.GetOK(key)
+	return value
+}
+
+// GetOK is like Get, but also returns whether a value was found, so that a stored nil value can be
+// told apart from a miss.
+func (ch *Child) GetOK(key interface{}) (interface{}, bool) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	return ch.get(key)
+}
+
+// get is GetOK with the lock held.
+func (ch *Child) get(key interface{}) (interface{}, bool) {
+	entry, ok := ch.entries[key]
+	if ok && !entry.expiry.IsZero() && !ch.parent.clock.Now().Before(entry.expiry) {
+		delete(ch.entries, key)
+		ok = false
+	}
+	if !ok {
+		return ch.parent.GetOK(key)
+	}
+	if entry.deleted {
+		return nil, false
+	}
+	return entry.value, true
+}
+
+// Delete removes the child's value for a key, and hides the parent's value from the child until a
+// new value is stored in the child. It returns true if the child saw a value for the key. The
+// parent is not changed.
+func (ch *Child) Delete(key interface{}) bool {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	_, found := ch.get(key)
+	ch.set(key, childEntry{deleted: true})
+	return found
+}
+
+// Discard drops the values stored in the child and the keys deleted in it, so that it reads through
+// to the parent for every key again.
+func (ch *Child) Discard() {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	ch.entries = nil
+}
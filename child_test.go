@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChild(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	parent := NewCache(WithClock(clock), WithDefaultTTL(time.Hour))
+	defer parent.Free()
+	parent.Store("Shared", "parent", time.Minute)
+	parent.Store("Hidden", "parent", time.Minute)
+
+	child := parent.NewChild()
+	child.Store("Memo", "child", time.Minute)
+	child.Store("Shared", "child", time.Minute)
+	child.Store("Short", "child", time.Second)
+	child.Store("Default", "child", DefaultLifetime)
+	child.Store("Negative", "child", -5*time.Second)
+
+	if v := child.Get("Memo"); v != "child" {
+		t.Errorf("Expected cache key 'Memo' to have the child's value, but got '%v'", v)
+	}
+	if v := parent.Get("Memo"); v != nil {
+		t.Errorf("Did not expect the child's value to be stored in the parent, but got '%v'", v)
+	}
+	if v := child.Get("Shared"); v != "child" {
+		t.Errorf("Expected the child's value to override the parent's, but got '%v'", v)
+	}
+	if v := parent.Get("Shared"); v != "parent" {
+		t.Errorf("Expected the parent to keep its value, but got '%v'", v)
+	}
+	if v := child.Get("Hidden"); v != "parent" {
+		t.Errorf("Expected the child to read through to the parent, but got '%v'", v)
+	}
+
+	if !child.Delete("Hidden") {
+		t.Errorf("Expected Delete to report the parent's value as deleted")
+	}
+	if _, ok := child.GetOK("Hidden"); ok {
+		t.Errorf("Did not expect a key deleted in the child to be found")
+	}
+	if v := parent.Get("Hidden"); v != "parent" {
+		t.Errorf("Expected deleting in the child to leave the parent's value, but got '%v'", v)
+	}
+
+	clock.Add(2 * time.Second)
+	if v := child.Get("Short"); v != nil {
+		t.Errorf("Expected the child's value to expire, but got '%v'", v)
+	}
+	if v := child.Get("Default"); v != "child" {
+		t.Errorf("Expected a value stored with DefaultLifetime to last the parent's default, but got '%v'", v)
+	}
+	if v := child.Get("Negative"); v != "child" {
+		t.Errorf("Expected a value stored with a negative lifetime to last the parent's default, but got '%v'", v)
+	}
+
+	child.Discard()
+	if v := child.Get("Shared"); v != "parent" {
+		t.Errorf("Expected a discarded child to read through to the parent, but got '%v'", v)
+	}
+	if v := child.Get("Hidden"); v != "parent" {
+		t.Errorf("Expected a discarded child to forget its deletions, but got '%v'", v)
+	}
+}
@@ -0,0 +1,138 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the source of the current time used for expiry, and of the tickers that drive the sweep
+// and periodic snapshots. The default is the system clock; an alternative can be given with
+// WithClock, which is mostly useful in tests. FakeClock is a Clock that only moves when told to.
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker delivers ticks at intervals, like a time.Ticker.
+type Ticker interface {
+	// C returns the channel the ticks are delivered on.
+	C() <-chan time.Time
+
+	// Stop turns off the ticker. No more ticks are delivered after it returns.
+	Stop()
+}
+
+// Clock returns the clock the cache measures lifetimes with, for code that converts absolute times
+// to the lifetimes it stores entries with.
+func (c *Cache) Clock() Clock {
+	return c.clock
+}
+
+// systemClock is a Clock that uses the system time.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+// systemTicker is a Ticker that wraps a time.Ticker.
+type systemTicker struct {
+	*time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+// FakeClock is a Clock for tests, whose time only changes when Add or Set is called. Its tickers
+// tick when the time is moved past their next tick, so tests can make entries expire and the sweep
+// run without sleeping. As with a time.Ticker, ticks are dropped if the last one has not been
+// received yet, so moving the time forward by several intervals at once delivers a single tick.
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+// NewFakeClock returns a FakeClock set to the given time.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the clock's current time.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTicker returns a Ticker that ticks every d as the clock's time is moved. It panics if d is
+// not positive, like time.NewTicker.
+func (c *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("cache: non-positive interval for FakeClock.NewTicker")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTicker{clock: c, c: make(chan time.Time, 1), interval: d, next: c.now.Add(d)}
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+// Add moves the clock's time forward by d, and delivers the ticks that are then due.
+func (c *FakeClock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(c.now.Add(d))
+}
+
+// Set sets the clock's time, and delivers the ticks that are then due. Setting the time back does
+// not deliver any ticks until it passes the tickers' next ticks again.
+func (c *FakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(now)
+}
+
+// set sets the time with the lock held.
+func (c *FakeClock) set(now time.Time) {
+	c.now = now
+	for _, t := range c.tickers {
+		if now.Before(t.next) {
+			continue
+		}
+		select {
+		case t.c <- now:
+		default:
+		}
+		t.next = t.next.Add((now.Sub(t.next)/t.interval + 1) * t.interval)
+	}
+}
+
+// fakeTicker is a Ticker of a FakeClock.
+type fakeTicker struct {
+	clock    *FakeClock
+	c        chan time.Time
+	interval time.Duration
+	next     time.Time
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTicker) Stop() {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, other := range c.tickers {
+		if other == t {
+			c.tickers = append(c.tickers[:i], c.tickers[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClockTicker(t *testing.T) {
+	start := time.Now()
+	clock := NewFakeClock(start)
+	ticker := clock.NewTicker(time.Second)
+
+	clock.Add(time.Millisecond * 500)
+	select {
+	case <-ticker.C():
+		t.Errorf("Did not expect a tick before the interval has passed")
+	default:
+	}
+
+	// several intervals at once deliver a single tick
+	clock.Add(time.Second * 3)
+	select {
+	case tick := <-ticker.C():
+		if !tick.Equal(start.Add(time.Millisecond * 3500)) {
+			t.Errorf("Expected the tick to carry the clock's time, but got %v", tick)
+		}
+	default:
+		t.Errorf("Expected a tick after the interval has passed")
+	}
+	select {
+	case <-ticker.C():
+		t.Errorf("Expected the ticks due while the last was unread to be dropped")
+	default:
+	}
+
+	// the next tick is 4s after the start
+	clock.Add(time.Millisecond * 400)
+	select {
+	case <-ticker.C():
+		t.Errorf("Did not expect a tick before the next interval")
+	default:
+	}
+	ticker.Stop()
+	clock.Add(time.Second)
+	select {
+	case <-ticker.C():
+		t.Errorf("Did not expect a tick after the ticker was stopped")
+	default:
+	}
+}
+
+func TestFakeClockSweep(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	var expired []interface{}
+	done := make(chan struct{}, 1)
+	cache := NewCache(WithClock(clock))
+	defer cache.Free()
+	cache.OnEvict(func(key, value interface{}, reason EvictionReason) {
+		expired = append(expired, key)
+		done <- struct{}{}
+	})
+
+	cache.Store("Key", "Value", time.Minute)
+	clock.Add(time.Minute)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the sweep to run when the clock moved past the sweep interval")
+	}
+	if len(expired) != 1 || expired[0] != "Key" || cache.Len() != 0 {
+		t.Errorf("Expected the sweep to expire 'Key', but expired %v, leaving %d entries", expired, cache.Len())
+	}
+}
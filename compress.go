@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+)
+
+// Compressor compresses and decompresses the values of a cache created with WithCompression.
+type Compressor interface {
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+// GzipCompressor is a Compressor that uses compress/gzip at the given level. A Level of 0 uses
+// gzip.DefaultCompression.
+type GzipCompressor struct {
+	Level int
+}
+
+// Compress returns data compressed with gzip.
+func (g GzipCompressor) Compress(data []byte) ([]byte, error) {
+	level := g.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decompress returns the data that Compress compressed into data.
+func (GzipCompressor) Decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// WithCompression has the cache compress values that are byte slices or strings of at least
+// threshold bytes when they are stored, and decompress them when they are retrieved, so that a few
+// large values, such as rendered pages, don't dominate its memory use. Values are compressed with
+// compressor, or with gzip if it is nil; another algorithm such as snappy can be used by
+// implementing Compressor. Compressed values count towards the limit set by SetMaxBytes with their
+// compressed size, unless they were stored with WithSize or the cache has a CostFunc, which is
+// given the uncompressed value.
+//
+// Each retrieval decompresses the value into a new byte slice or string, so it suits values that
+// are large and retrieved less often than they would be worth keeping uncompressed. A value that
+// can't be decompressed is returned as nil, and counted in the DecodeErrors of Stats. Compression
+// has no effect on a cache given a Store with WithStore; the Store can compress values itself.
+// Values encoded by a codec given to WithValueCodec are compressed if their encoding is at least
+// threshold bytes.
+func WithCompression(threshold int, compressor Compressor) Option {
+	return func(c *Cache) {
+		if compressor == nil {
+			compressor = GzipCompressor{}
+		}
+		c.compression = &compression{threshold: threshold, compressor: compressor}
+	}
+}
+
+// compression is how the values of a cache created with WithCompression are compressed.
+type compression struct {
+	threshold  int
+	compressor Compressor
+}
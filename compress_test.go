@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompression(t *testing.T) {
+	cache := NewCache(WithShards(1), WithCompression(1024, nil), WithSyncMap())
+	defer cache.Free()
+
+	page := strings.Repeat("<p>Hello, world</p>", 1000)
+	cache.Store("page", page, time.Minute)
+	cache.Store("bytes", []byte(page), time.Minute)
+	cache.Store("small", "<p>Hi</p>", time.Minute)
+
+	if v := cache.Get("page"); v != page {
+		t.Errorf("Expected cache key 'page' to be decompressed to the stored string, but got %d bytes", len(v.(string)))
+	}
+	if v, ok := cache.Get("bytes").([]byte); !ok || !bytes.Equal(v, []byte(page)) {
+		t.Errorf("Expected cache key 'bytes' to be decompressed to the stored byte slice, but got %d bytes", len(v))
+	}
+	if v := cache.Get("small"); v != "<p>Hi</p>" {
+		t.Errorf("Expected cache key 'small' to have value '<p>Hi</p>', but got '%v'", v)
+	}
+
+	// the compressed values take much less than their uncompressed size
+	if st := cache.Stats(); st.Bytes >= int64(len(page)) {
+		t.Errorf("Expected the compressed values to take less than %d bytes, but got %d", len(page), st.Bytes)
+	}
+
+	if v, ok := cache.DeleteAndGet("page"); !ok || v != page {
+		t.Errorf("Expected DeleteAndGet to return the decompressed value, but got %d bytes", len(v.(string)))
+	}
+}
+
+// brokenCompressor is a Compressor whose compressed data can't be decompressed.
+type brokenCompressor struct{}
+
+func (brokenCompressor) Compress(data []byte) ([]byte, error) {
+	return data, nil
+}
+
+func (brokenCompressor) Decompress(data []byte) ([]byte, error) {
+	return nil, errors.New("corrupt data")
+}
+
+func TestCompressionDecodeErrors(t *testing.T) {
+	cache := NewCache(WithCompression(1, brokenCompressor{}))
+	defer cache.Free()
+	cache.Store("page", "<p>Hello, world</p>", time.Minute)
+
+	if v := cache.Get("page"); v != nil {
+		t.Errorf("Expected a value that can't be decompressed to be nil, but got '%v'", v)
+	}
+	if n := cache.Stats().DecodeErrors; n != 1 {
+		t.Errorf("Expected 1 decode error, but got %d", n)
+	}
+}
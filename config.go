@@ -0,0 +1,78 @@
+package cache
+
+import "time"
+
+// Config configures a cache from a deployment's configuration rather than in code. It has JSON and
+// YAML field tags, so it can be decoded from a configuration file, and is given to
+// NewCacheFromConfig. Fields left at their zero value keep the cache's defaults.
+type Config struct {
+	// MaxBytes is the limit on the estimated size of the cache, as set by SetMaxBytes.
+	MaxBytes int64 `json:"max_bytes,omitempty" yaml:"max_bytes,omitempty"`
+
+	// DefaultTTL is the lifetime of entries stored with DefaultLifetime, as set by WithDefaultTTL.
+	DefaultTTL Duration `json:"default_ttl,omitempty" yaml:"default_ttl,omitempty"`
+
+	// SweepInterval is how often expired entries are removed, as set by WithSweepInterval.
+	SweepInterval Duration `json:"sweep_interval,omitempty" yaml:"sweep_interval,omitempty"`
+
+	// Shards is the number of shards, as set by WithShards.
+	Shards int `json:"shards,omitempty" yaml:"shards,omitempty"`
+
+	// Eviction is the eviction mode, by name, such as "lru" or "tinylfu", as set by WithEviction.
+	Eviction EvictionMode `json:"eviction,omitempty" yaml:"eviction,omitempty"`
+
+	// Jitter is the fraction by which lifetimes are randomised, as set by WithTTLJitter.
+	Jitter float64 `json:"jitter,omitempty" yaml:"jitter,omitempty"`
+}
+
+// Duration is a time.Duration that is written to and read from configuration files as a string
+// such as "5m" or "1h30m".
+type Duration time.Duration
+
+// MarshalText returns the duration as a string, such as "5m0s".
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// UnmarshalText parses a duration string, as accepted by time.ParseDuration.
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Options returns the options that configure a cache as cfg describes, apart from MaxBytes, which
+// is set after the cache is created. It is useful for combining a Config with other options, for
+// instance for a Manager.
+func (cfg Config) Options() []Option {
+	var opts []Option
+	if cfg.DefaultTTL > 0 {
+		opts = append(opts, WithDefaultTTL(time.Duration(cfg.DefaultTTL)))
+	}
+	if cfg.SweepInterval > 0 {
+		opts = append(opts, WithSweepInterval(time.Duration(cfg.SweepInterval)))
+	}
+	if cfg.Shards > 0 {
+		opts = append(opts, WithShards(cfg.Shards))
+	}
+	if cfg.Eviction != LRU {
+		opts = append(opts, WithEviction(cfg.Eviction))
+	}
+	if cfg.Jitter > 0 {
+		opts = append(opts, WithTTLJitter(cfg.Jitter))
+	}
+	return opts
+}
+
+// NewCacheFromConfig returns a new cache configured by cfg, and by any other options given, which
+// are applied after those from cfg.
+func NewCacheFromConfig(cfg Config, opts ...Option) *Cache {
+	c := NewCache(append(cfg.Options(), opts...)...)
+	if cfg.MaxBytes > 0 {
+		c.SetMaxBytes(cfg.MaxBytes)
+	}
+	return c
+}
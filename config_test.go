@@ -0,0 +1,37 @@
+package cache
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestNewCacheFromConfig(t *testing.T) {
+	var cfg Config
+	data := `{"max_bytes": 1048576, "default_ttl": "10m", "sweep_interval": "250ms", "shards": 4, "eviction": "TinyLFU", "jitter": 0.1}`
+	if err := json.Unmarshal([]byte(data), &cfg); err != nil {
+		t.Fatalf("Expected the config to decode, but got %v", err)
+	}
+	want := Config{MaxBytes: 1 << 20, DefaultTTL: Duration(10 * time.Minute), SweepInterval: Duration(250 * time.Millisecond), Shards: 4, Eviction: TinyLFU, Jitter: 0.1}
+	if cfg != want {
+		t.Errorf("Expected config %+v, but got %+v", want, cfg)
+	}
+
+	cache := NewCacheFromConfig(cfg)
+	defer cache.Free()
+	if len(cache.shards) != 4 || cache.defaultTTL != 10*time.Minute || cache.sweepInterval != 250*time.Millisecond || cache.eviction != TinyLFU || cache.jitter != 0.1 {
+		t.Errorf("Expected the cache to be configured from the config")
+	}
+	if n := cache.shards[0].maxBytes; n != 1<<18 {
+		t.Errorf("Expected the byte limit to be divided between the shards, but a shard has %d", n)
+	}
+
+	encoded, _ := json.Marshal(Config{DefaultTTL: Duration(time.Hour), Eviction: ARC})
+	if s := string(encoded); s != `{"default_ttl":"1h0m0s","eviction":"arc"}` {
+		t.Errorf("Expected durations and eviction modes to be encoded by name, but got %s", s)
+	}
+
+	if err := json.Unmarshal([]byte(`{"eviction": "mru"}`), &cfg); err == nil {
+		t.Errorf("Expected an unknown eviction mode to fail to decode")
+	}
+}
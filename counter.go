@@ -0,0 +1,46 @@
+package cache
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNotInteger is returned by Increment and Decrement when the existing value of the entry is not
+// an int or int64.
+var ErrNotInteger = errors.New("cache: value is not an integer")
+
+// Increment atomically adds delta to the integer value of an entry, and returns the new value. If
+// there is no entry for the key, or it has expired, one is created with a value of delta and the
+// given lifetime. The lifetime of an existing entry is not changed, so a counter created this way
+// counts over a fixed window. The value must be an int or an int64, and keeps its type.
+func (c *Cache) Increment(key interface{}, delta int64, lifetime time.Duration) (int64, error) {
+	if c.closed.Load() {
+		return 0, ErrClosed
+	}
+	now := c.clock.Now()
+	s := c.shardFor(key)
+	s.Lock()
+	defer c.unlock(s)
+
+	entry := s.unexpired(key, now)
+	if entry == nil {
+		s.add(key, c.newEntry(lifetime, nil), delta)
+		return delta, nil
+	}
+
+	switch v := s.value(entry).(type) {
+	case int64:
+		s.setValue(entry, v+delta, ChangeSet)
+		return v + delta, nil
+	case int:
+		s.setValue(entry, v+int(delta), ChangeSet)
+		return int64(v) + delta, nil
+	}
+	return 0, ErrNotInteger
+}
+
+// Decrement atomically subtracts delta from the integer value of an entry. It is the same as
+// Increment with the negated delta.
+func (c *Cache) Decrement(key interface{}, delta int64, lifetime time.Duration) (int64, error) {
+	return c.Increment(key, -delta, lifetime)
+}
@@ -0,0 +1,61 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIncrement(t *testing.T) {
+	cache := NewCache()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				cache.Increment("requests", 1, time.Minute)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if v := cache.Get("requests"); v != int64(1000) {
+		t.Errorf("Expected 1000 increments to total 1000, but got '%v'", v)
+	}
+	if n, err := cache.Decrement("requests", 10, time.Minute); err != nil || n != 990 {
+		t.Errorf("Expected decrement to give 990, but got %d (%v)", n, err)
+	}
+
+	cache.Store("int", 5, time.Minute)
+	if n, err := cache.Increment("int", 2, time.Minute); err != nil || n != 7 || cache.Get("int") != 7 {
+		t.Errorf("Expected int value to be incremented to 7, but got %d (%v)", n, err)
+	}
+
+	cache.Store("string", "five", time.Minute)
+	if _, err := cache.Increment("string", 1, time.Minute); err != ErrNotInteger {
+		t.Errorf("Expected ErrNotInteger incrementing a string, but got '%v'", err)
+	}
+}
+
+func TestIncrementExpired(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cache := NewCache(WithClock(clock), WithSweepInterval(time.Hour))
+	defer cache.Free()
+	var reasons []EvictionReason
+	cache.OnEvict(func(key, value interface{}, reason EvictionReason) {
+		reasons = append(reasons, reason)
+	})
+
+	cache.Increment("hits", 5, time.Minute)
+	clock.Add(2 * time.Minute)
+	if n, _ := cache.Increment("hits", 1, time.Minute); n != 1 {
+		t.Errorf("Expected an expired counter to start again at 1, but got %d", n)
+	}
+	if len(reasons) != 1 || reasons[0] != ReasonExpired {
+		t.Errorf("Expected the expired counter to be removed as expired, but got %v", reasons)
+	}
+	if st := cache.Stats(); st.Expirations != 1 {
+		t.Errorf("Expected 1 expiration, but got %d", st.Expirations)
+	}
+}
@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// WithDependencies makes an entry depend on the entries with the given keys. When any of those
+// entries is removed from the cache for any reason, or is a perpetual entry that is regenerated,
+// this entry is deleted too, which may in turn delete entries that depend on it. The keys don't
+// need to be in the cache when the entry is stored.
+func WithDependencies(keys ...interface{}) EntryOption {
+	return func(e *CacheEntry) {
+		e.dependsOn = append(e.dependsOn, keys...)
+	}
+}
+
+// StoreDependent stores a key/value pair like Store, making the entry depend on the entries with
+// the given keys. It is the same as calling Store with WithDependencies.
+func (c *Cache) StoreDependent(key interface{}, value interface{}, lifetime time.Duration, dependsOn ...interface{}) {
+	c.Store(key, value, lifetime, WithDependencies(dependsOn...))
+}
+
+// dependencies records, for each key, the entries that depend on it. It is shared by all the shards
+// of a cache, as an entry can depend on keys in other shards. Its mutex may be locked while a shard
+// is locked, but not the other way around.
+type dependencies struct {
+	sync.Mutex
+	dependents map[interface{}]map[*CacheEntry]struct{}
+}
+
+func newDependencies() *dependencies {
+	return &dependencies{dependents: make(map[interface{}]map[*CacheEntry]struct{})}
+}
+
+// add records the dependencies of an entry.
+func (d *dependencies) add(entry *CacheEntry) {
+	if len(entry.dependsOn) == 0 {
+		return
+	}
+	d.Lock()
+	for _, key := range entry.dependsOn {
+		entries := d.dependents[key]
+		if entries == nil {
+			entries = make(map[*CacheEntry]struct{})
+			d.dependents[key] = entries
+		}
+		entries[entry] = struct{}{}
+	}
+	d.Unlock()
+}
+
+// remove forgets the dependencies of an entry.
+func (d *dependencies) remove(entry *CacheEntry) {
+	if len(entry.dependsOn) == 0 {
+		return
+	}
+	d.Lock()
+	for _, key := range entry.dependsOn {
+		entries := d.dependents[key]
+		delete(entries, entry)
+		if len(entries) == 0 {
+			delete(d.dependents, key)
+		}
+	}
+	d.Unlock()
+}
+
+// take returns the entries that depend on a key, and forgets them.
+func (d *dependencies) take(key interface{}) []*CacheEntry {
+	d.Lock()
+	defer d.Unlock()
+	entries := d.dependents[key]
+	if len(entries) == 0 {
+		return nil
+	}
+	taken := make([]*CacheEntry, 0, len(entries))
+	for entry := range entries {
+		taken = append(taken, entry)
+	}
+	delete(d.dependents, key)
+	return taken
+}
+
+// invalidateDependents deletes the entries that depend on a key. It must be called without any
+// shard locked.
+func (c *Cache) invalidateDependents(key interface{}) {
+	for _, entry := range c.deps.take(key) {
+		s := c.shardFor(entry.key)
+		s.Lock()
+		if s.entries[entry.key] == entry {
+			s.remove(entry, ReasonDependency)
+		}
+		c.unlock(s)
+	}
+}
@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStoreDependent(t *testing.T) {
+	cache := NewShardedCache(4)
+
+	cache.Store("siteconfig", "config", time.Minute)
+	cache.StoreDependent("menu", "menu", time.Minute, "siteconfig")
+	cache.StoreDependent("footer", "footer", time.Minute, "menu")
+	cache.Store("other", "other", time.Minute)
+
+	cache.Delete("siteconfig")
+
+	for _, key := range []string{"menu", "footer"} {
+		if v := cache.Get(key); v != nil {
+			t.Errorf("Expected dependent cache key '%s' to be deleted, but has value '%v'", key, v)
+		}
+	}
+	if v := cache.Get("other"); v == nil {
+		t.Errorf("Expected cache key 'other' to be unaffected, but returned nil")
+	}
+}
+
+func TestDependentOnPerpetual(t *testing.T) {
+	cache := NewCache(WithSweepInterval(time.Millisecond * 10))
+
+	cache.StorePerpetual("sitetree", counter(0), time.Millisecond*20)
+	cache.StoreDependent("menu", "menu", time.Minute, "sitetree")
+
+	// regenerating the perpetual entry invalidates the entries derived from it
+	time.Sleep(time.Millisecond * 60)
+	if v := cache.Get("menu"); v != nil {
+		t.Errorf("Expected cache key 'menu' to be deleted when 'sitetree' was regenerated, but has value '%v'", v)
+	}
+}
+
+func TestDependentOnUpdate(t *testing.T) {
+	cache := NewCache()
+
+	cache.Store("siteconfig", "config", time.Minute)
+	cache.Store("hits", 0, time.Minute)
+	cache.StoreDependent("menu", "menu", time.Minute, "siteconfig")
+	cache.StoreDependent("counter", "counter", time.Minute, "hits")
+
+	// changing a value in place invalidates the entries derived from it, as storing it again does
+	cache.Update("siteconfig", func(old interface{}) interface{} { return "new config" }, time.Minute)
+	if v := cache.Get("menu"); v != nil {
+		t.Errorf("Expected cache key 'menu' to be deleted when 'siteconfig' was updated, but has value '%v'", v)
+	}
+	cache.Increment("hits", 1, time.Minute)
+	if v := cache.Get("counter"); v != nil {
+		t.Errorf("Expected cache key 'counter' to be deleted when 'hits' was incremented, but has value '%v'", v)
+	}
+}
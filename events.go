@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EventType is the kind of an Event.
+type EventType int
+
+const (
+	// EventSet means a value was stored, or changed, including by a perpetual entry being
+	// regenerated.
+	EventSet EventType = iota
+
+	// EventHit and EventMiss mean a retrieval found a value or didn't.
+	EventHit
+	EventMiss
+
+	// EventExpire means an entry was removed at the end of its lifetime.
+	EventExpire
+
+	// EventEvict means an entry was removed for a reason other than expiry, which is given by the
+	// event's Reason.
+	EventEvict
+
+	// EventRefresh means a perpetual entry was regenerated, and EventRefreshError that its
+	// generator failed, with the error given by the event's Err.
+	EventRefresh
+	EventRefreshError
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventSet:
+		return "set"
+	case EventHit:
+		return "hit"
+	case EventMiss:
+		return "miss"
+	case EventExpire:
+		return "expire"
+	case EventEvict:
+		return "evict"
+	case EventRefresh:
+		return "refresh"
+	case EventRefreshError:
+		return "refresh error"
+	}
+	return "unknown"
+}
+
+// Event is something that happened to an entry in a cache, as sent to the channel returned by
+// Cache.Events.
+type Event struct {
+	Type EventType
+	Key  interface{}
+	Time time.Time
+
+	// Reason is why the entry was removed, for EventExpire and EventEvict.
+	Reason EvictionReason
+
+	// Err is the error returned by the generator, for EventRefreshError.
+	Err error
+}
+
+// eventBuffer is the capacity of the channel returned by Events.
+const eventBuffer = 1024
+
+// WithEventSampling sends only a fraction of the cache's events to the channel returned by Events,
+// chosen at random, to reduce their cost in a busy cache. The fraction is clamped to between 0 and
+// 1. The default is 1, which sends every event.
+func WithEventSampling(fraction float64) Option {
+	return func(c *Cache) {
+		c.events.sample = min(max(fraction, 0), 1)
+	}
+}
+
+// events sends the events of a cache to the channel returned by Events, once it has been called.
+type events struct {
+	once   sync.Once
+	ch     atomic.Pointer[chan Event]
+	sample float64
+	clock  Clock
+}
+
+// Events returns a channel on which the cache sends an Event each time a value is stored,
+// retrieved or missed, an entry is removed, or a perpetual entry is regenerated. Events are only
+// sent once Events has been called, and every call returns the same channel. They are sent without
+// blocking, so if the channel's buffer is full because events are not received quickly enough,
+// they are dropped. The channel is never closed.
+func (c *Cache) Events() <-chan Event {
+	c.events.once.Do(func() {
+		ch := make(chan Event, eventBuffer)
+		c.events.ch.Store(&ch)
+	})
+	return *c.events.ch.Load()
+}
+
+// send sends an event, if Events has been called and the event is sampled.
+func (e *events) send(typ EventType, key interface{}, reason EvictionReason, err error) {
+	ch := e.ch.Load()
+	if ch == nil || (e.sample < 1 && rand.Float64() >= e.sample) {
+		return
+	}
+	select {
+	case *ch <- Event{Type: typ, Key: key, Time: e.clock.Now(), Reason: reason, Err: err}:
+	default:
+	}
+}
@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// receive returns the events sent so far.
+func receive(ch <-chan Event) []Event {
+	var events []Event
+	for {
+		select {
+		case e := <-ch:
+			events = append(events, e)
+		default:
+			return events
+		}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cache := NewCache(WithShards(1), WithClock(clock))
+	defer cache.Free()
+
+	cache.Store("before", "value", time.Minute)
+	ch := cache.Events()
+	if cache.Events() != ch {
+		t.Errorf("Expected Events to return the same channel each time")
+	}
+
+	cache.Store("Key", "value", time.Minute)
+	cache.Get("Key")
+	cache.Get("Missing")
+	cache.Delete("Key")
+	cache.Store("Short", "value", time.Second)
+	clock.Add(time.Second)
+	cache.Get("Short")
+
+	fail := errors.New("fail")
+	failing := false
+	cache.StorePerpetualE("Perpetual", func() (interface{}, error) {
+		if failing {
+			return nil, fail
+		}
+		return 1, nil
+	}, time.Hour)
+	cache.Refresh("Perpetual")
+	failing = true
+	cache.Refresh("Perpetual")
+
+	want := []Event{
+		{Type: EventSet, Key: "Key"},
+		{Type: EventHit, Key: "Key"},
+		{Type: EventMiss, Key: "Missing"},
+		{Type: EventEvict, Key: "Key", Reason: ReasonDeleted},
+		{Type: EventSet, Key: "Short"},
+		{Type: EventMiss, Key: "Short"},
+		{Type: EventExpire, Key: "Short", Reason: ReasonExpired},
+		{Type: EventSet, Key: "Perpetual"},
+		{Type: EventRefresh, Key: "Perpetual"},
+		{Type: EventSet, Key: "Perpetual"},
+		{Type: EventRefreshError, Key: "Perpetual", Err: fail},
+	}
+	got := receive(ch)
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d events, but got %d: %v", len(want), len(got), got)
+	}
+	for i, e := range got {
+		w := want[i]
+		if e.Type != w.Type || e.Key != w.Key || e.Reason != w.Reason || e.Err != w.Err {
+			t.Errorf("Expected event %d to be %v %v, but got %v %v", i, w.Type, w.Key, e.Type, e.Key)
+		}
+		if e.Time.IsZero() {
+			t.Errorf("Expected event %d to have a time", i)
+		}
+	}
+}
+
+func TestEventSampling(t *testing.T) {
+	cache := NewCache(WithEventSampling(0))
+	defer cache.Free()
+	ch := cache.Events()
+	cache.Store("Key", "value", time.Minute)
+	cache.Get("Key")
+	if got := receive(ch); len(got) != 0 {
+		t.Errorf("Did not expect any events to be sampled, but got %v", got)
+	}
+}
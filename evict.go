@@ -0,0 +1,123 @@
+package cache
+
+import "time"
+
+// EvictionReason describes why an entry was removed from the cache.
+type EvictionReason int
+
+const (
+	// ReasonExpired means the entry reached the end of its lifetime.
+	ReasonExpired EvictionReason = iota
+
+	// ReasonDeleted means the entry was removed with Delete.
+	ReasonDeleted
+
+	// ReasonReplaced means another value was stored under the same key.
+	ReasonReplaced
+
+	// ReasonCapacity means the entry was evicted to keep the cache within its limits.
+	ReasonCapacity
+
+	// ReasonFailed means the entry's generator failed and its FailurePolicy is Evict.
+	ReasonFailed
+
+	// ReasonDependency means an entry that the entry depends on was removed, regenerated or changed.
+	ReasonDependency
+
+	// ReasonPressure means the entry was shed to relieve memory pressure, by Shed or because the
+	// heap grew past the threshold given to WithMemoryPressure.
+	ReasonPressure
+)
+
+func (r EvictionReason) String() string {
+	switch r {
+	case ReasonExpired:
+		return "expired"
+	case ReasonDeleted:
+		return "deleted"
+	case ReasonReplaced:
+		return "replaced"
+	case ReasonCapacity:
+		return "capacity"
+	case ReasonFailed:
+		return "failed"
+	case ReasonDependency:
+		return "dependency"
+	case ReasonPressure:
+		return "pressure"
+	}
+	return "unknown"
+}
+
+// EvictFunc is called when an entry is removed from the cache, with the key and value of the entry
+// and the reason it was removed. It is called after the cache has released its locks, so it may use
+// the cache, but it is called synchronously and so should not block.
+type EvictFunc func(key, value interface{}, reason EvictionReason)
+
+// eviction records an entry that has been removed from a shard, so that hooks can be called once
+// the shard is unlocked.
+type eviction struct {
+	key     interface{}
+	value   interface{}
+	created time.Time
+	hook    EvictFunc
+	reason  EvictionReason
+}
+
+// OnEvict adds a function to be called whenever an entry is removed from the cache, for any
+// reason. Functions are called in the order they were added, after any function given to the
+// entry itself with WithOnEvict.
+func (c *Cache) OnEvict(fn EvictFunc) {
+	c.hookMu.Lock()
+	c.evictHooks = append(c.evictHooks, fn)
+	c.hookMu.Unlock()
+}
+
+// WithOnEvict gives a function to be called when this entry is removed from the cache, in addition
+// to any added to the cache with OnEvict.
+func WithOnEvict(fn EvictFunc) EntryOption {
+	return func(e *CacheEntry) {
+		e.onEvict = fn
+	}
+}
+
+// unlock unlocks a shard and then sends the changes made while it was locked to the channels
+// watching them, counts and calls the eviction hooks for any entries that were removed, and deletes
+// the entries that depend on those and on any entries whose values were changed. This should be
+// used in place of Unlock wherever entries may be removed or stored.
+func (c *Cache) unlock(s *shard) {
+	evicted, changed, watched := s.evicted, s.changed, s.watched
+	s.evicted, s.changed, s.watched = nil, nil, nil
+	if len(watched) > 0 {
+		// the watches are locked before the shard is unlocked, so that the changes to a key are
+		// sent in the order they were made
+		c.watches.mu.Lock()
+		s.Unlock()
+		for i := range watched {
+			watched[i].Value = c.clone(watched[i].Value)
+		}
+		c.watches.notify(watched)
+		c.watches.mu.Unlock()
+	} else {
+		s.Unlock()
+	}
+	if len(evicted) == 0 && len(changed) == 0 {
+		return
+	}
+	c.hookMu.Lock()
+	hooks := c.evictHooks
+	c.hookMu.Unlock()
+	for _, e := range evicted {
+		c.countEviction(e.key, e.created, e.reason)
+		if e.hook != nil {
+			e.hook(e.key, e.value, e.reason)
+		}
+		for _, hook := range hooks {
+			hook(e.key, e.value, e.reason)
+		}
+		c.invalidateDependents(e.key)
+	}
+	for _, key := range changed {
+		c.invalidateDependents(key)
+	}
+}
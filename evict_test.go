@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// evictions records the first reason an EvictFunc was called with for each key.
+type evictions struct {
+	sync.Mutex
+	reasons map[interface{}]EvictionReason
+}
+
+func (e *evictions) record(key, value interface{}, reason EvictionReason) {
+	e.Lock()
+	if _, ok := e.reasons[key]; !ok {
+		e.reasons[key] = reason
+	}
+	e.Unlock()
+}
+
+func (e *evictions) reason(key interface{}) (EvictionReason, bool) {
+	e.Lock()
+	defer e.Unlock()
+	r, ok := e.reasons[key]
+	return r, ok
+}
+
+func TestOnEvict(t *testing.T) {
+	cache := NewShardedCache(1)
+	ev := &evictions{reasons: make(map[interface{}]EvictionReason)}
+	cache.OnEvict(ev.record)
+
+	cache.Store("deleted", 1, time.Minute)
+	cache.Delete("deleted")
+
+	cache.Store("replaced", 1, time.Minute)
+	cache.Store("replaced", 2, time.Minute)
+
+	cache.Store("expired", 1, time.Millisecond)
+	time.Sleep(time.Millisecond * 10)
+	cache.Get("expired")
+
+	cache.SetMaxBytes(100)
+	cache.Store("evicted", make([]byte, 60), time.Minute)
+	cache.Store("kept", make([]byte, 60), time.Minute)
+
+	expected := map[string]EvictionReason{
+		"deleted":  ReasonDeleted,
+		"replaced": ReasonReplaced,
+		"expired":  ReasonExpired,
+		"evicted":  ReasonCapacity,
+	}
+	for key, want := range expected {
+		if got, ok := ev.reason(key); !ok || got != want {
+			t.Errorf("Expected cache key '%s' to be evicted with reason '%s', but got '%s' (called: %v)", key, want, got, ok)
+		}
+	}
+	if _, ok := ev.reason("kept"); ok {
+		t.Errorf("Did not expect cache key 'kept' to be evicted")
+	}
+}
+
+func TestEntryOnEvict(t *testing.T) {
+	cache := NewCache()
+	var got interface{}
+
+	cache.Store("key", "value", time.Minute, WithOnEvict(func(key, value interface{}, reason EvictionReason) {
+		// the cache is unlocked, so hooks can use it
+		cache.Get(key)
+		got = value
+	}))
+	cache.Delete("key")
+
+	if got != "value" {
+		t.Errorf("Expected the entry's hook to be called with value 'value', but got '%v'", got)
+	}
+}
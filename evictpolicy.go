@@ -0,0 +1,234 @@
+package cache
+
+import (
+	"container/list"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+)
+
+// EvictionMode selects how a cache chooses which entries to evict when it is over its byte limit.
+type EvictionMode int
+
+const (
+	// LRU evicts the least recently used entry. This is the default.
+	LRU EvictionMode = iota
+
+	// LFU evicts the least frequently used entry, and of those the least recently used.
+	LFU
+
+	// TinyLFU evicts using W-TinyLFU: new entries go into a small LRU window, and an entry leaving
+	// the window only displaces an entry in the main part of the cache if it has been used more
+	// often recently, as estimated by a frequency sketch. Keys that are used once don't push out
+	// entries that are used all the time, which suits skewed access patterns.
+	TinyLFU
+
+	// ARC evicts using the Adaptive Replacement Cache algorithm, which keeps entries used once and
+	// entries used more than once in separate lists, and remembers recently evicted keys to adapt
+	// the balance between the lists to how the cache is used.
+	ARC
+
+	// FIFO evicts the entry that was stored first, however it has been used since.
+	FIFO
+
+	// Random evicts an entry chosen at random.
+	Random
+
+	// SLRU evicts using segmented LRU: new entries are on probation until they are used again,
+	// when they move to a protected segment, and entries are evicted from probation first. A scan
+	// of many keys that are each used once only displaces other entries on probation, not the
+	// working set of entries that are used repeatedly.
+	SLRU
+)
+
+// evictionModeNames are the names of the eviction modes, as used by String and UnmarshalText.
+var evictionModeNames = map[EvictionMode]string{
+	LRU:     "lru",
+	LFU:     "lfu",
+	TinyLFU: "tinylfu",
+	ARC:     "arc",
+	FIFO:    "fifo",
+	Random:  "random",
+	SLRU:    "slru",
+}
+
+// String returns the name of the mode, such as "lru" or "tinylfu".
+func (m EvictionMode) String() string {
+	if name, ok := evictionModeNames[m]; ok {
+		return name
+	}
+	return fmt.Sprintf("EvictionMode(%d)", int(m))
+}
+
+// MarshalText returns the name of the mode, so that it is written to configuration files by name.
+func (m EvictionMode) MarshalText() ([]byte, error) {
+	if _, ok := evictionModeNames[m]; !ok {
+		return nil, fmt.Errorf("cache: unknown eviction mode %d", int(m))
+	}
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText sets the mode from its name, in any case.
+func (m *EvictionMode) UnmarshalText(text []byte) error {
+	for mode, name := range evictionModeNames {
+		if strings.EqualFold(string(text), name) {
+			*m = mode
+			return nil
+		}
+	}
+	return fmt.Errorf("cache: unknown eviction mode %q", text)
+}
+
+// WithEviction sets how the cache chooses which entries to evict when it is over the limit set by
+// SetMaxBytes. The default is LRU.
+func WithEviction(mode EvictionMode) Option {
+	return func(c *Cache) {
+		c.eviction = mode
+	}
+}
+
+// EvictionPolicy tracks the use of the entries in one shard of a cache, and chooses which to evict
+// when the shard is over its byte limit. It can be implemented to give a cache an eviction policy
+// other than those selected by WithEviction; see WithEvictionPolicy. Perpetual and pinned entries
+// are never evicted, so they are not given to the policy. The methods are called with the shard
+// locked, so they don't need to be safe for concurrent use, and they must not use the cache.
+type EvictionPolicy interface {
+	// OnStore is called when an entry is added.
+	OnStore(key interface{})
+
+	// OnGet is called when an entry is retrieved. Most retrievals only take the shard's read lock,
+	// so they are buffered and passed to OnGet just before Victim is called, and some may be
+	// dropped when there are a lot of them.
+	OnGet(key interface{})
+
+	// OnRemove is called when an entry is removed for any reason, including being evicted.
+	OnRemove(key interface{})
+
+	// Victim returns the key of the entry to evict next, or false if there are no entries. The
+	// entry is then removed, and OnRemove called for it.
+	Victim() (key interface{}, ok bool)
+}
+
+// WithEvictionPolicy gives the cache a custom eviction policy. newPolicy is called once for each
+// shard, and again for each priority other than PriorityNormal given to the shard's entries with
+// WithPriority, and must return a new policy each time. It takes precedence over WithEviction.
+func WithEvictionPolicy(newPolicy func() EvictionPolicy) Option {
+	return func(c *Cache) {
+		c.newPolicy = newPolicy
+	}
+}
+
+// policyFactory returns a function creating the eviction policies for the shards of the cache. It
+// is kept by the shards, so it must not refer to the cache.
+func (c *Cache) policyFactory() func() EvictionPolicy {
+	if c.newPolicy != nil {
+		return c.newPolicy
+	}
+	mode := c.eviction
+	return func() EvictionPolicy {
+		return newEvictionPolicy(mode)
+	}
+}
+
+// newEvictionPolicy returns a new eviction policy for the given mode.
+func newEvictionPolicy(mode EvictionMode) EvictionPolicy {
+	switch mode {
+	case LFU:
+		return newLFU()
+	case TinyLFU:
+		return newTinyLFU()
+	case ARC:
+		return newARC()
+	case FIFO:
+		return NewFIFOPolicy()
+	case Random:
+		return NewRandomPolicy()
+	case SLRU:
+		return newSLRU()
+	}
+	return NewLRUPolicy()
+}
+
+// lru is an EvictionPolicy that evicts the least recently used entry, or with fifo set, the entry
+// stored first.
+type lru struct {
+	order    *list.List
+	elements map[interface{}]*list.Element
+	fifo     bool
+}
+
+// NewLRUPolicy returns the EvictionPolicy used by the LRU mode, which evicts the least recently
+// used entry. It can be used to build other policies with WithEvictionPolicy.
+func NewLRUPolicy() EvictionPolicy {
+	return &lru{order: list.New(), elements: make(map[interface{}]*list.Element)}
+}
+
+// NewFIFOPolicy returns the EvictionPolicy used by the FIFO mode, which evicts the entry that was
+// stored first.
+func NewFIFOPolicy() EvictionPolicy {
+	return &lru{order: list.New(), elements: make(map[interface{}]*list.Element), fifo: true}
+}
+
+func (p *lru) OnStore(key interface{}) {
+	p.elements[key] = p.order.PushFront(key)
+}
+
+func (p *lru) OnGet(key interface{}) {
+	if el := p.elements[key]; el != nil && !p.fifo {
+		p.order.MoveToFront(el)
+	}
+}
+
+func (p *lru) OnRemove(key interface{}) {
+	if el := p.elements[key]; el != nil {
+		p.order.Remove(el)
+		delete(p.elements, key)
+	}
+}
+
+func (p *lru) Victim() (interface{}, bool) {
+	if el := p.order.Back(); el != nil {
+		return el.Value, true
+	}
+	return nil, false
+}
+
+// random is an EvictionPolicy that evicts an entry chosen at random. The keys are kept in a slice
+// so that one can be chosen in constant time, with a map from each key to its index.
+type random struct {
+	keys    []interface{}
+	indexes map[interface{}]int
+}
+
+// NewRandomPolicy returns the EvictionPolicy used by the Random mode, which evicts an entry chosen
+// at random.
+func NewRandomPolicy() EvictionPolicy {
+	return &random{indexes: make(map[interface{}]int)}
+}
+
+func (p *random) OnStore(key interface{}) {
+	p.indexes[key] = len(p.keys)
+	p.keys = append(p.keys, key)
+}
+
+func (p *random) OnGet(key interface{}) {}
+
+func (p *random) OnRemove(key interface{}) {
+	i, ok := p.indexes[key]
+	if !ok {
+		return
+	}
+	last := len(p.keys) - 1
+	p.keys[i] = p.keys[last]
+	p.indexes[p.keys[i]] = i
+	p.keys[last] = nil
+	p.keys = p.keys[:last]
+	delete(p.indexes, key)
+}
+
+func (p *random) Victim() (interface{}, bool) {
+	if len(p.keys) == 0 {
+		return nil, false
+	}
+	return p.keys[rand.IntN(len(p.keys))], true
+}
@@ -0,0 +1,154 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+// newestFirst is an EvictionPolicy that evicts the most recently stored entry.
+type newestFirst struct {
+	keys []interface{}
+}
+
+func (p *newestFirst) OnStore(key interface{}) {
+	p.keys = append(p.keys, key)
+}
+
+func (p *newestFirst) OnGet(key interface{}) {}
+
+func (p *newestFirst) OnRemove(key interface{}) {
+	for i, k := range p.keys {
+		if k == key {
+			p.keys = append(p.keys[:i], p.keys[i+1:]...)
+			return
+		}
+	}
+}
+
+func (p *newestFirst) Victim() (interface{}, bool) {
+	if len(p.keys) == 0 {
+		return nil, false
+	}
+	return p.keys[len(p.keys)-1], true
+}
+
+func TestEvictionPolicies(t *testing.T) {
+	// each test stores keys of size 10 in a cache with room for them all, uses some of them, and
+	// then stores one more, which must evict the key the policy chooses
+	newest := WithEvictionPolicy(func() EvictionPolicy { return &newestFirst{} })
+	tests := []struct {
+		name    string
+		opt     Option
+		stored  []string
+		gets    []string
+		evicted string
+	}{
+		{"LRU", WithEviction(LRU), []string{"a", "b"}, []string{"a"}, "b"},
+		// using a doesn't save it, as it was stored first
+		{"FIFO", WithEviction(FIFO), []string{"a", "b"}, []string{"a"}, "a"},
+		// b is used only once
+		{"SLRU", WithEviction(SLRU), []string{"a", "b"}, []string{"a"}, "b"},
+		{"ARC", WithEviction(ARC), []string{"a", "b"}, []string{"a"}, "b"},
+		// b is the least frequently used, even though it is not the least recently used
+		{"LFU", WithEviction(LFU), []string{"a", "b", "c"}, []string{"a", "a", "b", "c", "c"}, "b"},
+		{"WithEvictionPolicy", newest, []string{"a", "b"}, nil, "b"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cache := NewCache(WithShards(1), test.opt)
+			defer cache.Free()
+			cache.SetMaxBytes(int64(10 * len(test.stored)))
+			for _, key := range test.stored {
+				cache.Store(key, key, time.Minute, WithSize(10))
+			}
+			for _, key := range test.gets {
+				cache.Get(key)
+			}
+			cache.Store("new", "new", time.Minute, WithSize(10))
+
+			for _, key := range append(test.stored, "new") {
+				v := cache.Get(key)
+				switch {
+				case key == test.evicted && v != nil:
+					t.Errorf("Expected cache key '%s' to be evicted, but has value '%v'", key, v)
+				case key != test.evicted && v != key:
+					t.Errorf("Expected cache key '%s' to have a value, but got '%v'", key, v)
+				}
+			}
+		})
+	}
+}
+
+// recordingPolicy is an LRU policy that records the keys passed to OnGet.
+type recordingPolicy struct {
+	EvictionPolicy
+	gets []interface{}
+}
+
+func (p *recordingPolicy) OnGet(key interface{}) {
+	p.gets = append(p.gets, key)
+	p.EvictionPolicy.OnGet(key)
+}
+
+func TestEvictionPolicyEvictableOnly(t *testing.T) {
+	policy := &recordingPolicy{EvictionPolicy: newEvictionPolicy(LRU)}
+	cache := NewCache(WithShards(1), WithEvictionPolicy(func() EvictionPolicy { return policy }))
+	defer cache.Free()
+	cache.SetMaxBytes(100)
+
+	cache.StorePerpetual("perpetual", counter(0), time.Minute, WithSize(10))
+	cache.Store("pinned", "pinned", time.Minute, WithSize(10))
+	cache.Pin("pinned")
+	cache.Store("plain", "plain", time.Minute, WithSize(10))
+	for _, key := range []string{"perpetual", "pinned", "plain"} {
+		// with the read lock, passed to the policy when the next entry is stored, and with the
+		// write lock
+		cache.Get(key)
+		cache.GetVersioned(key)
+	}
+	cache.Store("other", "other", time.Minute, WithSize(10))
+
+	for _, key := range policy.gets {
+		if key != "plain" {
+			t.Errorf("Did not expect perpetual or pinned cache key '%v' to be passed to OnGet", key)
+		}
+	}
+	if len(policy.gets) != 2 {
+		t.Errorf("Expected both reads of cache key 'plain' to be passed to OnGet, but got %v", policy.gets)
+	}
+}
+
+func TestRandomPolicy(t *testing.T) {
+	p := NewRandomPolicy()
+	for i := 0; i < 10; i++ {
+		p.OnStore(i)
+	}
+	p.OnRemove(3)
+	seen := make(map[interface{}]bool)
+	for i := 0; i < 9; i++ {
+		key, ok := p.Victim()
+		if !ok || key == 3 {
+			t.Fatalf("Expected a victim that hasn't been removed, but got '%v', %v", key, ok)
+		}
+		seen[key] = true
+		p.OnRemove(key)
+	}
+	if len(seen) != 9 {
+		t.Errorf("Expected each of the 9 remaining keys to be chosen once, but got %v", seen)
+	}
+	if _, ok := p.Victim(); ok {
+		t.Errorf("Did not expect a victim once every key was removed")
+	}
+}
+
+func TestRandomEviction(t *testing.T) {
+	cache := NewCache(WithShards(1), WithEviction(Random))
+	defer cache.Free()
+	cache.SetMaxBytes(50)
+	for i := 0; i < 100; i++ {
+		cache.Store(i, i, time.Minute, WithSize(1))
+	}
+	if n := cache.Len(); n != 50 {
+		t.Errorf("Expected random eviction to keep the cache at 50 entries, but it has %d", n)
+	}
+}
@@ -0,0 +1,75 @@
+package cache
+
+import "container/heap"
+
+// expiryHeap is a min-heap of cache entries ordered by expiry, so the sweep only needs to look at
+// entries that are due rather than every entry in the cache. It implements heap.Interface; each
+// entry records its own index in the heap so it can be removed or fixed when it changes.
+type expiryHeap []*CacheEntry
+
+func (h expiryHeap) Len() int {
+	return len(h)
+}
+
+func (h expiryHeap) Less(i, j int) bool {
+	return h[i].expiry.Before(h[j].expiry)
+}
+
+func (h expiryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *expiryHeap) Push(x interface{}) {
+	entry := x.(*CacheEntry)
+	entry.index = len(*h)
+	*h = append(*h, entry)
+}
+
+func (h *expiryHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	entry := old[n-1]
+	old[n-1] = nil
+	entry.index = -1
+	*h = old[:n-1]
+	return entry
+}
+
+// schedule adds an entry to the heap. The entry must not already be in it. Entries with a zero
+// expiry never expire, and are not added.
+func (h *expiryHeap) schedule(entry *CacheEntry) {
+	if !entry.expiry.IsZero() {
+		heap.Push(h, entry)
+	}
+}
+
+// unschedule removes an entry from the heap, if it is in it.
+func (h *expiryHeap) unschedule(entry *CacheEntry) {
+	if entry.index >= 0 {
+		heap.Remove(h, entry.index)
+	}
+}
+
+// reschedule moves an entry to its place in the heap after its expiry has changed, adding it if
+// it is not in the heap, or removing it if its expiry is now zero.
+func (h *expiryHeap) reschedule(entry *CacheEntry) {
+	switch {
+	case entry.expiry.IsZero():
+		h.unschedule(entry)
+	case entry.index >= 0:
+		heap.Fix(h, entry.index)
+	default:
+		heap.Push(h, entry)
+	}
+}
+
+// popDue removes and returns the entry with the earliest expiry if it expires at or before
+// the given time in nanoseconds, or nil if no entry is due.
+func (h *expiryHeap) popDue(now int64) *CacheEntry {
+	if len(*h) == 0 || (*h)[0].expiry.UnixNano() > now {
+		return nil
+	}
+	return heap.Pop(h).(*CacheEntry)
+}
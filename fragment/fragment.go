@@ -0,0 +1,122 @@
+// Package fragment caches rendered fragments of html/template output in a cache.Cache, so that
+// the expensive parts of a page are rendered once and reused until they expire or are
+// invalidated. Templates use it through the cachefragment function added by Funcs:
+//
+//	{{cachefragment "menu" "5m" "menu.html" . "sitetree"}}
+//
+// renders the template named "menu.html" with the given data, caches the output for five minutes
+// under the key "menu" and tags it with "sitetree", and inserts it into the page.
+package fragment
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/mrmorphic/cache"
+)
+
+// Fragments caches rendered fragments in a cache. It is created with New, and is safe for
+// concurrent use.
+type Fragments struct {
+	c    *cache.Cache
+	ns   *cache.Namespace
+	name string
+}
+
+// Option configures Fragments.
+type Option func(*Fragments)
+
+// WithName sets the name of the cache.Namespace fragments are stored in. The default is
+// "fragment". Fragments sharing a cache should be given different names, unless they are meant to
+// share their fragments.
+func WithName(name string) Option {
+	return func(f *Fragments) {
+		f.name = name
+	}
+}
+
+// New returns Fragments that caches fragments in c.
+func New(c *cache.Cache, opts ...Option) *Fragments {
+	f := &Fragments{c: c, name: "fragment"}
+	for _, opt := range opts {
+		opt(f)
+	}
+	f.ns = c.Namespace(f.name)
+	return f
+}
+
+// Render returns the fragment cached under key, or if there is none, calls render to write it,
+// and caches what it writes for ttl with the given tags. If render fails nothing is cached.
+func (f *Fragments) Render(key string, ttl time.Duration, render func(w io.Writer) error, tags ...string) (template.HTML, error) {
+	if html, ok := f.ns.Get(key).(template.HTML); ok {
+		return html, nil
+	}
+	var buf bytes.Buffer
+	if err := render(&buf); err != nil {
+		return "", err
+	}
+	html := template.HTML(buf.String())
+	f.ns.Store(key, html, ttl, cache.WithTags(tags...))
+	return html, nil
+}
+
+// Funcs adds the cachefragment function to t, and returns t. It must be called before the
+// templates that use the function are parsed. The function is called as
+//
+//	cachefragment key ttl name data [tags...]
+//
+// and returns the fragment cached under key, or renders the template called name in t's set with
+// data, and caches the output for ttl with the tags. The ttl is a duration string such as "5m" or
+// "1h30m", a time.Duration, or a number of seconds.
+func (f *Fragments) Funcs(t *template.Template) *template.Template {
+	return t.Funcs(template.FuncMap{
+		"cachefragment": func(key string, ttl interface{}, name string, data interface{}, tags ...string) (template.HTML, error) {
+			d, err := duration(ttl)
+			if err != nil {
+				return "", err
+			}
+			return f.Render(key, d, func(w io.Writer) error {
+				return t.ExecuteTemplate(w, name, data)
+			}, tags...)
+		},
+	})
+}
+
+// duration converts the ttl given to cachefragment to a duration.
+func duration(ttl interface{}) (time.Duration, error) {
+	switch v := ttl.(type) {
+	case time.Duration:
+		return v, nil
+	case int:
+		return time.Duration(v) * time.Second, nil
+	case string:
+		if d, err := time.ParseDuration(v); err == nil {
+			return d, nil
+		}
+		if n, err := strconv.Atoi(v); err == nil {
+			return time.Duration(n) * time.Second, nil
+		}
+	}
+	return 0, fmt.Errorf("fragment: invalid ttl %v", ttl)
+}
+
+// Invalidate deletes the fragment cached under key, so that it is rendered again the next time it
+// is used.
+func (f *Fragments) Invalidate(key string) {
+	f.ns.Delete(key)
+}
+
+// InvalidateTag deletes the fragments tagged with tag. Tags are shared with the rest of the cache,
+// so this also deletes any other entries with the tag, as cache.Cache.InvalidateTag does.
+func (f *Fragments) InvalidateTag(tag string) {
+	f.c.InvalidateTag(tag)
+}
+
+// InvalidateAll deletes all the fragments.
+func (f *Fragments) InvalidateAll() {
+	f.c.InvalidateNamespace(f.name)
+}
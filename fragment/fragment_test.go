@@ -0,0 +1,63 @@
+package fragment
+
+import (
+	"html/template"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mrmorphic/cache"
+)
+
+func TestCacheFragment(t *testing.T) {
+	c := cache.NewCache()
+	defer c.Free()
+	f := New(c)
+
+	renders := 0
+	tmpl := f.Funcs(template.New("page").Funcs(template.FuncMap{
+		"count": func() int {
+			renders++
+			return renders
+		},
+	}))
+	template.Must(tmpl.Parse(`<main>{{cachefragment "menu" "5m" "menu" .Title "sitetree"}}</main>`))
+	template.Must(tmpl.New("menu").Parse(`<nav>{{.}} {{count}}</nav>`))
+
+	render := func() string {
+		var b strings.Builder
+		if err := tmpl.ExecuteTemplate(&b, "page", map[string]string{"Title": "Home & Away"}); err != nil {
+			t.Fatalf("Expected the page to render, but got %v", err)
+		}
+		return b.String()
+	}
+
+	want := "<main><nav>Home &amp; Away 1</nav></main>"
+	if got := render(); got != want {
+		t.Errorf("Expected the page to be '%s', but got '%s'", want, got)
+	}
+	if got := render(); got != want {
+		t.Errorf("Expected the fragment to be served from the cache as '%s', but got '%s'", want, got)
+	}
+	if ttl, ok := c.TTL(cache.NamespacedKey{Namespace: "fragment", Key: "menu"}); !ok || ttl > 5*time.Minute || ttl < 4*time.Minute {
+		t.Errorf("Expected the fragment to be cached for 5 minutes, but got %v", ttl)
+	}
+
+	f.InvalidateTag("sitetree")
+	if got := render(); got != "<main><nav>Home &amp; Away 2</nav></main>" {
+		t.Errorf("Expected the fragment to be rendered again after invalidating its tag, but got '%s'", got)
+	}
+	f.Invalidate("menu")
+	if got := render(); got != "<main><nav>Home &amp; Away 3</nav></main>" {
+		t.Errorf("Expected the fragment to be rendered again after invalidating it, but got '%s'", got)
+	}
+}
+
+func TestInvalidTTL(t *testing.T) {
+	c := cache.NewCache()
+	defer c.Free()
+	tmpl := template.Must(New(c).Funcs(template.New("page")).Parse(`{{cachefragment "k" "soon" "page" .}}`))
+	if err := tmpl.Execute(&strings.Builder{}, nil); err == nil {
+		t.Errorf("Expected an invalid ttl to fail")
+	}
+}
@@ -0,0 +1,315 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Getter loads the value of a key of a Group that is not cached, and writes it to dest. It is the
+// same as groupcache's Getter.
+type Getter interface {
+	Get(ctx context.Context, key string, dest Sink) error
+}
+
+// GetterFunc is a function that implements Getter.
+type GetterFunc func(ctx context.Context, key string, dest Sink) error
+
+func (f GetterFunc) Get(ctx context.Context, key string, dest Sink) error {
+	return f(ctx, key, dest)
+}
+
+// Sink receives the value of a key from Group.Get, or from a Getter.
+type Sink interface {
+	SetString(s string) error
+	SetBytes(b []byte) error
+}
+
+// StringSink returns a Sink that sets *s to the value.
+func StringSink(s *string) Sink {
+	return stringSink{s}
+}
+
+type stringSink struct {
+	s *string
+}
+
+func (s stringSink) SetString(v string) error {
+	*s.s = v
+	return nil
+}
+
+func (s stringSink) SetBytes(b []byte) error {
+	*s.s = string(b)
+	return nil
+}
+
+// ByteSliceSink returns a Sink that sets *b to a copy of the value, which the caller may modify.
+func ByteSliceSink(b *[]byte) Sink {
+	return byteSliceSink{b}
+}
+
+type byteSliceSink struct {
+	b *[]byte
+}
+
+func (s byteSliceSink) SetString(v string) error {
+	*s.b = []byte(v)
+	return nil
+}
+
+func (s byteSliceSink) SetBytes(b []byte) error {
+	*s.b = append([]byte(nil), b...)
+	return nil
+}
+
+// ErrNoValue is returned by Group.Get when the Getter returns without writing a value to its Sink.
+var ErrNoValue = errors.New("cache: getter did not set a value")
+
+// Peer is another process holding part of a distributed cache, from which a Group can fetch the
+// values of the keys that process owns.
+type Peer interface {
+	// Get returns the value of a key of the named group from the peer.
+	Get(ctx context.Context, group, key string) ([]byte, error)
+}
+
+// PeerPicker chooses which process owns each key of a Group, as groupcache's PeerPicker does.
+type PeerPicker interface {
+	// PickPeer returns the peer that owns a key, and true, or false if this process owns it.
+	PickPeer(key string) (peer Peer, ok bool)
+}
+
+// GroupOption configures a Group when it is created with NewGroup.
+type GroupOption func(*Group)
+
+// WithPeers has the Group fetch the values of keys owned by other processes from them.
+func WithPeers(peers PeerPicker) GroupOption {
+	return func(g *Group) {
+		g.peers = peers
+	}
+}
+
+// WithGroupLifetime sets how long the Group caches values for. The default is NoExpiry, as with
+// groupcache, where values are only removed when they are evicted.
+func WithGroupLifetime(lifetime time.Duration) GroupOption {
+	return func(g *Group) {
+		g.lifetime = lifetime
+	}
+}
+
+// Group is a named set of string keys whose values are byte strings, loaded by a Getter when they
+// are missing, with the semantics of a groupcache Group. Concurrent Gets of a missing key share a
+// single load. If the Group has peers, keys owned by another process are fetched from it instead,
+// and a fraction of those values are kept in this process too, so that hot keys don't all go to
+// one peer; if the peer fails the key is loaded locally. Values are kept in a namespace of a Cache
+// named after the group, so they are bounded by the cache's limits and can be invalidated with it.
+type Group struct {
+	name     string
+	getter   Getter
+	peers    PeerPicker
+	ns       *Namespace
+	lifetime time.Duration
+	stats    groupCounters
+
+	// the loads in progress, by key.
+	mu    sync.Mutex
+	calls map[string]*groupCall
+}
+
+// groupCall is a load of a key that's in progress. done is closed when it completes.
+type groupCall struct {
+	done  chan struct{}
+	value string
+	err   error
+}
+
+// hotKey is the key under which a Group keeps a value fetched from a peer that owns the key.
+type hotKey string
+
+// GroupStats holds statistics about the use of a Group, as returned by Group.Stats.
+type GroupStats struct {
+	// Gets counts calls to Get, and CacheHits those answered from the cache.
+	Gets      uint64
+	CacheHits uint64
+
+	// PeerLoads counts values fetched from peers, and PeerErrors fetches that failed.
+	PeerLoads  uint64
+	PeerErrors uint64
+
+	// LocalLoads counts calls to the Getter, and LocalLoadErrs those that failed.
+	LocalLoads    uint64
+	LocalLoadErrs uint64
+}
+
+type groupCounters struct {
+	gets, cacheHits, peerLoads, peerErrors, localLoads, localLoadErrs atomic.Uint64
+}
+
+var (
+	groupsMu sync.Mutex
+	groups   = make(map[string]*Group)
+)
+
+// NewGroup returns a Group with the given name, whose values are loaded by getter and kept in c.
+// The group can then be found by name with GetGroup, for instance by a server answering requests
+// from peers. Like groupcache's NewGroup, it panics if a group with the name already exists.
+func NewGroup(name string, c *Cache, getter Getter, opts ...GroupOption) *Group {
+	g := &Group{
+		name:     name,
+		getter:   getter,
+		ns:       c.Namespace(name),
+		lifetime: NoExpiry,
+		calls:    make(map[string]*groupCall),
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	groupsMu.Lock()
+	defer groupsMu.Unlock()
+	if groups[name] != nil {
+		panic("cache: duplicate group " + name)
+	}
+	groups[name] = g
+	return g
+}
+
+// GetGroup returns the group created by NewGroup with the given name, or nil if there is none.
+func GetGroup(name string) *Group {
+	groupsMu.Lock()
+	defer groupsMu.Unlock()
+	return groups[name]
+}
+
+// Name returns the name of the group.
+func (g *Group) Name() string {
+	return g.name
+}
+
+// Get writes the value of a key to dest, from the cache if it is there, and otherwise from the
+// peer that owns the key or the Getter, which are passed ctx. If another goroutine is already
+// loading the key, Get waits for that load to complete, or for ctx to be done, and returns its
+// result. If the Getter panics, the load fails with a *PanicError.
+func (g *Group) Get(ctx context.Context, key string, dest Sink) error {
+	g.stats.gets.Add(1)
+	if v, ok := g.cached(key); ok {
+		g.stats.cacheHits.Add(1)
+		return dest.SetString(v)
+	}
+
+	g.mu.Lock()
+	call := g.calls[key]
+	if call == nil {
+		call = &groupCall{done: make(chan struct{})}
+		g.calls[key] = call
+		g.mu.Unlock()
+		g.load(ctx, key, call)
+	} else {
+		g.mu.Unlock()
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if call.err != nil {
+		return call.err
+	}
+	return dest.SetString(call.value)
+}
+
+// cached returns the value of a key if it is in the cache.
+func (g *Group) cached(key string) (string, bool) {
+	if v, ok := g.ns.GetOK(key); ok {
+		return v.(string), true
+	}
+	if v, ok := g.ns.GetOK(hotKey(key)); ok {
+		return v.(string), true
+	}
+	return "", false
+}
+
+// load loads a key for a call, and completes it.
+func (g *Group) load(ctx context.Context, key string, pending *groupCall) {
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(pending.done)
+	}()
+
+	// another load may have completed since Get checked the cache
+	if v, ok := g.cached(key); ok {
+		pending.value = v
+		return
+	}
+	if g.peers != nil {
+		if peer, ok := g.peers.PickPeer(key); ok {
+			b, err := peer.Get(ctx, g.name, key)
+			if err == nil {
+				g.stats.peerLoads.Add(1)
+				pending.value = string(b)
+				if rand.IntN(10) == 0 {
+					g.ns.Store(hotKey(key), pending.value, g.lifetime)
+				}
+				return
+			}
+			g.stats.peerErrors.Add(1)
+		}
+	}
+
+	g.stats.localLoads.Add(1)
+	var sink valueSink
+	_, err := call(ctx, func(ctx context.Context) (interface{}, error) {
+		return nil, g.getter.Get(ctx, key, &sink)
+	})
+	if err != nil {
+		g.stats.localLoadErrs.Add(1)
+		pending.err = err
+		return
+	}
+	if !sink.set {
+		g.stats.localLoadErrs.Add(1)
+		pending.err = ErrNoValue
+		return
+	}
+	pending.value = sink.value
+	g.ns.Store(key, pending.value, g.lifetime)
+}
+
+// valueSink is the Sink a Group passes to its Getter.
+type valueSink struct {
+	value string
+	set   bool
+}
+
+func (s *valueSink) SetString(v string) error {
+	s.value, s.set = v, true
+	return nil
+}
+
+func (s *valueSink) SetBytes(b []byte) error {
+	s.value, s.set = string(b), true
+	return nil
+}
+
+// Remove removes the value of a key from this process's cache, so that the next Get loads it
+// again. It does not affect peers.
+func (g *Group) Remove(key string) {
+	g.ns.Delete(key)
+	g.ns.Delete(hotKey(key))
+}
+
+// Stats returns statistics about the use of the group.
+func (g *Group) Stats() GroupStats {
+	return GroupStats{
+		Gets:          g.stats.gets.Load(),
+		CacheHits:     g.stats.cacheHits.Load(),
+		PeerLoads:     g.stats.peerLoads.Load(),
+		PeerErrors:    g.stats.peerErrors.Load(),
+		LocalLoads:    g.stats.localLoads.Load(),
+		LocalLoadErrs: g.stats.localLoadErrs.Load(),
+	}
+}
@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type testPeers map[string]Peer
+
+func (p testPeers) PickPeer(key string) (Peer, bool) {
+	peer, ok := p[key]
+	return peer, ok
+}
+
+type testPeer func(ctx context.Context, group, key string) ([]byte, error)
+
+func (f testPeer) Get(ctx context.Context, group, key string) ([]byte, error) {
+	return f(ctx, group, key)
+}
+
+func TestGroup(t *testing.T) {
+	cache := NewCache()
+	defer cache.Free()
+	var loads atomic.Int32
+	release := make(chan struct{})
+	g := NewGroup("TestGroup", cache, GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		loads.Add(1)
+		<-release
+		return dest.SetString("value of " + key)
+	}))
+	if GetGroup("TestGroup") != g {
+		t.Errorf("Expected GetGroup to return the group")
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var v string
+			if err := g.Get(context.Background(), "key", StringSink(&v)); err != nil || v != "value of key" {
+				t.Errorf("Expected Get to return 'value of key', but got '%s', %v", v, err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	var b []byte
+	if err := g.Get(context.Background(), "key", ByteSliceSink(&b)); err != nil || string(b) != "value of key" {
+		t.Errorf("Expected the cached value 'value of key', but got '%s', %v", b, err)
+	}
+	if n := loads.Load(); n != 1 {
+		t.Errorf("Expected concurrent Gets to share one load, but the getter was called %d times", n)
+	}
+	if st := g.Stats(); st.Gets != 11 || st.LocalLoads != 1 || st.CacheHits < 1 {
+		t.Errorf("Expected 11 gets with 1 local load, but got %+v", st)
+	}
+
+	g.Remove("key")
+	g.Get(context.Background(), "key", ByteSliceSink(&b))
+	if n := loads.Load(); n != 2 {
+		t.Errorf("Expected the key to be loaded again after Remove, but the getter was called %d times", n)
+	}
+}
+
+func TestGroupPeers(t *testing.T) {
+	cache := NewCache()
+	defer cache.Free()
+	fail := errors.New("peer down")
+	peers := testPeers{
+		"remote": testPeer(func(ctx context.Context, group, key string) ([]byte, error) {
+			return []byte(group + " from peer"), nil
+		}),
+		"down": testPeer(func(ctx context.Context, group, key string) ([]byte, error) {
+			return nil, fail
+		}),
+	}
+	g := NewGroup("TestGroupPeers", cache, GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		if key == "missing" {
+			return nil
+		}
+		return dest.SetBytes([]byte("local"))
+	}), WithPeers(peers))
+
+	var v string
+	if err := g.Get(context.Background(), "remote", StringSink(&v)); err != nil || v != "TestGroupPeers from peer" {
+		t.Errorf("Expected the value to be fetched from the peer, but got '%s', %v", v, err)
+	}
+	if err := g.Get(context.Background(), "down", StringSink(&v)); err != nil || v != "local" {
+		t.Errorf("Expected the value to be loaded locally when the peer fails, but got '%s', %v", v, err)
+	}
+	if err := g.Get(context.Background(), "missing", StringSink(&v)); err != ErrNoValue {
+		t.Errorf("Expected ErrNoValue when the getter sets no value, but got %v", err)
+	}
+	if st := g.Stats(); st.PeerLoads != 1 || st.PeerErrors != 1 || st.LocalLoads != 2 || st.LocalLoadErrs != 1 {
+		t.Errorf("Expected 1 peer load, 1 peer error and 2 local loads with 1 error, but got %+v", st)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected NewGroup to panic for a duplicate name")
+		}
+	}()
+	NewGroup("TestGroupPeers", cache, nil)
+}
+
+func TestGroupPanic(t *testing.T) {
+	cache := NewCache()
+	defer cache.Free()
+	release := make(chan struct{})
+	g := NewGroup("TestGroupPanic", cache, GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		<-release
+		panic("getter crashed")
+	}))
+
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		go func() {
+			var v string
+			errs <- g.Get(context.Background(), "key", StringSink(&v))
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	for i := 0; i < 5; i++ {
+		var perr *PanicError
+		if err := <-errs; !errors.As(err, &perr) || perr.Value != "getter crashed" {
+			t.Errorf("Expected each Get to fail with the getter's panic, but got %v", err)
+		}
+	}
+	if _, ok := cache.Namespace("TestGroupPanic").GetOK("key"); ok {
+		t.Errorf("Did not expect a value to be cached after the getter panicked")
+	}
+}
@@ -0,0 +1,261 @@
+// Package grpc serves a cache.Cache as the gRPC service defined in cache.proto, so that processes
+// written in other languages, such as sidecars in the same pod, can share its state. The service
+// has Get, Set and Delete methods on string keys and byte values, and a Watch stream of changes.
+//
+// Server implements the service's methods on Go types with the same fields as the protobuf
+// messages, which encode themselves in the protobuf wire format, so that the package does not
+// depend on a gRPC or protobuf library unless it is built with the grpc tag. That adds
+// RegisterCacheServer, to register a Server with a grpc.Server, CacheClient, to call the service,
+// and a codec for the messages registered under its own name, CodecName, so that it is used
+// only for calls of this service.
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mrmorphic/cache"
+)
+
+// GetRequest, GetResponse and the other request and response types mirror the messages of the
+// same names in cache.proto.
+type GetRequest struct {
+	Namespace string
+	Key       string
+}
+
+type GetResponse struct {
+	Value []byte
+	Found bool
+}
+
+type SetRequest struct {
+	Namespace string
+	Key       string
+	Value     []byte
+	TTLMillis int64
+}
+
+type SetResponse struct{}
+
+type DeleteRequest struct {
+	Namespace string
+	Key       string
+}
+
+type DeleteResponse struct {
+	Deleted bool
+}
+
+type WatchRequest struct {
+	Namespace string
+	Keys      []string
+}
+
+type WatchEvent struct {
+	Type      string
+	Namespace string
+	Key       string
+	Reason    string
+}
+
+// WatchStream is the stream Watch sends events to. The server stream generated for the Watch
+// method has the same methods.
+type WatchStream interface {
+	Send(*WatchEvent) error
+	Context() context.Context
+}
+
+var (
+	// ErrEmptyKey is returned for a request without a key.
+	ErrEmptyKey = errors.New("grpc: empty key")
+
+	// ErrServerClosed is returned by Watch once Close has been called, or the cache freed.
+	ErrServerClosed = errors.New("grpc: server closed")
+)
+
+// Server implements the Cache service for a cache. It is created with New.
+type Server struct {
+	c *cache.Cache
+
+	// the cache's channels that Watch calls are receiving from, which Close unwatches to end them.
+	mu      sync.Mutex
+	watches map[<-chan cache.ValueChange]struct{}
+	closed  bool
+}
+
+// New returns a Server for the given cache.
+//
+// Values set by clients are stored as byte slices. Values stored by the service itself are
+// returned as they are if they are byte slices or strings, and formatted with fmt.Sprint
+// otherwise.
+func New(c *cache.Cache) *Server {
+	return &Server{c: c, watches: make(map[<-chan cache.ValueChange]struct{})}
+}
+
+// Close ends the Watch calls, and any made after it is called. It does not free the cache.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for ch := range s.watches {
+		delete(s.watches, ch)
+		s.c.Unwatch(ch)
+	}
+	return nil
+}
+
+// key returns the key in the cache for a key in a namespace.
+func key(namespace, key string) interface{} {
+	if namespace == "" {
+		return key
+	}
+	return cache.NamespacedKey{Namespace: namespace, Key: key}
+}
+
+// Get returns the value of a key.
+func (s *Server) Get(ctx context.Context, req *GetRequest) (*GetResponse, error) {
+	if req.Key == "" {
+		return nil, ErrEmptyKey
+	}
+	v, ok := s.c.GetOK(key(req.Namespace, req.Key))
+	if !ok {
+		return &GetResponse{}, nil
+	}
+	return &GetResponse{Value: format(v), Found: true}, nil
+}
+
+// format returns the bytes sent to clients for a value.
+func format(v interface{}) []byte {
+	switch v := v.(type) {
+	case []byte:
+		return v
+	case string:
+		return []byte(v)
+	case nil:
+		return nil
+	}
+	return []byte(fmt.Sprint(v))
+}
+
+// Set stores a value for a key.
+func (s *Server) Set(ctx context.Context, req *SetRequest) (*SetResponse, error) {
+	if req.Key == "" {
+		return nil, ErrEmptyKey
+	}
+	ttl := time.Duration(req.TTLMillis) * time.Millisecond
+	if ttl < 0 {
+		ttl = cache.NoExpiry
+	}
+	s.c.Store(key(req.Namespace, req.Key), slices.Clone(req.Value), ttl)
+	return &SetResponse{}, nil
+}
+
+// Delete deletes a key.
+func (s *Server) Delete(ctx context.Context, req *DeleteRequest) (*DeleteResponse, error) {
+	if req.Key == "" {
+		return nil, ErrEmptyKey
+	}
+	return &DeleteResponse{Deleted: s.c.Delete(key(req.Namespace, req.Key))}, nil
+}
+
+// Watch sends an event to stream each time a value is stored for one of the watched keys, or its
+// entry is removed, until the stream's context is done, Send fails or the server is closed. Events
+// are sent without holding up the cache, so a stream that falls too far behind misses events.
+func (s *Server) Watch(req *WatchRequest, stream WatchStream) error {
+	chans, err := s.watch(req)
+	if err != nil {
+		return err
+	}
+	defer s.unwatch(chans)
+
+	cases := []reflect.SelectCase{{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(stream.Context().Done())}}
+	for _, ch := range chans {
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch)})
+	}
+	for {
+		i, v, ok := reflect.Select(cases)
+		switch {
+		case i == 0:
+			return stream.Context().Err()
+		case !ok:
+			// unwatched by Close, or the cache has been freed
+			return ErrServerClosed
+		}
+		if ev := watchEvent(v.Interface().(cache.ValueChange)); ev != nil {
+			if err := stream.Send(ev); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// watch returns channels of the changes to the keys of a Watch request: one for each key, or one
+// for every key in the namespace if there are none.
+func (s *Server) watch(req *WatchRequest) ([]<-chan cache.ValueChange, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, ErrServerClosed
+	}
+	var chans []<-chan cache.ValueChange
+	if len(req.Keys) == 0 {
+		namespace := req.Namespace
+		chans = append(chans, s.c.WatchFunc(func(k interface{}) bool {
+			ns, _, ok := split(k)
+			return ok && ns == namespace
+		}))
+	}
+	keys := slices.Clone(req.Keys)
+	slices.Sort(keys)
+	for _, k := range slices.Compact(keys) {
+		chans = append(chans, s.c.Watch(key(req.Namespace, k)))
+	}
+	for _, ch := range chans {
+		s.watches[ch] = struct{}{}
+	}
+	return chans, nil
+}
+
+// unwatch stops the changes being sent to the channels of a Watch call, unless Close already has.
+func (s *Server) unwatch(chans []<-chan cache.ValueChange) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ch := range chans {
+		if _, ok := s.watches[ch]; ok {
+			delete(s.watches, ch)
+			s.c.Unwatch(ch)
+		}
+	}
+}
+
+// watchEvent returns the WatchEvent for a change, or nil if its key is not one the service has.
+func watchEvent(change cache.ValueChange) *WatchEvent {
+	namespace, k, ok := split(change.Key)
+	if !ok {
+		return nil
+	}
+	ev := &WatchEvent{Type: "set", Namespace: namespace, Key: k}
+	if change.Kind == cache.ChangeRemove {
+		ev.Type, ev.Reason = "delete", change.Reason.String()
+	}
+	return ev
+}
+
+// split returns the namespace and key of a key in the cache, the reverse of key, or false if it is
+// not a string key or a NamespacedKey with a string key.
+func split(k interface{}) (namespace, name string, ok bool) {
+	switch k := k.(type) {
+	case string:
+		return "", k, true
+	case cache.NamespacedKey:
+		name, ok := k.Key.(string)
+		return k.Namespace, name, ok
+	}
+	return "", "", false
+}
@@ -0,0 +1,166 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mrmorphic/cache"
+)
+
+// fakeStream is a WatchStream that sends events to a channel.
+type fakeStream struct {
+	ctx    context.Context
+	events chan *WatchEvent
+}
+
+func (s *fakeStream) Send(ev *WatchEvent) error {
+	s.events <- ev
+	return nil
+}
+
+func (s *fakeStream) Context() context.Context {
+	return s.ctx
+}
+
+// waitForWatch waits for a Watch call to have started watching the cache.
+func waitForWatch(s *Server) {
+	for {
+		s.mu.Lock()
+		n := len(s.watches)
+		s.mu.Unlock()
+		if n > 0 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// receiveEvents checks that the events sent to a stream are want, and no more.
+func receiveEvents(t *testing.T, stream *fakeStream, want []WatchEvent) {
+	t.Helper()
+	for _, w := range want {
+		select {
+		case ev := <-stream.events:
+			if *ev != w {
+				t.Errorf("Expected event %+v, but got %+v", w, *ev)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected event %+v, but got none", w)
+		}
+	}
+	select {
+	case ev := <-stream.events:
+		t.Errorf("Did not expect another event, but got %+v", *ev)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestGetSetDelete(t *testing.T) {
+	c := cache.NewCache()
+	defer c.Free()
+	s := New(c)
+	ctx := context.Background()
+
+	if _, err := s.Set(ctx, &SetRequest{Key: "Key", Value: []byte("Value"), TTLMillis: 60000}); err != nil {
+		t.Fatalf("Expected Set to succeed, but got %v", err)
+	}
+	if _, err := s.Set(ctx, &SetRequest{Namespace: "ns", Key: "Key", Value: []byte("Other")}); err != nil {
+		t.Fatalf("Expected Set to succeed, but got %v", err)
+	}
+	c.Store("Number", 42, time.Minute)
+
+	if resp, _ := s.Get(ctx, &GetRequest{Key: "Key"}); !resp.Found || string(resp.Value) != "Value" {
+		t.Errorf("Expected cache key 'Key' to have value 'Value', but got %+v", resp)
+	}
+	if resp, _ := s.Get(ctx, &GetRequest{Namespace: "ns", Key: "Key"}); !resp.Found || string(resp.Value) != "Other" {
+		t.Errorf("Expected cache key 'Key' in namespace 'ns' to have value 'Other', but got %+v", resp)
+	}
+	if resp, _ := s.Get(ctx, &GetRequest{Key: "Number"}); string(resp.Value) != "42" {
+		t.Errorf("Expected a value stored by the service to be formatted, but got %+v", resp)
+	}
+	if ttl, _ := c.TTL("Key"); ttl <= 50*time.Second || ttl > time.Minute {
+		t.Errorf("Expected cache key 'Key' to expire in a minute, but got TTL %v", ttl)
+	}
+	if ttl, _ := c.TTL(cache.NamespacedKey{Namespace: "ns", Key: "Key"}); ttl != cache.NoExpiry {
+		t.Errorf("Expected a key set without a TTL to never expire, but got TTL %v", ttl)
+	}
+
+	if resp, _ := s.Delete(ctx, &DeleteRequest{Key: "Key"}); !resp.Deleted {
+		t.Errorf("Expected cache key 'Key' to be deleted")
+	}
+	if resp, _ := s.Get(ctx, &GetRequest{Key: "Key"}); resp.Found {
+		t.Errorf("Did not expect deleted cache key 'Key' to be found, but got %+v", resp)
+	}
+	if resp, _ := s.Delete(ctx, &DeleteRequest{Key: "Key"}); resp.Deleted {
+		t.Errorf("Did not expect a missing key to be deleted")
+	}
+	if _, err := s.Get(ctx, &GetRequest{}); err != ErrEmptyKey {
+		t.Errorf("Expected ErrEmptyKey for a request without a key, but got %v", err)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	c := cache.NewCache()
+	defer c.Free()
+	s := New(c)
+	defer s.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := &fakeStream{ctx: ctx, events: make(chan *WatchEvent, 10)}
+	done := make(chan error)
+	go func() {
+		done <- s.Watch(&WatchRequest{Namespace: "ns", Keys: []string{"Key"}}, stream)
+	}()
+
+	waitForWatch(s)
+
+	ns := c.Namespace("ns")
+	c.Store("Key", "Value", time.Minute)
+	ns.Store("Other", "Value", time.Minute)
+	ns.Store("Key", "Value", time.Minute)
+	ns.Store("Key", "Changed", time.Minute)
+	ns.Delete("Key")
+
+	want := []WatchEvent{
+		{Type: "set", Namespace: "ns", Key: "Key"},
+		{Type: "set", Namespace: "ns", Key: "Key"},
+		{Type: "delete", Namespace: "ns", Key: "Key", Reason: "deleted"},
+	}
+	receiveEvents(t, stream, want)
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("Expected Watch to end with the stream's context, but got %v", err)
+	}
+}
+
+func TestWatchNamespace(t *testing.T) {
+	c := cache.NewCache()
+	defer c.Free()
+	s := New(c)
+	stream := &fakeStream{ctx: context.Background(), events: make(chan *WatchEvent, 10)}
+	done := make(chan error)
+	go func() {
+		done <- s.Watch(&WatchRequest{Namespace: "ns"}, stream)
+	}()
+	waitForWatch(s)
+
+	ns := c.Namespace("ns")
+	c.Store("Key", "Value", time.Minute)
+	ns.Store("Key", "Value", time.Minute)
+	ns.Store("Other", "Value", time.Minute)
+	ns.Store(42, "Value", time.Minute)
+	c.Namespace("other").Store("Key", "Value", time.Minute)
+	receiveEvents(t, stream, []WatchEvent{
+		{Type: "set", Namespace: "ns", Key: "Key"},
+		{Type: "set", Namespace: "ns", Key: "Other"},
+	})
+
+	s.Close()
+	if err := <-done; err != ErrServerClosed {
+		t.Errorf("Expected Watch to end when the server is closed, but got %v", err)
+	}
+	if err := s.Watch(&WatchRequest{}, stream); err != ErrServerClosed {
+		t.Errorf("Expected Watch to fail once the server is closed, but got %v", err)
+	}
+}
@@ -0,0 +1,211 @@
+//go:build grpc
+
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/status"
+)
+
+// ServiceName is the full name of the service in cache.proto.
+const ServiceName = "mrmorphic.cache.v1.Cache"
+
+// CodecName is the name the codec for the messages of the service is registered under, with
+// encoding.RegisterCodec. Calls of the service must use it as their content subtype, which
+// CallOption does for Go clients; clients in other languages send the content type
+// "application/grpc+cachepb". The messages are encoded in the protobuf wire format, but the codec
+// has its own name so that it doesn't replace the protobuf codec of the other services of a
+// grpc.Server.
+const CodecName = "cachepb"
+
+func init() {
+	encoding.RegisterCodec(codec{})
+}
+
+// cacheServer is the interface a registered service must implement.
+type cacheServer interface {
+	Get(context.Context, *GetRequest) (*GetResponse, error)
+	Set(context.Context, *SetRequest) (*SetResponse, error)
+	Delete(context.Context, *DeleteRequest) (*DeleteResponse, error)
+	Watch(*WatchRequest, WatchStream) error
+}
+
+// RegisterCacheServer registers a Server as the Cache service of a grpc.Server.
+func RegisterCacheServer(r grpc.ServiceRegistrar, s *Server) {
+	r.RegisterService(&serviceDesc, s)
+}
+
+// CallOption returns the option that calls of the service are made with, to encode their messages
+// with the codec registered as CodecName. CacheClient adds it to each call.
+func CallOption() grpc.CallOption {
+	return grpc.CallContentSubtype(CodecName)
+}
+
+// codec is the encoding.Codec registered as CodecName.
+type codec struct{}
+
+func (codec) Name() string {
+	return CodecName
+}
+
+func (codec) Marshal(v interface{}) ([]byte, error) {
+	if m, ok := v.(message); ok {
+		return m.marshal(), nil
+	}
+	return nil, fmt.Errorf("grpc: cannot marshal %T", v)
+}
+
+func (codec) Unmarshal(data []byte, v interface{}) error {
+	if m, ok := v.(message); ok {
+		return m.unmarshal(data)
+	}
+	return fmt.Errorf("grpc: cannot unmarshal %T", v)
+}
+
+// CacheClient calls the Cache service over a gRPC connection. It is created with NewCacheClient.
+type CacheClient struct {
+	cc grpc.ClientConnInterface
+}
+
+// NewCacheClient returns a CacheClient that calls the service over cc.
+func NewCacheClient(cc grpc.ClientConnInterface) *CacheClient {
+	return &CacheClient{cc: cc}
+}
+
+// Get calls the service's Get method.
+func (c *CacheClient) Get(ctx context.Context, req *GetRequest, opts ...grpc.CallOption) (*GetResponse, error) {
+	resp := new(GetResponse)
+	return resp, c.cc.Invoke(ctx, "/"+ServiceName+"/Get", req, resp, append(opts, CallOption())...)
+}
+
+// Set calls the service's Set method.
+func (c *CacheClient) Set(ctx context.Context, req *SetRequest, opts ...grpc.CallOption) (*SetResponse, error) {
+	resp := new(SetResponse)
+	return resp, c.cc.Invoke(ctx, "/"+ServiceName+"/Set", req, resp, append(opts, CallOption())...)
+}
+
+// Delete calls the service's Delete method.
+func (c *CacheClient) Delete(ctx context.Context, req *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error) {
+	resp := new(DeleteResponse)
+	return resp, c.cc.Invoke(ctx, "/"+ServiceName+"/Delete", req, resp, append(opts, CallOption())...)
+}
+
+// Watch calls the service's Watch method, and returns the stream of events, which ends when ctx
+// is done.
+func (c *CacheClient) Watch(ctx context.Context, req *WatchRequest, opts ...grpc.CallOption) (*WatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &serviceDesc.Streams[0], "/"+ServiceName+"/Watch", append(opts, CallOption())...)
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.SendMsg(req); err != nil {
+		return nil, err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return &WatchClient{stream}, nil
+}
+
+// WatchClient is the stream of events of a Watch call.
+type WatchClient struct {
+	grpc.ClientStream
+}
+
+// Recv returns the next event, or the error the stream ended with.
+func (w *WatchClient) Recv() (*WatchEvent, error) {
+	ev := new(WatchEvent)
+	if err := w.RecvMsg(ev); err != nil {
+		return nil, err
+	}
+	return ev, nil
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*cacheServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Get", Handler: getHandler},
+		{MethodName: "Set", Handler: setHandler},
+		{MethodName: "Delete", Handler: deleteHandler},
+	},
+	Streams: []grpc.StreamDesc{
+		{StreamName: "Watch", Handler: watchHandler, ServerStreams: true},
+	},
+	Metadata: "cache.proto",
+}
+
+// unary calls the handler of a unary method through the server's interceptor, if it has one.
+func unary(ctx context.Context, srv interface{}, method string, req interface{}, interceptor grpc.UnaryServerInterceptor, handler grpc.UnaryHandler) (interface{}, error) {
+	if interceptor == nil {
+		return handler(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + ServiceName + "/" + method}
+	return interceptor(ctx, req, info, handler)
+}
+
+func getHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := new(GetRequest)
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	return unary(ctx, srv, "Get", req, interceptor, func(ctx context.Context, req interface{}) (interface{}, error) {
+		resp, err := srv.(*Server).Get(ctx, req.(*GetRequest))
+		return resp, statusError(err)
+	})
+}
+
+func setHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := new(SetRequest)
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	return unary(ctx, srv, "Set", req, interceptor, func(ctx context.Context, req interface{}) (interface{}, error) {
+		resp, err := srv.(*Server).Set(ctx, req.(*SetRequest))
+		return resp, statusError(err)
+	})
+}
+
+func deleteHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := new(DeleteRequest)
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	return unary(ctx, srv, "Delete", req, interceptor, func(ctx context.Context, req interface{}) (interface{}, error) {
+		resp, err := srv.(*Server).Delete(ctx, req.(*DeleteRequest))
+		return resp, statusError(err)
+	})
+}
+
+func watchHandler(srv interface{}, stream grpc.ServerStream) error {
+	req := new(WatchRequest)
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+	return statusError(srv.(*Server).Watch(req, watchStream{stream}))
+}
+
+// watchStream is the WatchStream for the server stream of a Watch call.
+type watchStream struct {
+	grpc.ServerStream
+}
+
+func (s watchStream) Send(ev *WatchEvent) error {
+	return s.SendMsg(ev)
+}
+
+// statusError returns the gRPC status for the errors returned by Server.
+func statusError(err error) error {
+	switch {
+	case errors.Is(err, ErrEmptyKey):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, ErrServerClosed):
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	return err
+}
@@ -0,0 +1,72 @@
+//go:build grpc
+
+package grpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mrmorphic/cache"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestRegisterCacheServer(t *testing.T) {
+	c := cache.NewCache()
+	defer c.Free()
+	s := New(c)
+	defer s.Close()
+
+	// the service is served alongside one using the protobuf codec
+	lis := bufconn.Listen(1 << 16)
+	gs := grpc.NewServer()
+	RegisterCacheServer(gs, s)
+	healthpb.RegisterHealthServer(gs, health.NewServer())
+	go gs.Serve(lis)
+	defer gs.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Expected to connect to the server, but got %v", err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client := NewCacheClient(conn)
+
+	stream, err := client.Watch(ctx, &WatchRequest{Namespace: "ns", Keys: []string{"Key"}})
+	if err != nil {
+		t.Fatalf("Expected to call Watch, but got %v", err)
+	}
+	waitForWatch(s)
+
+	if _, err := client.Set(ctx, &SetRequest{Namespace: "ns", Key: "Key", Value: []byte("Value"), TTLMillis: 60000}); err != nil {
+		t.Fatalf("Expected Set to succeed, but got %v", err)
+	}
+	if resp, err := client.Get(ctx, &GetRequest{Namespace: "ns", Key: "Key"}); err != nil || !resp.Found || string(resp.Value) != "Value" {
+		t.Errorf("Expected cache key 'Key' to have value 'Value', but got %+v and %v", resp, err)
+	}
+	if _, err := client.Get(ctx, &GetRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument for a request without a key, but got %v", err)
+	}
+	if ev, err := stream.Recv(); err != nil || *ev != (WatchEvent{Type: "set", Namespace: "ns", Key: "Key"}) {
+		t.Errorf("Expected a set event for cache key 'Key', but got %+v and %v", ev, err)
+	}
+	if resp, err := client.Delete(ctx, &DeleteRequest{Namespace: "ns", Key: "Key"}); err != nil || !resp.Deleted {
+		t.Errorf("Expected Delete to delete cache key 'Key', but got %+v and %v", resp, err)
+	}
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil || resp.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("Expected the health service to be served with the protobuf codec, but got %v and %v", resp, err)
+	}
+}
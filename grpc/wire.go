@@ -0,0 +1,300 @@
+package grpc
+
+import (
+	"encoding/binary"
+	"errors"
+	"slices"
+)
+
+// The request and response types are encoded in the protobuf wire format, with the field numbers
+// of the messages in cache.proto. Fields with zero values are left out, as they are in proto3, and
+// fields that are not in cache.proto are skipped when decoding, so that clients generated from a
+// later version of it can still call the service.
+
+// message is a request or response type, which encodes itself.
+type message interface {
+	marshal() []byte
+	unmarshal(data []byte) error
+}
+
+// errMalformed is returned when decoding data that is not a valid protobuf message.
+var errMalformed = errors.New("grpc: malformed message")
+
+// the wire types of the fields used by cache.proto, and of those that are skipped.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// appendTag appends the tag of a field.
+func appendTag(b []byte, field, wireType int) []byte {
+	return binary.AppendUvarint(b, uint64(field)<<3|uint64(wireType))
+}
+
+// appendString appends a string or bytes field, unless it is empty.
+func appendString(b []byte, field int, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = appendTag(b, field, wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// appendBytes is appendString for a bytes field.
+func appendBytes(b []byte, field int, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	b = appendTag(b, field, wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// appendVarint appends an integer or bool field, unless it is zero.
+func appendVarint(b []byte, field int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = appendTag(b, field, wireVarint)
+	return binary.AppendUvarint(b, v)
+}
+
+// boolVarint returns the varint encoding of a bool.
+func boolVarint(v bool) uint64 {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+// field is a field decoded from a message. Varint is its value for wireVarint fields, and bytes
+// for wireBytes fields, which refers to the data being decoded.
+type field struct {
+	num      int
+	wireType int
+	varint   uint64
+	bytes    []byte
+}
+
+// decode calls fn with each field of a message in turn.
+func decode(data []byte, fn func(f field) error) error {
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errMalformed
+		}
+		data = data[n:]
+		f := field{num: int(tag >> 3), wireType: int(tag & 7)}
+		switch f.wireType {
+		case wireVarint:
+			f.varint, n = binary.Uvarint(data)
+			if n <= 0 {
+				return errMalformed
+			}
+			data = data[n:]
+		case wireBytes:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || length > uint64(len(data)-n) {
+				return errMalformed
+			}
+			f.bytes, data = data[n:n+int(length)], data[n+int(length):]
+		case wireFixed64, wireFixed32:
+			size := 8
+			if f.wireType == wireFixed32 {
+				size = 4
+			}
+			if len(data) < size {
+				return errMalformed
+			}
+			data = data[size:]
+			continue
+		default:
+			return errMalformed
+		}
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// str returns the value of a string field.
+func (f field) str() (string, error) {
+	if f.wireType != wireBytes {
+		return "", errMalformed
+	}
+	return string(f.bytes), nil
+}
+
+// copyBytes returns a copy of the value of a bytes field.
+func (f field) copyBytes() ([]byte, error) {
+	if f.wireType != wireBytes {
+		return nil, errMalformed
+	}
+	return slices.Clone(f.bytes), nil
+}
+
+// uint returns the value of an integer or bool field.
+func (f field) uint() (uint64, error) {
+	if f.wireType != wireVarint {
+		return 0, errMalformed
+	}
+	return f.varint, nil
+}
+
+// decodeKey decodes the namespace and key fields that the messages for a key have in common, as
+// fields 1 and 2.
+func decodeKey(f field, namespace, key *string) (err error) {
+	switch f.num {
+	case 1:
+		*namespace, err = f.str()
+	case 2:
+		*key, err = f.str()
+	}
+	return err
+}
+
+func (m *GetRequest) marshal() []byte {
+	b := appendString(nil, 1, m.Namespace)
+	return appendString(b, 2, m.Key)
+}
+
+func (m *GetRequest) unmarshal(data []byte) error {
+	*m = GetRequest{}
+	return decode(data, func(f field) error {
+		return decodeKey(f, &m.Namespace, &m.Key)
+	})
+}
+
+func (m *GetResponse) marshal() []byte {
+	b := appendBytes(nil, 1, m.Value)
+	return appendVarint(b, 2, boolVarint(m.Found))
+}
+
+func (m *GetResponse) unmarshal(data []byte) (err error) {
+	*m = GetResponse{}
+	return decode(data, func(f field) error {
+		switch f.num {
+		case 1:
+			m.Value, err = f.copyBytes()
+		case 2:
+			var v uint64
+			v, err = f.uint()
+			m.Found = v != 0
+		}
+		return err
+	})
+}
+
+func (m *SetRequest) marshal() []byte {
+	b := appendString(nil, 1, m.Namespace)
+	b = appendString(b, 2, m.Key)
+	b = appendBytes(b, 3, m.Value)
+	return appendVarint(b, 4, uint64(m.TTLMillis))
+}
+
+func (m *SetRequest) unmarshal(data []byte) (err error) {
+	*m = SetRequest{}
+	return decode(data, func(f field) error {
+		switch f.num {
+		case 3:
+			m.Value, err = f.copyBytes()
+		case 4:
+			var v uint64
+			v, err = f.uint()
+			m.TTLMillis = int64(v)
+		default:
+			err = decodeKey(f, &m.Namespace, &m.Key)
+		}
+		return err
+	})
+}
+
+func (m *SetResponse) marshal() []byte {
+	return nil
+}
+
+func (m *SetResponse) unmarshal(data []byte) error {
+	return decode(data, func(f field) error { return nil })
+}
+
+func (m *DeleteRequest) marshal() []byte {
+	b := appendString(nil, 1, m.Namespace)
+	return appendString(b, 2, m.Key)
+}
+
+func (m *DeleteRequest) unmarshal(data []byte) error {
+	*m = DeleteRequest{}
+	return decode(data, func(f field) error {
+		return decodeKey(f, &m.Namespace, &m.Key)
+	})
+}
+
+func (m *DeleteResponse) marshal() []byte {
+	return appendVarint(nil, 1, boolVarint(m.Deleted))
+}
+
+func (m *DeleteResponse) unmarshal(data []byte) error {
+	*m = DeleteResponse{}
+	return decode(data, func(f field) error {
+		if f.num != 1 {
+			return nil
+		}
+		v, err := f.uint()
+		m.Deleted = v != 0
+		return err
+	})
+}
+
+func (m *WatchRequest) marshal() []byte {
+	b := appendString(nil, 1, m.Namespace)
+	for _, k := range m.Keys {
+		// a repeated string is sent even when it is empty, so that it keeps its place
+		b = appendTag(b, 2, wireBytes)
+		b = binary.AppendUvarint(b, uint64(len(k)))
+		b = append(b, k...)
+	}
+	return b
+}
+
+func (m *WatchRequest) unmarshal(data []byte) (err error) {
+	*m = WatchRequest{}
+	return decode(data, func(f field) error {
+		switch f.num {
+		case 1:
+			m.Namespace, err = f.str()
+		case 2:
+			var k string
+			k, err = f.str()
+			m.Keys = append(m.Keys, k)
+		}
+		return err
+	})
+}
+
+func (m *WatchEvent) marshal() []byte {
+	b := appendString(nil, 1, m.Type)
+	b = appendString(b, 2, m.Namespace)
+	b = appendString(b, 3, m.Key)
+	return appendString(b, 4, m.Reason)
+}
+
+func (m *WatchEvent) unmarshal(data []byte) (err error) {
+	*m = WatchEvent{}
+	return decode(data, func(f field) error {
+		switch f.num {
+		case 1:
+			m.Type, err = f.str()
+		case 2:
+			m.Namespace, err = f.str()
+		case 3:
+			m.Key, err = f.str()
+		case 4:
+			m.Reason, err = f.str()
+		}
+		return err
+	})
+}
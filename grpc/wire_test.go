@@ -0,0 +1,58 @@
+package grpc
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestWireFormat(t *testing.T) {
+	// the encodings protoc's generated code gives the messages in cache.proto
+	tests := []struct {
+		m    message
+		want []byte
+	}{
+		{&GetRequest{Namespace: "ns", Key: "k"}, []byte{0x0a, 2, 'n', 's', 0x12, 1, 'k'}},
+		{&GetResponse{Value: []byte("v"), Found: true}, []byte{0x0a, 1, 'v', 0x10, 1}},
+		{&SetRequest{Key: "k", TTLMillis: 300}, []byte{0x12, 1, 'k', 0x20, 0xac, 0x02}},
+		{&SetRequest{Key: "k", TTLMillis: -1}, []byte{0x12, 1, 'k', 0x20, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}},
+		{&SetResponse{}, nil},
+		{&DeleteRequest{Key: "k"}, []byte{0x12, 1, 'k'}},
+		{&DeleteResponse{Deleted: true}, []byte{0x08, 1}},
+		{&WatchRequest{Namespace: "ns", Keys: []string{"a", ""}}, []byte{0x0a, 2, 'n', 's', 0x12, 1, 'a', 0x12, 0}},
+		{&WatchEvent{Type: "delete", Key: "k", Reason: "expired"}, append(append([]byte{0x0a, 6}, "delete"...), append([]byte{0x1a, 1, 'k', 0x22, 7}, "expired"...)...)},
+	}
+	for _, test := range tests {
+		b := test.m.marshal()
+		if !bytes.Equal(b, test.want) {
+			t.Errorf("Expected %+v to be encoded as %x, but got %x", test.m, test.want, b)
+		}
+		decoded := reflect.New(reflect.TypeOf(test.m).Elem()).Interface().(message)
+		if err := decoded.unmarshal(b); err != nil {
+			t.Errorf("Expected %x to decode, but got %v", b, err)
+		} else if !reflect.DeepEqual(decoded, test.m) {
+			t.Errorf("Expected %x to decode as %+v, but got %+v", b, test.m, decoded)
+		}
+	}
+}
+
+func TestWireUnknownFields(t *testing.T) {
+	// fields 3 to 6, of each wire type, are not in GetRequest
+	data := []byte{0x12, 1, 'k', 0x18, 0x96, 0x01, 0x22, 2, 'x', 'y', 0x29, 1, 2, 3, 4, 5, 6, 7, 8, 0x35, 1, 2, 3, 4}
+	var req GetRequest
+	if err := req.unmarshal(data); err != nil || req.Key != "k" {
+		t.Errorf("Expected unknown fields to be skipped, but got %+v and %v", req, err)
+	}
+
+	for _, data := range [][]byte{
+		{0x12, 5, 'k'},       // truncated
+		{0x12},               // missing length
+		{0x10, 1},            // key as a varint
+		{0x13},               // unsupported wire type
+		{0x0a, 1, 'n', 0x80}, // truncated tag
+	} {
+		if err := req.unmarshal(data); err != errMalformed {
+			t.Errorf("Expected %x to be malformed, but got %v", data, err)
+		}
+	}
+}
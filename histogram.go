@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// histogramBounds are the upper bounds of the buckets of a Histogram, chosen to span the lifetimes
+// typically given to cache entries.
+var histogramBounds = [...]time.Duration{
+	100 * time.Millisecond,
+	time.Second,
+	10 * time.Second,
+	time.Minute,
+	5 * time.Minute,
+	15 * time.Minute,
+	time.Hour,
+	6 * time.Hour,
+	24 * time.Hour,
+}
+
+// Histogram is a distribution of durations, as reported in Stats. Counts[i] is the number of
+// durations no longer than Bounds()[i] and longer than the bound before it, and the last count is
+// the number longer than every bound.
+type Histogram struct {
+	Counts [len(histogramBounds) + 1]uint64
+
+	// Count is the number of durations, and Sum their total.
+	Count uint64
+	Sum   time.Duration
+}
+
+// Bounds returns the upper bounds of the buckets, from 100ms up to a day.
+func (h Histogram) Bounds() []time.Duration {
+	return histogramBounds[:]
+}
+
+// Mean returns the average of the durations, or 0 if there are none.
+func (h Histogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// Quantile returns an upper bound on the given quantile of the durations, such as 0.9 for the
+// duration that 90% of them are no longer than: the bound of the bucket the quantile falls in. It
+// returns the largest bound if the quantile is longer than every bound, and 0 if there are no
+// durations.
+func (h Histogram) Quantile(q float64) time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	rank := max(uint64(math.Ceil(min(max(q, 0), 1)*float64(h.Count))), 1)
+	var seen uint64
+	for i, n := range h.Counts[:len(histogramBounds)] {
+		seen += n
+		if seen >= rank {
+			return histogramBounds[i]
+		}
+	}
+	return histogramBounds[len(histogramBounds)-1]
+}
+
+// add returns the histogram with the durations of another added.
+func (h Histogram) add(other Histogram) Histogram {
+	for i, n := range other.Counts {
+		h.Counts[i] += n
+	}
+	h.Count += other.Count
+	h.Sum += other.Sum
+	return h
+}
+
+// histogram is a Histogram that durations are recorded in as the cache is used.
+type histogram struct {
+	counts [len(histogramBounds) + 1]atomic.Uint64
+	count  atomic.Uint64
+	sum    atomic.Int64
+}
+
+// observe records a duration.
+func (h *histogram) observe(d time.Duration) {
+	i := 0
+	for i < len(histogramBounds) && d > histogramBounds[i] {
+		i++
+	}
+	h.counts[i].Add(1)
+	h.count.Add(1)
+	h.sum.Add(int64(d))
+}
+
+// snapshot returns the durations recorded so far.
+func (h *histogram) snapshot() Histogram {
+	var s Histogram
+	for i := range h.counts {
+		s.Counts[i] = h.counts[i].Load()
+	}
+	s.Count = h.count.Load()
+	s.Sum = time.Duration(h.sum.Load())
+	return s
+}
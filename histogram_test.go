@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistograms(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cache := NewCache(WithShards(1), WithClock(clock))
+	cache.SetMaxBytes(30)
+
+	cache.Store("a", "a", time.Minute, WithSize(10))
+	cache.Store("b", "b", 2*time.Hour, WithSize(10))
+	cache.Store("forever", "forever", NoExpiry, WithSize(10))
+
+	clock.Add(3 * time.Second)
+	cache.Get("a")
+	cache.Get("a")
+
+	// storing another evicts b, which is 3 seconds old
+	cache.Store("c", "c", time.Minute, WithSize(10))
+
+	st := cache.Stats()
+	if st.Lifetimes.Count != 3 || st.Lifetimes.Sum != 2*time.Hour+2*time.Minute {
+		t.Errorf("Expected 3 lifetimes adding up to 2h2m, but got %d adding up to %v", st.Lifetimes.Count, st.Lifetimes.Sum)
+	}
+	if q := st.Lifetimes.Quantile(0.5); q != time.Minute {
+		t.Errorf("Expected the median lifetime to be within a minute, but got %v", q)
+	}
+	if q := st.Lifetimes.Quantile(1); q != 6*time.Hour {
+		t.Errorf("Expected the longest lifetime to be within 6h, but got %v", q)
+	}
+	if st.FirstHits.Count != 1 || st.FirstHits.Mean() != 3*time.Second {
+		t.Errorf("Expected a single first hit after 3s, but got %d with mean %v", st.FirstHits.Count, st.FirstHits.Mean())
+	}
+	if st.EvictionAges.Count != 1 || st.EvictionAges.Sum != 3*time.Second {
+		t.Errorf("Expected a single eviction at 3s old, but got %d adding up to %v", st.EvictionAges.Count, st.EvictionAges.Sum)
+	}
+
+	total := sumStats(func(yield func(Stats) bool) {
+		yield(st)
+		yield(st)
+	})
+	if total.Lifetimes.Count != 6 || total.Lifetimes.Counts != [len(histogramBounds) + 1]uint64{0, 0, 0, 4, 0, 0, 0, 2} {
+		t.Errorf("Expected the lifetimes to be added together, but got %v", total.Lifetimes)
+	}
+}
+
+func TestHistogramQuantile(t *testing.T) {
+	var h histogram
+	if q := h.snapshot().Quantile(0.5); q != 0 {
+		t.Errorf("Expected no quantile of an empty histogram, but got %v", q)
+	}
+	h.observe(50 * time.Millisecond)
+	h.observe(48 * time.Hour)
+	if q := h.snapshot().Quantile(0.5); q != 100*time.Millisecond {
+		t.Errorf("Expected the median to be within 100ms, but got %v", q)
+	}
+	if q := h.snapshot().Quantile(0.99); q != 24*time.Hour {
+		t.Errorf("Expected durations longer than every bound to report the largest, but got %v", q)
+	}
+}
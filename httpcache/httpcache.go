@@ -0,0 +1,277 @@
+// Package httpcache provides net/http middleware that caches responses in a cache.Cache. Responses
+// to GET and HEAD requests are cached for the lifetime given by their Cache-Control header, and
+// separately for each combination of the request headers named in their Vary header. Cached
+// responses can be purged by path, or all at once.
+package httpcache
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mrmorphic/cache"
+)
+
+// Handler is an http.Handler that serves responses from a cache, and passes requests for responses
+// that are not cached to another handler. It is created with New or Middleware.
+type Handler struct {
+	c          *cache.Cache
+	ns         *cache.Namespace
+	name       string
+	next       http.Handler
+	defaultTTL time.Duration
+}
+
+// Option configures a Handler.
+type Option func(*Handler)
+
+// WithName sets the name of the cache.Namespace the handler's responses are stored in. The
+// default is "httpcache". Handlers sharing a cache should be given different names, unless they
+// are meant to share responses.
+func WithName(name string) Option {
+	return func(h *Handler) {
+		h.name = name
+	}
+}
+
+// WithDefaultTTL sets how long responses that have no max-age or s-maxage in their Cache-Control
+// header are cached. The default is zero, which means they are not cached.
+func WithDefaultTTL(d time.Duration) Option {
+	return func(h *Handler) {
+		h.defaultTTL = d
+	}
+}
+
+// New returns a Handler that caches the responses of next in c.
+func New(c *cache.Cache, next http.Handler, opts ...Option) *Handler {
+	h := &Handler{c: c, name: "httpcache", next: next}
+	for _, opt := range opts {
+		opt(h)
+	}
+	h.ns = c.Namespace(h.name)
+	return h
+}
+
+// Middleware returns a function that wraps a handler in a Handler, for use with routers that
+// take middleware in that form. Use New instead to keep the Handler, so responses can be purged.
+func Middleware(c *cache.Cache, opts ...Option) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return New(c, next, opts...)
+	}
+}
+
+// response is a cached response.
+type response struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// varyKey is the key under which the names of the headers a URL's responses vary by are stored.
+type varyKey struct {
+	method string
+	url    string
+}
+
+// responseKey is the key under which a response is stored.
+type responseKey struct {
+	method string
+	url    string
+	vary   string
+}
+
+// ServeHTTP serves the cached response for the request if there is one, and otherwise passes the
+// request to the next handler, caching its response if it may be. Requests with an Authorization
+// header or "Cache-Control: no-cache" are always passed on.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if (r.Method != http.MethodGet && r.Method != http.MethodHead) || r.Header.Get("Authorization") != "" {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	url := r.Host + r.URL.RequestURI()
+	vk := varyKey{r.Method, url}
+	noCache := hasDirective(r.Header.Get("Cache-Control"), "no-cache")
+
+	if !noCache {
+		if names, ok := h.ns.Get(vk).([]string); ok {
+			if resp, ok := h.ns.Get(responseKey{r.Method, url, varyValues(r, names)}).(*response); ok {
+				writeResponse(w, resp, "HIT")
+				return
+			}
+		}
+	}
+
+	rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+	w.Header().Set("X-Cache", "MISS")
+	h.next.ServeHTTP(rec, r)
+
+	header := rec.sent()
+	ttl, ok := h.lifetime(rec.status, header)
+	if !ok {
+		return
+	}
+	names := varyNames(header)
+	if names == nil {
+		return
+	}
+	resp := &response{status: rec.status, header: header, body: rec.body.Bytes()}
+	resp.header.Del("X-Cache")
+	tag := cache.WithTags(h.tag(r.URL.Path))
+	h.ns.Store(vk, names, ttl, tag)
+	h.ns.Store(responseKey{r.Method, url, varyValues(r, names)}, resp, ttl, tag, cache.WithSize(int64(len(resp.body))))
+}
+
+// Purge removes the cached responses for a path, for any host, query string or variant.
+func (h *Handler) Purge(path string) {
+	h.c.InvalidateTag(h.tag(path))
+}
+
+// PurgeAll removes all the handler's cached responses.
+func (h *Handler) PurgeAll() {
+	h.ns.Invalidate()
+}
+
+// tag returns the tag the handler's responses for a path are stored with.
+func (h *Handler) tag(path string) string {
+	return h.name + " " + path
+}
+
+// lifetime returns how long a response with the given status and header may be cached, and false
+// if it may not be.
+func (h *Handler) lifetime(status int, header http.Header) (time.Duration, bool) {
+	switch status {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent, http.StatusMovedPermanently,
+		http.StatusNotFound, http.StatusGone:
+	default:
+		return 0, false
+	}
+	if header.Get("Set-Cookie") != "" {
+		return 0, false
+	}
+	cc := header.Get("Cache-Control")
+	if hasDirective(cc, "no-store") || hasDirective(cc, "no-cache") || hasDirective(cc, "private") {
+		return 0, false
+	}
+	for _, name := range []string{"s-maxage", "max-age"} {
+		if v, ok := directive(cc, name); ok {
+			seconds, err := strconv.Atoi(v)
+			if err != nil || seconds <= 0 {
+				return 0, false
+			}
+			return time.Duration(seconds) * time.Second, true
+		}
+	}
+	return h.defaultTTL, h.defaultTTL > 0
+}
+
+// directive returns the value of the named directive in a Cache-Control header.
+func directive(cc, name string) (string, bool) {
+	for _, d := range strings.Split(cc, ",") {
+		d = strings.TrimSpace(d)
+		k, v, _ := strings.Cut(d, "=")
+		if strings.EqualFold(k, name) {
+			return strings.Trim(v, `"`), true
+		}
+	}
+	return "", false
+}
+
+// hasDirective returns true if the named directive is present in a Cache-Control header.
+func hasDirective(cc, name string) bool {
+	_, ok := directive(cc, name)
+	return ok
+}
+
+// varyNames returns the canonical names of the headers in a response's Vary header, or nil if the
+// response varies by "*" and so can't be cached.
+func varyNames(header http.Header) []string {
+	names := []string{}
+	for _, v := range header.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			name = strings.TrimSpace(name)
+			if name == "*" {
+				return nil
+			}
+			if name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	return names
+}
+
+// varyValues returns the values of the named headers in a request, joined into a single string.
+func varyValues(r *http.Request, names []string) string {
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.WriteString(strings.Join(r.Header.Values(name), ","))
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// writeResponse writes a cached response, with an X-Cache header saying where it came from.
+func writeResponse(w http.ResponseWriter, resp *response, source string) {
+	header := w.Header()
+	for k, v := range resp.header {
+		header[k] = append([]string(nil), v...)
+	}
+	header.Set("X-Cache", source)
+	w.WriteHeader(resp.status)
+	w.Write(resp.body)
+}
+
+// recorder is an http.ResponseWriter that passes a response through while keeping a copy of its
+// status, header and body. The header is copied when it is written, so changes the handler makes
+// to it afterwards, which aren't sent to the client, aren't cached either.
+type recorder struct {
+	http.ResponseWriter
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if r.header == nil {
+		r.status = status
+		r.header = r.ResponseWriter.Header().Clone()
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	if r.header == nil {
+		r.WriteHeader(http.StatusOK)
+	}
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+// Flush sends the response so far to the client, if the ResponseWriter supports it, so that
+// streaming handlers still stream.
+func (r *recorder) Flush() {
+	if r.header == nil {
+		r.WriteHeader(http.StatusOK)
+	}
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the ResponseWriter, for http.ResponseController.
+func (r *recorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// sent returns a copy of the header as it was sent to the client, or as it is now if nothing was
+// written.
+func (r *recorder) sent() http.Header {
+	if r.header == nil {
+		return r.ResponseWriter.Header().Clone()
+	}
+	return r.header
+}
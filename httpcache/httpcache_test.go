@@ -0,0 +1,148 @@
+package httpcache
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mrmorphic/cache"
+)
+
+// countingHandler returns a handler that writes how many times it has been called, with the given
+// Cache-Control and Vary headers.
+func countingHandler(cacheControl, vary string) http.Handler {
+	n := 0
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		w.Header().Set("Cache-Control", cacheControl)
+		if vary != "" {
+			w.Header().Set("Vary", vary)
+		}
+		fmt.Fprintf(w, "%d %s", n, r.Header.Get("Accept-Language"))
+	})
+}
+
+func get(h http.Handler, url string, header ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, url, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestCachesResponse(t *testing.T) {
+	c := cache.NewCache()
+	defer c.Free()
+	h := New(c, countingHandler("max-age=60", ""))
+
+	first := get(h, "/page")
+	second := get(h, "/page")
+	if first.Body.String() != "1 " || second.Body.String() != "1 " {
+		t.Errorf("Expected the second response to be cached, but got '%s' then '%s'", first.Body, second.Body)
+	}
+	if first.Header().Get("X-Cache") != "MISS" || second.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("Expected X-Cache to be MISS then HIT, but got '%s' then '%s'", first.Header().Get("X-Cache"), second.Header().Get("X-Cache"))
+	}
+	if second.Header().Get("Cache-Control") != "max-age=60" {
+		t.Errorf("Expected the cached response to keep its headers, but got Cache-Control '%s'", second.Header().Get("Cache-Control"))
+	}
+	if other := get(h, "/page?q=1"); other.Body.String() != "2 " {
+		t.Errorf("Expected a different query to be a separate response, but got '%s'", other.Body)
+	}
+}
+
+func TestNotCached(t *testing.T) {
+	for _, cc := range []string{"no-store", "private, max-age=60", "no-cache", ""} {
+		c := cache.NewCache()
+		h := New(c, countingHandler(cc, ""))
+		get(h, "/page")
+		if w := get(h, "/page"); w.Body.String() != "2 " {
+			t.Errorf("Did not expect a response with Cache-Control '%s' to be cached, but got '%s'", cc, w.Body)
+		}
+		c.Free()
+	}
+}
+
+func TestDefaultTTL(t *testing.T) {
+	c := cache.NewCache()
+	defer c.Free()
+	h := New(c, countingHandler("", ""), WithDefaultTTL(time.Minute))
+	get(h, "/page")
+	if w := get(h, "/page"); w.Body.String() != "1 " {
+		t.Errorf("Expected a response without Cache-Control to be cached with the default TTL, but got '%s'", w.Body)
+	}
+}
+
+func TestVary(t *testing.T) {
+	c := cache.NewCache()
+	defer c.Free()
+	h := New(c, countingHandler("max-age=60", "Accept-Language"))
+
+	get(h, "/page", "Accept-Language", "en")
+	get(h, "/page", "Accept-Language", "fr")
+	if w := get(h, "/page", "Accept-Language", "en"); w.Body.String() != "1 en" {
+		t.Errorf("Expected the 'en' variant to be cached, but got '%s'", w.Body)
+	}
+	if w := get(h, "/page", "Accept-Language", "fr"); w.Body.String() != "2 fr" {
+		t.Errorf("Expected the 'fr' variant to be cached, but got '%s'", w.Body)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	c := cache.NewCache()
+	defer c.Free()
+	h := New(c, countingHandler("max-age=60", ""))
+
+	get(h, "/page")
+	get(h, "/page?q=1")
+	get(h, "/other")
+	h.Purge("/page")
+	if w := get(h, "/page"); w.Body.String() != "4 " {
+		t.Errorf("Expected '/page' to be purged, but got '%s'", w.Body)
+	}
+	if w := get(h, "/other"); w.Body.String() != "3 " {
+		t.Errorf("Did not expect '/other' to be purged, but got '%s'", w.Body)
+	}
+	h.PurgeAll()
+	if w := get(h, "/other"); w.Body.String() != "5 " {
+		t.Errorf("Expected '/other' to be purged by PurgeAll, but got '%s'", w.Body)
+	}
+}
+
+func TestAuthorizationNotCached(t *testing.T) {
+	c := cache.NewCache()
+	defer c.Free()
+	h := New(c, countingHandler("max-age=60", ""))
+	get(h, "/page", "Authorization", "Bearer x")
+	if w := get(h, "/page", "Authorization", "Bearer x"); w.Body.String() != "2 " {
+		t.Errorf("Did not expect a request with Authorization to be cached, but got '%s'", w.Body)
+	}
+}
+
+func TestHeaderAsSent(t *testing.T) {
+	c := cache.NewCache()
+	defer c.Free()
+	h := New(c, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		fmt.Fprint(w, "streamed")
+		w.(http.Flusher).Flush()
+		// too late to be sent, so not cached either
+		w.Header().Set("X-Late", "late")
+	}))
+
+	first := get(h, "/page")
+	if !first.Flushed {
+		t.Errorf("Expected Flush to be passed through to the ResponseWriter")
+	}
+	second := get(h, "/page")
+	if second.Body.String() != "streamed" || second.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("Expected the flushed response to be cached, but got '%s'", second.Body)
+	}
+	if late := second.Header().Get("X-Late"); late != "" {
+		t.Errorf("Did not expect a header set after the response was written to be cached, but got '%s'", late)
+	}
+}
@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"reflect"
+	"slices"
+	"time"
+)
+
+// EntryInfo describes an entry in the cache, as returned by Inspect.
+type EntryInfo struct {
+	// Created is when the entry was stored. Storing under the same key again creates a new entry,
+	// but changing the value with Update, CompareAndSwap or Increment doesn't.
+	Created time.Time
+
+	// Refreshed is when the value of a perpetual entry was last generated, or zero for other
+	// entries.
+	Refreshed time.Time
+
+	// LastAccessed is when the entry was last retrieved, or zero if it never has been.
+	LastAccessed time.Time
+
+	// Hits is the number of times the entry has been retrieved.
+	Hits uint64
+
+	// Expiry is when the entry expires, or for perpetual entries when they are next regenerated.
+	// It is zero for entries that never expire and those stored with StoreWithPolicy.
+	Expiry time.Time
+
+	// Stale is true if the entry has passed the soft lifetime given by WithSoftTTL, or has been
+	// invalidated by InvalidateAndRefresh.
+	Stale bool
+
+	// Size is the estimated size of the value in bytes.
+	Size int64
+
+	// Perpetual and Negative are true for entries stored with StorePerpetual (or one of its
+	// variants) and StoreNegative.
+	Perpetual bool
+	Negative  bool
+
+	// Pinned is true if the entry's key is pinned with Pin, and Priority is the priority given to
+	// the entry with WithPriority.
+	Pinned   bool
+	Priority Priority
+
+	// Tags are the tags given to the entry with WithTags.
+	Tags []string
+
+	// Version is the version of the value, as returned by GetVersioned.
+	Version uint64
+
+	// Type is the type the value was stored with by StoreTyped, or nil for entries stored
+	// otherwise.
+	Type reflect.Type
+
+	// Failures is the number of consecutive times the generator of a perpetual entry has failed,
+	// and BreakerOpen is true if that has opened the circuit breaker set by WithCircuitBreaker.
+	Failures    int
+	BreakerOpen bool
+}
+
+// Inspect returns information about the entry for a key, and true, or false if there is no entry
+// for the key or it has expired. It does not count as retrieving the entry.
+func (c *Cache) Inspect(key interface{}) (EntryInfo, bool) {
+	now := c.clock.Now()
+	s := c.shardFor(key)
+	s.RLock()
+	defer s.RUnlock()
+	entry := s.entries[key]
+	if entry == nil || entry.expiredAt(now) {
+		return EntryInfo{}, false
+	}
+	_, pinned := s.pinned[key]
+	info := EntryInfo{
+		Created:     entry.created,
+		Refreshed:   entry.refreshed,
+		Hits:        entry.hits.Load(),
+		Expiry:      entry.expiry,
+		Stale:       entry.staleAt(now),
+		Size:        entry.size,
+		Perpetual:   entry.perpetual,
+		Negative:    entry.negative,
+		Tags:        slices.Clone(entry.tags),
+		Pinned:      pinned,
+		Priority:    entry.priority,
+		Version:     entry.version,
+		Type:        entry.typ,
+		Failures:    entry.failures,
+		BreakerOpen: entry.breakerOpen(),
+	}
+	if accessed := entry.accessed.Load(); accessed != 0 {
+		info.LastAccessed = time.Unix(0, accessed)
+	}
+	return info, true
+}
+
+// hit records that the entry has been retrieved at the given time, and returns true if this is the
+// first time. It is safe to call with only the shard's read lock held, or without it.
+func (e *CacheEntry) hit(now time.Time) bool {
+	e.accessed.Store(now.UnixNano())
+	return e.hits.Add(1) == 1
+}
@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInspect(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cache := NewCache(WithClock(clock))
+	defer cache.Free()
+	stored := clock.Now()
+
+	if _, ok := cache.Inspect("Key"); ok {
+		t.Errorf("Did not expect to inspect missing cache key 'Key'")
+	}
+
+	cache.Store("Key", "Value", time.Minute, WithTags("tag"))
+	info, ok := cache.Inspect("Key")
+	if !ok {
+		t.Fatalf("Expected to inspect cache key 'Key'")
+	}
+	if !info.Created.Equal(stored) || !info.Expiry.Equal(stored.Add(time.Minute)) {
+		t.Errorf("Expected cache key 'Key' to be created at %v and expire at %v, but got %v and %v", stored, stored.Add(time.Minute), info.Created, info.Expiry)
+	}
+	if info.Hits != 0 || !info.LastAccessed.IsZero() {
+		t.Errorf("Did not expect cache key 'Key' to have been accessed, but got %d hits, last at %v", info.Hits, info.LastAccessed)
+	}
+	if len(info.Tags) != 1 || info.Tags[0] != "tag" || info.Perpetual || info.Negative {
+		t.Errorf("Expected a non-perpetual entry with tag 'tag', but got %+v", info)
+	}
+
+	clock.Add(time.Second)
+	cache.Get("Key")
+	clock.Add(time.Second)
+	cache.Get("Key")
+	cache.Get("Missing")
+	info, _ = cache.Inspect("Key")
+	if info.Hits != 2 || !info.LastAccessed.Equal(stored.Add(2*time.Second)) {
+		t.Errorf("Expected 2 hits, last at %v, but got %d hits, last at %v", stored.Add(2*time.Second), info.Hits, info.LastAccessed)
+	}
+
+	clock.Add(time.Minute)
+	if _, ok := cache.Inspect("Key"); ok {
+		t.Errorf("Did not expect to inspect expired cache key 'Key'")
+	}
+}
+
+func TestInspectPerpetual(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cache := NewCache(WithClock(clock), WithSyncMap())
+	defer cache.Free()
+	stored := clock.Now()
+
+	cache.StorePerpetual("Key", counter(0), time.Minute)
+	clock.Add(time.Second)
+	cache.Get("Key")
+	if err := cache.Refresh("Key"); err != nil {
+		t.Fatalf("Expected Refresh to succeed, but got %v", err)
+	}
+	info, ok := cache.Inspect("Key")
+	if !ok || !info.Perpetual {
+		t.Fatalf("Expected to inspect perpetual cache key 'Key', but got %+v, %v", info, ok)
+	}
+	if !info.Created.Equal(stored) || !info.Refreshed.Equal(stored.Add(time.Second)) {
+		t.Errorf("Expected cache key 'Key' to be created at %v and refreshed at %v, but got %v and %v", stored, stored.Add(time.Second), info.Created, info.Refreshed)
+	}
+	if info.Hits != 1 || !info.LastAccessed.Equal(stored.Add(time.Second)) {
+		t.Errorf("Expected 1 hit read without a lock, but got %d hits, last at %v", info.Hits, info.LastAccessed)
+	}
+}
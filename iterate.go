@@ -0,0 +1,57 @@
+package cache
+
+// Len returns the number of entries in the cache. This may include entries that have expired but
+// not yet been removed by the sweep.
+func (c *Cache) Len() int {
+	n := 0
+	for _, s := range c.shards {
+		s.RLock()
+		n += len(s.entries)
+		s.RUnlock()
+	}
+	return n
+}
+
+// Keys returns the keys of all the entries in the cache that have not expired, in no particular
+// order.
+func (c *Cache) Keys() []interface{} {
+	var keys []interface{}
+	for _, s := range c.shards {
+		now := c.clock.Now()
+		s.RLock()
+		for key, entry := range s.entries {
+			if c.staleReads || !entry.expiredAt(now) {
+				keys = append(keys, key)
+			}
+		}
+		s.RUnlock()
+	}
+	return keys
+}
+
+// Range calls fn for each entry in the cache that has not expired, in no particular order, until
+// fn returns false. Each shard is copied while it is locked and fn is called on the copy, so fn may
+// use the cache, and changes made while Range runs may or may not be seen. Range does not count as
+// an access to the entries, so it does not affect the hit statistics or the eviction order.
+func (c *Cache) Range(fn func(key, value interface{}) bool) {
+	type pair struct {
+		key   interface{}
+		value interface{}
+	}
+	for _, s := range c.shards {
+		now := c.clock.Now()
+		s.RLock()
+		pairs := make([]pair, 0, len(s.entries))
+		for key, entry := range s.entries {
+			if c.staleReads || !entry.expiredAt(now) {
+				pairs = append(pairs, pair{key, s.value(entry)})
+			}
+		}
+		s.RUnlock()
+		for _, p := range pairs {
+			if !fn(p.key, c.clone(p.value)) {
+				return
+			}
+		}
+	}
+}
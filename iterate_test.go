@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestKeysAndRange(t *testing.T) {
+	cache := NewShardedCache(4)
+	cache.Store("a", 1, time.Minute)
+	cache.Store("b", 2, time.Minute)
+	cache.Store("c", 3, time.Minute)
+	cache.Store("expired", 4, time.Millisecond)
+	time.Sleep(time.Millisecond * 5)
+
+	if n := cache.Len(); n != 4 {
+		t.Errorf("Expected 4 entries before the sweep, but got %d", n)
+	}
+
+	var keys []string
+	for _, key := range cache.Keys() {
+		keys = append(keys, key.(string))
+	}
+	sort.Strings(keys)
+	if len(keys) != 3 || keys[0] != "a" || keys[1] != "b" || keys[2] != "c" {
+		t.Errorf("Expected keys a, b and c, but got %v", keys)
+	}
+
+	total := 0
+	cache.Range(func(key, value interface{}) bool {
+		// the shard is not locked, so the cache can be modified during Range
+		cache.Delete(key)
+		total += value.(int)
+		return true
+	})
+	if total != 6 {
+		t.Errorf("Expected values to total 6, but got %d", total)
+	}
+
+	calls := 0
+	cache.Store("a", 1, time.Minute)
+	cache.Store("b", 2, time.Minute)
+	cache.Range(func(key, value interface{}) bool {
+		calls++
+		return false
+	})
+	if calls != 1 {
+		t.Errorf("Expected Range to stop after fn returned false, but it was called %d times", calls)
+	}
+}
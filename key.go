@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// keyEscaper escapes the characters of Key's parts that would otherwise make different parts
+// produce the same key.
+var keyEscaper = strings.NewReplacer("%", "%25", PathSeparator, "%2F")
+
+// Key builds a string key from parts, such as Key("user", 42, "profile") for "user/42/profile".
+// The parts are joined with PathSeparator, so that DeletePrefix can delete keys by their leading
+// parts, and separators and escapes within a part are escaped, so that different parts always give
+// different keys. Parts can be strings, integers, floats, booleans, byte slices and fmt.Stringers;
+// Key panics if given a part of another type, whose formatting might not be stable.
+func Key(parts ...interface{}) string {
+	var b strings.Builder
+	for i, part := range parts {
+		if i > 0 {
+			b.WriteString(PathSeparator)
+		}
+		b.WriteString(keyEscaper.Replace(keyPart(part)))
+	}
+	return b.String()
+}
+
+// keyPart formats a part of a key built with Key.
+func keyPart(part interface{}) string {
+	switch v := part.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case fmt.Stringer:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	switch v := reflect.ValueOf(part); v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits())
+	}
+	panic(fmt.Sprintf("cache: key part %v has unsupported type %T", part, part))
+}
+
+// keyHashLen is the length of the hash that ShortenKey ends long keys with.
+const keyHashLen = 32
+
+// ShortenKey returns a key no longer than maxLen bytes, for stores and protocols that limit the
+// length of keys, such as memcached's 250. Keys that already fit are returned unchanged; longer
+// ones are cut short, without splitting a UTF-8 character, and end with "#" and a hash of the whole
+// key, so that they stay distinct. A maxLen too short to hold the hash as well gives as much of the
+// hash as fits. It panics if maxLen is not positive.
+func ShortenKey(key string, maxLen int) string {
+	if maxLen <= 0 {
+		panic("cache: non-positive length for ShortenKey")
+	}
+	if len(key) <= maxLen {
+		return key
+	}
+	sum := sha256.Sum256([]byte(key))
+	hash := hex.EncodeToString(sum[:])[:keyHashLen]
+	keep := maxLen - keyHashLen - 1
+	if keep <= 0 {
+		return hash[:min(maxLen, keyHashLen)]
+	}
+	for keep > 0 && !utf8.RuneStart(key[keep]) {
+		keep--
+	}
+	return key[:keep] + "#" + hash
+}
+
+// KeyError is the error for a key that can't be used in a cache because it isn't comparable, such
+// as a slice or a struct holding a map.
+type KeyError struct {
+	Key interface{}
+}
+
+func (e *KeyError) Error() string {
+	return fmt.Sprintf("cache: key of type %T is not comparable", e.Key)
+}
+
+// CheckKey returns a *KeyError if a key can't be used in a cache because it isn't comparable,
+// including keys of interface or struct types that hold values that aren't, and nil otherwise.
+func CheckKey(key interface{}) error {
+	if key != nil && !reflect.ValueOf(key).Comparable() {
+		return &KeyError{Key: key}
+	}
+	return nil
+}
+
+// WithKeyCheck makes the cache check every key it is given with CheckKey, and panic with the
+// *KeyError for keys that aren't comparable, rather than with a runtime error from deep within the
+// cache's maps. Checking keys takes a little time on every call, so it is best suited to tests
+// and to caches whose keys come from callers that can't be trusted to get them right.
+func WithKeyCheck() Option {
+	return func(c *Cache) {
+		c.checkKeys = true
+	}
+}
@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+type keyID int
+
+func (id keyID) String() string {
+	return fmt.Sprintf("id-%d", int(id))
+}
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		parts []interface{}
+		want  string
+	}{
+		{[]interface{}{"user", 42, "profile"}, "user/42/profile"},
+		{[]interface{}{"a/b", "c"}, "a%2Fb/c"},
+		{[]interface{}{"a", "b/c"}, "a/b%2Fc"},
+		{[]interface{}{"100%", uint8(7), int64(-3)}, "100%25/7/-3"},
+		{[]interface{}{1.5, true, []byte("raw"), keyID(3)}, "1.5/true/raw/id-3"},
+		{nil, ""},
+	}
+	for _, test := range tests {
+		if got := Key(test.parts...); got != test.want {
+			t.Errorf("Expected Key(%v) to be '%s', but got '%s'", test.parts, test.want, got)
+		}
+	}
+
+	cache := NewCache()
+	defer cache.Free()
+	cache.Store(Key("user", 42, "profile"), "profile", time.Minute)
+	cache.Store(Key("user", 42, "settings"), "settings", time.Minute)
+	if n := cache.DeletePrefix(Key("user", 42)); n != 2 {
+		t.Errorf("Expected DeletePrefix to delete the keys built under the prefix, but it deleted %d", n)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected Key to panic for a part of an unsupported type")
+		}
+	}()
+	Key("user", &struct{}{})
+}
+
+func TestShortenKey(t *testing.T) {
+	if got := ShortenKey("short", 250); got != "short" {
+		t.Errorf("Expected a short key to be unchanged, but got '%s'", got)
+	}
+	long := strings.Repeat("x", 300)
+	got := ShortenKey(long, 250)
+	if len(got) != 250 || !strings.HasPrefix(got, "xxx") {
+		t.Errorf("Expected the long key to be shortened to 250 bytes, but got %d: '%s'", len(got), got)
+	}
+	if other := ShortenKey(long+"y", 250); other == got {
+		t.Errorf("Expected long keys with the same prefix to be shortened to different keys")
+	}
+	if got := ShortenKey(long, 40); len(got) != 40 || got[:8] != "xxxxxxx#" {
+		t.Errorf("Expected the long key to be shortened to 40 bytes, but got '%s'", got)
+	}
+	if got := ShortenKey(long, 10); len(got) != 10 || !strings.HasPrefix(ShortenKey(long, 33), got) {
+		t.Errorf("Expected a length too short for the hash to give as much of it as fits, but got '%s'", got)
+	}
+
+	// the key is cut before a character that doesn't fit, rather than through it
+	accented := strings.Repeat("é", 100)
+	got = ShortenKey(accented, 40)
+	if !utf8.ValidString(got) || len(got) != 39 || !strings.HasPrefix(got, "éé") {
+		t.Errorf("Expected the key to be cut at the start of a character, but got '%s'", got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected ShortenKey to panic for a length of 0")
+		}
+	}()
+	ShortenKey(long, 0)
+}
+
+func TestKeyCheck(t *testing.T) {
+	type composite struct {
+		name string
+		part interface{}
+	}
+	if err := CheckKey(composite{"a", 1}); err != nil {
+		t.Errorf("Expected a comparable key to pass the check, but got %v", err)
+	}
+	var keyErr *KeyError
+	if err := CheckKey(composite{"a", []int{1}}); !errors.As(err, &keyErr) {
+		t.Errorf("Expected a *KeyError for a struct holding a slice, but got %v", err)
+	}
+
+	cache := NewCache(WithShards(1), WithKeyCheck())
+	defer cache.Free()
+	cache.Store("Key", "value", time.Minute)
+	defer func() {
+		err, _ := recover().(error)
+		if !errors.As(err, &keyErr) || err.Error() != "cache: key of type []string is not comparable" {
+			t.Errorf("Expected Store to panic with a *KeyError for a slice key, but got %v", err)
+		}
+	}()
+	cache.Store([]string{"a"}, "value", time.Minute)
+}
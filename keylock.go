@@ -0,0 +1,48 @@
+package cache
+
+import "sync"
+
+// keyLocks holds the mutexes given out by LockKey for the keys of one shard. A key's mutex only
+// exists while it is locked or waited for, so the table doesn't grow with the number of keys.
+type keyLocks struct {
+	mu    sync.Mutex
+	locks map[interface{}]*keyLock
+}
+
+// keyLock is the mutex for a key, and the number of callers holding or waiting for it.
+type keyLock struct {
+	sync.Mutex
+	refs int
+}
+
+// LockKey locks a mutex that is scoped to key, waiting until it is available, and returns a
+// function that unlocks it, which must be called exactly once. It lets callers serialise work on a
+// key, such as an expensive recomputation of its value or a read-modify-write made of several
+// calls, without holding up other keys. The mutex is separate from the cache's own locking: it
+// doesn't stop other goroutines that don't lock the key from using its entry, and holding it
+// doesn't block any cache operation. The key doesn't need to have an entry.
+func (c *Cache) LockKey(key interface{}) (unlock func()) {
+	kl := &c.shardFor(key).keyLocks
+	kl.mu.Lock()
+	if kl.locks == nil {
+		kl.locks = make(map[interface{}]*keyLock)
+	}
+	l := kl.locks[key]
+	if l == nil {
+		l = &keyLock{}
+		kl.locks[key] = l
+	}
+	l.refs++
+	kl.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		kl.mu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(kl.locks, key)
+		}
+		kl.mu.Unlock()
+	}
+}
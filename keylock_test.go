@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLockKey(t *testing.T) {
+	cache := NewShardedCache(4)
+	defer cache.Free()
+
+	// a read-modify-write made of Get and Store is only safe with the key locked
+	cache.Store("Counter", 0, time.Minute)
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock := cache.LockKey("Counter")
+			defer unlock()
+			n := cache.Get("Counter").(int)
+			time.Sleep(100 * time.Microsecond)
+			cache.Store("Counter", n+1, time.Minute)
+		}()
+	}
+	wg.Wait()
+	if v := cache.Get("Counter"); v != 50 {
+		t.Errorf("Expected cache key 'Counter' to be incremented 50 times, but got '%v'", v)
+	}
+
+	// other keys are not held up
+	unlock := cache.LockKey("A")
+	done := make(chan struct{})
+	go func() {
+		cache.LockKey("B")()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Errorf("Expected locking one key not to block another")
+	}
+	unlock()
+
+	for _, s := range cache.shards {
+		if n := len(s.keyLocks.locks); n != 0 {
+			t.Errorf("Expected no key mutexes to be kept once unlocked, but a shard has %d", n)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package cache
+
+import (
+	"container/heap"
+	"container/list"
+	"hash/maphash"
+)
+
+// lfu is an EvictionPolicy that evicts the least frequently used entry, and of those the least
+// recently used.
+type lfu struct {
+	nodes map[interface{}]*lfuNode
+	order lfuHeap
+	seq   uint64
+}
+
+// lfuNode is an entry tracked by lfu: how often it has been used, and when it was last used.
+type lfuNode struct {
+	key   interface{}
+	freq  uint64
+	seq   uint64
+	index int
+}
+
+// lfuHeap is a min-heap of lfuNodes, least frequently used first.
+type lfuHeap []*lfuNode
+
+func (h lfuHeap) Len() int {
+	return len(h)
+}
+
+func (h lfuHeap) Less(i, j int) bool {
+	if h[i].freq != h[j].freq {
+		return h[i].freq < h[j].freq
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h lfuHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *lfuHeap) Push(x interface{}) {
+	node := x.(*lfuNode)
+	node.index = len(*h)
+	*h = append(*h, node)
+}
+
+func (h *lfuHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	node := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return node
+}
+
+func newLFU() *lfu {
+	return &lfu{nodes: make(map[interface{}]*lfuNode)}
+}
+
+func (p *lfu) OnStore(key interface{}) {
+	p.seq++
+	node := &lfuNode{key: key, freq: 1, seq: p.seq}
+	p.nodes[key] = node
+	heap.Push(&p.order, node)
+}
+
+func (p *lfu) OnGet(key interface{}) {
+	if node := p.nodes[key]; node != nil {
+		p.seq++
+		node.freq++
+		node.seq = p.seq
+		heap.Fix(&p.order, node.index)
+	}
+}
+
+func (p *lfu) OnRemove(key interface{}) {
+	if node := p.nodes[key]; node != nil {
+		heap.Remove(&p.order, node.index)
+		delete(p.nodes, key)
+	}
+}
+
+func (p *lfu) Victim() (interface{}, bool) {
+	if len(p.order) == 0 {
+		return nil, false
+	}
+	return p.order[0].key, true
+}
+
+// frequencySketch is a count-min sketch estimating how often keys have been used recently. The
+// counters saturate at 15 and are all halved periodically, so that the estimates favour recent use.
+type frequencySketch struct {
+	seed      maphash.Seed
+	rows      [4][]uint8
+	mask      uint64
+	additions int
+	resetAt   int
+}
+
+func newFrequencySketch(width int) *frequencySketch {
+	s := &frequencySketch{seed: maphash.MakeSeed()}
+	s.resize(width)
+	return s
+}
+
+// resize clears the sketch and gives it the given width, rounded up to a power of two.
+func (s *frequencySketch) resize(width int) {
+	n := 16
+	for n < width {
+		n <<= 1
+	}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, n)
+	}
+	s.mask = uint64(n - 1)
+	s.additions = 0
+	s.resetAt = 10 * n
+}
+
+// fit grows the sketch if it is too small to estimate the frequencies of the given keys well. Keys
+// that are no longer in the cache still take up counters, so the sketch is kept much wider than the
+// number of keys. The estimates for the given keys are carried over when it grows.
+func (s *frequencySketch) fit(keys map[interface{}]*list.Element) {
+	if uint64(8*len(keys)) <= s.mask+1 {
+		return
+	}
+	estimates := make(map[interface{}]uint8, len(keys))
+	for key := range keys {
+		estimates[key] = s.estimate(key)
+	}
+	s.resize(16 * len(keys))
+	for key, est := range estimates {
+		for i, j := range s.indexes(key) {
+			s.rows[i][j] = max(s.rows[i][j], est)
+		}
+	}
+}
+
+// indexes returns the position of a key's counter in each row.
+func (s *frequencySketch) indexes(key interface{}) [4]uint64 {
+	h := maphash.Comparable(s.seed, key)
+	h1, h2 := h, h>>32|1
+	var idx [4]uint64
+	for i := range idx {
+		idx[i] = (h1 + uint64(i)*h2) & s.mask
+	}
+	return idx
+}
+
+// increment records a use of a key.
+func (s *frequencySketch) increment(key interface{}) {
+	for i, j := range s.indexes(key) {
+		if s.rows[i][j] < 15 {
+			s.rows[i][j]++
+		}
+	}
+	s.additions++
+	if s.additions >= s.resetAt {
+		for _, row := range s.rows {
+			for j := range row {
+				row[j] >>= 1
+			}
+		}
+		s.additions /= 2
+	}
+}
+
+// estimate returns the estimated number of recent uses of a key.
+func (s *frequencySketch) estimate(key interface{}) uint8 {
+	est := uint8(15)
+	for i, j := range s.indexes(key) {
+		est = min(est, s.rows[i][j])
+	}
+	return est
+}
+
+// tinyLFU is an EvictionPolicy implementing W-TinyLFU. New entries go into an LRU window of about
+// 1% of the entries. The rest are in a segmented LRU, an slru: entries enter its probation
+// segment, and move to its protected segment, which holds up to 80% of them, when they are used
+// again. Until the first eviction, entries leaving the window go straight into probation. After
+// that, when an entry must be evicted and the window is over its share, the least recently used
+// entry in the window competes with the next victim from probation, and the one the sketch
+// estimates has been used less often is evicted.
+type tinyLFU struct {
+	// true once an entry has been evicted, after which entries leaving the window must compete
+	// to be admitted.
+	full bool
+
+	sketch *frequencySketch
+	window *list.List
+
+	// the segments outside the window, which track the window's elements too.
+	*slru
+}
+
+func newTinyLFU() *tinyLFU {
+	return &tinyLFU{sketch: newFrequencySketch(1024), window: list.New(), slru: newSLRU()}
+}
+
+func (p *tinyLFU) OnStore(key interface{}) {
+	p.elements[key] = p.window.PushFront(&slruNode{key: key, segment: p.window})
+	p.sketch.fit(p.elements)
+	p.sketch.increment(key)
+	if !p.full {
+		for p.window.Len() > p.windowMax() {
+			p.move(p.window.Back(), p.probation)
+		}
+	}
+}
+
+// windowMax returns the number of entries the window holds.
+func (p *tinyLFU) windowMax() int {
+	return max(1, len(p.elements)/100)
+}
+
+func (p *tinyLFU) OnGet(key interface{}) {
+	el := p.elements[key]
+	if el == nil {
+		return
+	}
+	p.sketch.increment(key)
+	if el.Value.(*slruNode).segment == p.window {
+		p.window.MoveToFront(el)
+		return
+	}
+	p.slru.OnGet(key)
+}
+
+func (p *tinyLFU) Victim() (interface{}, bool) {
+	p.full = true
+	for p.window.Len() > p.windowMax() {
+		candidate := p.window.Back()
+		victim := p.probation.Back()
+		if victim == nil {
+			victim = p.protected.Back()
+		}
+		if victim == nil {
+			// nothing to compete with yet, so the candidate is admitted
+			p.move(candidate, p.probation)
+			continue
+		}
+		c, v := candidate.Value.(*slruNode).key, victim.Value.(*slruNode).key
+		if p.sketch.estimate(c) <= p.sketch.estimate(v) {
+			return c, true
+		}
+		p.move(candidate, p.probation)
+		return v, true
+	}
+	if key, ok := p.slru.Victim(); ok {
+		return key, true
+	}
+	if el := p.window.Back(); el != nil {
+		return el.Value.(*slruNode).key, true
+	}
+	return nil, false
+}
@@ -0,0 +1,44 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// hotKeysAfterScan stores 100 keys that are frequently used, then 1000 keys that are used once, and
+// returns how many of the frequently used keys are still in the cache.
+func hotKeysAfterScan(mode EvictionMode) int {
+	cache := NewCache(WithShards(1), WithEviction(mode))
+	defer cache.Free()
+	cache.SetMaxBytes(200)
+
+	for i := 0; i < 100; i++ {
+		cache.Store(i, i, time.Minute, WithSize(1))
+	}
+	for n := 0; n < 5; n++ {
+		for i := 0; i < 100; i++ {
+			cache.Get(i)
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		cache.Store(fmt.Sprint("cold", i), i, time.Minute, WithSize(1))
+	}
+
+	hot := 0
+	for i := 0; i < 100; i++ {
+		if cache.Get(i) != nil {
+			hot++
+		}
+	}
+	return hot
+}
+
+func TestTinyLFU(t *testing.T) {
+	if hot := hotKeysAfterScan(TinyLFU); hot < 90 {
+		t.Errorf("Expected TinyLFU to keep most of the frequently used keys, but it kept %d of 100", hot)
+	}
+	if hot := hotKeysAfterScan(LRU); hot > 10 {
+		t.Errorf("Expected LRU to evict the frequently used keys during a scan, but it kept %d of 100", hot)
+	}
+}
@@ -0,0 +1,158 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// LoadFunc loads the value for a key, for a LoaderCache.
+type LoadFunc func(key interface{}) (interface{}, error)
+
+// LoaderCache is a cache that loads the values of keys that are missing with a LoadFunc. Concurrent
+// calls to Get for a missing key share a single call of the LoadFunc, and errors can be cached so
+// that a failing key is not loaded again on every Get. It is created with NewLoaderCache.
+type LoaderCache struct {
+	cache         *Cache
+	load          LoadFunc
+	lifetime      time.Duration
+	errorLifetime time.Duration
+
+	// the loads in progress, by key.
+	mu    sync.Mutex
+	calls map[interface{}]*loadCall
+}
+
+// loadCall is a call of the LoadFunc that's in progress. done is closed when it completes.
+// invalidated is set if LoaderCache.InvalidateAndRefresh is called while it is in progress, so
+// that the key is loaded again once it completes.
+type loadCall struct {
+	done        chan struct{}
+	value       interface{}
+	err         error
+	invalidated bool
+}
+
+// loaded is the value a LoaderCache stores for a key, so that nil values and errors can be cached.
+type loaded struct {
+	value interface{}
+	err   error
+}
+
+// NewLoaderCache returns a LoaderCache that loads values with load and caches them for lifetime.
+// If errorLifetime is greater than zero, errors returned by load are cached for that long, and
+// returned by Get without calling load again; otherwise they are not cached. The options configure
+// the underlying Cache.
+func NewLoaderCache(load LoadFunc, lifetime, errorLifetime time.Duration, opts ...Option) *LoaderCache {
+	return &LoaderCache{
+		cache:         NewCache(opts...),
+		load:          load,
+		lifetime:      lifetime,
+		errorLifetime: errorLifetime,
+		calls:         make(map[interface{}]*loadCall),
+	}
+}
+
+// Get returns the value for a key, loading it if it is not in the cache. If another goroutine is
+// already loading the key, Get waits for that load to complete and returns its result. If the
+// LoadFunc panics, the load fails with a *PanicError, which like other errors is only cached if
+// errorLifetime is greater than zero. Once the cache has been freed, a key that isn't cached
+// returns ErrClosed, as does a load that was in progress.
+func (lc *LoaderCache) Get(key interface{}) (interface{}, error) {
+	if v, ok := lc.cache.Get(key).(loaded); ok {
+		return v.value, v.err
+	}
+	if lc.cache.closed.Load() {
+		return nil, ErrClosed
+	}
+
+	lc.mu.Lock()
+	if call := lc.calls[key]; call != nil {
+		lc.mu.Unlock()
+		<-call.done
+		return call.value, call.err
+	}
+	call := &loadCall{done: make(chan struct{})}
+	lc.calls[key] = call
+	lc.mu.Unlock()
+
+	lc.run(key, call)
+	return call.value, call.err
+}
+
+// InvalidateAndRefresh marks the cached value for a key stale and loads it again in the
+// background, without waiting for the new value, for instance when an administrator saves the data
+// behind it. Until the new value is loaded, Get keeps returning the old one, so readers don't each
+// try to load the key. Calls made while the key is being loaded share a single further load, which
+// starts once the current one completes, so the value reflects changes made before
+// InvalidateAndRefresh was called. If the load fails, the old value is kept unless errors are
+// cached.
+func (lc *LoaderCache) InvalidateAndRefresh(key interface{}) {
+	lc.cache.invalidate(key)
+
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	if call := lc.calls[key]; call != nil {
+		call.invalidated = true
+		return
+	}
+	call := &loadCall{done: make(chan struct{})}
+	lc.calls[key] = call
+	go lc.run(key, call)
+}
+
+// run calls the LoadFunc for a key, caches the result, and completes the call, which must have been
+// added to the calls in progress. If the call was invalidated while it was in progress, the key is
+// loaded again in the background. If the LoadFunc panics, the call fails with a *PanicError, and
+// if the cache has been freed, with ErrClosed.
+func (lc *LoaderCache) run(key interface{}, pending *loadCall) {
+	defer lc.complete(key, pending)
+	start := time.Now()
+	pending.value, pending.err = call(context.Background(), func(context.Context) (interface{}, error) {
+		return lc.load(key)
+	})
+	lc.cache.counters.loads.Add(1)
+	lc.cache.counters.loadTime.Add(int64(time.Since(start)))
+	if lc.cache.closed.Load() {
+		pending.value, pending.err = nil, ErrClosed
+		return
+	}
+	if pending.err == nil {
+		lc.cache.Store(key, loaded{value: pending.value}, lc.lifetime)
+	} else if lc.errorLifetime > 0 {
+		lc.cache.Store(key, loaded{err: pending.err}, lc.errorLifetime)
+	}
+}
+
+// complete removes a call from the calls in progress and closes its done channel, starting the
+// further load of an invalidated call unless the cache has been freed.
+func (lc *LoaderCache) complete(key interface{}, pending *loadCall) {
+	lc.mu.Lock()
+	delete(lc.calls, key)
+	var next *loadCall
+	if pending.invalidated && !lc.cache.closed.Load() {
+		next = &loadCall{done: make(chan struct{})}
+		lc.calls[key] = next
+	}
+	lc.mu.Unlock()
+	close(pending.done)
+	if next != nil {
+		go lc.run(key, next)
+	}
+}
+
+// Delete removes the cached value or error for a key, so that the next Get loads it again.
+func (lc *LoaderCache) Delete(key interface{}) {
+	lc.cache.Delete(key)
+}
+
+// Stats returns statistics about the use of the underlying cache. Loads counts the calls of the
+// LoadFunc.
+func (lc *LoaderCache) Stats() Stats {
+	return lc.cache.Stats()
+}
+
+// Free frees the underlying cache. See Cache.Free.
+func (lc *LoaderCache) Free() {
+	lc.cache.Free()
+}
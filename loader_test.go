@@ -0,0 +1,168 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLoaderCache(t *testing.T) {
+	var calls atomic.Int32
+	lc := NewLoaderCache(func(key interface{}) (interface{}, error) {
+		calls.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		return key.(string) + "!", nil
+	}, time.Minute, 0)
+	defer lc.Free()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := lc.Get("Key"); v != "Key!" || err != nil {
+				t.Errorf("Expected cache key 'Key' to load 'Key!', but got '%v', %v", v, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if v, _ := lc.Get("Key"); v != "Key!" {
+		t.Errorf("Expected cache key 'Key' to be cached with 'Key!', but got '%v'", v)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("Expected concurrent loads to be coalesced into 1 call, but there were %d", n)
+	}
+
+	lc.Delete("Key")
+	lc.Get("Key")
+	if n := calls.Load(); n != 2 {
+		t.Errorf("Expected the key to be loaded again after Delete, but there were %d calls", n)
+	}
+}
+
+func TestLoaderCacheInvalidateAndRefresh(t *testing.T) {
+	var calls atomic.Int32
+	lc := NewLoaderCache(func(key interface{}) (interface{}, error) {
+		n := calls.Add(1)
+		time.Sleep(30 * time.Millisecond)
+		return n, nil
+	}, time.Minute, 0)
+	defer lc.Free()
+
+	lc.Get("Key")
+	for i := 0; i < 3; i++ {
+		lc.InvalidateAndRefresh("Key")
+	}
+	if v, _ := lc.Get("Key"); v != int32(1) {
+		t.Errorf("Expected the old value to be returned while the key is loaded again, but got '%v'", v)
+	}
+	if _, stale, _ := lc.cache.GetStale("Key"); !stale {
+		t.Errorf("Expected the old value to be stale")
+	}
+
+	// the calls made during the first load share a single further one
+	time.Sleep(150 * time.Millisecond)
+	if v, _ := lc.Get("Key"); v != int32(3) {
+		t.Errorf("Expected cache key 'Key' to have been loaded twice more, but got '%v'", v)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("Expected 3 loads, but there were %d", n)
+	}
+}
+
+func TestLoaderCachePanic(t *testing.T) {
+	var calls atomic.Int32
+	lc := NewLoaderCache(func(key interface{}) (interface{}, error) {
+		if calls.Add(1) == 1 || key == "Panics" {
+			time.Sleep(20 * time.Millisecond)
+			panic("boom")
+		}
+		return "value", nil
+	}, time.Minute, 0)
+	defer lc.Free()
+
+	// both the load that panics and the Get waiting for it fail rather than blocking
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var panicErr *PanicError
+			if _, err := lc.Get("Key"); !errors.As(err, &panicErr) || panicErr.Value != "boom" {
+				t.Errorf("Expected a *PanicError from a panicking load, but got %v", err)
+			}
+		}()
+		time.Sleep(5 * time.Millisecond)
+	}
+	wg.Wait()
+
+	if v, err := lc.Get("Key"); v != "value" || err != nil {
+		t.Errorf("Expected the next Get to load the key again, but got '%v', %v", v, err)
+	}
+
+	// a panic in a background load doesn't crash the process, and releases the Get waiting for it
+	lc.InvalidateAndRefresh("Panics")
+	if _, err := lc.Get("Panics"); err == nil {
+		t.Errorf("Expected the Get waiting for the background load to fail")
+	}
+}
+
+func TestLoaderCacheFree(t *testing.T) {
+	release := make(chan struct{})
+	lc := NewLoaderCache(func(key interface{}) (interface{}, error) {
+		<-release
+		return "value", nil
+	}, time.Minute, 0)
+
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := lc.Get("Key")
+			errs <- err
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	lc.Free()
+	close(release)
+
+	// the load in progress and the Get waiting for it fail rather than blocking or panicking
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			if err != ErrClosed {
+				t.Errorf("Expected ErrClosed from a load completed after Free, but got %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Expected Get to return once the load completed")
+		}
+	}
+	if _, err := lc.Get("Key"); err != ErrClosed {
+		t.Errorf("Expected ErrClosed from Get after Free, but got %v", err)
+	}
+}
+
+func TestLoaderCacheErrors(t *testing.T) {
+	errNotFound := errors.New("not found")
+	for _, errorLifetime := range []time.Duration{0, time.Minute} {
+		calls := 0
+		lc := NewLoaderCache(func(key interface{}) (interface{}, error) {
+			calls++
+			return nil, errNotFound
+		}, time.Minute, errorLifetime)
+
+		lc.Get("Key")
+		if _, err := lc.Get("Key"); err != errNotFound {
+			t.Errorf("Expected the load error to be returned, but got %v", err)
+		}
+		want := 2
+		if errorLifetime > 0 {
+			want = 1
+		}
+		if calls != want {
+			t.Errorf("Expected %d loads with error lifetime %v, but there were %d", want, errorLifetime, calls)
+		}
+		lc.Free()
+	}
+}
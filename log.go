@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"log/slog"
+	"time"
+)
+
+// defaultSlowGenerator is how long a generator may take before it is logged as slow, unless
+// WithSlowGeneratorThreshold is given.
+const defaultSlowGenerator = time.Second
+
+// WithLogger has the cache log with l: failed regenerations of perpetual entries, circuit breakers
+// opening, entries shed under memory pressure, failed calls of its Store, and generators slower
+// than the threshold set by WithSlowGeneratorThreshold at the Warn level, and each sweep, with how
+// long it took and how many entries were due, at the Debug level. By default nothing is logged.
+func WithLogger(l *slog.Logger) Option {
+	return func(c *Cache) {
+		c.logger = l
+	}
+}
+
+// WithSlowGeneratorThreshold sets how long a generator may take before it is logged as slow by a
+// cache with a logger. The default is one second.
+func WithSlowGeneratorThreshold(d time.Duration) Option {
+	return func(c *Cache) {
+		c.slowGenerator = d
+	}
+}
+
+// logGenerated logs a call of an entry's generator that took elapsed, if it was slow.
+func (c *Cache) logGenerated(entry *CacheEntry, elapsed time.Duration, err error) {
+	if c.logger == nil || elapsed < c.slowGenerator {
+		return
+	}
+	attrs := []any{"key", entry.key, "elapsed", elapsed}
+	if err != nil {
+		attrs = append(attrs, "error", err)
+	}
+	c.logger.Warn("cache: slow generator", attrs...)
+}
+
+// logRefreshFailed logs a failed regeneration of a perpetual entry.
+func (c *Cache) logRefreshFailed(entry *CacheEntry, err error) {
+	if c.logger == nil {
+		return
+	}
+	c.logger.Warn("cache: refresh failed", "key", entry.key, "error", err, "failures", entry.failures)
+}
+
+// logBreakerOpened logs that the circuit breaker of a perpetual entry has opened.
+func (c *Cache) logBreakerOpened(entry *CacheEntry) {
+	if c.logger == nil {
+		return
+	}
+	c.logger.Warn("cache: circuit breaker opened", "key", entry.key, "failures", entry.failures)
+}
+
+// logShed logs that n entries were shed because the heap had grown to heap bytes.
+func (c *Cache) logShed(heap uint64, n int) {
+	if c.logger == nil {
+		return
+	}
+	c.logger.Warn("cache: shed entries under memory pressure", "heap", heap, "threshold", c.pressureThreshold, "shed", n)
+}
+
+// logSwept logs a sweep of the cache that took elapsed and found due entries due.
+func (c *Cache) logSwept(elapsed time.Duration, due int) {
+	if c.logger == nil {
+		return
+	}
+	c.logger.Debug("cache: swept", "elapsed", elapsed, "due", due, "shards", len(c.shards))
+}
@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// logBuffer collects log output written from several goroutines.
+type logBuffer struct {
+	sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *logBuffer) String() string {
+	b.Lock()
+	defer b.Unlock()
+	return b.buf.String()
+}
+
+func TestWithLogger(t *testing.T) {
+	var out logBuffer
+	logger := slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	cache := NewCache(WithLogger(logger), WithSlowGeneratorThreshold(5*time.Millisecond), WithSweepInterval(time.Millisecond))
+	defer cache.Free()
+
+	fail := errors.New("database down")
+	failing := false
+	cache.StorePerpetualE("Report", func() (interface{}, error) {
+		if failing {
+			return nil, fail
+		}
+		time.Sleep(10 * time.Millisecond)
+		return 1, nil
+	}, time.Hour)
+	failing = true
+	cache.Refresh("Report")
+
+	for i := 0; i < 100 && !strings.Contains(out.String(), "cache: swept"); i++ {
+		time.Sleep(time.Millisecond)
+	}
+	logged := out.String()
+	for _, want := range []string{
+		`msg="cache: slow generator" key=Report`,
+		`msg="cache: refresh failed" key=Report error="database down" failures=1`,
+		`msg="cache: swept"`,
+	} {
+		if !strings.Contains(logged, want) {
+			t.Errorf("Expected the log to contain '%s', but got:\n%s", want, logged)
+		}
+	}
+	if strings.Count(logged, "slow generator") != 1 {
+		t.Errorf("Expected only the slow generation to be logged as slow, but got:\n%s", logged)
+	}
+}
@@ -0,0 +1,141 @@
+package cache
+
+import (
+	"iter"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Manager creates and keeps track of named caches, so that an application has one place that
+// owns its caches and can free them all, rather than caches created wherever they are needed and
+// never freed. It is created with NewManager; the package-level Named and CloseAll functions use
+// DefaultManager.
+type Manager struct {
+	mu     sync.Mutex
+	caches map[string]*Cache
+	opts   []Option
+}
+
+// DefaultManager is the Manager used by Named and CloseAll.
+var DefaultManager = NewManager()
+
+// NewManager returns a Manager that creates caches with the given options, followed by any given
+// to Named.
+func NewManager(opts ...Option) *Manager {
+	return &Manager{caches: make(map[string]*Cache), opts: opts}
+}
+
+// Named returns the cache with the given name, creating it if it doesn't exist or has been freed.
+// The options are only used when the cache is created.
+func (m *Manager) Named(name string, opts ...Option) *Cache {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if c := m.caches[name]; c != nil && !c.closed.Load() {
+		return c
+	}
+	c := NewCache(append(slices.Clip(m.opts), opts...)...)
+	m.caches[name] = c
+	return c
+}
+
+// Lookup returns the cache with the given name and true, or false if the manager hasn't created
+// it or it has been freed.
+func (m *Manager) Lookup(name string) (*Cache, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := m.caches[name]
+	if c == nil || c.closed.Load() {
+		return nil, false
+	}
+	return c, true
+}
+
+// Names returns the names of the caches the manager has, in sorted order.
+func (m *Manager) Names() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var names []string
+	for name, c := range m.caches {
+		if !c.closed.Load() {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// CloseAll frees every cache the manager has created, and forgets them, so that Named creates new
+// caches afterwards.
+func (m *Manager) CloseAll() {
+	m.mu.Lock()
+	caches := m.caches
+	m.caches = make(map[string]*Cache)
+	m.mu.Unlock()
+	for _, c := range caches {
+		c.Free()
+	}
+}
+
+// Stats returns the statistics of each of the manager's caches, by name.
+func (m *Manager) Stats() map[string]Stats {
+	m.mu.Lock()
+	caches := make(map[string]*Cache, len(m.caches))
+	for name, c := range m.caches {
+		if !c.closed.Load() {
+			caches[name] = c
+		}
+	}
+	m.mu.Unlock()
+	stats := make(map[string]Stats, len(caches))
+	for name, c := range caches {
+		stats[name] = c.Stats()
+	}
+	return stats
+}
+
+// TotalStats returns the statistics of all of the manager's caches added together. The
+// AverageLoadTime is the average over all of their loads.
+func (m *Manager) TotalStats() Stats {
+	return sumStats(maps.Values(m.Stats()))
+}
+
+// sumStats adds statistics together, with the AverageLoadTime of the total the average over all
+// of their loads.
+func sumStats(stats iter.Seq[Stats]) Stats {
+	var total Stats
+	var loadTime time.Duration
+	for s := range stats {
+		total.Hits += s.Hits
+		total.Misses += s.Misses
+		total.Evictions += s.Evictions
+		total.Expirations += s.Expirations
+		total.Refreshes += s.Refreshes
+		total.RefreshErrors += s.RefreshErrors
+		total.DecodeErrors += s.DecodeErrors
+		total.StoreErrors += s.StoreErrors
+		total.Entries += s.Entries
+		total.Bytes += s.Bytes
+		total.Loads += s.Loads
+		loadTime += s.AverageLoadTime * time.Duration(s.Loads)
+		total.Lifetimes = total.Lifetimes.add(s.Lifetimes)
+		total.EvictionAges = total.EvictionAges.add(s.EvictionAges)
+		total.FirstHits = total.FirstHits.add(s.FirstHits)
+	}
+	if total.Loads > 0 {
+		total.AverageLoadTime = loadTime / time.Duration(total.Loads)
+	}
+	return total
+}
+
+// Named returns the cache with the given name from DefaultManager, creating it if needed. See
+// Manager.Named.
+func Named(name string, opts ...Option) *Cache {
+	return DefaultManager.Named(name, opts...)
+}
+
+// CloseAll frees every cache created with Named.
+func CloseAll() {
+	DefaultManager.CloseAll()
+}
@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestManager(t *testing.T) {
+	m := NewManager(WithDefaultTTL(time.Minute))
+
+	siteconfig := m.Named("siteconfig")
+	if m.Named("siteconfig") != siteconfig {
+		t.Errorf("Expected Named to return the same cache for the same name")
+	}
+	menus := m.Named("menus", WithShards(2))
+	if len(menus.shards) != 2 {
+		t.Errorf("Expected the options given to Named to be used, but the cache has %d shards", len(menus.shards))
+	}
+	if names := m.Names(); !slices.Equal(names, []string{"menus", "siteconfig"}) {
+		t.Errorf("Expected the manager to have both caches, but got %v", names)
+	}
+
+	siteconfig.Store("Title", "My Site", DefaultLifetime)
+	if ttl, ok := siteconfig.TTL("Title"); !ok || ttl <= 50*time.Second {
+		t.Errorf("Expected the manager's options to give the cache a default TTL, but got %v", ttl)
+	}
+	siteconfig.Get("Title")
+	menus.Store("Main", "menu", time.Minute)
+	menus.Get("Missing")
+
+	total := m.TotalStats()
+	if total.Entries != 2 || total.Hits != 1 || total.Misses != 1 {
+		t.Errorf("Expected the stats of both caches to be added together, but got %+v", total)
+	}
+	if s := m.Stats()["menus"]; s.Entries != 1 || s.Misses != 1 {
+		t.Errorf("Expected the stats of the menus cache, but got %+v", s)
+	}
+
+	m.CloseAll()
+	if !siteconfig.closed.Load() || !menus.closed.Load() {
+		t.Errorf("Expected CloseAll to free the caches")
+	}
+	if _, ok := m.Lookup("siteconfig"); ok {
+		t.Errorf("Did not expect a cache to be found after CloseAll")
+	}
+	if c := m.Named("siteconfig"); c == siteconfig {
+		t.Errorf("Expected Named to create a new cache after CloseAll")
+	}
+	m.CloseAll()
+}
@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// memo is the state of a function memoized with Memoize: the calls of it in progress, by argument.
+type memo[I comparable] struct {
+	mu    sync.Mutex
+	calls map[I]*loadCall
+}
+
+// memoKey is the key under which a result of a memoized function is stored. It includes the
+// function's memo, so that functions memoized in the same cache don't share results.
+type memoKey[I comparable] struct {
+	memo *memo[I]
+	in   I
+}
+
+// Memoize returns a version of fn that caches its results in c, for ttl. Calls with an argument
+// whose result is cached return it without calling fn, and concurrent calls with the same argument
+// share a single call of fn. Errors are returned to the calls sharing it but are not cached, so the
+// next call tries again. If fn panics, the calls return a *PanicError. Each function returned by
+// Memoize has its own results, even when two are memoized from the same fn. A ttl of
+// DefaultLifetime uses the cache's default lifetime. Once the cache has been freed, calls whose
+// result isn't cached return ErrClosed, as do those in progress.
+func Memoize[I comparable, O any](c *Cache, ttl time.Duration, fn func(I) (O, error)) func(I) (O, error) {
+	m := &memo[I]{calls: make(map[I]*loadCall)}
+	return func(in I) (O, error) {
+		key := memoKey[I]{memo: m, in: in}
+		if v, ok := c.GetOK(key); ok {
+			out, _ := v.(O)
+			return out, nil
+		}
+		if c.closed.Load() {
+			var zero O
+			return zero, ErrClosed
+		}
+
+		m.mu.Lock()
+		if pending := m.calls[in]; pending != nil {
+			m.mu.Unlock()
+			<-pending.done
+			return result[O](pending)
+		}
+		pending := &loadCall{done: make(chan struct{})}
+		m.calls[in] = pending
+		m.mu.Unlock()
+		defer m.complete(in, pending)
+
+		start := time.Now()
+		pending.value, pending.err = call(context.Background(), func(context.Context) (interface{}, error) {
+			return fn(in)
+		})
+		c.counters.loads.Add(1)
+		c.counters.loadTime.Add(int64(time.Since(start)))
+		if c.closed.Load() {
+			pending.value, pending.err = nil, ErrClosed
+		} else if pending.err == nil {
+			c.Store(key, pending.value, ttl)
+		}
+		return result[O](pending)
+	}
+}
+
+// complete removes a call from the calls in progress and closes its done channel.
+func (m *memo[I]) complete(in I, pending *loadCall) {
+	m.mu.Lock()
+	delete(m.calls, in)
+	m.mu.Unlock()
+	close(pending.done)
+}
+
+// result returns the result of a completed call of a memoized function, with the zero O for a nil
+// value.
+func result[O any](call *loadCall) (O, error) {
+	out, _ := call.value.(O)
+	return out, call.err
+}
@@ -0,0 +1,125 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemoize(t *testing.T) {
+	cache := NewCache()
+	defer cache.Free()
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	square := Memoize(cache, time.Minute, func(n int) (int, error) {
+		calls.Add(1)
+		<-release
+		return n * n, nil
+	})
+
+	// concurrent calls with the same argument share a call
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := square(3); v != 9 || err != nil {
+				t.Errorf("Expected the memoized function to return 9, but got %v, %v", v, err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if n := calls.Load(); n != 1 {
+		t.Errorf("Expected concurrent calls to share one call of the function, but it was called %d times", n)
+	}
+
+	if v, _ := square(3); v != 9 || calls.Load() != 1 {
+		t.Errorf("Expected the result to be cached, but got %v after %d calls", v, calls.Load())
+	}
+	if v, _ := square(4); v != 16 || calls.Load() != 2 {
+		t.Errorf("Expected a new argument to call the function, but got %v after %d calls", v, calls.Load())
+	}
+
+	// another memoized function doesn't share the results
+	double := Memoize(cache, time.Minute, func(n int) (int, error) {
+		return n * 2, nil
+	})
+	if v, _ := double(3); v != 6 {
+		t.Errorf("Expected a separately memoized function to have its own results, but got %v", v)
+	}
+}
+
+func TestMemoizeErrors(t *testing.T) {
+	cache := NewCache()
+	defer cache.Free()
+
+	fail := errors.New("not found")
+	calls := 0
+	lookup := Memoize(cache, time.Minute, func(name string) (*int, error) {
+		calls++
+		if name == "missing" {
+			return nil, fail
+		}
+		if name == "panic" {
+			panic("boom")
+		}
+		n := len(name)
+		return &n, nil
+	})
+
+	for i := 0; i < 2; i++ {
+		if _, err := lookup("missing"); err != fail {
+			t.Errorf("Expected the function's error, but got %v", err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("Expected errors not to be cached, but the function was called %d times", calls)
+	}
+	var pe *PanicError
+	if _, err := lookup("panic"); !errors.As(err, &pe) {
+		t.Errorf("Expected a panic to be returned as a *PanicError, but got %v", err)
+	}
+	if v, err := lookup("fred"); err != nil || *v != 4 {
+		t.Errorf("Expected the memoized function to return 4, but got %v, %v", v, err)
+	}
+}
+
+func TestMemoizeFree(t *testing.T) {
+	cache := NewCache()
+	release := make(chan struct{})
+	double := Memoize(cache, time.Minute, func(n int) (int, error) {
+		<-release
+		return n * 2, nil
+	})
+
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := double(2)
+			errs <- err
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	cache.Free()
+	close(release)
+
+	// the call in progress and the one waiting for it fail rather than blocking or panicking
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			if err != ErrClosed {
+				t.Errorf("Expected ErrClosed from a call completed after Free, but got %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Expected the memoized function to return once the call completed")
+		}
+	}
+	if _, err := double(2); err != ErrClosed {
+		t.Errorf("Expected ErrClosed from a call after Free, but got %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+// Package metrics exports the statistics of a cache.Cache to monitoring systems. Publish exposes
+// them with expvar, so they appear under /debug/vars. A prometheus.Collector is available by
+// building with the "prometheus" build tag; see NewCollector.
+package metrics
+
+import (
+	"expvar"
+
+	"github.com/mrmorphic/cache"
+)
+
+// Publish exposes the statistics of a cache as an expvar variable with the given name. The
+// statistics are read from the cache each time the variable is read. Like expvar.Publish, it
+// panics if a variable with the name already exists.
+func Publish(name string, c *cache.Cache) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return statsMap(c.Stats())
+	}))
+}
+
+// statsMap converts cache statistics to a map with stable, lower case names, which is what is
+// exported by both expvar and prometheus.
+func statsMap(st cache.Stats) map[string]interface{} {
+	return map[string]interface{}{
+		"hits":                      st.Hits,
+		"misses":                    st.Misses,
+		"hit_ratio":                 st.HitRatio(),
+		"evictions":                 st.Evictions,
+		"expirations":               st.Expirations,
+		"refreshes":                 st.Refreshes,
+		"refresh_errors":            st.RefreshErrors,
+		"decode_errors":             st.DecodeErrors,
+		"store_errors":              st.StoreErrors,
+		"entries":                   st.Entries,
+		"bytes":                     st.Bytes,
+		"loads":                     st.Loads,
+		"average_load_time_seconds": st.AverageLoadTime.Seconds(),
+		"lifetime_seconds":          histogramMap(st.Lifetimes),
+		"eviction_age_seconds":      histogramMap(st.EvictionAges),
+		"first_hit_seconds":         histogramMap(st.FirstHits),
+	}
+}
+
+// histogramMap converts a histogram of durations to a map of its count and a summary of the
+// durations in seconds.
+func histogramMap(h cache.Histogram) map[string]interface{} {
+	return map[string]interface{}{
+		"count": h.Count,
+		"sum":   h.Sum.Seconds(),
+		"mean":  h.Mean().Seconds(),
+		"p50":   h.Quantile(0.5).Seconds(),
+		"p90":   h.Quantile(0.9).Seconds(),
+		"p99":   h.Quantile(0.99).Seconds(),
+	}
+}
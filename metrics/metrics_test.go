@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"encoding/json"
+	"expvar"
+	"testing"
+	"time"
+
+	"github.com/mrmorphic/cache"
+)
+
+func TestPublish(t *testing.T) {
+	c := cache.NewCache()
+	c.Store("key", "value", time.Minute)
+	c.Get("key")
+	c.Get("missing")
+
+	Publish("testcache", c)
+
+	var vars map[string]interface{}
+	if err := json.Unmarshal([]byte(expvar.Get("testcache").String()), &vars); err != nil {
+		t.Fatalf("Could not decode published variable: %s", err)
+	}
+	if vars["hits"] != 1.0 || vars["misses"] != 1.0 || vars["entries"] != 1.0 {
+		t.Errorf("Expected 1 hit, 1 miss and 1 entry, but got %v", vars)
+	}
+}
+
+func TestStatsMapHistograms(t *testing.T) {
+	c := cache.NewCache()
+	c.Store("key", "value", time.Minute)
+
+	lifetimes := statsMap(c.Stats())["lifetime_seconds"].(map[string]interface{})
+	if lifetimes["count"] != uint64(1) || lifetimes["sum"] != 60.0 {
+		t.Errorf("Expected one lifetime of 60 seconds, but got %v", lifetimes)
+	}
+}
@@ -0,0 +1,114 @@
+//go:build prometheus
+
+package metrics
+
+import (
+	"github.com/mrmorphic/cache"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector is a prometheus.Collector that reports the statistics of a cache. The statistics are
+// read from the cache each time it is collected.
+type Collector struct {
+	cache *cache.Cache
+
+	hits          *prometheus.Desc
+	misses        *prometheus.Desc
+	evictions     *prometheus.Desc
+	expirations   *prometheus.Desc
+	refreshes     *prometheus.Desc
+	refreshErrors *prometheus.Desc
+	decodeErrors  *prometheus.Desc
+	storeErrors   *prometheus.Desc
+	entries       *prometheus.Desc
+	bytes         *prometheus.Desc
+	loads         *prometheus.Desc
+	loadTime      *prometheus.Desc
+	lifetimes     *prometheus.Desc
+	evictionAges  *prometheus.Desc
+	firstHits     *prometheus.Desc
+}
+
+// NewCollector returns a Collector for a cache. The name is added to each metric as the "cache"
+// label, so that several caches can be registered with the same registry.
+func NewCollector(name string, c *cache.Cache) *Collector {
+	labels := prometheus.Labels{"cache": name}
+	desc := func(metric, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName("cache", "", metric), help, nil, labels)
+	}
+	return &Collector{
+		cache:         c,
+		hits:          desc("hits_total", "Number of cache lookups that found a value."),
+		misses:        desc("misses_total", "Number of cache lookups that did not find a value."),
+		evictions:     desc("evictions_total", "Number of entries evicted to keep the cache within its limits."),
+		expirations:   desc("expirations_total", "Number of entries removed at the end of their lifetime."),
+		refreshes:     desc("refreshes_total", "Number of regenerations of perpetual entries."),
+		refreshErrors: desc("refresh_errors_total", "Number of regenerations of perpetual entries that failed."),
+		decodeErrors:  desc("decode_errors_total", "Number of values that could not be decompressed or decoded when retrieved."),
+		storeErrors:   desc("store_errors_total", "Number of calls of the cache's store that failed."),
+		entries:       desc("entries", "Number of entries in the cache."),
+		bytes:         desc("bytes", "Estimated total size, or cost, of the entries in the cache."),
+		loads:         desc("loads_total", "Number of calls to value generators."),
+		loadTime:      desc("average_load_time_seconds", "Average time taken by value generators."),
+		lifetimes:     desc("lifetime_seconds", "Lifetimes given to entries as they are stored."),
+		evictionAges:  desc("eviction_age_seconds", "Ages of entries when they are evicted."),
+		firstHits:     desc("first_hit_seconds", "Time from storing entries to their first retrieval."),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.hits
+	ch <- c.misses
+	ch <- c.evictions
+	ch <- c.expirations
+	ch <- c.refreshes
+	ch <- c.refreshErrors
+	ch <- c.decodeErrors
+	ch <- c.storeErrors
+	ch <- c.entries
+	ch <- c.bytes
+	ch <- c.loads
+	ch <- c.loadTime
+	ch <- c.lifetimes
+	ch <- c.evictionAges
+	ch <- c.firstHits
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	st := c.cache.Stats()
+	counter := func(desc *prometheus.Desc, v uint64) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(v))
+	}
+	gauge := func(desc *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v)
+	}
+	counter(c.hits, st.Hits)
+	counter(c.misses, st.Misses)
+	counter(c.evictions, st.Evictions)
+	counter(c.expirations, st.Expirations)
+	counter(c.refreshes, st.Refreshes)
+	counter(c.refreshErrors, st.RefreshErrors)
+	counter(c.decodeErrors, st.DecodeErrors)
+	counter(c.storeErrors, st.StoreErrors)
+	gauge(c.entries, float64(st.Entries))
+	gauge(c.bytes, float64(st.Bytes))
+	counter(c.loads, st.Loads)
+	gauge(c.loadTime, st.AverageLoadTime.Seconds())
+	histogram(ch, c.lifetimes, st.Lifetimes)
+	histogram(ch, c.evictionAges, st.EvictionAges)
+	histogram(ch, c.firstHits, st.FirstHits)
+}
+
+// histogram sends a histogram of durations as a prometheus histogram in seconds, whose buckets are
+// cumulative: each counts the durations no longer than its bound.
+func histogram(ch chan<- prometheus.Metric, desc *prometheus.Desc, h cache.Histogram) {
+	buckets := make(map[float64]uint64, len(h.Bounds()))
+	var seen uint64
+	for i, bound := range h.Bounds() {
+		seen += h.Counts[i]
+		buckets[bound.Seconds()] = seen
+	}
+	ch <- prometheus.MustNewConstHistogram(desc, h.Count, h.Sum.Seconds(), buckets)
+}
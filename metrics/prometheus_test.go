@@ -0,0 +1,49 @@
+//go:build prometheus
+
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mrmorphic/cache"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestCollector(t *testing.T) {
+	c := cache.NewCache()
+	defer c.Free()
+	c.Store("key", "value", time.Minute)
+	c.Get("key")
+	c.Get("missing")
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(NewCollector("testcache", c))
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Expected the metrics to be gathered, but got %v", err)
+	}
+
+	values := make(map[string]float64)
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			if labels := m.GetLabel(); len(labels) != 1 || labels[0].GetValue() != "testcache" {
+				t.Errorf("Expected metric %s to have the cache label, but got %v", family.GetName(), labels)
+			}
+			switch {
+			case m.GetCounter() != nil:
+				values[family.GetName()] = m.GetCounter().GetValue()
+			case m.GetGauge() != nil:
+				values[family.GetName()] = m.GetGauge().GetValue()
+			case m.GetHistogram() != nil:
+				values[family.GetName()] = float64(m.GetHistogram().GetSampleCount())
+			}
+		}
+	}
+	if values["cache_hits_total"] != 1 || values["cache_misses_total"] != 1 || values["cache_entries"] != 1 {
+		t.Errorf("Expected 1 hit, 1 miss and 1 entry, but got %v", values)
+	}
+	if values["cache_lifetime_seconds"] != 1 || values["cache_first_hit_seconds"] != 1 {
+		t.Errorf("Expected the lifetime and first hit of the entry to be observed, but got %v", values)
+	}
+}
@@ -0,0 +1,168 @@
+package cache
+
+import "time"
+
+// groupByShard splits keys into the shards they belong to, so that each shard only needs to be
+// locked once for a batch operation.
+func (c *Cache) groupByShard(keys []interface{}) map[*shard][]interface{} {
+	groups := make(map[*shard][]interface{})
+	for _, key := range keys {
+		s := c.shardFor(key)
+		groups[s] = append(groups[s], key)
+	}
+	return groups
+}
+
+// lockShards locks the shards the keys belong to, always in the same order so that concurrent
+// calls can't deadlock, and returns them in that order.
+func (c *Cache) lockShards(keys []interface{}) []*shard {
+	groups := c.groupByShard(keys)
+	locked := make([]*shard, 0, len(groups))
+	for _, s := range c.shards {
+		if _, ok := groups[s]; ok {
+			s.Lock()
+			locked = append(locked, s)
+		}
+	}
+	return locked
+}
+
+// GetMulti retrieves the values of several keys, locking each shard only once. The result maps
+// each key that has a value to its value; keys with no value are not in the result. The shards are
+// all locked together, so entries stored together with StoreAll or Swap are either all seen before
+// the change or all after it.
+func (c *Cache) GetMulti(keys []interface{}) map[interface{}]interface{} {
+	result := make(map[interface{}]interface{}, len(keys))
+	var stale []staleEntry
+	now := c.clock.Now()
+	locked := c.lockShards(keys)
+	for _, key := range keys {
+		s := c.shardFor(key)
+		entry := c.lookup(s, key, now)
+		if entry == nil {
+			continue
+		}
+		if entry.perpetual && entry.tooStale(now) {
+			stale = append(stale, staleEntry{key: key, s: s, entry: entry, done: c.refresh(s, entry)})
+			continue
+		}
+		result[key] = s.value(entry)
+	}
+	for _, s := range locked {
+		c.unlock(s)
+	}
+	if c.cloner != nil {
+		for key, value := range result {
+			result[key] = c.clone(value)
+		}
+	}
+
+	// entries too stale to return are returned once they have been regenerated, as they are by
+	// Get, having already been counted as hits.
+	for _, st := range stale {
+		<-st.done
+		st.s.RLock()
+		ok := st.s.entries[st.key] == st.entry
+		var value interface{}
+		if ok {
+			value = st.s.value(st.entry)
+		}
+		st.s.RUnlock()
+		if ok {
+			result[st.key] = c.clone(value)
+		}
+	}
+	return result
+}
+
+// staleEntry is a perpetual entry that GetMulti is waiting for the regeneration of.
+type staleEntry struct {
+	key   interface{}
+	s     *shard
+	entry *CacheEntry
+	done  chan struct{}
+}
+
+// StoreMulti stores several key/value pairs with the same lifetime and options, locking each shard
+// only once. It is otherwise the same as calling Store for each pair.
+func (c *Cache) StoreMulti(values map[interface{}]interface{}, lifetime time.Duration, opts ...EntryOption) {
+	groups := make(map[*shard][]interface{})
+	for key := range values {
+		s := c.shardFor(key)
+		groups[s] = append(groups[s], key)
+	}
+	for s, keys := range groups {
+		s.Lock()
+		for _, key := range keys {
+			s.add(key, c.newEntry(lifetime, opts), values[key])
+		}
+		c.unlock(s)
+	}
+}
+
+// DeleteMulti deletes several keys, locking each shard only once.
+func (c *Cache) DeleteMulti(keys []interface{}) {
+	for s, group := range c.groupByShard(keys) {
+		s.Lock()
+		for _, key := range group {
+			if entry := s.entries[key]; entry != nil {
+				s.remove(entry, ReasonDeleted)
+			}
+		}
+		c.unlock(s)
+	}
+	for _, key := range keys {
+		c.publishKey(key)
+	}
+}
+
+// Entry is a value to be stored by StoreAll or Swap, with the lifetime and options it would be
+// given to Store with.
+type Entry struct {
+	Value    interface{}
+	Lifetime time.Duration
+	Options  []EntryOption
+}
+
+// StoreAll stores a group of related entries atomically: the shards of all of the keys are locked
+// together while the entries are stored, so GetMulti sees either none of them or all of them, and
+// Get never sees one of them before the others are stored.
+func (c *Cache) StoreAll(entries map[interface{}]Entry) {
+	c.Swap(entries)
+}
+
+// Swap is like StoreAll, but also deletes the given keys in the same atomic step, for replacing a
+// group of entries with a new group that doesn't have all the same keys. A key that is in entries
+// is stored rather than deleted.
+func (c *Cache) Swap(entries map[interface{}]Entry, keys ...interface{}) {
+	stored := make([]*CacheEntry, 0, len(entries))
+	all := make([]interface{}, 0, len(entries)+len(keys))
+	for key, e := range entries {
+		stored = append(stored, c.newEntry(e.Lifetime, e.Options))
+		all = append(all, key)
+	}
+	var deleted []interface{}
+	for _, key := range keys {
+		if _, ok := entries[key]; !ok {
+			deleted = append(deleted, key)
+		}
+	}
+	all = append(all, deleted...)
+
+	locked := c.lockShards(all)
+	for i, key := range all[:len(stored)] {
+		c.shardFor(key).add(key, stored[i], entries[key].Value)
+	}
+	for _, key := range deleted {
+		s := c.shardFor(key)
+		if entry := s.entries[key]; entry != nil {
+			s.remove(entry, ReasonDeleted)
+		}
+	}
+	for _, s := range locked {
+		c.unlock(s)
+	}
+	for _, key := range deleted {
+		c.publishKey(key)
+	}
+}
@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMulti(t *testing.T) {
+	cache := NewShardedCache(4)
+
+	cache.StoreMulti(map[interface{}]interface{}{"a": 1, "b": 2, "c": 3}, time.Minute)
+
+	got := cache.GetMulti([]interface{}{"a", "b", "c", "missing"})
+	if len(got) != 3 || got["a"] != 1 || got["b"] != 2 || got["c"] != 3 {
+		t.Errorf("Expected values for a, b and c, but got %v", got)
+	}
+
+	cache.DeleteMulti([]interface{}{"a", "c"})
+
+	got = cache.GetMulti([]interface{}{"a", "b", "c"})
+	if len(got) != 1 || got["b"] != 2 {
+		t.Errorf("Expected only b to remain, but got %v", got)
+	}
+}
+
+func TestStoreAll(t *testing.T) {
+	cache := NewShardedCache(16)
+	defer cache.Free()
+
+	keys := []interface{}{"SiteTree", "Menu1", "Menu2", "Menu3"}
+	version := func(n int) map[interface{}]Entry {
+		entries := make(map[interface{}]Entry)
+		for _, key := range keys {
+			entries[key] = Entry{Value: n, Lifetime: time.Minute}
+		}
+		return entries
+	}
+	cache.StoreAll(version(0))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for n := 1; n <= 200; n++ {
+			cache.StoreAll(version(n))
+		}
+	}()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		got := cache.GetMulti(keys)
+		for _, key := range keys {
+			if got[key] != got["SiteTree"] {
+				t.Fatalf("Expected all entries to be from the same version, but got %v", got)
+			}
+		}
+	}
+
+	cache.Swap(map[interface{}]Entry{"SiteTree": {Value: "new", Lifetime: time.Minute}}, "Menu2", "Menu3", "SiteTree")
+	got := cache.GetMulti(keys)
+	if len(got) != 2 || got["SiteTree"] != "new" || got["Menu1"] != 200 {
+		t.Errorf("Expected Swap to store SiteTree and delete Menu2 and Menu3, but got %v", got)
+	}
+}
+
+func TestGetMultiStale(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cache := NewCache(WithClock(clock), WithSweepInterval(time.Hour))
+	defer cache.Free()
+	var n int
+	cache.StorePerpetual("Stale", func() interface{} {
+		n++
+		if n == 1 {
+			return "first"
+		}
+		return nil
+	}, time.Minute, WithStaleWhileRevalidate(time.Minute))
+	cache.Store("Nil", nil, time.Hour)
+
+	// too stale to return, so GetMulti waits for the regenerated value, which is nil
+	clock.Add(3 * time.Minute)
+	got := cache.GetMulti([]interface{}{"Stale", "Nil"})
+	if v, ok := got["Stale"]; !ok || v != nil {
+		t.Errorf("Expected the regenerated nil value of cache key 'Stale', but got %v", got)
+	}
+	if v, ok := got["Nil"]; !ok || v != nil {
+		t.Errorf("Expected the nil value of cache key 'Nil', but got %v", got)
+	}
+	if hits := cache.Stats().Hits; hits != 2 {
+		t.Errorf("Expected each key to be counted as one hit, but got %d hits", hits)
+	}
+}
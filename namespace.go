@@ -0,0 +1,253 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// NamespacedKey is the key under which entries stored through a Namespace are held in the
+// underlying cache.
+type NamespacedKey struct {
+	Namespace string
+	Key       interface{}
+}
+
+// Namespace is a view of a Cache in which keys are scoped to a name, so that different subsystems
+// can share a cache without their keys colliding, and each can be invalidated independently. It
+// is created with Cache.Namespace.
+type Namespace struct {
+	cache *Cache
+	name  string
+}
+
+// Namespace returns a view of the cache in which keys are scoped to the given name. Namespaces
+// with the same name share their entries.
+func (c *Cache) Namespace(name string) *Namespace {
+	return &Namespace{cache: c, name: name}
+}
+
+// InvalidateNamespace deletes all the entries stored through a namespace with the given name.
+// Each shard is locked once while its entries are checked.
+func (c *Cache) InvalidateNamespace(name string) {
+	c.invalidateNamespace(name)
+	c.publish(Invalidation{Kind: NamespaceInvalidation, Name: name})
+}
+
+// invalidateNamespace is InvalidateNamespace without publishing to the cache's bus.
+func (c *Cache) invalidateNamespace(name string) {
+	for _, s := range c.shards {
+		s.Lock()
+		for key, entry := range s.entries {
+			if nk, ok := key.(NamespacedKey); ok && nk.Namespace == name {
+				s.remove(entry, ReasonDeleted)
+			}
+		}
+		c.unlock(s)
+	}
+}
+
+// key returns the key in the underlying cache for a key in the namespace.
+func (ns *Namespace) key(key interface{}) NamespacedKey {
+	return NamespacedKey{Namespace: ns.name, Key: key}
+}
+
+// Name returns the name of the namespace.
+func (ns *Namespace) Name() string {
+	return ns.name
+}
+
+// Store is Cache.Store within the namespace.
+func (ns *Namespace) Store(key interface{}, value interface{}, lifetime time.Duration, opts ...EntryOption) {
+	ns.cache.Store(ns.key(key), value, lifetime, opts...)
+}
+
+// StorePerpetual is Cache.StorePerpetual within the namespace.
+func (ns *Namespace) StorePerpetual(key interface{}, fn ValueGenerator, lifetime time.Duration, opts ...EntryOption) {
+	ns.cache.StorePerpetual(ns.key(key), fn, lifetime, opts...)
+}
+
+// StorePerpetualE is Cache.StorePerpetualE within the namespace.
+func (ns *Namespace) StorePerpetualE(key interface{}, fn ValueGeneratorE, lifetime time.Duration, opts ...EntryOption) error {
+	return ns.cache.StorePerpetualE(ns.key(key), fn, lifetime, opts...)
+}
+
+// StorePerpetualContext is Cache.StorePerpetualContext within the namespace.
+func (ns *Namespace) StorePerpetualContext(ctx context.Context, key interface{}, fn ContextValueGenerator, lifetime time.Duration, opts ...EntryOption) error {
+	return ns.cache.StorePerpetualContext(ctx, ns.key(key), fn, lifetime, opts...)
+}
+
+// StoreTyped is Cache.StoreTyped within the namespace.
+func (ns *Namespace) StoreTyped(key interface{}, value interface{}, lifetime time.Duration, opts ...EntryOption) {
+	ns.cache.StoreTyped(ns.key(key), value, lifetime, opts...)
+}
+
+// Get is Cache.Get within the namespace.
+func (ns *Namespace) Get(key interface{}) interface{} {
+	return ns.cache.Get(ns.key(key))
+}
+
+// GetOK is Cache.GetOK within the namespace.
+func (ns *Namespace) GetOK(key interface{}) (interface{}, bool) {
+	return ns.cache.GetOK(ns.key(key))
+}
+
+// GetAs is Cache.GetAs within the namespace.
+func (ns *Namespace) GetAs(key interface{}, ptr interface{}) error {
+	return ns.cache.GetAs(ns.key(key), ptr)
+}
+
+// Lookup is Cache.Lookup within the namespace.
+func (ns *Namespace) Lookup(key interface{}) (value interface{}, found, negative bool) {
+	return ns.cache.Lookup(ns.key(key))
+}
+
+// StoreNegative is Cache.StoreNegative within the namespace.
+func (ns *Namespace) StoreNegative(key interface{}, lifetime time.Duration, opts ...EntryOption) {
+	ns.cache.StoreNegative(ns.key(key), lifetime, opts...)
+}
+
+// Delete is Cache.Delete within the namespace.
+func (ns *Namespace) Delete(key interface{}) bool {
+	return ns.cache.Delete(ns.key(key))
+}
+
+// DeleteAndGet is Cache.DeleteAndGet within the namespace.
+func (ns *Namespace) DeleteAndGet(key interface{}) (interface{}, bool) {
+	return ns.cache.DeleteAndGet(ns.key(key))
+}
+
+// Refresh is Cache.Refresh within the namespace.
+func (ns *Namespace) Refresh(key interface{}) error {
+	return ns.cache.Refresh(ns.key(key))
+}
+
+// InvalidateAndRefresh is Cache.InvalidateAndRefresh within the namespace.
+func (ns *Namespace) InvalidateAndRefresh(key interface{}) error {
+	return ns.cache.InvalidateAndRefresh(ns.key(key))
+}
+
+// Pause is Cache.Pause within the namespace.
+func (ns *Namespace) Pause(key interface{}) error {
+	return ns.cache.Pause(ns.key(key))
+}
+
+// Resume is Cache.Resume within the namespace.
+func (ns *Namespace) Resume(key interface{}) error {
+	return ns.cache.Resume(ns.key(key))
+}
+
+// SetLifetime is Cache.SetLifetime within the namespace.
+func (ns *Namespace) SetLifetime(key interface{}, lifetime time.Duration) error {
+	return ns.cache.SetLifetime(ns.key(key), lifetime)
+}
+
+// Inspect is Cache.Inspect within the namespace.
+func (ns *Namespace) Inspect(key interface{}) (EntryInfo, bool) {
+	return ns.cache.Inspect(ns.key(key))
+}
+
+// GetStale is Cache.GetStale within the namespace.
+func (ns *Namespace) GetStale(key interface{}) (value interface{}, stale, found bool) {
+	return ns.cache.GetStale(ns.key(key))
+}
+
+// GetWait is Cache.GetWait within the namespace.
+func (ns *Namespace) GetWait(ctx context.Context, key interface{}) (interface{}, error) {
+	return ns.cache.GetWait(ctx, ns.key(key))
+}
+
+// Watch is Cache.Watch within the namespace. The keys of the changes sent are NamespacedKeys.
+func (ns *Namespace) Watch(key interface{}) <-chan ValueChange {
+	return ns.cache.Watch(ns.key(key))
+}
+
+// LockKey is Cache.LockKey within the namespace.
+func (ns *Namespace) LockKey(key interface{}) (unlock func()) {
+	return ns.cache.LockKey(ns.key(key))
+}
+
+// Pin is Cache.Pin within the namespace.
+func (ns *Namespace) Pin(key interface{}) {
+	ns.cache.Pin(ns.key(key))
+}
+
+// Unpin is Cache.Unpin within the namespace.
+func (ns *Namespace) Unpin(key interface{}) {
+	ns.cache.Unpin(ns.key(key))
+}
+
+// StoreSliding is Cache.StoreSliding within the namespace.
+func (ns *Namespace) StoreSliding(key interface{}, value interface{}, idle, maxLifetime time.Duration, opts ...EntryOption) {
+	ns.cache.StoreSliding(ns.key(key), value, idle, maxLifetime, opts...)
+}
+
+// StoreTagged is Cache.StoreTagged within the namespace. Tags are not scoped to the namespace.
+func (ns *Namespace) StoreTagged(key interface{}, value interface{}, lifetime time.Duration, tags ...string) {
+	ns.cache.StoreTagged(ns.key(key), value, lifetime, tags...)
+}
+
+// StoreWithPolicy is Cache.StoreWithPolicy within the namespace.
+func (ns *Namespace) StoreWithPolicy(key interface{}, value interface{}, policy ExpiryPolicy, opts ...EntryOption) {
+	ns.cache.StoreWithPolicy(ns.key(key), value, policy, opts...)
+}
+
+// StoreDependent is Cache.StoreDependent within the namespace. The keys it depends on are also in
+// the namespace.
+func (ns *Namespace) StoreDependent(key interface{}, value interface{}, lifetime time.Duration, dependsOn ...interface{}) {
+	keys := make([]interface{}, len(dependsOn))
+	for i, k := range dependsOn {
+		keys[i] = ns.key(k)
+	}
+	ns.cache.StoreDependent(ns.key(key), value, lifetime, keys...)
+}
+
+// Add is Cache.Add within the namespace.
+func (ns *Namespace) Add(key interface{}, value interface{}, lifetime time.Duration, opts ...EntryOption) bool {
+	return ns.cache.Add(ns.key(key), value, lifetime, opts...)
+}
+
+// Update is Cache.Update within the namespace.
+func (ns *Namespace) Update(key interface{}, fn func(old interface{}) interface{}, lifetime time.Duration) interface{} {
+	return ns.cache.Update(ns.key(key), fn, lifetime)
+}
+
+// CompareAndSwap is Cache.CompareAndSwap within the namespace.
+func (ns *Namespace) CompareAndSwap(key interface{}, old, new interface{}) bool {
+	return ns.cache.CompareAndSwap(ns.key(key), old, new)
+}
+
+// Increment is Cache.Increment within the namespace.
+func (ns *Namespace) Increment(key interface{}, delta int64, lifetime time.Duration) (int64, error) {
+	return ns.cache.Increment(ns.key(key), delta, lifetime)
+}
+
+// Decrement is Cache.Decrement within the namespace.
+func (ns *Namespace) Decrement(key interface{}, delta int64, lifetime time.Duration) (int64, error) {
+	return ns.cache.Decrement(ns.key(key), delta, lifetime)
+}
+
+// GetVersioned is Cache.GetVersioned within the namespace.
+func (ns *Namespace) GetVersioned(key interface{}) (value interface{}, version uint64, ok bool) {
+	return ns.cache.GetVersioned(ns.key(key))
+}
+
+// StoreIfVersion is Cache.StoreIfVersion within the namespace.
+func (ns *Namespace) StoreIfVersion(key, value interface{}, expectedVersion uint64, lifetime time.Duration, opts ...EntryOption) (uint64, bool) {
+	return ns.cache.StoreIfVersion(ns.key(key), value, expectedVersion, lifetime, opts...)
+}
+
+// Touch is Cache.Touch within the namespace.
+func (ns *Namespace) Touch(key interface{}, lifetime time.Duration) bool {
+	return ns.cache.Touch(ns.key(key), lifetime)
+}
+
+// TTL is Cache.TTL within the namespace.
+func (ns *Namespace) TTL(key interface{}) (time.Duration, bool) {
+	return ns.cache.TTL(ns.key(key))
+}
+
+// Invalidate deletes all the entries in the namespace. It is the same as calling
+// InvalidateNamespace on the cache with the namespace's name.
+func (ns *Namespace) Invalidate() {
+	ns.cache.InvalidateNamespace(ns.name)
+}
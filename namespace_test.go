@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNamespace(t *testing.T) {
+	cache := NewCache()
+	templates := cache.Namespace("templates")
+	menus := cache.Namespace("menus")
+
+	templates.Store("home", "template", time.Minute)
+	menus.Store("home", "menu", time.Minute)
+	cache.Store("home", "global", time.Minute)
+
+	if v := templates.Get("home"); v != "template" {
+		t.Errorf("Expected templates key 'home' to have value 'template', but got '%v'", v)
+	}
+	if v := menus.Get("home"); v != "menu" {
+		t.Errorf("Expected menus key 'home' to have value 'menu', but got '%v'", v)
+	}
+
+	cache.InvalidateNamespace("templates")
+
+	if v := templates.Get("home"); v != nil {
+		t.Errorf("Expected templates key 'home' to be invalidated, but has value '%v'", v)
+	}
+	if v := menus.Get("home"); v != "menu" {
+		t.Errorf("Expected menus key 'home' to be unaffected, but got '%v'", v)
+	}
+	if v := cache.Get("home"); v != "global" {
+		t.Errorf("Expected cache key 'home' to be unaffected, but got '%v'", v)
+	}
+}
+
+func TestNamespaceKeyMethods(t *testing.T) {
+	cache := NewCache()
+	defer cache.Free()
+	ns := cache.Namespace("counters")
+
+	if !ns.Add("hits", 1, time.Minute) || ns.Add("hits", 2, time.Minute) {
+		t.Errorf("Expected only the first Add to succeed")
+	}
+	if n, err := ns.Increment("hits", 2, time.Minute); n != 3 || err != nil {
+		t.Errorf("Expected Increment to return 3, but got %d, %v", n, err)
+	}
+	if v := ns.Update("hits", func(old interface{}) interface{} { return old.(int) * 2 }, time.Minute); v != 6 {
+		t.Errorf("Expected Update to return 6, but got '%v'", v)
+	}
+	if !ns.CompareAndSwap("hits", 6, 7) {
+		t.Errorf("Expected CompareAndSwap to swap the value")
+	}
+	if !ns.Touch("hits", time.Hour) {
+		t.Errorf("Expected Touch to find the entry")
+	}
+	if ttl, ok := ns.TTL("hits"); !ok || ttl <= time.Minute {
+		t.Errorf("Expected the touched entry to have a TTL of about an hour, but got %v, %v", ttl, ok)
+	}
+
+	// the methods use the namespaced key, not the key itself
+	if v := cache.Get(NamespacedKey{Namespace: "counters", Key: "hits"}); v != 7 {
+		t.Errorf("Expected the value to be stored under the namespaced key, but got '%v'", v)
+	}
+	if _, ok := cache.GetOK("hits"); ok {
+		t.Errorf("Did not expect the key to be stored outside the namespace")
+	}
+
+	ns.Store("parent", "value", time.Minute)
+	ns.StoreDependent("child", "value", time.Minute, "parent")
+	ns.Delete("parent")
+	if _, ok := ns.GetOK("child"); ok {
+		t.Errorf("Expected the dependent entry to be deleted with the entry it depends on in the namespace")
+	}
+}
@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// DefaultLifetime can be passed to Store in place of a lifetime, to have the entry use the
+// cache's default lifetime as set by WithDefaultTTL.
+const DefaultLifetime time.Duration = -1
+
+// NoExpiry can be passed to Store in place of a lifetime, to store an entry that never expires. It
+// remains in the cache until it is deleted or evicted.
+const NoExpiry time.Duration = 0
+
+// Option configures a Cache when it is created with NewCache.
+type Option func(*Cache)
+
+// WithShards sets the number of shards the cache's entries are split between. Each shard has its
+// own lock, so more shards reduce contention between goroutines using the cache concurrently.
+// The default is based on GOMAXPROCS. A count less than 1 is treated as 1.
+func WithShards(n int) Option {
+	return func(c *Cache) {
+		if n < 1 {
+			n = 1
+		}
+		c.shardCount = n
+	}
+}
+
+// WithSweepInterval sets how often the cache checks for expired entries. The default is one
+// second. Get also checks the expiry of the entry it returns, so this mostly determines how
+// quickly expired entries are freed and perpetual entries regenerated.
+func WithSweepInterval(d time.Duration) Option {
+	return func(c *Cache) {
+		if d > 0 {
+			c.sweepInterval = d
+		}
+	}
+}
+
+// WithDefaultTTL sets the lifetime used for entries stored with a lifetime of DefaultLifetime
+// (or any other negative duration). The default is NoExpiry, so such entries never expire.
+func WithDefaultTTL(d time.Duration) Option {
+	return func(c *Cache) {
+		c.defaultTTL = d
+	}
+}
+
+// WithClock sets the clock used to determine when entries expire, and to schedule the sweep and
+// periodic snapshots.
+func WithClock(clock Clock) Option {
+	return func(c *Cache) {
+		c.clock = clock
+	}
+}
+
+// WithStaleReads makes Get return entries that have passed their expiry but have not yet been
+// removed by the sweep, rather than treating them as missing. Values may then be up to one sweep
+// interval out of date, which is how the cache originally behaved.
+func WithStaleReads() Option {
+	return func(c *Cache) {
+		c.staleReads = true
+	}
+}
+
+// WithTTLJitter randomises the lifetime of each entry by up to the given fraction either way, so
+// that entries stored at the same time, such as when a program starts, don't all expire in the
+// same sweep. For example a fraction of 0.1 gives an entry stored with a lifetime of ten minutes
+// a lifetime between nine and eleven minutes. Perpetual entries are given a new random lifetime
+// each time they are regenerated. The fraction is limited to between 0 and 1.
+func WithTTLJitter(fraction float64) Option {
+	return func(c *Cache) {
+		c.jitter = min(max(fraction, 0), 1)
+	}
+}
+
+// jittered returns a lifetime randomised by the cache's TTL jitter. The result is at least a
+// nanosecond, so that a lifetime jittered down to nothing isn't NoExpiry.
+func (c *Cache) jittered(lifetime time.Duration) time.Duration {
+	if c.jitter == 0 || lifetime <= 0 {
+		return lifetime
+	}
+	return max(lifetime+time.Duration(float64(lifetime)*c.jitter*(2*rand.Float64()-1)), time.Nanosecond)
+}
@@ -0,0 +1,428 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+// ErrNotFound is returned when an operation needs an entry for a key and there is none.
+var ErrNotFound = errors.New("cache: no entry for key")
+
+// ErrNotPerpetual is returned when an operation that applies only to perpetual entries is used
+// with an entry that is not perpetual.
+var ErrNotPerpetual = errors.New("cache: entry is not perpetual")
+
+// PanicError is the error a generation fails with when the generator panics. The panic is
+// recovered, so it doesn't stop the sweep or the goroutine regenerating the entry, and the entry's
+// FailurePolicy is applied as for any other error.
+type PanicError struct {
+	// Value is the value passed to panic.
+	Value interface{}
+
+	// Stack is the stack trace of the goroutine when it panicked.
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("cache: generator panicked: %v", e.Value)
+}
+
+// FailurePolicy determines what happens to a perpetual entry when its generator fails.
+type FailurePolicy int
+
+const (
+	// KeepPrevious keeps the previous value until the entry next expires, at the end of its
+	// normal lifetime. This is the default.
+	KeepPrevious FailurePolicy = iota
+
+	// Retry keeps the previous value, but tries to regenerate it again sooner than its normal
+	// lifetime, backing off exponentially while the generator keeps failing. See WithRetryBackoff.
+	Retry
+
+	// Evict removes the entry from the cache.
+	Evict
+)
+
+// default backoff for the Retry failure policy, if WithRetryBackoff is not given.
+const (
+	defaultMinBackoff = time.Second
+	defaultMaxBackoff = time.Minute
+)
+
+// WithFailurePolicy sets what happens to a perpetual entry when its generator fails.
+func WithFailurePolicy(policy FailurePolicy) EntryOption {
+	return func(e *CacheEntry) {
+		e.policy = policy
+	}
+}
+
+// WithRetryBackoff sets the backoff used by the Retry failure policy. The first retry happens min
+// after the failure, and the delay doubles with each consecutive failure up to max. The default
+// is from one second to one minute.
+func WithRetryBackoff(min, max time.Duration) EntryOption {
+	return func(e *CacheEntry) {
+		e.minBackoff = min
+		e.maxBackoff = max
+	}
+}
+
+// WithStaleWhileRevalidate makes a perpetual entry regenerate its value in a background goroutine
+// when it expires, rather than in the sweep. The old value continues to be returned while this
+// happens, so a slow generator doesn't hold up the expiry of other entries. If maxStale is greater
+// than zero and the value is still not regenerated that long after expiry, Get blocks until the
+// fresh value is available rather than returning a value that stale.
+//
+// It has no effect on entries that are not perpetual.
+func WithStaleWhileRevalidate(maxStale time.Duration) EntryOption {
+	return func(e *CacheEntry) {
+		e.async = true
+		e.maxStale = maxStale
+	}
+}
+
+// WithRefreshAhead makes a perpetual entry regenerate its value once the given fraction of its
+// lifetime has passed, rather than at the end of it, so that the new value is usually ready before
+// the old one is due to expire. For example with a fraction of 0.8, an entry with a lifetime of
+// ten minutes is regenerated every eight minutes. The fraction is limited to between 0 and 1, and
+// a fraction of 0 or 1 regenerates at the end of the lifetime as usual. Combine it with
+// WithStaleWhileRevalidate to regenerate in the background, in which case maxStale is counted from
+// the end of the lifetime rather than from when regeneration starts.
+//
+// It has no effect on entries that are not perpetual.
+func WithRefreshAhead(fraction float64) EntryOption {
+	return func(e *CacheEntry) {
+		if fraction > 0 && fraction < 1 {
+			e.refreshAhead = fraction
+		}
+	}
+}
+
+// WithGeneratorTimeout limits how long a perpetual entry's generator may take. The context passed
+// to a ContextValueGenerator has this deadline, and the generation fails if it is exceeded. Plain
+// ValueGenerators can't be interrupted, so this has no effect on them.
+func WithGeneratorTimeout(d time.Duration) EntryOption {
+	return func(e *CacheEntry) {
+		e.timeout = d
+	}
+}
+
+// WithCircuitBreaker gives a perpetual entry a circuit breaker that opens after its generator fails
+// threshold times in a row, including generations that exceed the timeout set by
+// WithGeneratorTimeout. While the breaker is open the entry keeps its last value, whatever its
+// FailurePolicy, and the generator is retried with the backoff set by WithRetryBackoff, doubling
+// after each further failure, so that a struggling backend isn't called at the entry's usual rate.
+// The breaker closes when the generator next succeeds.
+//
+// It has no effect on entries that are not perpetual.
+func WithCircuitBreaker(threshold int) EntryOption {
+	return func(e *CacheEntry) {
+		e.breaker = threshold
+	}
+}
+
+// generate calls the entry's generator with the given context, limited by the entry's timeout,
+// and records how long it took. If the generator panics, it returns a *PanicError.
+func (c *Cache) generate(ctx context.Context, entry *CacheEntry) (interface{}, error) {
+	if entry.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, entry.timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	value, err := call(ctx, entry.fn)
+	elapsed := time.Since(start)
+	c.counters.loads.Add(1)
+	c.counters.loadTime.Add(int64(elapsed))
+	c.logGenerated(entry, elapsed, err)
+	return value, err
+}
+
+// call calls a generator, recovering from a panic in it.
+func call(ctx context.Context, fn ContextValueGenerator) (value interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			value, err = nil, &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return fn(ctx)
+}
+
+// refresh starts regenerating a perpetual entry in a new goroutine if it is not already being
+// regenerated, and returns a channel that is closed when the regeneration completes. The caller
+// must hold the shard's lock.
+func (c *Cache) refresh(s *shard, entry *CacheEntry) chan struct{} {
+	done := entry.refreshing
+	if done == nil {
+		done = make(chan struct{})
+		entry.refreshing = done
+		if c.pool != nil {
+			c.pool.submit(s, entry)
+		} else {
+			go c.regenerate(s, entry)
+		}
+	}
+	return done
+}
+
+// regenerate calls a perpetual entry's generator and replaces its value with the result. The value
+// is replaced atomically, unless the entry was deleted or replaced while the value was being
+// generated. If the generator fails, the entry's failure policy is applied and the error is
+// returned. The shard must not be locked by the caller, as the generator may be slow.
+func (c *Cache) regenerate(s *shard, entry *CacheEntry) error {
+	if !c.regenerations.start() {
+		return c.abandon(s, entry)
+	}
+	defer c.regenerations.done()
+	nv, err := c.generate(c.ctx, entry)
+	c.countRefresh(entry.key, err)
+
+	s.Lock()
+	defer c.unlock(s)
+
+	if entry.refreshing != nil {
+		close(entry.refreshing)
+		entry.refreshing = nil
+	}
+	entry.reserved = false
+	if s.entries[entry.key] != entry {
+		return err
+	}
+
+	lifetime := entry.ahead(c.jittered(entry.lifetime))
+	if err != nil {
+		entry.failures++
+		c.logRefreshFailed(entry, err)
+		switch {
+		case entry.breakerOpen():
+			if entry.failures == entry.breaker {
+				c.logBreakerOpened(entry)
+			}
+			lifetime = entry.backoff(entry.failures - entry.breaker + 1)
+		case entry.policy == Evict:
+			s.remove(entry, ReasonFailed)
+			return err
+		case entry.policy == Retry:
+			lifetime = entry.backoff(entry.failures)
+		}
+	} else {
+		// store the new value, which is only up to date if it wasn't invalidated again while it
+		// was being generated
+		entry.failures = 0
+		entry.invalidated = entry.rerun
+		s.setValue(entry, nv, ChangeRefresh)
+	}
+
+	// recompute the expiry
+	now := c.clock.Now()
+	if err == nil {
+		entry.refreshed = now
+	}
+	entry.expiry = now.Add(lifetime)
+	if entry.rerun {
+		entry.rerun = false
+		c.refresh(s, entry)
+	} else if !entry.paused {
+		s.expiries.reschedule(entry)
+	}
+	return err
+}
+
+// Refresh regenerates the value of a perpetual entry now, rather than waiting for it to expire,
+// and reschedules its next regeneration for a full lifetime later. It waits for the new value, and
+// returns the generator's error if it fails, in which case the entry's FailurePolicy is applied.
+// If the entry is already being regenerated, Refresh waits for that to finish and then regenerates
+// it again, so the value always reflects changes made before Refresh was called. It returns
+// ErrNotFound if there is no entry for the key, and ErrNotPerpetual if the entry is not perpetual.
+func (c *Cache) Refresh(key interface{}) error {
+	if c.closed.Load() {
+		return ErrClosed
+	}
+	s := c.shardFor(key)
+	s.Lock()
+	entry := s.entries[key]
+	for entry != nil && entry.refreshing != nil {
+		done := entry.refreshing
+		s.Unlock()
+		<-done
+		s.Lock()
+		entry = s.entries[key]
+	}
+	if _, err := perpetualEntry(s, key); err != nil {
+		s.Unlock()
+		return err
+	}
+	// take the entry off the heap so that the sweep doesn't also regenerate it.
+	entry.refreshing = make(chan struct{})
+	s.expiries.unschedule(entry)
+	s.Unlock()
+	return c.regenerate(s, entry)
+}
+
+// InvalidateAndRefresh marks the value of a perpetual entry stale and starts regenerating it in the
+// background, without waiting for the new value, for instance when an administrator saves the data
+// behind it. Until the new value is stored, Get keeps returning the old one and GetStale reports
+// it as stale, so readers don't each try to repopulate the entry. Calls made while the entry is
+// being regenerated share a single further regeneration, which starts once the current one
+// completes, so the value reflects changes made before InvalidateAndRefresh was called. It returns
+// ErrNotFound if there is no entry for the key, and ErrNotPerpetual if the entry is not perpetual.
+// LoaderCache.InvalidateAndRefresh does the same for loaded values.
+func (c *Cache) InvalidateAndRefresh(key interface{}) error {
+	if c.closed.Load() {
+		return ErrClosed
+	}
+	s := c.shardFor(key)
+	s.Lock()
+	defer s.Unlock()
+	entry, err := perpetualEntry(s, key)
+	if err != nil {
+		return err
+	}
+	entry.invalidated = true
+	if entry.refreshing != nil {
+		entry.rerun = true
+		return nil
+	}
+	// take the entry off the heap so that the sweep doesn't also regenerate it.
+	s.expiries.unschedule(entry)
+	c.refresh(s, entry)
+	return nil
+}
+
+// invalidate marks the entry for a key stale, as InvalidateAndRefresh does, and returns true if
+// there is one.
+func (c *Cache) invalidate(key interface{}) bool {
+	s := c.shardFor(key)
+	s.Lock()
+	defer s.Unlock()
+	entry := s.entries[key]
+	if entry == nil {
+		return false
+	}
+	entry.invalidated = true
+	return true
+}
+
+// perpetualEntry returns the perpetual entry for a key, or an error if there is no entry or it is
+// not perpetual. The caller must hold the shard's lock.
+func perpetualEntry(s *shard, key interface{}) (*CacheEntry, error) {
+	entry := s.entries[key]
+	switch {
+	case entry == nil:
+		return nil, ErrNotFound
+	case !entry.perpetual:
+		return nil, ErrNotPerpetual
+	}
+	return entry, nil
+}
+
+// Pause stops a perpetual entry from being regenerated, while keeping its current value, until
+// Resume is called. Refresh can still be used to regenerate a paused entry. It returns ErrNotFound
+// if there is no entry for the key, and ErrNotPerpetual if the entry is not perpetual.
+func (c *Cache) Pause(key interface{}) error {
+	if c.closed.Load() {
+		return ErrClosed
+	}
+	s := c.shardFor(key)
+	s.Lock()
+	defer s.Unlock()
+	entry, err := perpetualEntry(s, key)
+	if err != nil {
+		return err
+	}
+	entry.paused = true
+	s.expiries.unschedule(entry)
+	return nil
+}
+
+// Resume restarts the regeneration of a perpetual entry paused with Pause. If the entry was due to
+// be regenerated while it was paused, it is regenerated by the next sweep. It returns ErrNotFound
+// if there is no entry for the key, and ErrNotPerpetual if the entry is not perpetual.
+func (c *Cache) Resume(key interface{}) error {
+	if c.closed.Load() {
+		return ErrClosed
+	}
+	s := c.shardFor(key)
+	s.Lock()
+	defer s.Unlock()
+	entry, err := perpetualEntry(s, key)
+	if err != nil {
+		return err
+	}
+	entry.paused = false
+	if entry.refreshing == nil {
+		// entries being regenerated are rescheduled when that completes
+		s.expiries.reschedule(entry)
+	}
+	return nil
+}
+
+// SetLifetime changes the lifetime of a perpetual entry. The entry is next regenerated the new
+// lifetime from now, and with the new lifetime after that. It returns ErrNotFound if there is no
+// entry for the key, and ErrNotPerpetual if the entry is not perpetual.
+func (c *Cache) SetLifetime(key interface{}, lifetime time.Duration) error {
+	if c.closed.Load() {
+		return ErrClosed
+	}
+	s := c.shardFor(key)
+	s.Lock()
+	defer s.Unlock()
+	entry, err := perpetualEntry(s, key)
+	if err != nil {
+		return err
+	}
+	entry.lifetime = lifetime
+	if entry.refreshing == nil {
+		entry.expiry = c.clock.Now().Add(entry.ahead(lifetime))
+		if !entry.paused {
+			s.expiries.reschedule(entry)
+		}
+	}
+	return nil
+}
+
+// breakerOpen returns true if the entry has a circuit breaker and it is open.
+func (entry *CacheEntry) breakerOpen() bool {
+	return entry.breaker > 0 && entry.failures >= entry.breaker
+}
+
+// backoff returns how long to wait before retrying the generation of an entry that has failed n
+// times.
+func (entry *CacheEntry) backoff(n int) time.Duration {
+	min, max := entry.minBackoff, entry.maxBackoff
+	if min <= 0 {
+		min = defaultMinBackoff
+	}
+	if max <= 0 {
+		max = defaultMaxBackoff
+	}
+	d := min
+	for i := 1; i < n && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
+
+// ahead returns how long after being generated a perpetual entry with the given lifetime should be
+// regenerated, taking account of WithRefreshAhead.
+func (entry *CacheEntry) ahead(lifetime time.Duration) time.Duration {
+	if entry.refreshAhead == 0 {
+		return lifetime
+	}
+	return time.Duration(float64(lifetime) * entry.refreshAhead)
+}
+
+// tooStale returns true if a perpetual entry is so far past its expiry that Get should wait for it
+// to be regenerated.
+func (entry *CacheEntry) tooStale(now time.Time) bool {
+	if !entry.async || entry.maxStale <= 0 {
+		return false
+	}
+	early := entry.lifetime - entry.ahead(entry.lifetime)
+	return now.After(entry.expiry.Add(early + entry.maxStale))
+}
@@ -0,0 +1,468 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// counter returns a ValueGenerator that returns 1, 2, 3... on successive calls, after sleeping
+// for the given delay.
+func counter(delay time.Duration) ValueGenerator {
+	var mu sync.Mutex
+	n := 0
+	return func() interface{} {
+		time.Sleep(delay)
+		mu.Lock()
+		defer mu.Unlock()
+		n++
+		return n
+	}
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	cache := NewCache(WithSweepInterval(time.Millisecond * 10))
+	key := "SWR"
+
+	cache.StorePerpetual(key, counter(time.Millisecond*200), time.Millisecond*50, WithStaleWhileRevalidate(0))
+
+	// the entry has expired and is being regenerated in the background, so the old value is
+	// returned without waiting
+	time.Sleep(time.Millisecond * 100)
+	start := time.Now()
+	if v := cache.Get(key); v == nil || v.(int) != 1 {
+		t.Errorf("Expected cache key '%s' to have stale value 1, but got '%v'", key, v)
+	}
+	if d := time.Since(start); d > time.Millisecond*50 {
+		t.Errorf("Expected Get to return without waiting for regeneration, but took %s", d)
+	}
+
+	time.Sleep(time.Millisecond * 300)
+	if v := cache.Get(key); v == nil || v.(int) < 2 {
+		t.Errorf("Expected cache key '%s' to have been regenerated, but got '%v'", key, v)
+	}
+}
+
+func TestStaleWhileRevalidateMaxStale(t *testing.T) {
+	// the sweep never runs during the test, so only Get regenerates the value
+	cache := NewCache(WithSweepInterval(time.Hour))
+	key := "MaxStale"
+
+	cache.StorePerpetual(key, counter(0), time.Millisecond*20, WithStaleWhileRevalidate(time.Millisecond*20))
+
+	time.Sleep(time.Millisecond * 60)
+	if v := cache.Get(key); v == nil || v.(int) != 2 {
+		t.Errorf("Expected Get to wait for a fresh value of cache key '%s', but got '%v'", key, v)
+	}
+}
+
+func TestStorePerpetualContextError(t *testing.T) {
+	cache := NewCache()
+	key := "Failing"
+	fail := errors.New("generator failed")
+
+	err := cache.StorePerpetualContext(context.Background(), key, func(context.Context) (interface{}, error) {
+		return nil, fail
+	}, time.Second)
+
+	if err != fail {
+		t.Errorf("Expected the generator's error, but got '%v'", err)
+	}
+	if v := cache.Get(key); v != nil {
+		t.Errorf("Did not expect cache key '%s' to be set, but has value '%v'", key, v)
+	}
+}
+
+// blockingGenerator returns "initial" on its first call, and on later calls blocks until its
+// context is done, sending the context's error on the returned channel.
+func blockingGenerator() (ContextValueGenerator, chan error) {
+	errs := make(chan error, 1)
+	first := true
+	return func(ctx context.Context) (interface{}, error) {
+		if first {
+			first = false
+			return "initial", nil
+		}
+		<-ctx.Done()
+		errs <- ctx.Err()
+		return nil, ctx.Err()
+	}, errs
+}
+
+func TestGeneratorTimeout(t *testing.T) {
+	cache := NewCache(WithSweepInterval(time.Millisecond * 10))
+	key := "Timeout"
+	fn, errs := blockingGenerator()
+
+	cache.StorePerpetualContext(context.Background(), key, fn, time.Millisecond*20, WithGeneratorTimeout(time.Millisecond*20))
+
+	select {
+	case err := <-errs:
+		if err != context.DeadlineExceeded {
+			t.Errorf("Expected the generator's context to time out, but got '%v'", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Generator was not timed out")
+	}
+
+	// the failed regeneration keeps the previous value
+	if v := cache.Get(key); v == nil || v.(string) != "initial" {
+		t.Errorf("Expected cache key '%s' to keep value 'initial', but got '%v'", key, v)
+	}
+}
+
+func TestFreeCancelsGenerator(t *testing.T) {
+	cache := NewCache(WithSweepInterval(time.Millisecond * 10))
+	fn, errs := blockingGenerator()
+
+	cache.StorePerpetualContext(context.Background(), "Hung", fn, time.Millisecond*20)
+
+	// let the sweep start regenerating the entry, which hangs until it is cancelled
+	time.Sleep(time.Millisecond * 100)
+	cache.Free()
+
+	select {
+	case err := <-errs:
+		if err != context.Canceled {
+			t.Errorf("Expected the generator's context to be cancelled, but got '%v'", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Generator was not cancelled by Free")
+	}
+}
+
+// failingAfter returns a ValueGeneratorE that succeeds the given number of times, and then fails.
+// The number of calls made is sent on the returned channel after each call.
+func failingAfter(successes int) (ValueGeneratorE, chan int) {
+	calls := make(chan int, 100)
+	n := 0
+	return func() (interface{}, error) {
+		n++
+		calls <- n
+		if n > successes {
+			return nil, errors.New("generator failed")
+		}
+		return n, nil
+	}, calls
+}
+
+func TestFailurePolicyEvict(t *testing.T) {
+	cache := NewCache(WithSweepInterval(time.Millisecond * 10))
+	key := "Evict"
+	fn, _ := failingAfter(1)
+
+	if err := cache.StorePerpetualE(key, fn, time.Millisecond*20, WithFailurePolicy(Evict)); err != nil {
+		t.Fatalf("Unexpected error storing perpetual entry: %s", err)
+	}
+	time.Sleep(time.Millisecond * 100)
+
+	if v := cache.Get(key); v != nil {
+		t.Errorf("Expected cache key '%s' to be evicted after failing, but has value '%v'", key, v)
+	}
+}
+
+func TestFailurePolicyRetry(t *testing.T) {
+	cache := NewCache(WithSweepInterval(time.Millisecond * 5))
+	key := "Retry"
+	fn, calls := failingAfter(1)
+
+	// the lifetime is long, so the generator is only called again quickly if it is retried
+	cache.StorePerpetualE(key, fn, time.Millisecond*50, WithFailurePolicy(Retry), WithRetryBackoff(time.Millisecond*5, time.Millisecond*10))
+	time.Sleep(time.Millisecond * 200)
+
+	if n := len(calls); n < 8 {
+		t.Errorf("Expected the failing generator to be retried several times, but was called %d times", n)
+	}
+	if v := cache.Get(key); v == nil || v.(int) != 1 {
+		t.Errorf("Expected cache key '%s' to keep value 1, but got '%v'", key, v)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cache := NewCache(WithClock(clock), WithSweepInterval(time.Hour))
+	defer cache.Free()
+
+	failing := false
+	n := 0
+	fn := func() (interface{}, error) {
+		if failing {
+			return nil, errors.New("database down")
+		}
+		n++
+		return n, nil
+	}
+	// both use the Evict policy, which only applies until the breaker opens
+	cache.StorePerpetualE("Key", fn, time.Second*10, WithFailurePolicy(Evict), WithCircuitBreaker(2), WithRetryBackoff(time.Minute, time.Hour))
+	cache.StorePerpetualE("Guarded", fn, time.Second*10, WithCircuitBreaker(1), WithFailurePolicy(Evict), WithRetryBackoff(time.Minute, time.Hour))
+	failing = true
+
+	cache.Refresh("Guarded")
+	if info, ok := cache.Inspect("Guarded"); !ok || !info.BreakerOpen {
+		t.Fatalf("Expected the breaker to open after one failure and keep the entry, but got %+v, %v", info, ok)
+	}
+
+	cache.Refresh("Key")
+	if v := cache.Get("Key"); v != nil {
+		t.Errorf("Expected the entry to be evicted before its breaker opened, but got '%v'", v)
+	}
+
+	for i, backoff := range []time.Duration{2 * time.Minute, 4 * time.Minute} {
+		cache.Refresh("Guarded")
+		info, _ := cache.Inspect("Guarded")
+		if info.Failures != i+2 || !info.BreakerOpen {
+			t.Errorf("Expected the breaker to stay open after %d failures, but got %+v", i+2, info)
+		}
+		if d := info.Expiry.Sub(clock.Now()); d != backoff {
+			t.Errorf("Expected a retry after %v with the breaker open, but it is due in %v", backoff, d)
+		}
+	}
+	if v := cache.Get("Guarded"); v != 2 {
+		t.Errorf("Expected the stale value 2 to be served while the breaker is open, but got '%v'", v)
+	}
+
+	failing = false
+	cache.Refresh("Guarded")
+	if info, _ := cache.Inspect("Guarded"); info.BreakerOpen || info.Failures != 0 {
+		t.Errorf("Expected the breaker to close after a success, but got %+v", info)
+	}
+	if v := cache.Get("Guarded"); v != 3 {
+		t.Errorf("Expected cache key 'Guarded' to have the new value 3, but got '%v'", v)
+	}
+}
+
+func TestGeneratorPanic(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cache := NewCache(WithClock(clock), WithSweepInterval(time.Millisecond))
+	defer cache.Free()
+
+	calls := 0
+	panicking := func() interface{} {
+		calls++
+		if calls > 1 {
+			panic("generator bug")
+		}
+		return calls
+	}
+	evicted := make(chan EvictionReason, 1)
+	cache.StorePerpetual("Panics", panicking, time.Minute, WithFailurePolicy(Evict), WithOnEvict(func(key, value interface{}, reason EvictionReason) {
+		evicted <- reason
+	}))
+	cache.Store("Plain", "Value", 2*time.Minute)
+
+	clock.Add(time.Minute)
+	select {
+	case reason := <-evicted:
+		if reason != ReasonFailed {
+			t.Errorf("Expected the panicking entry to be evicted as failed, but the reason was %v", reason)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the panicking entry to be evicted")
+	}
+
+	// the sweep must have survived the panic to expire other entries
+	clock.Add(time.Minute)
+	for i := 0; i < 100 && cache.Len() > 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if v := cache.Get("Plain"); v != nil {
+		t.Errorf("Expected cache key 'Plain' to expire after the panic, but has value '%v'", v)
+	}
+
+	calls = 0
+	cache.StorePerpetual("Refreshed", panicking, time.Hour)
+	var perr *PanicError
+	if err := cache.Refresh("Refreshed"); !errors.As(err, &perr) || perr.Value != "generator bug" {
+		t.Errorf("Expected Refresh to return the recovered panic, but got %v", err)
+	}
+	if v := cache.Get("Refreshed"); v != 1 {
+		t.Errorf("Expected cache key 'Refreshed' to keep its value after the panic, but got '%v'", v)
+	}
+}
+
+func TestRefreshAhead(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cache := NewCache(WithClock(clock), WithSweepInterval(time.Millisecond))
+	defer cache.Free()
+
+	cache.StorePerpetual("Ahead", counter(0), time.Minute*10, WithRefreshAhead(0.5))
+	cache.StorePerpetual("AtExpiry", counter(0), time.Minute*10)
+
+	clock.Add(time.Minute * 6)
+	time.Sleep(20 * time.Millisecond)
+	if v := cache.Get("Ahead"); v != 2 {
+		t.Errorf("Expected the entry to be regenerated halfway through its lifetime, but got '%v'", v)
+	}
+	if v := cache.Get("AtExpiry"); v != 1 {
+		t.Errorf("Did not expect the entry without refresh-ahead to be regenerated yet, but got '%v'", v)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	cache := NewCache()
+	defer cache.Free()
+
+	cache.StorePerpetual("Key", counter(0), time.Hour)
+	if err := cache.Refresh("Key"); err != nil {
+		t.Errorf("Expected Refresh to succeed, but got %v", err)
+	}
+	if v := cache.Get("Key"); v != 2 {
+		t.Errorf("Expected cache key 'Key' to have been regenerated, but got '%v'", v)
+	}
+	if ttl, _ := cache.TTL("Key"); ttl < time.Minute*59 {
+		t.Errorf("Expected Refresh to reschedule the next regeneration, but the TTL is %v", ttl)
+	}
+
+	cache.Store("Plain", "value", time.Hour)
+	if err := cache.Refresh("Plain"); err != ErrNotPerpetual {
+		t.Errorf("Expected ErrNotPerpetual refreshing a plain entry, but got %v", err)
+	}
+	if err := cache.Refresh("Missing"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound refreshing a missing entry, but got %v", err)
+	}
+
+	fail := errors.New("failed")
+	cache.StorePerpetualE("Failing", func() (interface{}, error) {
+		if v := cache.Get("Failing"); v != nil {
+			return nil, fail
+		}
+		return 1, nil
+	}, time.Hour)
+	if err := cache.Refresh("Failing"); err != fail {
+		t.Errorf("Expected Refresh to return the generator's error, but got %v", err)
+	}
+}
+
+func TestInvalidateAndRefresh(t *testing.T) {
+	cache := NewCache()
+	defer cache.Free()
+
+	cache.StorePerpetual("Key", counter(30*time.Millisecond), time.Hour)
+	for i := 0; i < 3; i++ {
+		if err := cache.InvalidateAndRefresh("Key"); err != nil {
+			t.Errorf("Expected InvalidateAndRefresh to succeed, but got %v", err)
+		}
+	}
+	if v, stale, _ := cache.GetStale("Key"); v != 1 || !stale {
+		t.Errorf("Expected the old value to be returned as stale while it is regenerated, but got '%v', %v", v, stale)
+	}
+
+	// the calls made during the first regeneration share a single further one
+	time.Sleep(150 * time.Millisecond)
+	if v, stale, _ := cache.GetStale("Key"); v != 3 || stale {
+		t.Errorf("Expected cache key 'Key' to have been regenerated twice and no longer be stale, but got '%v', %v", v, stale)
+	}
+	if ttl, _ := cache.TTL("Key"); ttl < time.Minute*59 {
+		t.Errorf("Expected the next regeneration to be rescheduled, but the TTL is %v", ttl)
+	}
+
+	cache.Store("Plain", "value", time.Hour)
+	if err := cache.InvalidateAndRefresh("Plain"); err != ErrNotPerpetual {
+		t.Errorf("Expected ErrNotPerpetual invalidating a plain entry, but got %v", err)
+	}
+	if err := cache.InvalidateAndRefresh("Missing"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound invalidating a missing entry, but got %v", err)
+	}
+}
+
+func TestInvalidateDuringSweep(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cache := NewCache(WithClock(clock), WithSweepInterval(time.Hour))
+	defer cache.Free()
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	cache.StorePerpetual("Key", func() interface{} {
+		n := calls.Add(1)
+		if n == 2 {
+			<-release
+		}
+		return int(n)
+	}, time.Minute)
+
+	// the sweep regenerates the entry itself, blocking until it is released
+	clock.Add(time.Minute)
+	swept := make(chan struct{})
+	go func() {
+		cache.Sweep()
+		close(swept)
+	}()
+	for i := 0; i < 200 && calls.Load() < 2; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if err := cache.InvalidateAndRefresh("Key"); err != nil {
+		t.Errorf("Expected InvalidateAndRefresh to succeed, but got %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if n := calls.Load(); n != 2 {
+		t.Errorf("Expected InvalidateAndRefresh to wait for the sweep's regeneration, but there were %d", n)
+	}
+	close(release)
+	<-swept
+
+	// the invalidation is regenerated once the sweep's regeneration has completed
+	for i := 0; i < 200 && calls.Load() < 3; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if v, stale, _ := cache.GetStale("Key"); v != 3 || stale {
+		t.Errorf("Expected the value generated after the invalidation, but got '%v', stale %v", v, stale)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("Expected the generator to be called 3 times, but it was called %d times", n)
+	}
+}
+
+func TestPauseResume(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cache := NewCache(WithClock(clock), WithSweepInterval(time.Millisecond))
+	defer cache.Free()
+
+	cache.StorePerpetual("Key", counter(0), time.Minute)
+	if err := cache.Pause("Key"); err != nil {
+		t.Errorf("Expected Pause to succeed, but got %v", err)
+	}
+	clock.Add(time.Minute * 2)
+	time.Sleep(20 * time.Millisecond)
+	if v := cache.Get("Key"); v != 1 {
+		t.Errorf("Did not expect a paused entry to be regenerated, but got '%v'", v)
+	}
+
+	cache.Resume("Key")
+	clock.Add(time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if v := cache.Get("Key"); v != 2 {
+		t.Errorf("Expected a resumed entry that was due to be regenerated, but got '%v'", v)
+	}
+
+	if err := cache.Pause("Missing"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound pausing a missing entry, but got %v", err)
+	}
+}
+
+func TestSetLifetime(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cache := NewCache(WithClock(clock), WithSweepInterval(time.Millisecond))
+	defer cache.Free()
+
+	cache.StorePerpetual("Key", counter(0), time.Hour)
+	if err := cache.SetLifetime("Key", time.Minute); err != nil {
+		t.Errorf("Expected SetLifetime to succeed, but got %v", err)
+	}
+	clock.Add(time.Minute * 2)
+	time.Sleep(20 * time.Millisecond)
+	if v := cache.Get("Key"); v != 2 {
+		t.Errorf("Expected the entry to be regenerated after its new lifetime, but got '%v'", v)
+	}
+	if ttl, _ := cache.TTL("Key"); ttl > time.Minute {
+		t.Errorf("Expected the new lifetime to be used after regeneration, but the TTL is %v", ttl)
+	}
+
+	cache.Store("Plain", "value", time.Hour)
+	if err := cache.SetLifetime("Plain", time.Minute); err != ErrNotPerpetual {
+		t.Errorf("Expected ErrNotPerpetual for a plain entry, but got %v", err)
+	}
+}
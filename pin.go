@@ -0,0 +1,48 @@
+package cache
+
+// Pin exempts the entry for a key from being evicted to keep the cache within the limit set by
+// SetMaxBytes, however rarely it is used, until Unpin is called. The pin belongs to the key rather
+// than the entry, so it applies to entries stored for the key after Pin is called, and the key
+// stays pinned when its entry is replaced, expires or is deleted. Pinned entries still expire at
+// the end of their lifetime, and still count towards the limit.
+func (c *Cache) Pin(key interface{}) {
+	s := c.shardFor(key)
+	s.Lock()
+	defer s.Unlock()
+	if _, ok := s.pinned[key]; ok {
+		return
+	}
+	if s.pinned == nil {
+		s.pinned = make(map[interface{}]struct{})
+	}
+	s.pinned[key] = struct{}{}
+	if entry := s.entries[key]; entry != nil && !entry.perpetual {
+		s.policy.OnRemove(key)
+	}
+}
+
+// Unpin removes the pin set on a key by Pin, so that its entry can be evicted again. If the cache
+// is over its limit, entries are evicted straight away.
+func (c *Cache) Unpin(key interface{}) {
+	s := c.shardFor(key)
+	s.Lock()
+	defer c.unlock(s)
+	if _, ok := s.pinned[key]; !ok {
+		return
+	}
+	delete(s.pinned, key)
+	if entry := s.entries[key]; entry != nil && !entry.perpetual {
+		s.policy.OnStore(key)
+		s.evictBytes()
+	}
+}
+
+// evictable returns true if an entry is given to the shard's eviction policy: if it is neither
+// perpetual nor pinned. The caller must hold the lock.
+func (s *shard) evictable(entry *CacheEntry) bool {
+	if entry.perpetual {
+		return false
+	}
+	_, pinned := s.pinned[entry.key]
+	return !pinned
+}
@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPin(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cache := NewCache(WithShards(1), WithClock(clock))
+	defer cache.Free()
+	cache.SetMaxBytes(30)
+
+	cache.Pin("Config")
+	cache.Store("Config", "config", time.Minute, WithSize(10))
+	for _, key := range []string{"a", "b", "c", "d"} {
+		cache.Store(key, key, time.Minute, WithSize(10))
+	}
+	if v := cache.Get("Config"); v != "config" {
+		t.Errorf("Expected the pinned entry not to be evicted, but got '%v'", v)
+	}
+	if v := cache.Get("a"); v != nil {
+		t.Errorf("Expected the least recently used unpinned entry to be evicted, but has value '%v'", v)
+	}
+	if info, _ := cache.Inspect("Config"); !info.Pinned {
+		t.Errorf("Expected Inspect to report the entry as pinned")
+	}
+
+	// the pin survives the entry being replaced
+	cache.Store("Config", "new config", time.Minute, WithSize(10))
+	cache.Store("e", "e", time.Minute, WithSize(10))
+	if v := cache.Get("Config"); v != "new config" {
+		t.Errorf("Expected the replaced pinned entry not to be evicted, but got '%v'", v)
+	}
+
+	cache.Unpin("Config")
+	for _, key := range []string{"f", "g", "h"} {
+		cache.Store(key, key, time.Minute, WithSize(10))
+	}
+	if v := cache.Get("Config"); v != nil {
+		t.Errorf("Expected the entry to be evicted once unpinned, but has value '%v'", v)
+	}
+
+	cache.Pin("Short")
+	cache.Store("Short", "value", time.Second)
+	clock.Add(time.Second * 2)
+	if v := cache.Get("Short"); v != nil {
+		t.Errorf("Expected a pinned entry to still expire, but has value '%v'", v)
+	}
+}
@@ -0,0 +1,55 @@
+package cache
+
+// ExpiryPolicy decides whether an entry stored with StoreWithPolicy has expired. It is polled by
+// the sweep, and the entry is removed the first time it returns true. It is called without any
+// locks held, and may be called from a different goroutine each time.
+type ExpiryPolicy func() bool
+
+// StoreWithPolicy stores a key/value pair in the cache that expires when the policy function
+// returns true, rather than after a fixed lifetime. The policy is polled each time the sweep runs,
+// so this suits expiry driven by external signals, such as a file's modification time or a version
+// counter in a database. Get does not poll the policy, so a value may be returned for up to one
+// sweep interval after the policy would have expired it.
+func (c *Cache) StoreWithPolicy(key interface{}, value interface{}, policy ExpiryPolicy, opts ...EntryOption) {
+	entry := &CacheEntry{expiryPolicy: policy, created: c.clock.Now(), index: -1}
+	for _, opt := range opts {
+		opt(entry)
+	}
+	s := c.shardFor(key)
+	s.Lock()
+	s.add(key, entry, value)
+	c.unlock(s)
+}
+
+// pollPolicies calls the policy function of each entry in a shard that has one, and removes the
+// entries whose policy says they have expired. The shard is not locked while the policies run.
+func (c *Cache) pollPolicies(s *shard) {
+	s.Lock()
+	if len(s.policies) == 0 {
+		s.Unlock()
+		return
+	}
+	entries := make([]*CacheEntry, 0, len(s.policies))
+	for entry := range s.policies {
+		entries = append(entries, entry)
+	}
+	s.Unlock()
+
+	var expired []*CacheEntry
+	for _, entry := range entries {
+		if entry.expiryPolicy() {
+			expired = append(expired, entry)
+		}
+	}
+	if len(expired) == 0 {
+		return
+	}
+
+	s.Lock()
+	for _, entry := range expired {
+		if s.entries[entry.key] == entry {
+			s.remove(entry, ReasonExpired)
+		}
+	}
+	c.unlock(s)
+}
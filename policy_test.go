@@ -0,0 +1,28 @@
+package cache
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStoreWithPolicy(t *testing.T) {
+	cache := NewCache(WithSweepInterval(time.Millisecond * 10))
+	var version atomic.Int64
+	stored := version.Load()
+
+	cache.StoreWithPolicy("config", "value", func() bool {
+		return version.Load() != stored
+	})
+
+	time.Sleep(time.Millisecond * 50)
+	if v := cache.Get("config"); v != "value" {
+		t.Errorf("Expected cache key 'config' to have value 'value' while the policy holds, but got '%v'", v)
+	}
+
+	version.Add(1)
+	time.Sleep(time.Millisecond * 50)
+	if v := cache.Get("config"); v != nil {
+		t.Errorf("Expected cache key 'config' to be expired by its policy, but has value '%v'", v)
+	}
+}
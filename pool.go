@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"sync"
+	"weak"
+)
+
+// WithRefreshWorkers has perpetual entries regenerated by a pool of n goroutines, rather than by
+// the sweep or, for entries stored with WithStaleWhileRevalidate, by a new goroutine for each
+// regeneration. This bounds how many generators run at once, so that many perpetual entries
+// expiring together don't stall the sweep or put a spike of load on whatever they are generated
+// from. Entries waiting for a worker keep returning their current value.
+func WithRefreshWorkers(n int) Option {
+	return func(c *Cache) {
+		c.refreshWorkers = n
+	}
+}
+
+// refreshPool is a pool of goroutines regenerating perpetual entries. Entries are queued without
+// blocking, as they are submitted with the shard locked.
+type refreshPool struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queue  []refreshJob
+	closed bool
+}
+
+// refreshJob is a perpetual entry waiting to be regenerated.
+type refreshJob struct {
+	s     *shard
+	entry *CacheEntry
+}
+
+// newRefreshPool starts n workers regenerating entries of the cache. Like the sweep, the workers
+// only hold a weak pointer to the cache.
+func newRefreshPool(c *Cache, n int) *refreshPool {
+	p := &refreshPool{}
+	p.cond = sync.NewCond(&p.mu)
+	for i := 0; i < n; i++ {
+		go p.work(weak.Make(c))
+	}
+	return p
+}
+
+// work regenerates queued entries until the pool is closed.
+func (p *refreshPool) work(wc weak.Pointer[Cache]) {
+	for {
+		p.mu.Lock()
+		for len(p.queue) == 0 && !p.closed {
+			p.cond.Wait()
+		}
+		if p.closed {
+			p.mu.Unlock()
+			return
+		}
+		job := p.queue[0]
+		p.queue[0] = refreshJob{}
+		p.queue = p.queue[1:]
+		p.mu.Unlock()
+		if c := wc.Value(); c != nil {
+			c.regenerate(job.s, job.entry)
+		}
+	}
+}
+
+// submit queues an entry to be regenerated. The caller must hold the shard's lock. If the pool has
+// been closed the entry is not regenerated, and anything waiting for it is released.
+func (p *refreshPool) submit(s *shard, entry *CacheEntry) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		close(entry.refreshing)
+		entry.refreshing = nil
+		return
+	}
+	p.queue = append(p.queue, refreshJob{s, entry})
+	p.cond.Signal()
+}
+
+// close stops the workers once they finish the regenerations they are running. Entries still
+// queued are not regenerated, and anything waiting for them is released.
+func (p *refreshPool) close() {
+	p.mu.Lock()
+	p.closed = true
+	queue := p.queue
+	p.queue = nil
+	p.cond.Broadcast()
+	p.mu.Unlock()
+
+	for _, job := range queue {
+		job.s.Lock()
+		if job.entry.refreshing != nil {
+			close(job.entry.refreshing)
+			job.entry.refreshing = nil
+		}
+		job.s.Unlock()
+	}
+}
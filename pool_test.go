@@ -0,0 +1,44 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRefreshWorkers(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cache := NewCache(WithClock(clock), WithSweepInterval(time.Millisecond), WithRefreshWorkers(2))
+	defer cache.Free()
+
+	var mu sync.Mutex
+	running, most, calls := 0, 0, 0
+	gen := func() interface{} {
+		mu.Lock()
+		running++
+		calls++
+		if running > most {
+			most = running
+		}
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return 1
+	}
+	for i := 0; i < 10; i++ {
+		cache.StorePerpetual(i, gen, time.Minute)
+	}
+
+	clock.Add(time.Minute * 2)
+	time.Sleep(100 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if calls < 20 {
+		t.Errorf("Expected all 10 entries to be regenerated, but there were %d regenerations", calls-10)
+	}
+	if most > 2 {
+		t.Errorf("Expected at most 2 generators to run at once, but %d did", most)
+	}
+}
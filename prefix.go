@@ -0,0 +1,100 @@
+package cache
+
+import "strings"
+
+// PathSeparator separates the segments of string keys used as paths, such as
+// "sitetree/5/children", which DeletePrefix deletes by subtree.
+const PathSeparator = "/"
+
+// pathNode is a node in a shard's index of string keys by path. Each node is a segment, and holds
+// the key whose path ends there, if there is one, and how many keys are in its subtree so that
+// empty branches can be pruned.
+type pathNode struct {
+	children map[string]*pathNode
+	key      string
+	has      bool
+	count    int
+}
+
+// add adds a key to the index.
+func (n *pathNode) add(key string) {
+	n.count++
+	for _, seg := range strings.Split(key, PathSeparator) {
+		child := n.children[seg]
+		if child == nil {
+			if n.children == nil {
+				n.children = make(map[string]*pathNode)
+			}
+			child = &pathNode{}
+			n.children[seg] = child
+		}
+		child.count++
+		n = child
+	}
+	n.key, n.has = key, true
+}
+
+// remove removes a key added to the index, pruning the nodes left with no keys.
+func (n *pathNode) remove(key string) {
+	n.count--
+	for _, seg := range strings.Split(key, PathSeparator) {
+		child := n.children[seg]
+		child.count--
+		if child.count == 0 {
+			delete(n.children, seg)
+			return
+		}
+		n = child
+	}
+	n.key, n.has = "", false
+}
+
+// find returns the node for a path, or nil if no key has it as a prefix.
+func (n *pathNode) find(path string) *pathNode {
+	for _, seg := range strings.Split(path, PathSeparator) {
+		if n = n.children[seg]; n == nil {
+			return nil
+		}
+	}
+	return n
+}
+
+// keys appends the keys in the subtree of the node to keys.
+func (n *pathNode) keys(keys []string) []string {
+	if n.has {
+		keys = append(keys, n.key)
+	}
+	for _, child := range n.children {
+		keys = child.keys(keys)
+	}
+	return keys
+}
+
+// DeletePrefix deletes the entries whose keys are strings in the subtree of path: the key equal to
+// path, and the keys that start with path followed by PathSeparator. For instance,
+// DeletePrefix("sitetree/5") deletes "sitetree/5" and "sitetree/5/children", but not
+// "sitetree/50". A trailing PathSeparator is ignored. String keys are indexed by path, so only the
+// matching keys are visited rather than every key in the cache. It returns the number of entries
+// deleted.
+func (c *Cache) DeletePrefix(path string) int {
+	n := c.deletePrefix(path)
+	c.publish(Invalidation{Kind: PrefixInvalidation, Name: path})
+	return n
+}
+
+// deletePrefix is DeletePrefix without publishing to the cache's bus.
+func (c *Cache) deletePrefix(path string) int {
+	path = strings.TrimSuffix(path, PathSeparator)
+	deleted := 0
+	for _, s := range c.shards {
+		s.Lock()
+		if node := s.paths.find(path); node != nil {
+			for _, key := range node.keys(nil) {
+				s.remove(s.entries[key], ReasonDeleted)
+				deleted++
+			}
+		}
+		c.unlock(s)
+	}
+	return deleted
+}
@@ -0,0 +1,43 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeletePrefix(t *testing.T) {
+	cache := NewShardedCache(4)
+	defer cache.Free()
+
+	for _, key := range []string{"sitetree/5", "sitetree/5/children", "sitetree/5/children/menu", "sitetree/50", "sitetree/6/children", "sitetree"} {
+		cache.Store(key, key, time.Minute)
+	}
+	cache.Store(5, "not a path", time.Minute)
+
+	if n := cache.DeletePrefix("sitetree/5"); n != 3 {
+		t.Errorf("Expected 3 entries to be deleted, but %d were", n)
+	}
+	for _, key := range []string{"sitetree/5", "sitetree/5/children", "sitetree/5/children/menu"} {
+		if v := cache.Get(key); v != nil {
+			t.Errorf("Expected cache key '%s' to be deleted with its subtree, but has value '%v'", key, v)
+		}
+	}
+	for _, key := range []interface{}{"sitetree/50", "sitetree/6/children", "sitetree", 5} {
+		if v := cache.Get(key); v == nil {
+			t.Errorf("Expected cache key '%v' outside the subtree to remain", key)
+		}
+	}
+
+	cache.Delete("sitetree/6/children")
+	if n := cache.DeletePrefix("sitetree/6"); n != 0 {
+		t.Errorf("Expected a deleted key to be removed from the index, but %d entries were deleted", n)
+	}
+	if n := cache.DeletePrefix("sitetree/"); n != 2 {
+		t.Errorf("Expected the rest of the sitetree to be deleted, but %d entries were", n)
+	}
+	for _, s := range cache.shards {
+		if len(s.paths.children) != 0 || s.paths.count != 0 {
+			t.Errorf("Expected the path index to be empty, but has %d keys", s.paths.count)
+		}
+	}
+}
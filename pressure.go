@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"math"
+	"runtime/metrics"
+)
+
+// heapMetric is the runtime metric compared with the threshold given to WithMemoryPressure: the
+// bytes occupied by live and not yet collected objects on the heap.
+const heapMetric = "/memory/classes/heap/objects:bytes"
+
+// heapBytes returns the current value of heapMetric.
+func heapBytes() uint64 {
+	sample := []metrics.Sample{{Name: heapMetric}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return sample[0].Value.Uint64()
+}
+
+// WithMemoryPressure has the cache check the size of the process's heap after each sweep, and if
+// it is over threshold bytes, shed the given fraction of its entries as Shed does. The heap is
+// measured with runtime/metrics, which doesn't stop the world, so checking it each sweep is cheap.
+// An application that learns of memory pressure some other way, such as from its container, can
+// call Shed itself instead.
+func WithMemoryPressure(threshold uint64, fraction float64) Option {
+	return func(c *Cache) {
+		c.pressureThreshold = threshold
+		c.pressureFraction = fraction
+	}
+}
+
+// Shed evicts the given fraction of the evictable entries in each shard, rounded up, choosing them
+// with the cache's eviction policy and taking the lower priorities first, to free memory when the
+// process is under pressure. Perpetual and pinned entries are never shed. The entries are removed
+// with ReasonPressure, so they are reported to the hooks added with OnEvict and as EventEvict
+// events. It returns the number of entries shed.
+func (c *Cache) Shed(fraction float64) int {
+	fraction = min(max(fraction, 0), 1)
+	shed := 0
+	for _, s := range c.shards {
+		s.Lock()
+		shed += s.shed(fraction)
+		c.unlock(s)
+	}
+	return shed
+}
+
+// shed evicts the given fraction of the shard's evictable entries, and returns how many it evicted.
+// The caller must hold the lock.
+func (s *shard) shed(fraction float64) int {
+	evictable := 0
+	for _, entry := range s.entries {
+		if s.evictable(entry) {
+			evictable++
+		}
+	}
+	s.drainReads()
+	n := int(math.Ceil(fraction * float64(evictable)))
+	for i := 0; i < n; i++ {
+		key, ok := s.policy.Victim()
+		entry := s.entries[key]
+		if !ok || entry == nil || !s.evictable(entry) {
+			return i
+		}
+		s.remove(entry, ReasonPressure)
+	}
+	return n
+}
+
+// checkPressure sheds entries if the heap is over the threshold given to WithMemoryPressure.
+func (c *Cache) checkPressure() {
+	if c.pressureThreshold == 0 {
+		return
+	}
+	if heap := heapBytes(); heap > c.pressureThreshold {
+		c.logShed(heap, c.Shed(c.pressureFraction))
+	}
+}
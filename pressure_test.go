@@ -0,0 +1,61 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestShed(t *testing.T) {
+	cache := NewCache(WithShards(1))
+	defer cache.Free()
+	var shed []interface{}
+	cache.OnEvict(func(key, value interface{}, reason EvictionReason) {
+		if reason == ReasonPressure {
+			shed = append(shed, key)
+		}
+	})
+
+	cache.StorePerpetual("Perpetual", counter(0), time.Hour)
+	cache.Pin("Pinned")
+	cache.Store("Pinned", "pinned", time.Minute)
+	cache.Store("Low", "low", time.Minute, WithPriority(PriorityLow))
+	for _, key := range []string{"a", "b", "c"} {
+		cache.Store(key, key, time.Minute)
+	}
+
+	// half of the four evictable entries, the low priority one first and then the least recently
+	// used
+	if n := cache.Shed(0.5); n != 2 {
+		t.Errorf("Expected 2 entries to be shed, but got %d", n)
+	}
+	if len(shed) != 2 || shed[0] != "Low" || shed[1] != "a" {
+		t.Errorf("Expected 'Low' and 'a' to be reported as shed, but got %v", shed)
+	}
+	for _, key := range []string{"Perpetual", "Pinned", "b", "c"} {
+		if _, ok := cache.GetOK(key); !ok {
+			t.Errorf("Expected cache key '%s' not to be shed", key)
+		}
+	}
+	if st := cache.Stats(); st.Evictions != 2 {
+		t.Errorf("Expected the shed entries to be counted as evictions, but got %d", st.Evictions)
+	}
+
+	if n := cache.Shed(1); n != 2 || cache.Len() != 2 {
+		t.Errorf("Expected every evictable entry to be shed, but shed %d and left %d", n, cache.Len())
+	}
+}
+
+func TestWithMemoryPressure(t *testing.T) {
+	// any heap is over the threshold
+	cache := NewCache(WithMemoryPressure(1, 1), WithSweepInterval(time.Millisecond))
+	defer cache.Free()
+	cache.Store("Key", "Value", time.Minute)
+
+	deadline := time.Now().Add(time.Second)
+	for cache.Len() > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if v := cache.Get("Key"); v != nil {
+		t.Errorf("Expected cache key 'Key' to be shed under memory pressure, but has value '%v'", v)
+	}
+}
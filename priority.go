@@ -0,0 +1,101 @@
+package cache
+
+// Priority is how strongly an entry is kept when the cache is over the limit set by SetMaxBytes.
+type Priority int
+
+const (
+	// PriorityLow entries are evicted before any others. It suits values that are cheap to
+	// compute again.
+	PriorityLow Priority = iota - 1
+
+	// PriorityNormal is the priority of entries stored without WithPriority.
+	PriorityNormal
+
+	// PriorityHigh entries are only evicted once there are no entries of lower priority left to
+	// evict. It suits values that are expensive to compute, such as the results of slow queries.
+	PriorityHigh
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	}
+	return "unknown"
+}
+
+// WithPriority sets the priority of an entry for eviction. Entries are evicted from the lowest
+// priority that has any evictable entries, and within a priority by the cache's eviction policy, so
+// for instance with LRU the least recently used low priority entry is evicted before any normal
+// priority entry, however recently it was used.
+func WithPriority(p Priority) EntryOption {
+	return func(e *CacheEntry) {
+		e.priority = min(max(p, PriorityLow), PriorityHigh)
+	}
+}
+
+// prioritized is the EvictionPolicy of a shard. It keeps a policy for each priority, created when
+// an entry of that priority is first stored, and takes victims from the lowest priority first.
+type prioritized struct {
+	newPolicy func() EvictionPolicy
+	levels    [3]EvictionPolicy
+
+	// the shard's entries, to find the priority of a key as it is stored or retrieved, and the
+	// priorities of the keys stored with priorities other than PriorityNormal, as their entries
+	// are already gone when they are removed.
+	entries map[interface{}]*CacheEntry
+	ranked  map[interface{}]Priority
+}
+
+func newPrioritized(entries map[interface{}]*CacheEntry, newPolicy func() EvictionPolicy) *prioritized {
+	p := &prioritized{newPolicy: newPolicy, entries: entries, ranked: make(map[interface{}]Priority)}
+	p.levels[PriorityNormal-PriorityLow] = newPolicy()
+	return p
+}
+
+// level returns the policy for a priority, creating it if it hasn't been used before.
+func (p *prioritized) level(priority Priority) EvictionPolicy {
+	i := priority - PriorityLow
+	if p.levels[i] == nil {
+		p.levels[i] = p.newPolicy()
+	}
+	return p.levels[i]
+}
+
+func (p *prioritized) OnStore(key interface{}) {
+	priority := p.entries[key].priority
+	if priority != PriorityNormal {
+		p.ranked[key] = priority
+	}
+	p.level(priority).OnStore(key)
+}
+
+func (p *prioritized) OnGet(key interface{}) {
+	if entry := p.entries[key]; entry != nil {
+		p.level(entry.priority).OnGet(key)
+	}
+}
+
+func (p *prioritized) OnRemove(key interface{}) {
+	priority, ok := p.ranked[key]
+	if ok {
+		delete(p.ranked, key)
+	}
+	p.level(priority).OnRemove(key)
+}
+
+func (p *prioritized) Victim() (interface{}, bool) {
+	for _, policy := range p.levels {
+		if policy == nil {
+			continue
+		}
+		if key, ok := policy.Victim(); ok {
+			return key, true
+		}
+	}
+	return nil, false
+}
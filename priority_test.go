@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPriority(t *testing.T) {
+	for _, mode := range []EvictionMode{LRU, LFU, TinyLFU, ARC, SLRU} {
+		cache := NewCache(WithShards(1), WithEviction(mode))
+		cache.SetMaxBytes(40)
+
+		cache.Store("Report", "report", time.Minute, WithSize(10), WithPriority(PriorityHigh))
+		cache.Store("Lookup", "lookup", time.Minute, WithSize(10), WithPriority(PriorityLow))
+		cache.Store("a", "a", time.Minute, WithSize(10))
+		cache.Store("b", "b", time.Minute, WithSize(10))
+		for i := 0; i < 5; i++ {
+			cache.Get("Lookup")
+		}
+
+		// the low priority entry goes first, however often it has been used
+		cache.Store("c", "c", time.Minute, WithSize(10))
+		if v := cache.Get("Lookup"); v != nil {
+			t.Errorf("Expected the low priority entry to be evicted first with %v, but has value '%v'", mode, v)
+		}
+
+		// the high priority entry outlasts the normal ones
+		for _, key := range []string{"d", "e", "f", "g"} {
+			cache.Store(key, key, time.Minute, WithSize(10))
+		}
+		if v := cache.Get("Report"); v != "report" {
+			t.Errorf("Expected the high priority entry not to be evicted with %v, but got '%v'", mode, v)
+		}
+		if info, _ := cache.Inspect("Report"); info.Priority != PriorityHigh {
+			t.Errorf("Expected Inspect to report the priority as high with %v, but got %v", mode, info.Priority)
+		}
+
+		// until there are no others left to evict
+		cache.Store("Big", "big", time.Minute, WithSize(35))
+		if v := cache.Get("Report"); v != nil {
+			t.Errorf("Expected the high priority entry to be evicted last with %v, but has value '%v'", mode, v)
+		}
+		cache.Free()
+	}
+}
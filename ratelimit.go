@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// WithRefreshRate limits how often the cache regenerates perpetual entries when they expire, to an
+// average of rate regenerations a second with bursts of up to burst. When more entries expire at
+// once than the limit allows, the rest are deferred until the limit lets them through, in the
+// order they expired, and keep returning their current value in the meantime. Regenerations
+// started with Refresh are not limited. A rate of zero or less means no limit.
+func WithRefreshRate(rate float64, burst int) Option {
+	return func(c *Cache) {
+		if rate <= 0 {
+			c.limiter = nil
+			return
+		}
+		c.limiter = newTokenBucket(rate, burst)
+	}
+}
+
+// tokenBucket is a token bucket rate limiter. Tokens are reserved rather than taken, so a caller
+// that finds the bucket empty is told when its token will be available, and callers are let
+// through in the order they arrived.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	burst = max(burst, 1)
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst)}
+}
+
+// reserve takes a token, and returns how long after now the caller must wait before using it.
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.last.IsZero() && now.After(b.last) {
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	if now.After(b.last) {
+		b.last = now
+	}
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// deferRefresh reserves a regeneration of a perpetual entry that has expired, and returns true if
+// it must wait for it, in which case the entry has been rescheduled for when it may be
+// regenerated. An entry that was deferred is not deferred again when it next expires. No token is
+// taken for an entry that has been removed or is already being regenerated, as it won't be
+// regenerated now.
+func (c *Cache) deferRefresh(s *shard, entry *CacheEntry) bool {
+	if c.limiter == nil {
+		return false
+	}
+	s.Lock()
+	defer s.Unlock()
+	if entry.reserved {
+		entry.reserved = false
+		return false
+	}
+	if s.entries[entry.key] != entry || entry.refreshing != nil {
+		return false
+	}
+	now := c.clock.Now()
+	wait := c.limiter.reserve(now)
+	if wait <= 0 {
+		return false
+	}
+	entry.reserved = true
+	entry.expiry = now.Add(wait)
+	if !entry.paused {
+		s.expiries.reschedule(entry)
+	}
+	return true
+}
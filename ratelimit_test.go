@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRefreshRate(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cache := NewCache(WithClock(clock), WithSweepInterval(time.Millisecond), WithRefreshRate(2, 2))
+	defer cache.Free()
+
+	var calls atomic.Int32
+	keys := []string{"A", "B", "C", "D", "E", "F"}
+	for _, key := range keys {
+		cache.StorePerpetual(key, func() interface{} {
+			return int(calls.Add(1))
+		}, time.Minute)
+	}
+	calls.Store(0)
+
+	waitFor := func(n int32) {
+		for i := 0; i < 200 && calls.Load() < n; i++ {
+			time.Sleep(time.Millisecond)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	clock.Add(time.Minute)
+	waitFor(2)
+	if n := calls.Load(); n != 2 {
+		t.Errorf("Expected a burst of 2 regenerations, but got %d", n)
+	}
+	for _, key := range keys {
+		if v := cache.Get(key); v == nil {
+			t.Errorf("Expected deferred cache key '%s' to keep its value, but it has none", key)
+		}
+	}
+
+	// the rest are let through at two a second
+	clock.Add(time.Second)
+	waitFor(4)
+	if n := calls.Load(); n != 4 {
+		t.Errorf("Expected 4 regenerations after a second, but got %d", n)
+	}
+	clock.Add(time.Second)
+	waitFor(6)
+	if n := calls.Load(); n != 6 {
+		t.Errorf("Expected all 6 entries to be regenerated after two seconds, but got %d", n)
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	b := newTokenBucket(10, 1)
+	now := time.Now()
+	if d := b.reserve(now); d != 0 {
+		t.Errorf("Expected the first token to be available at once, but had to wait %v", d)
+	}
+	if d := b.reserve(now); d != 100*time.Millisecond {
+		t.Errorf("Expected to wait 100ms for the second token, but had to wait %v", d)
+	}
+	if d := b.reserve(now); d != 200*time.Millisecond {
+		t.Errorf("Expected to wait 200ms for the third token, but had to wait %v", d)
+	}
+	if d := b.reserve(now.Add(time.Second)); d != 0 {
+		t.Errorf("Expected the bucket to refill after a second, but had to wait %v", d)
+	}
+}
+
+func TestRefreshRateTokenOnlyForRefresh(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cache := NewCache(WithClock(clock), WithSweepInterval(time.Hour), WithRefreshRate(1, 1))
+	defer cache.Free()
+
+	cache.StorePerpetual("A", func() interface{} { return 1 }, time.Minute)
+	s := cache.shardFor("A")
+	s.Lock()
+	entry := s.entries["A"]
+	entry.refreshing = make(chan struct{})
+	s.Unlock()
+
+	// an entry already being regenerated, or removed, isn't regenerated now, so takes no token
+	if cache.deferRefresh(s, entry) {
+		t.Errorf("Expected an entry being regenerated not to be deferred")
+	}
+	s.Lock()
+	entry.refreshing = nil
+	s.Unlock()
+	cache.Delete("A")
+	if cache.deferRefresh(s, entry) {
+		t.Errorf("Expected a removed entry not to be deferred")
+	}
+	if d := cache.limiter.reserve(clock.Now()); d != 0 {
+		t.Errorf("Expected the token to be left for a refresh, but had to wait %v", d)
+	}
+
+	unlimited := NewCache(WithRefreshRate(0, 0))
+	defer unlimited.Free()
+	if unlimited.limiter != nil {
+		t.Errorf("Expected a rate of zero not to limit regenerations")
+	}
+}
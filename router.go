@@ -0,0 +1,250 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"hash/maphash"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// defaultRouterReplicas is the number of points each cache has on a Router's hash ring.
+const defaultRouterReplicas = 128
+
+// Router distributes keys across several caches by consistent hashing, for instance to keep one
+// cache per NUMA node or per backend shard. It has the same methods as Cache for storing,
+// retrieving and deleting keys, which it passes to the cache that owns the key, so code written
+// against those methods doesn't need to know about the fan-out; methods that aren't about a single
+// key, such as InvalidateTag and Clear, are applied to every cache. Adding or removing a cache only
+// moves the keys it gains or loses. It is created with NewRouter.
+type Router struct {
+	seed     maphash.Seed
+	replicas int
+
+	mu     sync.RWMutex
+	caches []*Cache
+	ids    map[*Cache]uint64
+	nextID uint64
+	ring   []ringPoint
+}
+
+// ringPoint is a point on a Router's hash ring, owned by a cache.
+type ringPoint struct {
+	hash  uint64
+	cache *Cache
+}
+
+// ringKey is hashed to place the points of a cache on the ring.
+type ringKey struct {
+	id      uint64
+	replica int
+}
+
+// NewRouter returns a Router that distributes keys across the given caches.
+func NewRouter(caches ...*Cache) *Router {
+	r := &Router{seed: maphash.MakeSeed(), replicas: defaultRouterReplicas, ids: make(map[*Cache]uint64)}
+	for _, c := range caches {
+		r.AddCache(c)
+	}
+	return r
+}
+
+// AddCache adds a cache to those the router distributes keys across. The keys that it now owns
+// are not moved from the caches that owned them before, so they are missed until they are stored
+// again.
+func (r *Router) AddCache(c *Cache) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.ids[c]; ok {
+		return
+	}
+	r.nextID++
+	r.ids[c] = r.nextID
+	r.caches = append(r.caches, c)
+	for i := 0; i < r.replicas; i++ {
+		r.ring = append(r.ring, ringPoint{hash: maphash.Comparable(r.seed, ringKey{r.nextID, i}), cache: c})
+	}
+	sort.Slice(r.ring, func(i, j int) bool {
+		return r.ring[i].hash < r.ring[j].hash
+	})
+}
+
+// RemoveCache removes a cache from those the router distributes keys across, so that its keys are
+// owned by the other caches. The cache itself is not freed.
+func (r *Router) RemoveCache(c *Cache) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.ids[c]; !ok {
+		return
+	}
+	delete(r.ids, c)
+	r.caches = slices.DeleteFunc(r.caches, func(other *Cache) bool { return other == c })
+	r.ring = slices.DeleteFunc(r.ring, func(p ringPoint) bool { return p.cache == c })
+}
+
+// Caches returns the caches the router distributes keys across.
+func (r *Router) Caches() []*Cache {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return slices.Clone(r.caches)
+}
+
+// CacheFor returns the cache that owns a key. It panics if the router has no caches.
+func (r *Router) CacheFor(key interface{}) *Cache {
+	h := maphash.Comparable(r.seed, key)
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.ring) == 0 {
+		panic("cache: router has no caches")
+	}
+	i := sort.Search(len(r.ring), func(i int) bool {
+		return r.ring[i].hash >= h
+	})
+	if i == len(r.ring) {
+		i = 0
+	}
+	return r.ring[i].cache
+}
+
+// Store is Cache.Store on the cache that owns the key.
+func (r *Router) Store(key interface{}, value interface{}, lifetime time.Duration, opts ...EntryOption) {
+	r.CacheFor(key).Store(key, value, lifetime, opts...)
+}
+
+// StoreNegative is Cache.StoreNegative on the cache that owns the key.
+func (r *Router) StoreNegative(key interface{}, lifetime time.Duration, opts ...EntryOption) {
+	r.CacheFor(key).StoreNegative(key, lifetime, opts...)
+}
+
+// StorePerpetual is Cache.StorePerpetual on the cache that owns the key.
+func (r *Router) StorePerpetual(key interface{}, fn ValueGenerator, lifetime time.Duration, opts ...EntryOption) {
+	r.CacheFor(key).StorePerpetual(key, fn, lifetime, opts...)
+}
+
+// StorePerpetualE is Cache.StorePerpetualE on the cache that owns the key.
+func (r *Router) StorePerpetualE(key interface{}, fn ValueGeneratorE, lifetime time.Duration, opts ...EntryOption) error {
+	return r.CacheFor(key).StorePerpetualE(key, fn, lifetime, opts...)
+}
+
+// StorePerpetualContext is Cache.StorePerpetualContext on the cache that owns the key.
+func (r *Router) StorePerpetualContext(ctx context.Context, key interface{}, fn ContextValueGenerator, lifetime time.Duration, opts ...EntryOption) error {
+	return r.CacheFor(key).StorePerpetualContext(ctx, key, fn, lifetime, opts...)
+}
+
+// Add is Cache.Add on the cache that owns the key.
+func (r *Router) Add(key interface{}, value interface{}, lifetime time.Duration, opts ...EntryOption) bool {
+	return r.CacheFor(key).Add(key, value, lifetime, opts...)
+}
+
+// Get is Cache.Get on the cache that owns the key.
+func (r *Router) Get(key interface{}) interface{} {
+	return r.CacheFor(key).Get(key)
+}
+
+// GetOK is Cache.GetOK on the cache that owns the key.
+func (r *Router) GetOK(key interface{}) (interface{}, bool) {
+	return r.CacheFor(key).GetOK(key)
+}
+
+// Lookup is Cache.Lookup on the cache that owns the key.
+func (r *Router) Lookup(key interface{}) (value interface{}, found, negative bool) {
+	return r.CacheFor(key).Lookup(key)
+}
+
+// GetAs is Cache.GetAs on the cache that owns the key.
+func (r *Router) GetAs(key interface{}, ptr interface{}) error {
+	return r.CacheFor(key).GetAs(key, ptr)
+}
+
+// Delete is Cache.Delete on the cache that owns the key.
+func (r *Router) Delete(key interface{}) bool {
+	return r.CacheFor(key).Delete(key)
+}
+
+// Update is Cache.Update on the cache that owns the key.
+func (r *Router) Update(key interface{}, fn func(old interface{}) interface{}, lifetime time.Duration) interface{} {
+	return r.CacheFor(key).Update(key, fn, lifetime)
+}
+
+// Increment is Cache.Increment on the cache that owns the key.
+func (r *Router) Increment(key interface{}, delta int64, lifetime time.Duration) (int64, error) {
+	return r.CacheFor(key).Increment(key, delta, lifetime)
+}
+
+// Touch is Cache.Touch on the cache that owns the key.
+func (r *Router) Touch(key interface{}, lifetime time.Duration) bool {
+	return r.CacheFor(key).Touch(key, lifetime)
+}
+
+// TTL is Cache.TTL on the cache that owns the key.
+func (r *Router) TTL(key interface{}) (time.Duration, bool) {
+	return r.CacheFor(key).TTL(key)
+}
+
+// Refresh is Cache.Refresh on the cache that owns the key.
+func (r *Router) Refresh(key interface{}) error {
+	return r.CacheFor(key).Refresh(key)
+}
+
+// InvalidateAndRefresh is Cache.InvalidateAndRefresh on the cache that owns the key.
+func (r *Router) InvalidateAndRefresh(key interface{}) error {
+	return r.CacheFor(key).InvalidateAndRefresh(key)
+}
+
+// Inspect is Cache.Inspect on the cache that owns the key.
+func (r *Router) Inspect(key interface{}) (EntryInfo, bool) {
+	return r.CacheFor(key).Inspect(key)
+}
+
+// InvalidateTag is Cache.InvalidateTag on every cache.
+func (r *Router) InvalidateTag(tag string) {
+	for _, c := range r.Caches() {
+		c.InvalidateTag(tag)
+	}
+}
+
+// InvalidateNamespace is Cache.InvalidateNamespace on every cache.
+func (r *Router) InvalidateNamespace(name string) {
+	for _, c := range r.Caches() {
+		c.InvalidateNamespace(name)
+	}
+}
+
+// Clear is Cache.Clear on every cache. Each cache is cleared atomically, but not all of them
+// together.
+func (r *Router) Clear() {
+	for _, c := range r.Caches() {
+		c.Clear()
+	}
+}
+
+// Len returns the number of entries in all of the caches.
+func (r *Router) Len() int {
+	n := 0
+	for _, c := range r.Caches() {
+		n += c.Len()
+	}
+	return n
+}
+
+// Stats returns the statistics of all of the caches added together, as Manager.TotalStats does.
+func (r *Router) Stats() Stats {
+	return sumStats(func(yield func(Stats) bool) {
+		for _, c := range r.Caches() {
+			if !yield(c.Stats()) {
+				return
+			}
+		}
+	})
+}
+
+// Close closes every cache, and returns the errors from closing them joined together.
+func (r *Router) Close() error {
+	var errs []error
+	for _, c := range r.Caches() {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+}
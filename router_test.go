@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRouter(t *testing.T) {
+	a, b, c := NewCache(), NewCache(), NewCache()
+	router := NewRouter(a, b)
+	defer router.Close()
+	defer c.Free()
+
+	owners := make(map[string]*Cache)
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key%d", i)
+		router.Store(key, i, time.Minute, WithTags("tag"))
+		owners[key] = router.CacheFor(key)
+		if v := owners[key].Get(key); v != i {
+			t.Fatalf("Expected cache key '%s' to be stored in the cache that owns it, but got '%v'", key, v)
+		}
+	}
+	if a.Len() < 300 || b.Len() < 300 {
+		t.Errorf("Expected the keys to be spread across the caches, but got %d and %d", a.Len(), b.Len())
+	}
+	if v := router.Get("key42"); v != 42 {
+		t.Errorf("Expected cache key 'key42' to have value 42, but got '%v'", v)
+	}
+	if n := router.Len(); n != 1000 {
+		t.Errorf("Expected 1000 entries in all, but got %d", n)
+	}
+	if st := router.Stats(); st.Entries != 1000 {
+		t.Errorf("Expected the statistics to be added together, but got %d entries", st.Entries)
+	}
+
+	// adding a cache only moves the keys that it takes over
+	router.AddCache(c)
+	moved := 0
+	for key, owner := range owners {
+		if now := router.CacheFor(key); now != owner {
+			if now != c {
+				t.Fatalf("Expected cache key '%s' to stay where it was or move to the new cache", key)
+			}
+			moved++
+		}
+	}
+	if moved == 0 || moved > 500 {
+		t.Errorf("Expected about a third of the keys to move to the new cache, but %d did", moved)
+	}
+
+	router.RemoveCache(c)
+	for key, owner := range owners {
+		if router.CacheFor(key) != owner {
+			t.Fatalf("Expected cache key '%s' to go back to its owner when the cache is removed", key)
+		}
+	}
+
+	router.InvalidateTag("tag")
+	if n := router.Len(); n != 0 {
+		t.Errorf("Expected InvalidateTag to apply to every cache, but %d entries are left", n)
+	}
+}
@@ -0,0 +1,115 @@
+package cache
+
+import "math/rand/v2"
+
+// defaultExpirySamples is the number of entries checked in each round of sampled expiry if
+// WithSampledExpiry is given zero.
+const defaultExpirySamples = 20
+
+// maxSampleRounds bounds the rounds of sampled expiry in a sweep of each shard, so that a shard
+// where most entries have expired doesn't hold its lock for long.
+const maxSampleRounds = 16
+
+// WithSampledExpiry has the sweep find expired entries by sampling, as Redis does, rather than by
+// keeping them ordered by expiry. Each sweep checks n entries chosen at random from each shard,
+// removing those that have expired, and repeats while more than a quarter of those it checked had
+// expired. Storing an entry then costs the same however large the cache is, instead of growing
+// with the logarithm of its size, which suits very large caches of short-lived entries.
+//
+// Expired entries are never returned, but some are removed later than they would be by the
+// default sweep, so they may take up memory for longer, and their expiry events and eviction hooks
+// come later. Perpetual entries are still regenerated when they are due. If n is zero, 20 entries
+// are checked in each round.
+func WithSampledExpiry(n int) Option {
+	return func(c *Cache) {
+		if n <= 0 {
+			n = defaultExpirySamples
+		}
+		c.expirySamples = n
+	}
+}
+
+// expirySchedule tracks when the entries in a shard expire. By default it is an expiryHeap. With
+// sampled expiry, entries other than perpetual ones that have an expiry are instead kept in no
+// particular order, each recording its position plus one in sample, and sampled by the sweep.
+type expirySchedule struct {
+	expiryHeap
+
+	// the number of entries checked in each round of sampled expiry, or zero if expiry isn't
+	// sampled, and the entries that are.
+	sampling int
+	sampled  []*CacheEntry
+}
+
+// isSampled returns true if the entry's expiry is found by sampling rather than by the heap.
+func (e *expirySchedule) isSampled(entry *CacheEntry) bool {
+	return e.sampling > 0 && !entry.perpetual
+}
+
+func (e *expirySchedule) schedule(entry *CacheEntry) {
+	if !e.isSampled(entry) {
+		e.expiryHeap.schedule(entry)
+	} else if !entry.expiry.IsZero() {
+		e.addSample(entry)
+	}
+}
+
+func (e *expirySchedule) unschedule(entry *CacheEntry) {
+	if entry.sample > 0 {
+		e.removeSample(entry)
+	} else {
+		e.expiryHeap.unschedule(entry)
+	}
+}
+
+func (e *expirySchedule) reschedule(entry *CacheEntry) {
+	switch {
+	case !e.isSampled(entry):
+		e.expiryHeap.reschedule(entry)
+	case entry.expiry.IsZero():
+		if entry.sample > 0 {
+			e.removeSample(entry)
+		}
+	case entry.sample == 0:
+		e.addSample(entry)
+	}
+}
+
+func (e *expirySchedule) addSample(entry *CacheEntry) {
+	e.sampled = append(e.sampled, entry)
+	entry.sample = len(e.sampled)
+}
+
+func (e *expirySchedule) removeSample(entry *CacheEntry) {
+	i, last := entry.sample-1, len(e.sampled)-1
+	e.sampled[i] = e.sampled[last]
+	e.sampled[i].sample = i + 1
+	e.sampled[last] = nil
+	e.sampled = e.sampled[:last]
+	entry.sample = 0
+}
+
+// sweepSamples removes the sampled entries it finds have expired from a shard, and returns how
+// many it removed.
+func (c *Cache) sweepSamples(s *shard) int {
+	now := c.clock.Now()
+	removed := 0
+	s.Lock()
+	defer c.unlock(s)
+	for round := 0; round < maxSampleRounds; round++ {
+		n := min(s.expiries.sampling, len(s.expiries.sampled))
+		expired := 0
+		for i := 0; i < n; i++ {
+			entry := s.expiries.sampled[rand.IntN(len(s.expiries.sampled))]
+			if entry.expiredAt(now) {
+				s.remove(entry, ReasonExpired)
+				expired++
+			}
+		}
+		removed += expired
+		if n == 0 || expired*4 <= n {
+			break
+		}
+	}
+	return removed
+}
@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSampledExpiry(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cache := NewCache(WithShards(1), WithClock(clock), WithSweepInterval(time.Hour), WithSampledExpiry(5))
+	defer cache.Free()
+	s := cache.shards[0]
+
+	for i := 0; i < 100; i++ {
+		cache.Store(i, i, time.Second)
+	}
+	for i := 100; i < 110; i++ {
+		cache.Store(i, i, NoExpiry)
+	}
+	cache.StorePerpetual("Perpetual", counter(0), time.Second)
+	if len(s.expiries.sampled) != 100 || s.expiries.Len() != 1 {
+		t.Fatalf("Expected the entries with an expiry to be sampled and the perpetual entry to be in the heap, but got %d and %d", len(s.expiries.sampled), s.expiries.Len())
+	}
+
+	clock.Add(2 * time.Second)
+	if v := cache.Get(0); v != nil {
+		t.Errorf("Did not expect an expired entry to be returned before it is swept, but got '%v'", v)
+	}
+	sweeps := 0
+	for cache.Len() > 11 && sweeps < 100 {
+		cache.sweep(s)
+		sweeps++
+	}
+	if n := cache.Len(); n != 11 {
+		t.Errorf("Expected the expired entries to be removed by sampling, but %d entries are left", n)
+	}
+	if sweeps > 10 {
+		t.Errorf("Expected sampling to repeat while most entries have expired, but it took %d sweeps", sweeps)
+	}
+	if v := cache.Get("Perpetual"); v != 2 {
+		t.Errorf("Expected the perpetual entry to be regenerated when due, but got '%v'", v)
+	}
+
+	// an entry that stops expiring is no longer sampled
+	cache.Store("Key", "Value", time.Minute)
+	cache.Touch("Key", NoExpiry)
+	if len(s.expiries.sampled) != 0 {
+		t.Errorf("Expected an entry that never expires not to be sampled, but %d entries are", len(s.expiries.sampled))
+	}
+}
@@ -0,0 +1,366 @@
+// Package server serves a cache.Cache over the memcached text protocol, so that other processes,
+// such as sidecars and operations tools, can inspect and prime the cache of a running service with
+// any memcached client. The get, set, delete, flush_all, version and quit commands are supported.
+package server
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mrmorphic/cache"
+)
+
+// maxExptime is the largest exptime memcached treats as a number of seconds; larger values are
+// Unix times.
+const maxExptime = 60 * 60 * 24 * 30
+
+// DefaultMaxItemSize is the largest value a client can set unless WithMaxItemSize is given, which
+// is the same as memcached's default.
+const DefaultMaxItemSize = 1 << 20
+
+// maxLineLength is the longest command line a client can send. Longer lines close the connection.
+const maxLineLength = 8192
+
+// Server serves a cache over the memcached text protocol. It is created with New.
+type Server struct {
+	c           *cache.Cache
+	ns          *cache.Namespace
+	maxItemSize int
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithNamespace has the server use the keys of a cache.Namespace with the given name, rather than
+// those of the cache itself, and flush_all invalidate only the namespace.
+func WithNamespace(name string) Option {
+	return func(s *Server) {
+		s.ns = s.c.Namespace(name)
+	}
+}
+
+// WithMaxItemSize sets the largest value, in bytes, that a client can set. Larger values are
+// discarded and the client is sent a SERVER_ERROR. The default is DefaultMaxItemSize.
+func WithMaxItemSize(n int) Option {
+	return func(s *Server) {
+		s.maxItemSize = n
+	}
+}
+
+// New returns a Server for the given cache.
+//
+// Keys are strings, and values set by clients are stored as byte slices; the flags given with
+// them are not kept, and values are always returned with flags 0. Values stored by the service
+// itself are returned as they are if they are byte slices or strings, and formatted with fmt.Sprint
+// otherwise, so that they can be inspected.
+func New(c *cache.Cache, opts ...Option) *Server {
+	s := &Server{
+		c:           c,
+		maxItemSize: DefaultMaxItemSize,
+		listeners:   make(map[net.Listener]struct{}),
+		conns:       make(map[net.Conn]struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// ErrServerClosed is returned by Serve and ListenAndServe after Close has been called.
+var ErrServerClosed = errors.New("server: closed")
+
+// ListenAndServe listens on the TCP address addr and serves connections to it until Close is
+// called.
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts connections on l and serves each on its own goroutine until Close is called. It
+// always returns a non-nil error, which is ErrServerClosed after Close.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		l.Close()
+		return ErrServerClosed
+	}
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			delete(s.listeners, l)
+			s.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+		if !s.track(conn) {
+			conn.Close()
+			return ErrServerClosed
+		}
+		go func() {
+			defer s.untrack(conn)
+			s.ServeConn(conn)
+		}()
+	}
+}
+
+// track records an open connection so that Close can close it, and returns false if the server
+// has been closed.
+func (s *Server) track(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	return true
+}
+
+func (s *Server) untrack(conn net.Conn) {
+	s.mu.Lock()
+	delete(s.conns, conn)
+	s.mu.Unlock()
+	conn.Close()
+}
+
+// Close stops the server's listeners and closes its connections. It does not free the cache.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for l := range s.listeners {
+		l.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	return nil
+}
+
+// ServeConn serves commands read from conn until the client quits or the connection fails. A
+// command line longer than the limit closes the connection.
+func (s *Server) ServeConn(conn io.ReadWriter) {
+	r := bufio.NewReaderSize(conn, maxLineLength)
+	w := bufio.NewWriter(conn)
+	for {
+		line, err := r.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			w.WriteString("CLIENT_ERROR line too long\r\n")
+			w.Flush()
+			return
+		}
+		if err != nil {
+			return
+		}
+		if !s.command(strings.Fields(string(line)), r, w) {
+			w.Flush()
+			return
+		}
+		if w.Flush() != nil {
+			return
+		}
+	}
+}
+
+// command runs a command, reading any data block from r and writing the response to w. It
+// returns false if the connection should be closed.
+func (s *Server) command(fields []string, r *bufio.Reader, w *bufio.Writer) bool {
+	if len(fields) == 0 {
+		w.WriteString("ERROR\r\n")
+		return true
+	}
+	args := fields[1:]
+	switch fields[0] {
+	case "get":
+		s.get(args, w)
+	case "set":
+		return s.set(args, r, w)
+	case "delete":
+		s.delete(args, w)
+	case "flush_all":
+		s.flushAll(args, w)
+	case "version":
+		w.WriteString("VERSION mrmorphic/cache\r\n")
+	case "quit":
+		return false
+	default:
+		w.WriteString("ERROR\r\n")
+	}
+	return true
+}
+
+func (s *Server) get(keys []string, w *bufio.Writer) {
+	if len(keys) == 0 {
+		w.WriteString("ERROR\r\n")
+		return
+	}
+	for _, key := range keys {
+		v, ok := s.lookup(key)
+		if !ok {
+			continue
+		}
+		data := format(v)
+		fmt.Fprintf(w, "VALUE %s 0 %d\r\n", key, len(data))
+		w.Write(data)
+		w.WriteString("\r\n")
+	}
+	w.WriteString("END\r\n")
+}
+
+// format returns the bytes sent to clients for a value.
+func format(v interface{}) []byte {
+	switch v := v.(type) {
+	case []byte:
+		return v
+	case string:
+		return []byte(v)
+	case nil:
+		return nil
+	}
+	return []byte(fmt.Sprint(v))
+}
+
+// set handles "set <key> <flags> <exptime> <bytes> [noreply]", followed by the data block. A data
+// block larger than the server's maximum item size is discarded without being stored. It returns
+// false if the data block can't be read.
+func (s *Server) set(args []string, r *bufio.Reader, w *bufio.Writer) bool {
+	noreply := len(args) == 5 && args[4] == "noreply"
+	if len(args) != 4 && !noreply {
+		w.WriteString("ERROR\r\n")
+		return true
+	}
+	_, flagsErr := strconv.ParseUint(args[1], 10, 32)
+	exptime, expErr := strconv.ParseInt(args[2], 10, 64)
+	n, lenErr := strconv.Atoi(args[3])
+	if flagsErr != nil || expErr != nil || lenErr != nil || n < 0 {
+		w.WriteString("CLIENT_ERROR bad command line format\r\n")
+		return true
+	}
+	if n > s.maxItemSize {
+		if _, err := io.CopyN(io.Discard, r, int64(n)+2); err != nil {
+			return false
+		}
+		w.WriteString("SERVER_ERROR object too large for cache\r\n")
+		return true
+	}
+	data := make([]byte, n+2)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return false
+	}
+	if data[n] != '\r' || data[n+1] != '\n' {
+		w.WriteString("CLIENT_ERROR bad data chunk\r\n")
+		return true
+	}
+	if lifetime, ok := s.lifetime(exptime); !ok {
+		s.remove(args[0])
+	} else if err := s.store(args[0], data[:n], lifetime); err != nil {
+		fmt.Fprintf(w, "SERVER_ERROR %s\r\n", err)
+		return true
+	}
+	if !noreply {
+		w.WriteString("STORED\r\n")
+	}
+	return true
+}
+
+// lifetime converts a memcached exptime to a lifetime, returning false if the item has already
+// expired. Zero means the item never expires, values up to 30 days are relative, and larger ones
+// are Unix times, which are compared with the cache's clock.
+func (s *Server) lifetime(exptime int64) (time.Duration, bool) {
+	switch {
+	case exptime == 0:
+		return cache.NoExpiry, true
+	case exptime < 0:
+		return 0, false
+	case exptime <= maxExptime:
+		return time.Duration(exptime) * time.Second, true
+	}
+	lifetime := time.Unix(exptime, 0).Sub(s.c.Clock().Now())
+	return lifetime, lifetime > 0
+}
+
+func (s *Server) delete(args []string, w *bufio.Writer) {
+	noreply := len(args) == 2 && args[1] == "noreply"
+	if len(args) != 1 && !noreply {
+		w.WriteString("ERROR\r\n")
+		return
+	}
+	deleted := s.remove(args[0])
+	switch {
+	case noreply:
+	case deleted:
+		w.WriteString("DELETED\r\n")
+	default:
+		w.WriteString("NOT_FOUND\r\n")
+	}
+}
+
+// flushAll handles "flush_all [delay] [noreply]". A delay is not supported, so everything is
+// flushed straight away. Like memcached's, it only invalidates data: the cache is reset rather than
+// cleared, so the service's perpetual entries are kept and regenerated.
+func (s *Server) flushAll(args []string, w *bufio.Writer) {
+	noreply := len(args) > 0 && args[len(args)-1] == "noreply"
+	if s.ns != nil {
+		s.ns.Invalidate()
+	} else {
+		s.c.Reset()
+	}
+	if !noreply {
+		w.WriteString("OK\r\n")
+	}
+}
+
+func (s *Server) lookup(key string) (interface{}, bool) {
+	if s.ns != nil {
+		return s.ns.GetOK(key)
+	}
+	return s.c.GetOK(key)
+}
+
+// store stores a value, returning cache.ErrClosed rather than panicking if the cache has been
+// freed, so that a client can't crash the process.
+func (s *Server) store(key string, value []byte, lifetime time.Duration) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if r != cache.ErrClosed {
+				panic(r)
+			}
+			err = cache.ErrClosed
+		}
+	}()
+	if s.ns != nil {
+		s.ns.Store(key, value, lifetime)
+	} else {
+		s.c.Store(key, value, lifetime)
+	}
+	return nil
+}
+
+func (s *Server) remove(key string) bool {
+	if s.ns != nil {
+		return s.ns.Delete(key)
+	}
+	return s.c.Delete(key)
+}
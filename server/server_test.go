@@ -0,0 +1,203 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mrmorphic/cache"
+)
+
+// session sends commands to a server over a pipe and reads the responses.
+type session struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func newSession(t *testing.T, s *Server) *session {
+	client, conn := net.Pipe()
+	go func() {
+		s.ServeConn(conn)
+		conn.Close()
+	}()
+	t.Cleanup(func() { client.Close() })
+	return &session{t: t, conn: client, r: bufio.NewReader(client)}
+}
+
+// send writes a command and returns the response lines up to and including the one that ends it.
+func (s *session) send(command string, end ...string) []string {
+	s.t.Helper()
+	s.conn.SetDeadline(time.Now().Add(time.Second))
+	fmt.Fprint(s.conn, command)
+	var lines []string
+	for {
+		line, err := s.r.ReadString('\n')
+		if err != nil {
+			s.t.Fatalf("Expected a response to '%s', but got %v", strings.TrimSpace(command), err)
+		}
+		line = strings.TrimSuffix(line, "\r\n")
+		lines = append(lines, line)
+		for _, e := range end {
+			if line == e {
+				return lines
+			}
+		}
+		if len(end) == 0 {
+			return lines
+		}
+	}
+}
+
+func TestServer(t *testing.T) {
+	c := cache.NewCache()
+	defer c.Free()
+	c.Store("service", 42, time.Minute)
+	s := newSession(t, New(c))
+
+	if got := s.send("set greeting 0 0 5\r\nhello\r\n"); got[0] != "STORED" {
+		t.Errorf("Expected STORED, but got %v", got)
+	}
+	if v := c.Get("greeting"); string(v.([]byte)) != "hello" {
+		t.Errorf("Expected the set value to be stored in the cache, but got '%v'", v)
+	}
+
+	got := s.send("get greeting service missing\r\n", "END")
+	want := []string{"VALUE greeting 0 5", "hello", "VALUE service 0 2", "42", "END"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected %v, but got %v", want, got)
+	}
+
+	if got := s.send("delete greeting\r\n"); got[0] != "DELETED" {
+		t.Errorf("Expected DELETED, but got %v", got)
+	}
+	if got := s.send("delete greeting\r\n"); got[0] != "NOT_FOUND" {
+		t.Errorf("Expected NOT_FOUND, but got %v", got)
+	}
+
+	if got := s.send("set short 0 60 1 noreply\r\nx\r\nset bad 0 0 1\r\nxyz"); got[0] != "CLIENT_ERROR bad data chunk" {
+		t.Errorf("Expected only an error for the bad data chunk, but got %v", got)
+	}
+	if ttl, ok := c.TTL("short"); !ok || ttl <= 50*time.Second {
+		t.Errorf("Expected the exptime to set the lifetime, but got TTL %v", ttl)
+	}
+
+	if got := s.send("flush_all\r\n"); got[0] != "OK" {
+		t.Errorf("Expected OK, but got %v", got)
+	}
+	if c.Len() != 0 {
+		t.Errorf("Expected flush_all to clear the cache, but it has %d entries", c.Len())
+	}
+
+	c.StorePerpetual("perpetual", func() interface{} { return "generated" }, time.Minute)
+	c.Store("plain", "value", time.Minute)
+	s.send("flush_all\r\n")
+	if v := c.Get("perpetual"); v != "generated" || c.Len() != 1 {
+		t.Errorf("Expected flush_all to keep the perpetual entry, but got '%v' and %d entries", v, c.Len())
+	}
+	if got := s.send("bogus\r\n"); got[0] != "ERROR" {
+		t.Errorf("Expected ERROR for an unknown command, but got %v", got)
+	}
+}
+
+func TestServerLimits(t *testing.T) {
+	c := cache.NewCache()
+	defer c.Free()
+	s := newSession(t, New(c, WithMaxItemSize(4)))
+
+	if got := s.send("set big 0 0 5\r\nhello\r\n"); got[0] != "SERVER_ERROR object too large for cache" {
+		t.Errorf("Expected a SERVER_ERROR for a value over the limit, but got %v", got)
+	}
+	if got := s.send("set small 0 0 4\r\nhell\r\n"); got[0] != "STORED" {
+		t.Errorf("Expected the data of the large value to be discarded before the next command, but got %v", got)
+	}
+	if _, ok := c.GetOK("big"); ok {
+		t.Errorf("Did not expect the value over the limit to be stored")
+	}
+
+	// the line is written from another goroutine, as the server stops reading it part way
+	go fmt.Fprint(s.conn, "get "+strings.Repeat("k", maxLineLength)+"\r\n")
+	s.conn.SetDeadline(time.Now().Add(time.Second))
+	if line, _ := s.r.ReadString('\n'); line != "CLIENT_ERROR line too long\r\n" {
+		t.Errorf("Expected an error for a command line over the limit, but got '%s'", line)
+	}
+	if _, err := s.r.ReadString('\n'); err != io.EOF {
+		t.Errorf("Expected the connection to be closed after a command line over the limit, but got %v", err)
+	}
+}
+
+func TestServerUnixExptime(t *testing.T) {
+	clock := cache.NewFakeClock(time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC))
+	c := cache.NewCache(cache.WithClock(clock))
+	defer c.Free()
+	s := newSession(t, New(c))
+
+	// an exptime past 30 days is a Unix time by the cache's clock
+	exptime := clock.Now().Add(time.Minute).Unix()
+	if got := s.send(fmt.Sprintf("set key 0 %d 5\r\nvalue\r\n", exptime)); got[0] != "STORED" {
+		t.Errorf("Expected STORED, but got %v", got)
+	}
+	if ttl, ok := c.TTL("key"); !ok || ttl != time.Minute {
+		t.Errorf("Expected a TTL of a minute from the cache's clock, but got %v, %v", ttl, ok)
+	}
+}
+
+func TestServerClosedCache(t *testing.T) {
+	c := cache.NewCache()
+	s := newSession(t, New(c))
+	c.Free()
+
+	if got := s.send("set key 0 0 5\r\nvalue\r\n"); got[0] != "SERVER_ERROR "+cache.ErrClosed.Error() {
+		t.Errorf("Expected a SERVER_ERROR storing in a freed cache, but got %v", got)
+	}
+	if got := s.send("get key\r\n", "END"); got[len(got)-1] != "END" {
+		t.Errorf("Expected the connection to keep serving, but got %v", got)
+	}
+}
+
+func TestServerNamespace(t *testing.T) {
+	c := cache.NewCache()
+	defer c.Free()
+	c.Store("outside", "value", time.Minute)
+	c.Namespace("sessions").Store("inside", "value", time.Minute)
+	s := newSession(t, New(c, WithNamespace("sessions")))
+
+	got := s.send("get inside outside\r\n", "END")
+	if len(got) != 3 || got[0] != "VALUE inside 0 5" {
+		t.Errorf("Expected only the key in the namespace, but got %v", got)
+	}
+	s.send("flush_all\r\n")
+	if c.Len() != 1 || c.Get("outside") != "value" {
+		t.Errorf("Expected flush_all to invalidate only the namespace, but the cache has %d entries", c.Len())
+	}
+}
+
+func TestListenAndServe(t *testing.T) {
+	c := cache.NewCache()
+	defer c.Free()
+	srv := New(c)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() { done <- srv.Serve(l) }()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprint(conn, "version\r\n")
+	if line, _ := bufio.NewReader(conn).ReadString('\n'); !strings.HasPrefix(line, "VERSION") {
+		t.Errorf("Expected a VERSION response, but got '%s'", line)
+	}
+
+	srv.Close()
+	if err := <-done; err != ErrServerClosed {
+		t.Errorf("Expected Serve to return ErrServerClosed, but got %v", err)
+	}
+}
@@ -0,0 +1,294 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// Shadow evaluates a configuration of a cache against the one in use, such as LFU eviction instead
+// of LRU, a larger size limit or shorter lifetimes, on real traffic and without risk. It has the
+// same methods as Cache for storing, retrieving and deleting keys, which it applies to the primary
+// cache and mirrors to a candidate cache configured differently. Values are only ever returned from
+// the primary; reads are repeated on the candidate to count whether it would have had the value,
+// and Stats compares the hit ratios of the two. It is created with NewShadow.
+//
+// Changes made to the primary other than through the Shadow, including with Update and Increment,
+// which aren't mirrored so that their functions aren't called twice, are only seen by the candidate
+// when it misses a key that the primary has.
+type Shadow struct {
+	primary   *Cache
+	candidate *Cache
+	lifetime  func(time.Duration) time.Duration
+
+	reads         atomic.Uint64
+	primaryHits   atomic.Uint64
+	candidateHits atomic.Uint64
+}
+
+// ShadowOption configures a Shadow when it is created with NewShadow.
+type ShadowOption func(*Shadow)
+
+// WithShadowLifetime has the candidate cache store entries with the lifetime returned by fn, given
+// the lifetime they are stored with in the primary, to evaluate a different scheme of lifetimes.
+// The lifetime fn is given may be DefaultLifetime or NoExpiry.
+func WithShadowLifetime(fn func(lifetime time.Duration) time.Duration) ShadowOption {
+	return func(sh *Shadow) {
+		sh.lifetime = fn
+	}
+}
+
+// NewShadow returns a Shadow that uses primary and mirrors to candidate. The candidate should be
+// empty, and not be used other than through the Shadow.
+func NewShadow(primary, candidate *Cache, opts ...ShadowOption) *Shadow {
+	sh := &Shadow{primary: primary, candidate: candidate}
+	for _, opt := range opts {
+		opt(sh)
+	}
+	return sh
+}
+
+// Primary returns the cache whose values the Shadow returns.
+func (sh *Shadow) Primary() *Cache {
+	return sh.primary
+}
+
+// Candidate returns the cache that operations are mirrored to.
+func (sh *Shadow) Candidate() *Cache {
+	return sh.candidate
+}
+
+// ShadowStats compares the primary and candidate caches of a Shadow, as returned by Shadow.Stats.
+type ShadowStats struct {
+	// Reads is the number of reads made through the Shadow, and PrimaryHits and CandidateHits are
+	// how many of them each cache had a value for.
+	Reads         uint64
+	PrimaryHits   uint64
+	CandidateHits uint64
+
+	// Primary and Candidate are the statistics of the caches themselves, such as how many entries
+	// they hold and how many they have evicted. Those of the primary include its use other than
+	// through the Shadow.
+	Primary   Stats
+	Candidate Stats
+}
+
+// PrimaryHitRatio returns the fraction of the reads made through the Shadow that the primary had a
+// value for, or 0 if there have been none.
+func (s ShadowStats) PrimaryHitRatio() float64 {
+	if s.Reads == 0 {
+		return 0
+	}
+	return float64(s.PrimaryHits) / float64(s.Reads)
+}
+
+// CandidateHitRatio returns the fraction of the reads made through the Shadow that the candidate
+// had a value for, or 0 if there have been none.
+func (s ShadowStats) CandidateHitRatio() float64 {
+	if s.Reads == 0 {
+		return 0
+	}
+	return float64(s.CandidateHits) / float64(s.Reads)
+}
+
+// Stats returns the comparison of the primary and candidate caches so far.
+func (sh *Shadow) Stats() ShadowStats {
+	return ShadowStats{
+		Reads:         sh.reads.Load(),
+		PrimaryHits:   sh.primaryHits.Load(),
+		CandidateHits: sh.candidateHits.Load(),
+		Primary:       sh.primary.Stats(),
+		Candidate:     sh.candidate.Stats(),
+	}
+}
+
+// mirrored is added to the options of entries stored in the candidate, so that their eviction
+// hooks are only run for the primary's entries.
+func mirrored(e *CacheEntry) {
+	e.onEvict = nil
+}
+
+// mirror returns the lifetime and options to store an entry in the candidate with.
+func (sh *Shadow) mirror(lifetime time.Duration, opts []EntryOption) (time.Duration, []EntryOption) {
+	if sh.lifetime != nil {
+		lifetime = sh.lifetime(lifetime)
+	}
+	return lifetime, append(opts[:len(opts):len(opts)], mirrored)
+}
+
+// Store is Cache.Store on both caches.
+func (sh *Shadow) Store(key interface{}, value interface{}, lifetime time.Duration, opts ...EntryOption) {
+	sh.primary.Store(key, value, lifetime, opts...)
+	lifetime, opts = sh.mirror(lifetime, opts)
+	sh.candidate.Store(key, value, lifetime, opts...)
+}
+
+// StoreNegative is Cache.StoreNegative on both caches.
+func (sh *Shadow) StoreNegative(key interface{}, lifetime time.Duration, opts ...EntryOption) {
+	sh.primary.StoreNegative(key, lifetime, opts...)
+	lifetime, opts = sh.mirror(lifetime, opts)
+	sh.candidate.StoreNegative(key, lifetime, opts...)
+}
+
+// StorePerpetual is Cache.StorePerpetual on the primary. The candidate is given a perpetual entry
+// that takes the primary's value when it is regenerated, rather than calling the generator again.
+func (sh *Shadow) StorePerpetual(key interface{}, fn ValueGenerator, lifetime time.Duration, opts ...EntryOption) {
+	sh.primary.StorePerpetual(key, fn, lifetime, opts...)
+	sh.mirrorPerpetual(key, lifetime, opts)
+}
+
+// StorePerpetualE is Cache.StorePerpetualE on the primary, mirrored as StorePerpetual is if it
+// succeeds.
+func (sh *Shadow) StorePerpetualE(key interface{}, fn ValueGeneratorE, lifetime time.Duration, opts ...EntryOption) error {
+	if err := sh.primary.StorePerpetualE(key, fn, lifetime, opts...); err != nil {
+		return err
+	}
+	sh.mirrorPerpetual(key, lifetime, opts)
+	return nil
+}
+
+// StorePerpetualContext is Cache.StorePerpetualContext on the primary, mirrored as StorePerpetual
+// is if it succeeds.
+func (sh *Shadow) StorePerpetualContext(ctx context.Context, key interface{}, fn ContextValueGenerator, lifetime time.Duration, opts ...EntryOption) error {
+	if err := sh.primary.StorePerpetualContext(ctx, key, fn, lifetime, opts...); err != nil {
+		return err
+	}
+	sh.mirrorPerpetual(key, lifetime, opts)
+	return nil
+}
+
+// mirrorPerpetual stores a perpetual entry in the candidate whose generator takes the primary's
+// value.
+func (sh *Shadow) mirrorPerpetual(key interface{}, lifetime time.Duration, opts []EntryOption) {
+	lifetime, opts = sh.mirror(lifetime, opts)
+	sh.candidate.StorePerpetual(key, func() interface{} {
+		return sh.primary.current(key)
+	}, lifetime, opts...)
+}
+
+// current returns the value of the entry for a key, or nil if there is none, without counting a
+// hit or a miss.
+func (c *Cache) current(key interface{}) interface{} {
+	s := c.shardFor(key)
+	s.RLock()
+	defer s.RUnlock()
+	if entry := s.entries[key]; entry != nil {
+		return s.value(entry)
+	}
+	return nil
+}
+
+// Add is Cache.Add on the primary. If it adds the value, it is also stored in the candidate.
+func (sh *Shadow) Add(key interface{}, value interface{}, lifetime time.Duration, opts ...EntryOption) bool {
+	if !sh.primary.Add(key, value, lifetime, opts...) {
+		return false
+	}
+	lifetime, opts = sh.mirror(lifetime, opts)
+	sh.candidate.Store(key, value, lifetime, opts...)
+	return true
+}
+
+// Get is Cache.Get on the primary, counting whether each cache has the value as Lookup does.
+func (sh *Shadow) Get(key interface{}) interface{} {
+	value, _, _ := sh.Lookup(key)
+	return value
+}
+
+// GetOK is Cache.GetOK on the primary, counting whether each cache has the value as Lookup does.
+func (sh *Shadow) GetOK(key interface{}) (interface{}, bool) {
+	value, found, _ := sh.Lookup(key)
+	return value, found
+}
+
+// Lookup is Cache.Lookup on the primary, counting whether each cache has an entry for the key.
+// When the primary has an entry that the candidate doesn't, because the candidate has evicted it or
+// it has expired there, the candidate is given the primary's value for the rest of the primary's
+// lifetime, as though the caller had stored it again after the miss.
+func (sh *Shadow) Lookup(key interface{}) (value interface{}, found, negative bool) {
+	value, found, negative = sh.primary.Lookup(key)
+	_, candidateFound, _ := sh.candidate.Lookup(key)
+	sh.reads.Add(1)
+	if found {
+		sh.primaryHits.Add(1)
+	}
+	switch {
+	case candidateFound:
+		sh.candidateHits.Add(1)
+	case found:
+		sh.refill(key, value, negative)
+	}
+	return value, found, negative
+}
+
+// refill stores a value the primary has in the candidate, which missed it. An entry that expires in
+// the primary, including a perpetual entry overdue for regeneration, is kept from never expiring
+// in the candidate.
+func (sh *Shadow) refill(key interface{}, value interface{}, negative bool) {
+	ttl, ok := sh.primary.TTL(key)
+	if !ok {
+		return
+	}
+	lifetime, opts := sh.mirror(ttl, nil)
+	if ttl != NoExpiry {
+		lifetime = max(lifetime, time.Nanosecond)
+	}
+	if negative {
+		sh.candidate.StoreNegative(key, lifetime, opts...)
+	} else {
+		sh.candidate.Store(key, value, lifetime, opts...)
+	}
+}
+
+// GetAs is Cache.GetAs on the primary, counting whether each cache has the value as Lookup does.
+func (sh *Shadow) GetAs(key interface{}, ptr interface{}) error {
+	dst, err := destination(ptr)
+	if err != nil {
+		return err
+	}
+	value, found, negative := sh.Lookup(key)
+	return sh.primary.assign(key, dst, value, found, negative)
+}
+
+// Delete is Cache.Delete on both caches, returning the primary's result.
+func (sh *Shadow) Delete(key interface{}) bool {
+	sh.candidate.Delete(key)
+	return sh.primary.Delete(key)
+}
+
+// Touch is Cache.Touch on both caches, returning the primary's result.
+func (sh *Shadow) Touch(key interface{}, lifetime time.Duration) bool {
+	touched := sh.primary.Touch(key, lifetime)
+	lifetime, _ = sh.mirror(lifetime, nil)
+	sh.candidate.Touch(key, lifetime)
+	return touched
+}
+
+// DeletePrefix is Cache.DeletePrefix on both caches, returning the primary's result.
+func (sh *Shadow) DeletePrefix(path string) int {
+	sh.candidate.DeletePrefix(path)
+	return sh.primary.DeletePrefix(path)
+}
+
+// InvalidateTag is Cache.InvalidateTag on both caches.
+func (sh *Shadow) InvalidateTag(tag string) {
+	sh.primary.InvalidateTag(tag)
+	sh.candidate.InvalidateTag(tag)
+}
+
+// InvalidateNamespace is Cache.InvalidateNamespace on both caches.
+func (sh *Shadow) InvalidateNamespace(name string) {
+	sh.primary.InvalidateNamespace(name)
+	sh.candidate.InvalidateNamespace(name)
+}
+
+// Clear is Cache.Clear on both caches.
+func (sh *Shadow) Clear() {
+	sh.primary.Clear()
+	sh.candidate.Clear()
+}
+
+// Close closes both caches, and returns the errors from closing them joined together.
+func (sh *Shadow) Close() error {
+	return errors.Join(sh.primary.Close(), sh.candidate.Close())
+}
@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestShadow(t *testing.T) {
+	primary := NewCache(WithShards(1))
+	primary.SetMaxBytes(20)
+	candidate := NewCache(WithShards(1))
+	candidate.SetMaxBytes(30)
+	sh := NewShadow(primary, candidate)
+	defer sh.Close()
+
+	// cycling through three keys thrashes the primary, which holds two, but fits in the candidate
+	evicted := 0
+	for i := 0; i < 30; i++ {
+		key := []string{"a", "b", "c"}[i%3]
+		if _, ok := sh.GetOK(key); !ok {
+			sh.Store(key, key, time.Minute, WithSize(10), WithOnEvict(func(key, value interface{}, reason EvictionReason) {
+				evicted++
+			}))
+		}
+	}
+	st := sh.Stats()
+	if st.Reads != 30 || st.PrimaryHits != 0 || st.CandidateHits != 27 {
+		t.Errorf("Expected 30 reads with 0 primary hits and 27 candidate hits, but got %d, %d and %d", st.Reads, st.PrimaryHits, st.CandidateHits)
+	}
+	if r := st.CandidateHitRatio(); r != 0.9 {
+		t.Errorf("Expected a candidate hit ratio of 0.9, but got %v", r)
+	}
+	if st.Primary.Entries != 2 || st.Candidate.Entries != 3 {
+		t.Errorf("Expected the caches' own statistics, but got %d and %d entries", st.Primary.Entries, st.Candidate.Entries)
+	}
+	if evicted != 28 {
+		t.Errorf("Expected the eviction hook to run only for the primary's 28 evictions, but it ran %d times", evicted)
+	}
+
+	// values are only returned from the primary
+	candidate.Store("only", "candidate", time.Minute)
+	if v := sh.Get("only"); v != nil {
+		t.Errorf("Expected values to be returned from the primary only, but got '%v'", v)
+	}
+}
+
+func TestShadowRefill(t *testing.T) {
+	primary := NewCache()
+	candidate := NewCache()
+	sh := NewShadow(primary, candidate, WithShadowLifetime(func(lifetime time.Duration) time.Duration {
+		return lifetime / 2
+	}))
+	defer sh.Close()
+
+	sh.Store("Key", "value", time.Hour)
+	if ttl, _ := candidate.TTL("Key"); ttl > 30*time.Minute {
+		t.Errorf("Expected the candidate to be given the shadow lifetime, but the TTL is %v", ttl)
+	}
+
+	// a key the candidate misses is filled from the primary
+	primary.Store("Direct", "value", time.Hour)
+	sh.Get("Direct")
+	if v := sh.Get("Direct"); v != "value" {
+		t.Errorf("Expected cache key 'Direct' to have value 'value', but got '%v'", v)
+	}
+	if st := sh.Stats(); st.PrimaryHits != 2 || st.CandidateHits != 1 {
+		t.Errorf("Expected the candidate to hit once it was filled, but got %d primary and %d candidate hits", st.PrimaryHits, st.CandidateHits)
+	}
+
+	sh.StorePerpetual("Perpetual", counter(0), time.Hour)
+	if v := candidate.Get("Perpetual"); v != 1 {
+		t.Errorf("Expected the candidate to take the primary's perpetual value, but got '%v'", v)
+	}
+	if err := candidate.Refresh("Perpetual"); err != nil || sh.Get("Perpetual") != 1 {
+		t.Errorf("Expected regenerating the candidate not to call the generator again, but got %v", err)
+	}
+
+	if !sh.Delete("Key") || candidate.Len() != 2 {
+		t.Errorf("Expected Delete to delete from both caches, but the candidate has %d entries", candidate.Len())
+	}
+}
+
+func TestShadowRefillOverdue(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	primary := NewCache(WithClock(clock), WithSweepInterval(time.Hour))
+	candidate := NewCache(WithClock(clock), WithSweepInterval(time.Hour))
+	sh := NewShadow(primary, candidate, WithShadowLifetime(func(lifetime time.Duration) time.Duration {
+		return lifetime / 2
+	}))
+	defer sh.Close()
+
+	// a perpetual entry overdue in the primary doesn't become one that never expires in the candidate
+	primary.StorePerpetual("Key", func() interface{} { return "value" }, time.Minute)
+	clock.Add(time.Minute * 2)
+	sh.Get("Key")
+	if ttl, ok := candidate.TTL("Key"); ok && ttl == NoExpiry {
+		t.Errorf("Expected the refilled entry to expire in the candidate, but it never expires")
+	}
+}
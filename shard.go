@@ -0,0 +1,299 @@
+package cache
+
+import (
+	"log/slog"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// shard holds a subset of the entries of a Cache. Each key belongs to exactly one shard, chosen
+// by hashing the key, and each shard has its own mutex so that operations on keys in different
+// shards don't contend with each other.
+type shard struct {
+	// mutex to safely handle changes to the shard across goroutines. Get only takes the read lock
+	// when it doesn't need to change the shard, so that concurrent reads don't serialise.
+	sync.RWMutex
+
+	// the map of entries.
+	entries map[interface{}]*CacheEntry
+
+	// where the values of the entries are kept.
+	store Store
+
+	// views of the entries that can be read without the lock, if the cache was created with
+	// WithSyncMap.
+	views *sync.Map
+
+	// tracks the use of entries that aren't perpetual, to choose which to evict when the shard is
+	// over its byte limit.
+	policy EvictionPolicy
+
+	// keys retrieved with only the read lock held, for which the policy's OnGet is still to be
+	// called. Keys are dropped if it is full, so the policy sees most rather than all reads.
+	reads chan interface{}
+
+	// entries ordered by expiry, so the sweep only touches entries that are due, or with sampled
+	// expiry, the entries to sample.
+	expiries expirySchedule
+
+	// the entries stored with StoreWithPolicy, whose policies are polled by the sweep.
+	policies map[*CacheEntry]struct{}
+
+	// the keys of the entries carrying each tag.
+	tags map[string]map[interface{}]struct{}
+
+	// the string keys of the entries, indexed by path for DeletePrefix.
+	paths pathNode
+
+	// estimated total size (or cost) of all entries in bytes, and the limit above which entries are
+	// evicted. A maxBytes of 0 means there is no limit.
+	bytes    int64
+	maxBytes int64
+
+	// the cache's CostFunc, if it has one.
+	costFunc CostFunc
+
+	// the cache's events, to which stores are sent.
+	events *events
+
+	// the cache's watches, and the changes to send them once the shard is unlocked.
+	watches *watches
+	watched []ValueChange
+
+	// how values are compressed and encoded, if the cache was created with WithCompression or
+	// WithValueCodec, and the number of values that could not be unpacked.
+	compression  *compression
+	valueCodec   Codec
+	decodeErrors atomic.Uint64
+
+	// the number of calls of the store that failed, and the cache's logger to log them with.
+	storeErrors atomic.Uint64
+	logger      *slog.Logger
+
+	// the version given to the value last stored in the shard. Versions are counted per shard
+	// rather than per entry, so a key's versions keep increasing when it is deleted and stored
+	// again.
+	version uint64
+
+	// the mutexes of the shard's keys locked with LockKey.
+	keyLocks keyLocks
+
+	// the keys pinned with Pin, whose entries are not given to the eviction policy.
+	pinned map[interface{}]struct{}
+
+	// the keys that GetWait calls are waiting to be stored.
+	waiters map[interface{}]*waiter
+
+	// dependencies between entries, shared with the other shards of the cache.
+	deps *dependencies
+
+	// entries removed while the shard is locked, for which eviction hooks are still to be called,
+	// and the keys of entries whose values were changed in place while it is locked, by Update or
+	// the regeneration of a perpetual entry. Entries that depend on either are deleted once the
+	// shard is unlocked.
+	evicted []eviction
+	changed []interface{}
+}
+
+func newShard(store Store, deps *dependencies, newPolicy func() EvictionPolicy) *shard {
+	if store == nil {
+		store = make(mapStore)
+	}
+	entries := make(map[interface{}]*CacheEntry)
+	return &shard{
+		store:    store,
+		deps:     deps,
+		entries:  entries,
+		policy:   newPrioritized(entries, newPolicy),
+		reads:    make(chan interface{}, readBuffer),
+		tags:     make(map[string]map[interface{}]struct{}),
+		policies: make(map[*CacheEntry]struct{}),
+	}
+}
+
+// readBuffer is the number of reads each shard buffers for its eviction policy.
+const readBuffer = 256
+
+// defaultShards returns the number of shards used by NewCache, which is GOMAXPROCS rounded up to
+// a power of two.
+func defaultShards() int {
+	n := 1
+	for n < runtime.GOMAXPROCS(0) {
+		n <<= 1
+	}
+	return n
+}
+
+// add an entry with the given value to the shard under the given key, replacing any entry already
+// there, and evict entries if this takes the shard over its byte limit. The caller must hold the
+// lock.
+func (s *shard) add(key interface{}, entry *CacheEntry, value interface{}) {
+	if old := s.entries[key]; old != nil {
+		s.remove(old, ReasonReplaced)
+	}
+	entry.key = key
+	s.version++
+	entry.version = s.version
+	stored := s.pack(value)
+	entry.size = entry.sizeHint
+	if entry.size == 0 {
+		entry.size = s.cost(key, value, stored)
+	}
+	if err := s.store.Set(key, stored); err != nil {
+		// the key is left without an entry, as it would be if it had been evicted straight away
+		s.storeFailed("set", key, err)
+		return
+	}
+	s.publish(entry, stored)
+	s.events.send(EventSet, key, 0, nil)
+	s.watch(ChangeSet, key, value, 0)
+	s.expiries.schedule(entry)
+	s.index(entry)
+	if entry.expiryPolicy != nil {
+		s.policies[entry] = struct{}{}
+	}
+	s.deps.add(entry)
+	s.entries[key] = entry
+	s.bytes += entry.size
+	s.wake(key)
+
+	// make room for the entry before giving it to the eviction policy, so that it is not chosen
+	// itself unless it doesn't fit on its own.
+	s.evictBytes()
+	if s.evictable(entry) {
+		s.policy.OnStore(key)
+		s.evictBytes()
+	}
+}
+
+// unexpired returns the entry for a key, or nil if there is none or it has expired. An expired
+// entry is removed as having expired, so that an entry stored in its place isn't reported as
+// replacing it. The caller must hold the lock.
+func (s *shard) unexpired(key interface{}, now time.Time) *CacheEntry {
+	entry := s.entries[key]
+	if entry != nil && entry.expiredAt(now) {
+		s.remove(entry, ReasonExpired)
+		return nil
+	}
+	return entry
+}
+
+// setValue replaces the value of an entry in the shard, updating its size and evicting entries if
+// this takes the shard over its byte limit, and records the change so that the entries depending
+// on it are deleted once the shard is unlocked. kind is ChangeRefresh if a perpetual entry was
+// regenerated, or ChangeSet otherwise. The caller must hold the lock.
+func (s *shard) setValue(entry *CacheEntry, value interface{}, kind ChangeKind) {
+	stored := s.pack(value)
+	if err := s.store.Set(entry.key, stored); err != nil {
+		// the entry keeps its old value
+		s.storeFailed("set", entry.key, err)
+		return
+	}
+	s.version++
+	entry.version = s.version
+	s.publish(entry, stored)
+	s.events.send(EventSet, entry.key, 0, nil)
+	s.watch(kind, entry.key, value, 0)
+	s.changed = append(s.changed, entry.key)
+	if entry.sizeHint == 0 {
+		s.bytes -= entry.size
+		entry.size = s.cost(entry.key, value, stored)
+		s.bytes += entry.size
+		s.evictBytes()
+	}
+}
+
+// cost returns the size of a value without a size given by WithSize, from the cache's CostFunc if
+// it has one, or estimated otherwise from the value as it is kept in the store.
+func (s *shard) cost(key, value, stored interface{}) int64 {
+	if s.costFunc != nil {
+		return s.costFunc(key, value)
+	}
+	if p, ok := stored.(packed); ok {
+		return int64(len(p.data))
+	}
+	return estimateSize(value)
+}
+
+// value returns the value of an entry in the shard. The caller must hold the lock.
+func (s *shard) value(entry *CacheEntry) interface{} {
+	return s.unpack(s.stored(entry))
+}
+
+// stored returns the value of an entry as it is kept in the store, which may be packed. The
+// caller must hold the lock.
+func (s *shard) stored(entry *CacheEntry) interface{} {
+	v, _, err := s.store.Get(entry.key)
+	if err != nil {
+		s.storeFailed("get", entry.key, err)
+		return nil
+	}
+	return v
+}
+
+// remove an entry from the shard, recording the reason for the eviction hooks, and return its
+// value. The caller must hold the lock.
+func (s *shard) remove(entry *CacheEntry, reason EvictionReason) interface{} {
+	value, _, err := s.store.Delete(entry.key)
+	if err != nil {
+		s.storeFailed("delete", entry.key, err)
+	}
+	value = s.unpack(value)
+	s.unpublish(entry.key)
+	delete(s.entries, entry.key)
+	s.policy.OnRemove(entry.key)
+	s.expiries.unschedule(entry)
+	s.unindex(entry)
+	delete(s.policies, entry)
+	s.deps.remove(entry)
+	s.bytes -= entry.size
+	s.evicted = append(s.evicted, eviction{key: entry.key, value: value, created: entry.created, hook: entry.onEvict, reason: reason})
+	if reason != ReasonReplaced {
+		s.watch(ChangeRemove, entry.key, value, reason)
+	}
+	return value
+}
+
+// evict the entries chosen by the shard's eviction policy until the shard is within its byte
+// limit. The caller must hold the lock.
+func (s *shard) evictBytes() {
+	if s.maxBytes <= 0 {
+		return
+	}
+	s.drainReads()
+	for s.bytes > s.maxBytes {
+		key, ok := s.policy.Victim()
+		entry := s.entries[key]
+		if !ok || entry == nil || !s.evictable(entry) {
+			// a policy given to WithEvictionPolicy chose a key it shouldn't have
+			return
+		}
+		s.remove(entry, ReasonCapacity)
+	}
+}
+
+// recordRead buffers a read made without the lock held for writing, to be passed to the eviction
+// policy later. If the buffer is full the read is dropped, which is always the case when there is
+// no byte limit, as the buffer is only drained when entries may need to be evicted.
+func (s *shard) recordRead(key interface{}) {
+	select {
+	case s.reads <- key:
+	default:
+	}
+}
+
+// drainReads passes buffered reads to the eviction policy. The caller must hold the lock.
+func (s *shard) drainReads() {
+	for {
+		select {
+		case key := <-s.reads:
+			if entry := s.entries[key]; entry != nil && s.evictable(entry) {
+				s.policy.OnGet(key)
+			}
+		default:
+			return
+		}
+	}
+}
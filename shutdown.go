@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// regenerations counts the regenerations of perpetual entries in progress, so that Shutdown can
+// stop new ones from starting and wait for the rest to finish.
+type regenerations struct {
+	mu      sync.Mutex
+	running int
+	stopped bool
+	idle    chan struct{}
+}
+
+// start records that a regeneration is starting, and returns true, unless Shutdown has stopped
+// regenerations, in which case it returns false.
+func (r *regenerations) start() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stopped {
+		return false
+	}
+	r.running++
+	return true
+}
+
+// done records that a regeneration recorded by start has finished.
+func (r *regenerations) done() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.running--
+	if r.stopped && r.running == 0 {
+		close(r.idle)
+	}
+}
+
+// stop stops any more regenerations from starting, and returns a channel that is closed once the
+// regenerations in progress have finished.
+func (r *regenerations) stop() <-chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.stopped {
+		r.stopped = true
+		r.idle = make(chan struct{})
+		if r.running == 0 {
+			close(r.idle)
+		}
+	}
+	return r.idle
+}
+
+// abandon gives up a regeneration of a perpetual entry that was due to start after Shutdown was
+// called, releasing anything waiting for it, and returns ErrClosed. The shard must not be locked by
+// the caller.
+func (c *Cache) abandon(s *shard, entry *CacheEntry) error {
+	s.Lock()
+	defer s.Unlock()
+	if entry.refreshing != nil {
+		close(entry.refreshing)
+		entry.refreshing = nil
+	}
+	entry.reserved = false
+	return ErrClosed
+}
+
+// Shutdown closes the cache gracefully, as an alternative to Free and Close. It stops perpetual
+// entries from starting to be regenerated, and waits for the regenerations already in progress to
+// finish, so that generators aren't cancelled while they are writing to a backend or holding a
+// connection. Once they have, or the context is done, it closes the cache as Close does: a final
+// snapshot is saved if WithSnapshotFile was given, the context of generators still running is
+// cancelled, and the sweep is stopped. It returns the context's error if the context was done
+// before the regenerations finished, joined with any error from saving the snapshot, or ErrClosed
+// if the cache was already closed. The entries' values can be read until the cache is closed.
+func (c *Cache) Shutdown(ctx context.Context) error {
+	if c.closed.Load() {
+		return ErrClosed
+	}
+	var err error
+	select {
+	case <-c.regenerations.stop():
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	return errors.Join(err, c.Close())
+}
@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShutdown(t *testing.T) {
+	cache := NewCache()
+	started := make(chan struct{}, 2)
+	var finished atomic.Bool
+	var cancelled atomic.Bool
+	n := 0
+	cache.StorePerpetualContext(context.Background(), "Key", func(ctx context.Context) (interface{}, error) {
+		n++
+		if n > 1 {
+			started <- struct{}{}
+			time.Sleep(50 * time.Millisecond)
+			cancelled.Store(ctx.Err() != nil)
+			finished.Store(true)
+		}
+		return n, nil
+	}, time.Hour)
+
+	cache.InvalidateAndRefresh("Key")
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := cache.Shutdown(ctx); err != nil {
+		t.Errorf("Expected Shutdown to succeed, but got %v", err)
+	}
+	if !finished.Load() || cancelled.Load() {
+		t.Errorf("Expected Shutdown to wait for the regeneration in progress without cancelling it")
+	}
+	if err := cache.Refresh("Key"); err != ErrClosed {
+		t.Errorf("Expected Refresh to return ErrClosed after Shutdown, but got %v", err)
+	}
+	if err := cache.Shutdown(ctx); err != ErrClosed {
+		t.Errorf("Expected ErrClosed shutting down a closed cache, but got %v", err)
+	}
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	cache := NewCache()
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	n := 0
+	cache.StorePerpetualContext(context.Background(), "Key", func(ctx context.Context) (interface{}, error) {
+		n++
+		if n > 1 {
+			close(started)
+			<-ctx.Done()
+			close(cancelled)
+		}
+		return n, nil
+	}, time.Hour)
+
+	cache.InvalidateAndRefresh("Key")
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := cache.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected Shutdown to return the context's error at the deadline, but got %v", err)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Errorf("Expected the generator's context to be cancelled at the deadline")
+	}
+}
+
+func TestShutdownStopsRegenerations(t *testing.T) {
+	cache := NewCache(WithRefreshWorkers(1))
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	n := 0
+	cache.StorePerpetual("Slow", func() interface{} {
+		n++
+		if n > 1 {
+			started <- struct{}{}
+			<-release
+		}
+		return n
+	}, time.Hour)
+	cache.StorePerpetual("Queued", counter(0), time.Hour)
+
+	// the worker is busy with the first entry, so the second is queued behind it
+	cache.InvalidateAndRefresh("Slow")
+	<-started
+	cache.InvalidateAndRefresh("Queued")
+	done := make(chan error)
+	go func() {
+		done <- cache.Shutdown(context.Background())
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	if err := <-done; err != nil {
+		t.Errorf("Expected Shutdown to succeed, but got %v", err)
+	}
+	if v, stale, _ := cache.GetStale("Queued"); v != 1 || !stale {
+		t.Errorf("Expected the queued regeneration not to be started after Shutdown, but got '%v', %v", v, stale)
+	}
+}
@@ -0,0 +1,71 @@
+package cache
+
+import "container/list"
+
+// slru is an EvictionPolicy implementing segmented LRU. New entries go into the probation segment,
+// and only move to the protected segment when they are used again, so entries used once, such as
+// those stored by a scan of many keys, are evicted before the entries that are used repeatedly.
+// The protected segment holds up to 80% of the entries; when it is over that, its least recently
+// used entry goes back to the front of probation, where it gets a second chance to be used before
+// it is evicted. Victims come from probation, and only from protected once probation is empty.
+//
+// tinyLFU uses an slru for the entries outside its window, adding the window as a third segment,
+// so the segment of a node can be one slru doesn't have.
+type slru struct {
+	probation *list.List
+	protected *list.List
+	elements  map[interface{}]*list.Element
+}
+
+// slruNode is a key tracked by slru or tinyLFU, and the segment it is in.
+type slruNode struct {
+	key     interface{}
+	segment *list.List
+}
+
+func newSLRU() *slru {
+	return &slru{probation: list.New(), protected: list.New(), elements: make(map[interface{}]*list.Element)}
+}
+
+// move moves an entry to the front of a segment.
+func (p *slru) move(el *list.Element, to *list.List) {
+	node := el.Value.(*slruNode)
+	node.segment.Remove(el)
+	node.segment = to
+	p.elements[node.key] = to.PushFront(node)
+}
+
+func (p *slru) OnStore(key interface{}) {
+	p.elements[key] = p.probation.PushFront(&slruNode{key: key, segment: p.probation})
+}
+
+func (p *slru) OnGet(key interface{}) {
+	el := p.elements[key]
+	if el == nil {
+		return
+	}
+	if el.Value.(*slruNode).segment == p.protected {
+		p.protected.MoveToFront(el)
+		return
+	}
+	p.move(el, p.protected)
+	if p.protected.Len() > (p.probation.Len()+p.protected.Len())*8/10 {
+		p.move(p.protected.Back(), p.probation)
+	}
+}
+
+func (p *slru) OnRemove(key interface{}) {
+	if el := p.elements[key]; el != nil {
+		el.Value.(*slruNode).segment.Remove(el)
+		delete(p.elements, key)
+	}
+}
+
+func (p *slru) Victim() (interface{}, bool) {
+	for _, segment := range []*list.List{p.probation, p.protected} {
+		if el := segment.Back(); el != nil {
+			return el.Value.(*slruNode).key, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,32 @@
+package cache
+
+import "testing"
+
+func TestSLRU(t *testing.T) {
+	if hot := hotKeysAfterScan(SLRU); hot < 75 {
+		t.Errorf("Expected SLRU to keep most of the frequently used keys, but it kept %d of 100", hot)
+	}
+}
+
+func TestSLRUSecondChance(t *testing.T) {
+	p := newSLRU()
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		p.OnStore(key)
+	}
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		p.OnGet(key)
+	}
+	// only 4 of the 5 entries fit in the protected segment, so the least recently used goes back
+	// on probation
+	if p.protected.Len() != 4 || p.probation.Front().Value.(*slruNode).key != "a" {
+		t.Errorf("Expected 'a' to be demoted to probation when protected was full")
+	}
+	p.OnStore("f")
+	if key, _ := p.Victim(); key != "a" {
+		t.Errorf("Expected the demoted 'a' to be the next victim, but got '%v'", key)
+	}
+	p.OnGet("a")
+	if key, _ := p.Victim(); key != "f" {
+		t.Errorf("Expected 'a' to get a second chance when used on probation, leaving 'f' as the victim, but got '%v'", key)
+	}
+}
@@ -0,0 +1,278 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+	"weak"
+)
+
+// Codec converts values to and from bytes. It is used by SaveTo and LoadFrom to write snapshots
+// of the cache, where the default is GobCodec, and by caches created with WithValueCodec to keep
+// their values encoded.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// GobCodec is a Codec that uses encoding/gob. Keys and values of types other than the basic types
+// must be registered with gob.Register before a snapshot containing them is saved or loaded.
+type GobCodec struct{}
+
+func (GobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// JSONCodec is a Codec that uses encoding/json. Keys and values are decoded as the types
+// encoding/json uses for interface{} values, so for instance numbers are loaded as float64 and
+// structs as map[string]interface{}.
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// WithCodec sets the Codec used by SaveTo and LoadFrom.
+func WithCodec(codec Codec) Option {
+	return func(c *Cache) {
+		c.codec = codec
+	}
+}
+
+// WithSnapshotFile has the cache warm-start from a snapshot in the file at path, if there is one,
+// and save a snapshot to the file every interval and when the cache is freed. Loading the snapshot
+// is best-effort: if the file is missing or can't be decoded the cache starts empty. A snapshot is
+// written to a temporary file that then replaces the old one, so a crash while saving does not
+// lose the previous snapshot.
+func WithSnapshotFile(path string, interval time.Duration) Option {
+	return func(c *Cache) {
+		c.snapshotPath = path
+		c.snapshotInterval = interval
+	}
+}
+
+// snapshotEntry is how an entry is written to a snapshot. Perpetual entries are saved with their
+// last value; their generators can't be saved.
+type snapshotEntry struct {
+	Key       interface{}
+	Value     interface{}
+	Expiry    time.Time
+	Perpetual bool
+	Negative  bool
+	Tags      []string
+}
+
+// SaveTo writes a snapshot of the entries in the cache that have not expired to w, using the
+// cache's Codec. Entries stored with StoreWithPolicy are not saved, and nor are sliding expiry
+// settings, dependencies or eviction hooks. Each shard is copied while it is locked, so changes
+// made while SaveTo runs may or may not be included.
+func (c *Cache) SaveTo(w io.Writer) error {
+	var entries []snapshotEntry
+	for _, s := range c.shards {
+		now := c.clock.Now()
+		s.RLock()
+		for key, entry := range s.entries {
+			if entry.expiryPolicy != nil || entry.expiredAt(now) {
+				continue
+			}
+			entries = append(entries, snapshotEntry{
+				Key:       key,
+				Value:     s.value(entry),
+				Expiry:    entry.expiry,
+				Perpetual: entry.perpetual,
+				Negative:  entry.negative,
+				Tags:      entry.tags,
+			})
+		}
+		s.RUnlock()
+	}
+	data, err := c.codec.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// LoadFrom reads a snapshot written by SaveTo from r and stores the entries in it that have not
+// expired since, replacing any entries with the same keys. Entries that were perpetual are loaded
+// as warm values: they expire as normal unless StorePerpetual (or one of its variants) is called
+// for their key before they do, in which case the new perpetual entry starts with the loaded value
+// instead of calling its generator, and is first regenerated when the loaded value expires.
+func (c *Cache) LoadFrom(r io.Reader) error {
+	if c.closed.Load() {
+		return ErrClosed
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	var entries []snapshotEntry
+	if err := c.codec.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+	now := c.clock.Now()
+	for _, e := range entries {
+		if !e.Expiry.IsZero() && !now.Before(e.Expiry) {
+			continue
+		}
+		c.loadEntry(e.Key, e.Value, &CacheEntry{expiry: e.Expiry, warm: e.Perpetual, negative: e.Negative, tags: e.Tags, created: now, index: -1})
+	}
+	return nil
+}
+
+// loadEntry stores an entry loaded by LoadFrom or Import.
+func (c *Cache) loadEntry(key, value interface{}, entry *CacheEntry) {
+	s := c.shardFor(key)
+	s.Lock()
+	s.add(key, entry, value)
+	c.unlock(s)
+}
+
+// EntryDump is an entry of a cache as exported by Export, for sending to another cache, typically
+// in another process, to be loaded with Import. Its fields are exported so that it can be encoded
+// with encoding/gob or encoding/json; keys and values must be of types the encoding supports, and
+// of registered types for gob.
+type EntryDump struct {
+	Key   interface{}
+	Value interface{}
+
+	// TTL is the time that was left until the entry expires, or for a perpetual entry until it
+	// was next due to be regenerated. It is NoExpiry for entries that never expire. It is relative
+	// rather than a point in time, so that it doesn't depend on the clocks of the two processes
+	// agreeing.
+	TTL time.Duration
+
+	// Perpetual is true if the entry was perpetual, and Lifetime is then its lifetime. Generators
+	// can't be exported, so the entry is imported as a warm value, as LoadFrom loads it.
+	Perpetual bool
+	Lifetime  time.Duration
+
+	Negative bool
+	Tags     []string
+}
+
+// Export returns the entries in the cache that have not expired, for loading into another cache
+// with Import. Like SaveTo, it leaves out entries stored with StoreWithPolicy, along with sliding
+// expiry settings, dependencies and eviction hooks, and each shard is copied while it is locked.
+func (c *Cache) Export() []EntryDump {
+	var dumps []EntryDump
+	for _, s := range c.shards {
+		now := c.clock.Now()
+		s.RLock()
+		for key, entry := range s.entries {
+			if entry.expiryPolicy != nil || entry.expiredAt(now) {
+				continue
+			}
+			dump := EntryDump{
+				Key:       key,
+				Value:     s.value(entry),
+				Perpetual: entry.perpetual,
+				Negative:  entry.negative,
+				Tags:      slices.Clone(entry.tags),
+			}
+			if entry.perpetual {
+				dump.Lifetime = entry.lifetime
+			}
+			if !entry.expiry.IsZero() {
+				// an overdue perpetual entry is imported as due straight away
+				dump.TTL = max(entry.expiry.Sub(now), time.Nanosecond)
+			}
+			dumps = append(dumps, dump)
+		}
+		s.RUnlock()
+	}
+	return dumps
+}
+
+// Import stores entries exported by Export, replacing any entries with the same keys. Each entry
+// expires its TTL after it is imported. Entries that were perpetual are imported as warm values,
+// as LoadFrom loads them: they expire as normal unless StorePerpetual (or one of its variants) is
+// called for their key first, which then starts with the imported value.
+func (c *Cache) Import(dumps []EntryDump) error {
+	if c.closed.Load() {
+		return ErrClosed
+	}
+	now := c.clock.Now()
+	for _, d := range dumps {
+		entry := &CacheEntry{expiry: expiryAt(now, d.TTL), warm: d.Perpetual, lifetime: d.Lifetime, negative: d.Negative, tags: slices.Clone(d.Tags), created: now, index: -1}
+		c.loadEntry(d.Key, d.Value, entry)
+	}
+	return nil
+}
+
+// takeWarm returns the value and expiry of the warm entry loaded by LoadFrom for key, if there is
+// one that has not expired. The caller must hold the shard's lock.
+func (c *Cache) takeWarm(s *shard, key interface{}) (interface{}, time.Time, bool) {
+	entry := s.entries[key]
+	if entry == nil || !entry.warm || entry.expiredAt(c.clock.Now()) {
+		return nil, time.Time{}, false
+	}
+	return s.value(entry), entry.expiry, true
+}
+
+// saveSnapshot writes a snapshot to the file given to WithSnapshotFile.
+func (c *Cache) saveSnapshot() error {
+	f, err := os.CreateTemp(filepath.Dir(c.snapshotPath), filepath.Base(c.snapshotPath)+".tmp")
+	if err != nil {
+		return err
+	}
+	if err := c.SaveTo(f); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), c.snapshotPath)
+}
+
+// startSnapshots loads the snapshot file given to WithSnapshotFile, and starts saving it
+// periodically until the cache is freed.
+func (c *Cache) startSnapshots() {
+	if f, err := os.Open(c.snapshotPath); err == nil {
+		c.LoadFrom(f)
+		f.Close()
+	}
+	if c.snapshotInterval <= 0 {
+		return
+	}
+	go snapshotLoop(weak.Make(c), c.clock.NewTicker(c.snapshotInterval), c.ctx.Done())
+}
+
+// snapshotLoop saves a snapshot of a cache on each tick, until done is closed or the cache has
+// been garbage collected.
+func snapshotLoop(wc weak.Pointer[Cache], ticker Ticker, done <-chan struct{}) {
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C():
+			c := wc.Value()
+			if c == nil {
+				return
+			}
+			c.saveSnapshot()
+		case <-done:
+			return
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSaveToLoadFrom(t *testing.T) {
+	for _, codec := range []Codec{GobCodec{}, JSONCodec{}} {
+		cache := NewCache(WithCodec(codec))
+		cache.Store("Key", "Value", time.Minute, WithTags("tag"))
+		cache.Store("Expired", "Value", time.Millisecond)
+		time.Sleep(2 * time.Millisecond)
+
+		var buf bytes.Buffer
+		if err := cache.SaveTo(&buf); err != nil {
+			t.Fatalf("Expected SaveTo to succeed with %T, but got %v", codec, err)
+		}
+		cache.Free()
+
+		loaded := NewCache(WithCodec(codec))
+		if err := loaded.LoadFrom(&buf); err != nil {
+			t.Fatalf("Expected LoadFrom to succeed with %T, but got %v", codec, err)
+		}
+		if v := loaded.Get("Key"); v != "Value" {
+			t.Errorf("Expected cache key 'Key' to be loaded with value 'Value' using %T, but got '%v'", codec, v)
+		}
+		if v := loaded.Get("Expired"); v != nil {
+			t.Errorf("Did not expect expired cache key to be loaded using %T, but has value '%v'", codec, v)
+		}
+		if ttl, ok := loaded.TTL("Key"); !ok || ttl <= 50*time.Second {
+			t.Errorf("Expected loaded key to keep its expiry using %T, but got TTL %v", codec, ttl)
+		}
+		loaded.InvalidateTag("tag")
+		if v := loaded.Get("Key"); v != nil {
+			t.Errorf("Expected loaded key to keep its tags using %T, but got '%v' after invalidating", codec, v)
+		}
+		loaded.Free()
+	}
+}
+
+func TestLoadFromWarmsPerpetual(t *testing.T) {
+	cache := NewCache()
+	cache.StorePerpetual("Key", counter(0), time.Minute)
+	var buf bytes.Buffer
+	if err := cache.SaveTo(&buf); err != nil {
+		t.Fatalf("Expected SaveTo to succeed, but got %v", err)
+	}
+	cache.Free()
+
+	loaded := NewCache()
+	defer loaded.Free()
+	if err := loaded.LoadFrom(&buf); err != nil {
+		t.Fatalf("Expected LoadFrom to succeed, but got %v", err)
+	}
+	calls := 0
+	loaded.StorePerpetual("Key", func() interface{} {
+		calls++
+		return 100
+	}, time.Minute)
+	if calls != 0 {
+		t.Errorf("Expected the warm value to be used instead of calling the generator, but it was called %d times", calls)
+	}
+	if v := loaded.Get("Key"); v != 1 {
+		t.Errorf("Expected cache key 'Key' to have the warm value 1, but got '%v'", v)
+	}
+}
+
+func TestSnapshotFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+
+	cache := NewCache(WithSnapshotFile(path, time.Hour))
+	cache.Store("Key", "Value", time.Minute)
+	cache.Free()
+
+	restarted := NewCache(WithSnapshotFile(path, time.Hour))
+	defer restarted.Free()
+	if v := restarted.Get("Key"); v != "Value" {
+		t.Errorf("Expected cache key 'Key' to be warm-started with value 'Value', but got '%v'", v)
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	cache := NewCache()
+	cache.Store("Key", "Value", time.Minute, WithTags("tag"))
+	cache.Store("Forever", "Value", NoExpiry)
+	cache.StoreNegative("Missing", time.Minute)
+	cache.StorePerpetual("Perpetual", counter(0), time.Hour)
+	dumps := cache.Export()
+	cache.Free()
+
+	// send the entries over the wire
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(dumps); err != nil {
+		t.Fatalf("Expected the export to encode, but got %v", err)
+	}
+	var received []EntryDump
+	if err := gob.NewDecoder(&buf).Decode(&received); err != nil {
+		t.Fatalf("Expected the export to decode, but got %v", err)
+	}
+
+	replica := NewCache()
+	defer replica.Free()
+	if err := replica.Import(received); err != nil {
+		t.Fatalf("Expected Import to succeed, but got %v", err)
+	}
+	if v := replica.Get("Key"); v != "Value" {
+		t.Errorf("Expected cache key 'Key' to be imported with value 'Value', but got '%v'", v)
+	}
+	if ttl, ok := replica.TTL("Key"); !ok || ttl <= 50*time.Second || ttl > time.Minute {
+		t.Errorf("Expected the imported key to keep its remaining TTL, but got %v", ttl)
+	}
+	if ttl, ok := replica.TTL("Forever"); !ok || ttl != NoExpiry {
+		t.Errorf("Expected the imported key to never expire, but got TTL %v", ttl)
+	}
+	if _, found, negative := replica.Lookup("Missing"); !found || !negative {
+		t.Errorf("Expected the negative entry to be imported")
+	}
+	replica.InvalidateTag("tag")
+	if v := replica.Get("Key"); v != nil {
+		t.Errorf("Expected the imported key to keep its tags, but got '%v' after invalidating", v)
+	}
+
+	for _, d := range received {
+		if d.Key == "Perpetual" && (!d.Perpetual || d.Lifetime != time.Hour) {
+			t.Errorf("Expected the perpetual entry to be exported with its lifetime, but got %+v", d)
+		}
+	}
+	calls := 0
+	replica.StorePerpetual("Perpetual", func() interface{} {
+		calls++
+		return 100
+	}, time.Hour)
+	if v := replica.Get("Perpetual"); v != 1 || calls != 0 {
+		t.Errorf("Expected the imported value to be used for the perpetual entry, but got '%v' with %d calls", v, calls)
+	}
+}
@@ -0,0 +1,238 @@
+// Package sqlcache caches the results of database/sql queries in a cache.Cache. A query wrapped
+// with CachedQuery is run the first time its result is needed, and its rows are kept for a
+// lifetime. Results are tagged with the tables they are read from, so that writing to a table
+// with Exec, or calling InvalidateTable, deletes the cached results that read from it.
+package sqlcache
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mrmorphic/cache"
+)
+
+// namespace is the name of the cache.Namespace results are stored in.
+const namespace = "sqlcache"
+
+// Result is the result of a query: the names of its columns, and the values of each row in the
+// same order. Values are as database/sql scans them into an interface{}, so their types depend on
+// the driver.
+type Result struct {
+	Columns []string
+	Rows    [][]interface{}
+}
+
+// Maps returns the rows of the result as maps from column name to value.
+func (r *Result) Maps() []map[string]interface{} {
+	maps := make([]map[string]interface{}, len(r.Rows))
+	for i, row := range r.Rows {
+		m := make(map[string]interface{}, len(r.Columns))
+		for j, col := range r.Columns {
+			m[col] = row[j]
+		}
+		maps[i] = m
+	}
+	return maps
+}
+
+// Query is a query whose result is cached. It is created with CachedQuery, and is safe for
+// concurrent use.
+type Query struct {
+	c      *cache.Cache
+	ns     *cache.Namespace
+	db     *sql.DB
+	ttl    time.Duration
+	query  string
+	args   []interface{}
+	key    queryKey
+	tables []string
+}
+
+// queryKey is the key under which the result of a query is stored. The arguments are formatted,
+// as they need not be comparable.
+type queryKey struct {
+	query string
+	args  string
+}
+
+// queryCall is a run of a query that Gets are waiting for.
+type queryCall struct {
+	done   chan struct{}
+	result *Result
+	err    error
+}
+
+// callKey is the cache and key that the result of a queryCall is stored under.
+type callKey struct {
+	c   *cache.Cache
+	key queryKey
+}
+
+// calls are the queries being run, so that concurrent Gets of the same query share a run, even
+// when they are made through different Querys.
+var (
+	callsMu sync.Mutex
+	calls   = make(map[callKey]*queryCall)
+)
+
+// tableKey is the key of the entry that InvalidateTable stores for a table, whose version is the
+// table's generation.
+type tableKey struct {
+	table string
+}
+
+// CachedQuery returns a Query that runs query with args on db, and caches the result in c for ttl.
+// Queries with the same text and arguments share their cached result.
+func CachedQuery(c *cache.Cache, db *sql.DB, ttl time.Duration, query string, args ...interface{}) *Query {
+	return &Query{
+		c:     c,
+		ns:    c.Namespace(namespace),
+		db:    db,
+		ttl:   ttl,
+		query: query,
+		args:  args,
+		key:   queryKey{query: query, args: fmt.Sprintf("%#v", args)},
+	}
+}
+
+// Tables records the tables the query reads from, so that its cached result is deleted when they
+// are written to with Exec or InvalidateTable. It returns the query, so it can be chained with
+// CachedQuery.
+func (q *Query) Tables(tables ...string) *Query {
+	q.tables = append(q.tables, tables...)
+	return q
+}
+
+// Get returns the result of the query, from the cache if it is there, or by running the query and
+// caching its result. If another goroutine is already running the same query on the same cache,
+// Get waits for its result instead. Errors are not cached, and neither are results that one of the
+// query's tables was written to or invalidated while they were read, as they may already be stale.
+func (q *Query) Get(ctx context.Context) (*Result, error) {
+	if r, ok := q.ns.Get(q.key).(*Result); ok {
+		return r, nil
+	}
+
+	k := callKey{c: q.c, key: q.key}
+	callsMu.Lock()
+	call := calls[k]
+	if call == nil {
+		call = &queryCall{done: make(chan struct{})}
+		calls[k] = call
+		callsMu.Unlock()
+		generations := q.generations()
+		call.result, call.err = q.run(ctx)
+		if call.err == nil {
+			q.store(call.result, generations)
+		}
+		callsMu.Lock()
+		delete(calls, k)
+		callsMu.Unlock()
+		close(call.done)
+		return call.result, call.err
+	}
+	callsMu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.result, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// generations returns the generations of the query's tables, which change each time they are
+// invalidated.
+func (q *Query) generations() []uint64 {
+	generations := make([]uint64, len(q.tables))
+	for i, table := range q.tables {
+		// an entry that has been evicted has generation 0, so that its result isn't stored
+		if info, ok := q.ns.Inspect(tableKey{table}); ok {
+			generations[i] = info.Version
+		}
+	}
+	return generations
+}
+
+// store caches the result of the query if its tables still have the generations they had before
+// it was run. A table invalidated after they are checked but before the result is stored would
+// not delete it, so they are checked again once it is stored.
+func (q *Query) store(r *Result, generations []uint64) {
+	if !slices.Equal(q.generations(), generations) {
+		return
+	}
+	q.ns.Store(q.key, r, q.ttl, cache.WithTags(tableTags(q.tables)...))
+	if !slices.Equal(q.generations(), generations) {
+		q.ns.Delete(q.key)
+	}
+}
+
+// run runs the query and reads all of its rows.
+func (q *Query) run(ctx context.Context) (*Result, error) {
+	rows, err := q.db.QueryContext(ctx, q.query, q.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	r := &Result{Columns: cols}
+	for rows.Next() {
+		row := make([]interface{}, len(cols))
+		dest := make([]interface{}, len(cols))
+		for i := range row {
+			dest[i] = &row[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		r.Rows = append(r.Rows, row)
+	}
+	return r, rows.Err()
+}
+
+// Invalidate deletes the cached result of the query, so that the next Get runs it again.
+func (q *Query) Invalidate() {
+	q.ns.Delete(q.key)
+}
+
+// TableTag returns the tag the results of queries reading from a table are stored with, for use
+// with cache.Cache.InvalidateTag.
+func TableTag(table string) string {
+	return namespace + ":table:" + table
+}
+
+// tableTags returns the tags for a list of tables.
+func tableTags(tables []string) []string {
+	tags := make([]string, len(tables))
+	for i, table := range tables {
+		tags[i] = TableTag(table)
+	}
+	return tags
+}
+
+// InvalidateTable deletes the cached results of the queries that read from a table, including
+// those of queries being run.
+func InvalidateTable(c *cache.Cache, table string) {
+	c.Namespace(namespace).Store(tableKey{table}, struct{}{}, cache.NoExpiry)
+	c.InvalidateTag(TableTag(table))
+}
+
+// Exec runs a statement that writes to the given tables on db, and if it succeeds deletes the
+// cached results of the queries that read from them.
+func Exec(ctx context.Context, c *cache.Cache, db *sql.DB, tables []string, query string, args ...interface{}) (sql.Result, error) {
+	res, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	for _, table := range tables {
+		InvalidateTable(c, table)
+	}
+	return res, nil
+}
@@ -0,0 +1,222 @@
+package sqlcache
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mrmorphic/cache"
+)
+
+// fakeDB is a database with a single table of settings, served by a minimal driver. It only
+// understands the statements the tests use.
+type fakeDB struct {
+	mu       sync.Mutex
+	settings map[string]string
+	queries  int
+
+	// called by each query once it has read the settings, if it is set.
+	during func()
+}
+
+func (db *fakeDB) Connect(context.Context) (driver.Conn, error) {
+	return fakeConn{db}, nil
+}
+
+func (db *fakeDB) Driver() driver.Driver {
+	return nil
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return fakeStmt{c.db, query}, nil
+}
+
+func (c fakeConn) Close() error {
+	return nil
+}
+
+func (c fakeConn) Begin() (driver.Tx, error) {
+	return nil, driver.ErrSkip
+}
+
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+func (s fakeStmt) Close() error {
+	return nil
+}
+
+func (s fakeStmt) NumInput() int {
+	return -1
+}
+
+// Exec runs an update of a setting: the arguments are the value and the name.
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	s.db.settings[args[1].(string)] = args[0].(string)
+	return driver.RowsAffected(1), nil
+}
+
+// Query returns all the settings, or with an argument, the setting with that name.
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	rows := s.read(args)
+	if s.db.during != nil {
+		s.db.during()
+	}
+	return rows, nil
+}
+
+func (s fakeStmt) read(args []driver.Value) *fakeRows {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	s.db.queries++
+	rows := &fakeRows{}
+	for name, value := range s.db.settings {
+		if len(args) == 0 || args[0] == name {
+			rows.rows = append(rows.rows, []driver.Value{name, value})
+		}
+	}
+	sort.Slice(rows.rows, func(i, j int) bool {
+		return rows.rows[i][0].(string) < rows.rows[j][0].(string)
+	})
+	return rows
+}
+
+type fakeRows struct {
+	rows [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	return []string{"name", "value"}
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func newFakeDB() (*fakeDB, *sql.DB) {
+	fake := &fakeDB{settings: map[string]string{"title": "My Site", "theme": "dark"}}
+	return fake, sql.OpenDB(fake)
+}
+
+func TestCachedQuery(t *testing.T) {
+	c := cache.NewCache()
+	defer c.Free()
+	fake, db := newFakeDB()
+	defer db.Close()
+	ctx := context.Background()
+
+	all := CachedQuery(c, db, time.Minute, "SELECT name, value FROM settings").Tables("settings")
+	for i := 0; i < 3; i++ {
+		r, err := all.Get(ctx)
+		if err != nil {
+			t.Fatalf("Expected the query to succeed, but got %v", err)
+		}
+		if len(r.Rows) != 2 || r.Maps()[1]["value"] != "My Site" {
+			t.Errorf("Expected the two settings, but got %v", r.Maps())
+		}
+	}
+	if fake.queries != 1 {
+		t.Errorf("Expected the query to be run once, but it was run %d times", fake.queries)
+	}
+
+	// the same query with different arguments is cached separately
+	title := CachedQuery(c, db, time.Minute, "SELECT name, value FROM settings WHERE name = ?", "title").Tables("settings")
+	if r, err := title.Get(ctx); err != nil || len(r.Rows) != 1 {
+		t.Errorf("Expected one row for the title, but got %v, %v", r, err)
+	}
+	if fake.queries != 2 {
+		t.Errorf("Expected a query with other arguments to be run, but there were %d queries", fake.queries)
+	}
+
+	if _, err := Exec(ctx, c, db, []string{"settings"}, "UPDATE settings SET value = ? WHERE name = ?", "New Site", "title"); err != nil {
+		t.Fatalf("Expected the update to succeed, but got %v", err)
+	}
+	r, _ := title.Get(ctx)
+	if len(r.Rows) != 1 || r.Rows[0][1] != "New Site" {
+		t.Errorf("Expected the write to invalidate the cached title, but got %v", r.Rows)
+	}
+	all.Get(ctx)
+	if fake.queries != 4 {
+		t.Errorf("Expected both queries to be run again after the write, but there were %d queries", fake.queries)
+	}
+
+	all.Invalidate()
+	all.Get(ctx)
+	if fake.queries != 5 {
+		t.Errorf("Expected the query to be run again after Invalidate, but there were %d queries", fake.queries)
+	}
+}
+
+func TestCachedQueryWrittenWhileRunning(t *testing.T) {
+	c := cache.NewCache()
+	defer c.Free()
+	fake, db := newFakeDB()
+	defer db.Close()
+	ctx := context.Background()
+
+	title := CachedQuery(c, db, time.Minute, "SELECT name, value FROM settings WHERE name = ?", "title").Tables("settings")
+	fake.during = func() {
+		fake.during = nil
+		Exec(ctx, c, db, []string{"settings"}, "UPDATE settings SET value = ? WHERE name = ?", "New Site", "title")
+	}
+	if r, err := title.Get(ctx); err != nil || r.Rows[0][1] != "My Site" {
+		t.Errorf("Expected the rows read before the write, but got %v, %v", r, err)
+	}
+	if r, _ := title.Get(ctx); r.Rows[0][1] != "New Site" || fake.queries != 2 {
+		t.Errorf("Expected the rows read during the write not to be cached, but got %v after %d queries", r.Rows, fake.queries)
+	}
+	title.Get(ctx)
+	if fake.queries != 2 {
+		t.Errorf("Expected the rows read after the write to be cached, but there were %d queries", fake.queries)
+	}
+}
+
+func TestCachedQueryShared(t *testing.T) {
+	c := cache.NewCache()
+	defer c.Free()
+	fake, db := newFakeDB()
+	defer db.Close()
+	ctx := context.Background()
+
+	// two Querys for the same query share a run, as they share a result
+	first := CachedQuery(c, db, time.Minute, "SELECT name, value FROM settings")
+	second := CachedQuery(c, db, time.Minute, "SELECT name, value FROM settings")
+	done := make(chan *Result)
+	fake.during = func() {
+		fake.during = nil
+		go func() {
+			r, _ := second.Get(ctx)
+			done <- r
+		}()
+		time.Sleep(20 * time.Millisecond)
+	}
+	r, _ := first.Get(ctx)
+	if shared := <-done; shared != r {
+		t.Errorf("Expected the second Query to get the result of the first")
+	}
+	if fake.queries != 1 {
+		t.Errorf("Expected the query to be run once, but it was run %d times", fake.queries)
+	}
+}
@@ -0,0 +1,150 @@
+package cache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats holds statistics about the use of a Cache, as returned by Cache.Stats. The counts are
+// totals since the cache was created.
+type Stats struct {
+	// Hits and Misses count the calls to Get that found a value or didn't.
+	Hits   uint64
+	Misses uint64
+
+	// Evictions counts entries removed to keep the cache within its limits or shed under memory
+	// pressure, or because their generator failed and their FailurePolicy is Evict. Expirations
+	// counts entries removed at the end of their lifetime.
+	Evictions   uint64
+	Expirations uint64
+
+	// Refreshes counts the regenerations of perpetual entries, and RefreshErrors those that failed.
+	Refreshes     uint64
+	RefreshErrors uint64
+
+	// DecodeErrors counts the values of a cache created with WithCompression or WithValueCodec that
+	// could not be decompressed or decoded when they were retrieved, which were returned as nil.
+	DecodeErrors uint64
+
+	// StoreErrors counts the calls of the Store given to WithStore that failed, and those of the
+	// second tier of a Tiered cache with the cache as its first tier.
+	StoreErrors uint64
+
+	// Entries is the number of entries currently in the cache, and Bytes their total estimated
+	// size, or cost if the cache has a CostFunc.
+	Entries int
+	Bytes   int64
+
+	// Loads is the number of calls made to generators, including those that generate the initial
+	// value of a perpetual entry, and AverageLoadTime the average time they took.
+	Loads           uint64
+	AverageLoadTime time.Duration
+
+	// Lifetimes is the distribution of the lifetimes given to entries that expire as they are
+	// stored. EvictionAges is the distribution of the ages of entries when they are evicted to keep
+	// the cache within its limits or shed, and FirstHits of how long entries are stored before they
+	// are first retrieved. Comparing them shows whether lifetimes are longer than entries are used
+	// for, or so short that entries expire before they are retrieved.
+	Lifetimes    Histogram
+	EvictionAges Histogram
+	FirstHits    Histogram
+}
+
+// HitRatio returns the fraction of calls to Get that found a value, or 0 if Get has not been called.
+func (s Stats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// counters are the statistics the cache updates as it is used.
+type counters struct {
+	hits          atomic.Uint64
+	misses        atomic.Uint64
+	evictions     atomic.Uint64
+	expirations   atomic.Uint64
+	refreshes     atomic.Uint64
+	refreshErrors atomic.Uint64
+	loads         atomic.Uint64
+	loadTime      atomic.Int64
+	lifetimes     histogram
+	evictionAges  histogram
+	firstHits     histogram
+}
+
+// Stats returns statistics about the use of the cache.
+func (c *Cache) Stats() Stats {
+	st := Stats{
+		Hits:          c.counters.hits.Load(),
+		Misses:        c.counters.misses.Load(),
+		Evictions:     c.counters.evictions.Load(),
+		Expirations:   c.counters.expirations.Load(),
+		Refreshes:     c.counters.refreshes.Load(),
+		RefreshErrors: c.counters.refreshErrors.Load(),
+		Loads:         c.counters.loads.Load(),
+		Lifetimes:     c.counters.lifetimes.snapshot(),
+		EvictionAges:  c.counters.evictionAges.snapshot(),
+		FirstHits:     c.counters.firstHits.snapshot(),
+	}
+	if st.Loads > 0 {
+		st.AverageLoadTime = time.Duration(c.counters.loadTime.Load() / int64(st.Loads))
+	}
+	for _, s := range c.shards {
+		s.RLock()
+		st.Entries += len(s.entries)
+		st.Bytes += s.bytes
+		s.RUnlock()
+		st.DecodeErrors += s.decodeErrors.Load()
+		st.StoreErrors += s.storeErrors.Load()
+	}
+	return st
+}
+
+// countEviction updates the statistics for an entry that has been removed, and sends its event.
+func (c *Cache) countEviction(key interface{}, created time.Time, reason EvictionReason) {
+	switch reason {
+	case ReasonExpired:
+		c.counters.expirations.Add(1)
+	case ReasonCapacity, ReasonPressure:
+		c.counters.evictions.Add(1)
+		c.counters.evictionAges.observe(c.clock.Now().Sub(created))
+	case ReasonFailed:
+		c.counters.evictions.Add(1)
+	}
+	if reason == ReasonExpired {
+		c.events.send(EventExpire, key, reason, nil)
+	} else {
+		c.events.send(EventEvict, key, reason, nil)
+	}
+}
+
+// countFirstHit records that an entry has been retrieved, and how long after it was stored if this
+// is the first time.
+func (c *Cache) countFirstHit(entry *CacheEntry, now time.Time) {
+	if entry.hit(now) {
+		c.counters.firstHits.observe(now.Sub(entry.created))
+	}
+}
+
+// countHit and countMiss update the statistics for a retrieval of a key, and send its event.
+func (c *Cache) countHit(key interface{}) {
+	c.counters.hits.Add(1)
+	c.events.send(EventHit, key, 0, nil)
+}
+
+func (c *Cache) countMiss(key interface{}) {
+	c.counters.misses.Add(1)
+	c.events.send(EventMiss, key, 0, nil)
+}
+
+// countRefresh updates the statistics for a regeneration of a perpetual entry, and sends its event.
+func (c *Cache) countRefresh(key interface{}, err error) {
+	c.counters.refreshes.Add(1)
+	if err != nil {
+		c.counters.refreshErrors.Add(1)
+		c.events.send(EventRefreshError, key, 0, err)
+	} else {
+		c.events.send(EventRefresh, key, 0, nil)
+	}
+}
@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	cache := NewShardedCache(1)
+
+	cache.Store("a", "value", time.Minute)
+	cache.Store("expired", "value", time.Millisecond)
+	cache.StorePerpetual("perpetual", func() interface{} {
+		time.Sleep(time.Millisecond * 10)
+		return "value"
+	}, time.Minute)
+
+	time.Sleep(time.Millisecond * 5)
+	cache.Get("a")
+	cache.Get("perpetual")
+	cache.Get("missing")
+	cache.Get("expired")
+
+	st := cache.Stats()
+	if st.Hits != 2 || st.Misses != 2 {
+		t.Errorf("Expected 2 hits and 2 misses, but got %d and %d", st.Hits, st.Misses)
+	}
+	if st.HitRatio() != 0.5 {
+		t.Errorf("Expected a hit ratio of 0.5, but got %f", st.HitRatio())
+	}
+	if st.Expirations != 1 {
+		t.Errorf("Expected 1 expiration, but got %d", st.Expirations)
+	}
+	if st.Entries != 2 {
+		t.Errorf("Expected 2 entries, but got %d", st.Entries)
+	}
+	if st.Loads != 1 || st.AverageLoadTime < time.Millisecond*10 {
+		t.Errorf("Expected 1 load taking at least 10ms, but got %d taking %s", st.Loads, st.AverageLoadTime)
+	}
+}
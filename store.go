@@ -0,0 +1,78 @@
+package cache
+
+// Store is where a Cache keeps the values of its entries. The cache itself keeps track of when
+// entries expire, which are perpetual and so on, and uses the Store only to hold the values, so a
+// Store can be backed by something other than memory, such as Redis, BoltDB or memcached.
+//
+// By default each shard of a cache keeps its values in its own map. A Store given to WithStore is
+// instead shared by all the shards, so it must be safe for concurrent use. Its methods are called
+// while a shard is locked, including while it is only read locked, so a slow Store holds up other
+// operations on the same shard; using more shards reduces the effect of this. A Store should not
+// use the cache that uses it.
+//
+// The methods that the cache calls return an error if the Store is unavailable. A value that can't
+// be retrieved is returned as nil, as a value that can't be decoded is; a value that can't be
+// stored leaves the key without an entry, or for a value replaced in place by Update or the
+// regeneration of a perpetual entry, with its old value; and an entry whose value can't be deleted
+// is still removed from the cache. Each error is counted in the StoreErrors of Stats, and logged if
+// the cache was given WithLogger.
+type Store interface {
+	// Get returns the value stored for a key, and true, or false if there is none.
+	Get(key interface{}) (interface{}, bool, error)
+
+	// Set stores a value for a key, replacing any value already stored.
+	Set(key interface{}, value interface{}) error
+
+	// Delete removes the value for a key, returning the value and true, or false if there was none.
+	Delete(key interface{}) (interface{}, bool, error)
+
+	// Iterate calls fn for each key and value in the store until fn returns false, for instance to
+	// list or migrate what a store shared between processes holds. The cache doesn't call it.
+	Iterate(fn func(key, value interface{}) bool) error
+}
+
+// WithStore makes the cache keep its values in the given Store, rather than in memory. The store
+// should be empty, or hold only values that the cache will overwrite, as the cache only knows about
+// the entries stored through it.
+func WithStore(store Store) Option {
+	return func(c *Cache) {
+		c.store = store
+	}
+}
+
+// mapStore is the default Store. Each shard has its own, which is only used while the shard is
+// locked, so it needs no locking of its own.
+type mapStore map[interface{}]interface{}
+
+func (m mapStore) Get(key interface{}) (interface{}, bool, error) {
+	v, ok := m[key]
+	return v, ok, nil
+}
+
+func (m mapStore) Set(key interface{}, value interface{}) error {
+	m[key] = value
+	return nil
+}
+
+func (m mapStore) Delete(key interface{}) (interface{}, bool, error) {
+	v, ok := m[key]
+	delete(m, key)
+	return v, ok, nil
+}
+
+func (m mapStore) Iterate(fn func(key, value interface{}) bool) error {
+	for k, v := range m {
+		if !fn(k, v) {
+			break
+		}
+	}
+	return nil
+}
+
+// storeFailed counts a failed call of the shard's store, and logs it if the cache has a logger.
+func (s *shard) storeFailed(op string, key interface{}, err error) {
+	s.storeErrors.Add(1)
+	if s.logger != nil {
+		s.logger.Warn("cache: store failed", "op", op, "key", key, "error", err)
+	}
+}
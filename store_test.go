@@ -0,0 +1,142 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lockedStore is a Store that can be shared between shards, recording how many values it holds.
+// While err is set, its methods fail with it.
+type lockedStore struct {
+	sync.Mutex
+	values map[interface{}]interface{}
+	err    error
+}
+
+func (l *lockedStore) Get(key interface{}) (interface{}, bool, error) {
+	l.Lock()
+	defer l.Unlock()
+	if l.err != nil {
+		return nil, false, l.err
+	}
+	v, ok := l.values[key]
+	return v, ok, nil
+}
+
+func (l *lockedStore) Set(key interface{}, value interface{}) error {
+	l.Lock()
+	defer l.Unlock()
+	if l.err != nil {
+		return l.err
+	}
+	l.values[key] = value
+	return nil
+}
+
+func (l *lockedStore) Delete(key interface{}) (interface{}, bool, error) {
+	l.Lock()
+	defer l.Unlock()
+	if l.err != nil {
+		return nil, false, l.err
+	}
+	v, ok := l.values[key]
+	delete(l.values, key)
+	return v, ok, nil
+}
+
+func (l *lockedStore) Iterate(fn func(key, value interface{}) bool) error {
+	l.Lock()
+	defer l.Unlock()
+	if l.err != nil {
+		return l.err
+	}
+	for k, v := range l.values {
+		if !fn(k, v) {
+			break
+		}
+	}
+	return nil
+}
+
+func (l *lockedStore) fail(err error) {
+	l.Lock()
+	l.err = err
+	l.Unlock()
+}
+
+func (l *lockedStore) len() int {
+	l.Lock()
+	defer l.Unlock()
+	return len(l.values)
+}
+
+func TestWithStore(t *testing.T) {
+	store := &lockedStore{values: make(map[interface{}]interface{})}
+	cache := NewCache(WithStore(store), WithShards(4))
+
+	cache.Store("a", 1, time.Minute)
+	cache.Store("b", 2, time.Millisecond)
+	cache.StorePerpetual("c", func() interface{} { return 3 }, time.Minute)
+
+	if n := store.len(); n != 3 {
+		t.Errorf("Expected the store to hold 3 values, but has %d", n)
+	}
+	if v, _, _ := store.Get("a"); v != 1 {
+		t.Errorf("Expected the store to hold value 1 for key 'a', but got '%v'", v)
+	}
+
+	// expiry is still handled by the cache
+	time.Sleep(time.Millisecond * 5)
+	if v := cache.Get("b"); v != nil {
+		t.Errorf("Expected cache key 'b' to have expired, but has value '%v'", v)
+	}
+	if v := cache.Get("c"); v != 3 {
+		t.Errorf("Expected cache key 'c' to have value 3, but got '%v'", v)
+	}
+
+	cache.Delete("a")
+	if n := store.len(); n != 1 {
+		t.Errorf("Expected deleted and expired values to be removed from the store, but it has %d", n)
+	}
+	var keys []interface{}
+	store.Iterate(func(key, value interface{}) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if len(keys) != 1 || keys[0] != "c" {
+		t.Errorf("Expected Iterate to list cache key 'c', but got %v", keys)
+	}
+}
+
+func TestStoreErrors(t *testing.T) {
+	store := &lockedStore{values: make(map[interface{}]interface{})}
+	cache := NewCache(WithStore(store))
+	defer cache.Free()
+
+	cache.Store("a", 1, time.Minute)
+	down := errors.New("store down")
+	store.fail(down)
+
+	// a value that can't be retrieved is returned as nil, and one that can't be stored isn't cached
+	if v := cache.Get("a"); v != nil {
+		t.Errorf("Expected a value that can't be retrieved to be nil, but got '%v'", v)
+	}
+	cache.Store("b", 2, time.Minute)
+	if _, ok := cache.GetOK("b"); ok {
+		t.Errorf("Did not expect a value that couldn't be stored to be cached")
+	}
+	if !cache.Delete("a") {
+		t.Errorf("Expected the entry to be removed from the cache when the store can't delete it")
+	}
+	if n := cache.Stats().StoreErrors; n != 3 {
+		t.Errorf("Expected 3 store errors to be counted, but got %d", n)
+	}
+
+	store.fail(nil)
+	cache.Store("b", 2, time.Minute)
+	if v := cache.Get("b"); v != 2 {
+		t.Errorf("Expected the value to be stored once the store is back, but got '%v'", v)
+	}
+}
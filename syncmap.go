@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// WithSyncMap makes Get, GetOK and Lookup read entries without taking any lock, for workloads with
+// a stable set of keys that are read far more often than they are written. Each shard keeps a
+// sync.Map alongside its entries, holding the value and expiry of each entry, which is updated
+// whenever they change. This costs extra memory and makes writes slower. Entries with an idle
+// timeout, and perpetual entries stored with WithStaleWhileRevalidate and a maxStale, are still
+// read with the shard locked. It has no effect on a cache given a Store with WithStore, as values
+// kept elsewhere may be changed by other processes.
+func WithSyncMap() Option {
+	return func(c *Cache) {
+		c.syncMap = true
+	}
+}
+
+// readView is what a shard's sync.Map holds for an entry: enough to return it without the lock.
+type readView struct {
+	entry    *CacheEntry
+	value    interface{}
+	expiry   time.Time
+	negative bool
+}
+
+// publish updates the view of an entry read by loadView, after its value or expiry has changed.
+// The value is as it is kept in the store. The caller must hold the lock.
+func (s *shard) publish(entry *CacheEntry, value interface{}) {
+	if s.views == nil {
+		return
+	}
+	if entry.idle > 0 || (entry.perpetual && entry.async && entry.maxStale > 0) {
+		s.views.Delete(entry.key)
+		return
+	}
+	view := &readView{entry: entry, value: value, negative: entry.negative}
+	if !entry.perpetual {
+		view.expiry = entry.expiry
+	}
+	s.views.Store(entry.key, view)
+}
+
+// unpublish removes the view of an entry. The caller must hold the lock.
+func (s *shard) unpublish(key interface{}) {
+	if s.views != nil {
+		s.views.Delete(key)
+	}
+}
+
+// loadView is Lookup without any lock, using the shard's sync.Map. It returns false if there is no
+// view for the key or it has expired, in which case Lookup must use the shard instead.
+func (c *Cache) loadView(s *shard, key interface{}) (value interface{}, negative, ok bool) {
+	if s.views == nil {
+		return nil, false, false
+	}
+	v, found := s.views.Load(key)
+	if !found {
+		return nil, false, false
+	}
+	view := v.(*readView)
+	now := c.clock.Now()
+	if !c.staleReads && !view.expiry.IsZero() && !view.expiry.After(now) {
+		return nil, false, false
+	}
+	c.countHit(key)
+	c.countFirstHit(view.entry, now)
+	s.recordRead(key)
+	return s.unpack(view.value), view.negative, true
+}
+
+// newViews returns the sync.Map a shard of the cache keeps its views in, or nil if the cache was
+// not created with WithSyncMap.
+func (c *Cache) newViews() *sync.Map {
+	if !c.syncMap || c.store != nil {
+		return nil
+	}
+	return new(sync.Map)
+}
@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSyncMap(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cache := NewCache(WithSyncMap(), WithClock(clock))
+	defer cache.Free()
+
+	cache.Store("Key", "Value", time.Minute)
+	if v := cache.Get("Key"); v != "Value" {
+		t.Errorf("Expected cache key 'Key' to have value 'Value', but got '%v'", v)
+	}
+	cache.Store("Key", "Value2", time.Minute)
+	if v := cache.Get("Key"); v != "Value2" {
+		t.Errorf("Expected cache key 'Key' to have the new value 'Value2', but got '%v'", v)
+	}
+
+	cache.Touch("Key", 3*time.Minute)
+	clock.Add(2 * time.Minute)
+	if v := cache.Get("Key"); v != "Value2" {
+		t.Errorf("Expected touched cache key 'Key' to have value 'Value2', but got '%v'", v)
+	}
+	clock.Add(2 * time.Minute)
+	if v := cache.Get("Key"); v != nil {
+		t.Errorf("Did not expect cache key 'Key' to be still set after expiry, but has value '%v'", v)
+	}
+
+	cache.Store("Key", "Value", time.Minute)
+	cache.Delete("Key")
+	if v := cache.Get("Key"); v != nil {
+		t.Errorf("Did not expect cache key 'Key' to be still set after deleting, but has value '%v'", v)
+	}
+
+	cache.StoreNegative("Missing", time.Minute)
+	if _, found, negative := cache.Lookup("Missing"); !found || !negative {
+		t.Errorf("Expected cache key 'Missing' to be found as negative, but got found %v, negative %v", found, negative)
+	}
+
+	if st := cache.Stats(); st.Hits != 4 || st.Misses != 2 {
+		t.Errorf("Expected 4 hits and 2 misses, but got %d and %d", st.Hits, st.Misses)
+	}
+}
+
+func TestSyncMapConcurrentReads(t *testing.T) {
+	cache := NewCache(WithSyncMap(), WithShards(2))
+	defer cache.Free()
+	cache.SetMaxBytes(1 << 20)
+	for i := 0; i < 100; i++ {
+		cache.Store(i, i, time.Minute)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := (g*7 + i) % 100
+				if v := cache.Get(key); v != key {
+					t.Errorf("Expected cache key %d to have value %d, but got '%v'", key, key, v)
+					return
+				}
+				if i%100 == 0 {
+					cache.Store(key, key, time.Minute)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,64 @@
+package cache
+
+import "time"
+
+// WithTags attaches tags to an entry, so that it can be deleted along with all the other entries
+// carrying the same tag by InvalidateTag.
+func WithTags(tags ...string) EntryOption {
+	return func(e *CacheEntry) {
+		e.tags = append(e.tags, tags...)
+	}
+}
+
+// StoreTagged stores a key/value pair in the cache like Store, attaching the given tags to the
+// entry. It is the same as calling Store with WithTags.
+func (c *Cache) StoreTagged(key interface{}, value interface{}, lifetime time.Duration, tags ...string) {
+	c.Store(key, value, lifetime, WithTags(tags...))
+}
+
+// InvalidateTag deletes all the entries carrying the given tag.
+func (c *Cache) InvalidateTag(tag string) {
+	c.invalidateTag(tag)
+	c.publish(Invalidation{Kind: TagInvalidation, Name: tag})
+}
+
+// invalidateTag is InvalidateTag without publishing to the cache's bus.
+func (c *Cache) invalidateTag(tag string) {
+	for _, s := range c.shards {
+		s.Lock()
+		for key := range s.tags[tag] {
+			s.remove(s.entries[key], ReasonDeleted)
+		}
+		c.unlock(s)
+	}
+}
+
+// index adds an entry to the shard's tag index, and its key to the path index if it is a string.
+// The caller must hold the lock.
+func (s *shard) index(entry *CacheEntry) {
+	if key, ok := entry.key.(string); ok {
+		s.paths.add(key)
+	}
+	for _, tag := range entry.tags {
+		keys := s.tags[tag]
+		if keys == nil {
+			keys = make(map[interface{}]struct{})
+			s.tags[tag] = keys
+		}
+		keys[entry.key] = struct{}{}
+	}
+}
+
+// unindex removes an entry from the shard's tag and path indexes. The caller must hold the lock.
+func (s *shard) unindex(entry *CacheEntry) {
+	if key, ok := entry.key.(string); ok {
+		s.paths.remove(key)
+	}
+	for _, tag := range entry.tags {
+		keys := s.tags[tag]
+		delete(keys, entry.key)
+		if len(keys) == 0 {
+			delete(s.tags, tag)
+		}
+	}
+}
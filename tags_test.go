@@ -0,0 +1,32 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInvalidateTag(t *testing.T) {
+	cache := NewCache()
+
+	cache.StoreTagged("page5/header", "header", time.Minute, "page5")
+	cache.StoreTagged("page5/menu", "menu", time.Minute, "page5", "menus")
+	cache.StoreTagged("page6/menu", "menu", time.Minute, "page6", "menus")
+	cache.StorePerpetual("page5/children", func() interface{} { return "children" }, time.Minute, WithTags("page5"))
+
+	cache.InvalidateTag("page5")
+
+	for _, key := range []string{"page5/header", "page5/menu", "page5/children"} {
+		if v := cache.Get(key); v != nil {
+			t.Errorf("Expected cache key '%s' to be invalidated, but has value '%v'", key, v)
+		}
+	}
+	if v := cache.Get("page6/menu"); v == nil {
+		t.Errorf("Expected cache key 'page6/menu' to be unaffected, but returned nil")
+	}
+
+	// the page5 menu was removed from the menus tag when it was invalidated
+	cache.InvalidateTag("menus")
+	if v := cache.Get("page6/menu"); v != nil {
+		t.Errorf("Expected cache key 'page6/menu' to be invalidated, but has value '%v'", v)
+	}
+}
@@ -0,0 +1,98 @@
+package cache
+
+import "time"
+
+// TTLStore is a Store that can expire values itself. When the second tier of a Tiered cache
+// implements it, values are stored there with the second tier's lifetime.
+type TTLStore interface {
+	Store
+
+	// SetTTL stores a value for a key like Set, to be removed by the store after the given lifetime.
+	SetTTL(key interface{}, value interface{}, lifetime time.Duration) error
+}
+
+// Tiered is a two-tier cache, with a local Cache as the first tier over a second tier Store that is
+// typically shared between processes, such as Redis. Reads check the first tier and fall back to
+// the second, promoting values found there into the first tier. Writes go to both tiers. When the
+// second tier fails, a read is a miss unless the first tier has the value, and a write only goes to
+// the first tier; the errors are counted in the StoreErrors of the first tier's Stats, and logged
+// if it was given WithLogger.
+type Tiered struct {
+	l1 *Cache
+	l2 Store
+
+	l1Lifetime time.Duration
+	l2Lifetime time.Duration
+}
+
+// NewTiered returns a Tiered cache over the given tiers. Values are kept in the first tier for
+// l1Lifetime, and in the second for l2Lifetime if it implements TTLStore; otherwise the second tier
+// keeps values until they are deleted. The first tier lifetime is usually the shorter, as it
+// determines how long a process may return a value after another process has replaced it.
+func NewTiered(l1 *Cache, l2 Store, l1Lifetime, l2Lifetime time.Duration) *Tiered {
+	return &Tiered{l1: l1, l2: l2, l1Lifetime: l1Lifetime, l2Lifetime: l2Lifetime}
+}
+
+// Get returns the value for a key, or nil if neither tier has one. If only the second tier has a
+// value, it is stored in the first tier for the first tier's lifetime.
+func (t *Tiered) Get(key interface{}) interface{} {
+	v, _ := t.GetOK(key)
+	return v
+}
+
+// GetOK is like Get, but also returns whether either tier had a value for the key, so that a
+// stored nil can be told apart from a miss.
+func (t *Tiered) GetOK(key interface{}) (interface{}, bool) {
+	if v, ok := t.l1.GetOK(key); ok {
+		return v, true
+	}
+	v, ok, err := t.l2.Get(key)
+	if err != nil {
+		t.failed("get", key, err)
+		return nil, false
+	}
+	if !ok {
+		return nil, false
+	}
+	t.l1.Store(key, v, t.l1Lifetime)
+	return v, true
+}
+
+// Store stores a value in both tiers, with each tier's lifetime.
+func (t *Tiered) Store(key interface{}, value interface{}) {
+	t.StoreLifetimes(key, value, t.l1Lifetime, t.l2Lifetime)
+}
+
+// StoreLifetimes stores a value in both tiers, with the given lifetimes in place of those the
+// Tiered cache was created with.
+func (t *Tiered) StoreLifetimes(key interface{}, value interface{}, l1Lifetime, l2Lifetime time.Duration) {
+	var err error
+	if ts, ok := t.l2.(TTLStore); ok {
+		err = ts.SetTTL(key, value, l2Lifetime)
+	} else {
+		err = t.l2.Set(key, value)
+	}
+	if err != nil {
+		t.failed("set", key, err)
+	}
+	t.l1.Store(key, value, l1Lifetime)
+}
+
+// Delete removes a key from both tiers. Other processes sharing the second tier may still have the
+// value in their first tier until it expires there.
+func (t *Tiered) Delete(key interface{}) {
+	if _, _, err := t.l2.Delete(key); err != nil {
+		t.failed("delete", key, err)
+	}
+	t.l1.Delete(key)
+}
+
+// failed counts and logs a failed call of the second tier in the first tier.
+func (t *Tiered) failed(op string, key interface{}, err error) {
+	t.l1.shardFor(key).storeFailed(op, key, err)
+}
+
+// Local returns the first tier cache.
+func (t *Tiered) Local() *Cache {
+	return t.l1
+}
@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTiered(t *testing.T) {
+	remote := &lockedStore{values: make(map[interface{}]interface{})}
+	a := NewTiered(NewCache(), remote, time.Minute, time.Hour)
+	b := NewTiered(NewCache(), remote, time.Minute, time.Hour)
+
+	a.Store("siteconfig", "config")
+
+	if v, _, _ := remote.Get("siteconfig"); v != "config" {
+		t.Errorf("Expected the value to be written through to the second tier, but got '%v'", v)
+	}
+
+	// b finds the value in the second tier and promotes it
+	if v := b.Get("siteconfig"); v != "config" {
+		t.Errorf("Expected cache key 'siteconfig' to be found in the second tier, but got '%v'", v)
+	}
+	remote.Delete("siteconfig")
+	if v := b.Get("siteconfig"); v != "config" {
+		t.Errorf("Expected cache key 'siteconfig' to have been promoted to the first tier, but got '%v'", v)
+	}
+
+	a.Delete("siteconfig")
+	if v := a.Get("siteconfig"); v != nil {
+		t.Errorf("Expected cache key 'siteconfig' to be deleted from both tiers, but has value '%v'", v)
+	}
+}
+
+func TestTieredStoredNil(t *testing.T) {
+	remote := &lockedStore{values: make(map[interface{}]interface{})}
+	tiered := NewTiered(NewCache(), remote, time.Minute, time.Hour)
+
+	tiered.Store("optional", nil)
+	remote.Set("optional", "changed")
+	if v, ok := tiered.GetOK("optional"); v != nil || !ok {
+		t.Errorf("Expected the stored nil to be found in the first tier, but got '%v', %v", v, ok)
+	}
+}
+
+func TestTieredStoreErrors(t *testing.T) {
+	remote := &lockedStore{values: make(map[interface{}]interface{}), err: errors.New("redis down")}
+	tiered := NewTiered(NewCache(), remote, time.Minute, time.Hour)
+
+	if _, ok := tiered.GetOK("siteconfig"); ok {
+		t.Errorf("Expected a read to miss when neither tier has the value")
+	}
+	tiered.Store("siteconfig", "config")
+	if v := tiered.Get("siteconfig"); v != "config" {
+		t.Errorf("Expected the value to be stored in the first tier, but got '%v'", v)
+	}
+	if n := tiered.Local().Stats().StoreErrors; n != 2 {
+		t.Errorf("Expected the second tier's 2 errors to be counted, but got %d", n)
+	}
+}
@@ -0,0 +1,148 @@
+package cache
+
+import "time"
+
+// Touch sets the expiry of an entry to the given lifetime from now, and returns true, or returns
+// false if there is no entry for the key or it has already expired. A lifetime of DefaultLifetime
+// uses the cache's default lifetime, and NoExpiry makes the entry never expire. For a perpetual
+// entry this changes when it is next regenerated, but not the lifetime it is given after that. An
+// entry with a soft lifetime is fresh again, and becomes stale that soft lifetime from now.
+func (c *Cache) Touch(key interface{}, lifetime time.Duration) bool {
+	if lifetime < 0 {
+		lifetime = c.defaultTTL
+	}
+	now := c.clock.Now()
+	s := c.shardFor(key)
+	s.Lock()
+	defer s.Unlock()
+	entry := s.entries[key]
+	if entry == nil || entry.expiredAt(now) {
+		return false
+	}
+	entry.expiry = expiryAt(now, lifetime)
+	entry.soften(now)
+	s.publish(entry, s.stored(entry))
+	if !entry.perpetual || entry.index >= 0 {
+		// perpetual entries being regenerated are rescheduled when that completes
+		s.expiries.reschedule(entry)
+	}
+	return true
+}
+
+// TTL returns the time remaining until an entry expires, and true, or false if there is no entry
+// for the key or it has already expired. For a perpetual entry it is the time until it is next
+// regenerated, which is a nanosecond if it is overdue, so that it isn't taken for NoExpiry. For an
+// entry that never expires it is NoExpiry.
+func (c *Cache) TTL(key interface{}) (time.Duration, bool) {
+	now := c.clock.Now()
+	s := c.shardFor(key)
+	s.RLock()
+	defer s.RUnlock()
+	entry := s.entries[key]
+	if entry == nil || entry.expiredAt(now) {
+		return 0, false
+	}
+	if entry.expiry.IsZero() {
+		return NoExpiry, true
+	}
+	return max(entry.expiry.Sub(now), time.Nanosecond), true
+}
+
+// WithSoftTTL gives an entry a soft lifetime, after which it is stale but can still be served, as
+// well as the hard lifetime it is stored with, after which it expires. Get returns stale entries as
+// usual, and GetStale reports that they are stale, so that the caller can return the stale value
+// straight away and refresh it in the background. Both lifetimes are counted from when the entry
+// is stored, or last touched with Touch. It has no effect on perpetual entries, or if it is not
+// shorter than the lifetime.
+func WithSoftTTL(soft time.Duration) EntryOption {
+	return func(e *CacheEntry) {
+		e.softTTL = soft
+	}
+}
+
+// GetStale is like GetOK, but also reports whether the value is stale: whether its entry has
+// passed the soft lifetime given by WithSoftTTL, or been invalidated by InvalidateAndRefresh and
+// not yet regenerated.
+func (c *Cache) GetStale(key interface{}) (value interface{}, stale, found bool) {
+	now := c.clock.Now()
+	s := c.shardFor(key)
+	s.Lock()
+	entry := c.lookup(s, key, now)
+	if entry != nil {
+		value, stale, found = s.value(entry), entry.staleAt(now), true
+	}
+	c.unlock(s)
+	return c.clone(value), stale, found
+}
+
+// staleAt returns true if the entry has passed its soft lifetime at the given time, or has been
+// invalidated by InvalidateAndRefresh.
+func (entry *CacheEntry) staleAt(now time.Time) bool {
+	return entry.invalidated || !entry.softExpiry.IsZero() && !entry.softExpiry.After(now)
+}
+
+// soften sets the soft expiry of an entry with a soft lifetime to that lifetime from now, or clears
+// it if the soft lifetime is not shorter than the time left until the entry expires.
+func (entry *CacheEntry) soften(now time.Time) {
+	if entry.softTTL <= 0 || entry.perpetual {
+		return
+	}
+	entry.softExpiry = time.Time{}
+	if entry.expiry.IsZero() || entry.softTTL < entry.expiry.Sub(now) {
+		entry.softExpiry = now.Add(entry.softTTL)
+	}
+}
+
+// WithIdleTimeout gives an entry sliding expiration: it expires if it is not retrieved with Get
+// for the idle duration, and each Get extends its expiry to idle from then. The lifetime given when
+// storing the entry is still the longest it can remain in the cache. It has no effect on perpetual
+// entries.
+func WithIdleTimeout(idle time.Duration) EntryOption {
+	return func(e *CacheEntry) {
+		e.idle = idle
+	}
+}
+
+// StoreSliding stores a key/value pair that expires once it hasn't been retrieved for the idle
+// duration, and in any case after maxLifetime if it is greater than zero. This is the usual
+// behaviour for sessions and authentication tokens.
+func (c *Cache) StoreSliding(key interface{}, value interface{}, idle, maxLifetime time.Duration, opts ...EntryOption) {
+	entry := c.newEntry(idle, append([]EntryOption{WithIdleTimeout(idle)}, opts...))
+	if maxLifetime > 0 {
+		entry.deadline = c.clock.Now().Add(maxLifetime)
+	} else {
+		entry.deadline = time.Time{}
+	}
+	s := c.shardFor(key)
+	s.Lock()
+	s.add(key, entry, value)
+	c.unlock(s)
+}
+
+// slide extends the expiry of an entry with an idle timeout to idle from now, but not past its
+// deadline. It returns false if the entry doesn't have an idle timeout.
+func (entry *CacheEntry) slide(now time.Time) bool {
+	if entry.idle <= 0 || entry.perpetual {
+		return false
+	}
+	entry.expiry = now.Add(entry.idle)
+	if !entry.deadline.IsZero() && entry.expiry.After(entry.deadline) {
+		entry.expiry = entry.deadline
+	}
+	return true
+}
+
+// expiredAt returns true if the entry is not perpetual and has passed its expiry at the given time.
+// An entry with a zero expiry never expires.
+func (entry *CacheEntry) expiredAt(now time.Time) bool {
+	return !entry.perpetual && !entry.expiry.IsZero() && !entry.expiry.After(now)
+}
+
+// expiryAt returns the expiry of an entry with the given lifetime from now, which is zero if the
+// lifetime is NoExpiry.
+func expiryAt(now time.Time, lifetime time.Duration) time.Time {
+	if lifetime == NoExpiry {
+		return time.Time{}
+	}
+	return now.Add(lifetime)
+}
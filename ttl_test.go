@@ -0,0 +1,161 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTouchAndTTL(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cache := NewCache(WithClock(clock))
+	key := "Session"
+
+	if _, ok := cache.TTL(key); ok {
+		t.Errorf("Did not expect a TTL for missing cache key '%s'", key)
+	}
+	if cache.Touch(key, time.Minute) {
+		t.Errorf("Did not expect Touch to succeed for missing cache key '%s'", key)
+	}
+
+	cache.Store(key, "value", time.Minute)
+	clock.Add(time.Second * 20)
+
+	if ttl, ok := cache.TTL(key); !ok || ttl != time.Second*40 {
+		t.Errorf("Expected cache key '%s' to have TTL 40s, but got %s (%v)", key, ttl, ok)
+	}
+
+	if !cache.Touch(key, time.Minute) {
+		t.Errorf("Expected Touch to succeed for cache key '%s'", key)
+	}
+	clock.Add(time.Second * 50)
+
+	if v := cache.Get(key); v == nil {
+		t.Errorf("Expected touched cache key '%s' to still have a value, but returned nil", key)
+	}
+	if ttl, ok := cache.TTL(key); !ok || ttl != time.Second*10 {
+		t.Errorf("Expected cache key '%s' to have TTL 10s, but got %s (%v)", key, ttl, ok)
+	}
+}
+
+func TestSlidingExpiration(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cache := NewCache(WithClock(clock))
+
+	cache.StoreSliding("session", "value", time.Minute, time.Minute*3)
+	cache.Store("token", "value", time.Minute*10, WithIdleTimeout(time.Minute))
+
+	// each access within the idle timeout keeps the entries alive
+	for i := 0; i < 2; i++ {
+		clock.Add(time.Second * 50)
+		if cache.Get("session") == nil || cache.Get("token") == nil {
+			t.Fatalf("Expected sliding entries to be kept alive by Get")
+		}
+	}
+
+	// the session can't be extended past its maximum lifetime of 3 minutes
+	clock.Add(time.Second * 50)
+	cache.Get("token")
+	clock.Add(time.Second * 50)
+	if v := cache.Get("session"); v != nil {
+		t.Errorf("Expected cache key 'session' to expire at its maximum lifetime, but has value '%v'", v)
+	}
+
+	// the token expires once it is idle
+	clock.Add(time.Second * 70)
+	if v := cache.Get("token"); v != nil {
+		t.Errorf("Expected cache key 'token' to expire when idle, but has value '%v'", v)
+	}
+}
+
+func TestNoExpiry(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cache := NewCache(WithClock(clock), WithSweepInterval(time.Millisecond))
+	defer cache.Free()
+
+	cache.Store("Forever", "value", NoExpiry)
+	cache.Store("Default", "value", DefaultLifetime)
+	cache.Store("Short", "value", time.Minute)
+	if !cache.Touch("Short", NoExpiry) {
+		t.Errorf("Expected Touch to find the entry")
+	}
+
+	clock.Add(time.Hour * 24 * 365)
+	time.Sleep(20 * time.Millisecond)
+	for _, key := range []string{"Forever", "Default", "Short"} {
+		if v := cache.Get(key); v != "value" {
+			t.Errorf("Expected cache key '%s' never to expire, but got '%v'", key, v)
+		}
+		if ttl, ok := cache.TTL(key); ttl != NoExpiry || !ok {
+			t.Errorf("Expected cache key '%s' to have a TTL of NoExpiry, but got %v, %v", key, ttl, ok)
+		}
+	}
+}
+
+func TestTTLOverdue(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cache := NewCache(WithClock(clock), WithSweepInterval(time.Hour))
+	defer cache.Free()
+
+	// a perpetual entry that the sweep hasn't regenerated yet isn't reported as never expiring
+	cache.StorePerpetual("Key", func() interface{} { return "value" }, time.Minute)
+	clock.Add(time.Minute * 2)
+	if ttl, ok := cache.TTL("Key"); ttl != time.Nanosecond || !ok {
+		t.Errorf("Expected an overdue perpetual entry to have a TTL of 1ns, but got %v, %v", ttl, ok)
+	}
+}
+
+func TestSoftTTL(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cache := NewCache(WithClock(clock))
+	defer cache.Free()
+
+	cache.Store("Menu", "menu", time.Minute*10, WithSoftTTL(time.Minute))
+	if v, stale, found := cache.GetStale("Menu"); !found || stale || v != "menu" {
+		t.Errorf("Expected a fresh value, but got '%v', stale %v, found %v", v, stale, found)
+	}
+
+	clock.Add(time.Minute * 2)
+	if v, stale, found := cache.GetStale("Menu"); !found || !stale || v != "menu" {
+		t.Errorf("Expected the value to be served as stale after its soft TTL, but got '%v', stale %v, found %v", v, stale, found)
+	}
+	if v := cache.Get("Menu"); v != "menu" {
+		t.Errorf("Expected Get to return the stale value, but got '%v'", v)
+	}
+	if info, _ := cache.Inspect("Menu"); !info.Stale {
+		t.Errorf("Expected Inspect to report the entry as stale")
+	}
+
+	clock.Add(time.Minute * 10)
+	if v, _, found := cache.GetStale("Menu"); found {
+		t.Errorf("Expected the entry to be gone after its hard TTL, but got '%v'", v)
+	}
+
+	cache.Store("Plain", "value", time.Minute)
+	clock.Add(time.Second * 30)
+	if _, stale, _ := cache.GetStale("Plain"); stale {
+		t.Errorf("Did not expect an entry without a soft TTL to be stale")
+	}
+}
+
+func TestTouchSoftTTL(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cache := NewCache(WithClock(clock))
+	defer cache.Free()
+
+	cache.Store("Menu", "menu", time.Minute*10, WithSoftTTL(time.Minute))
+	clock.Add(time.Minute * 2)
+	cache.Touch("Menu", time.Minute*10)
+	if _, stale, _ := cache.GetStale("Menu"); stale {
+		t.Errorf("Expected a touched entry to be fresh again")
+	}
+	clock.Add(time.Minute)
+	if _, stale, _ := cache.GetStale("Menu"); !stale {
+		t.Errorf("Expected a touched entry to be stale its soft TTL after it was touched")
+	}
+
+	// the soft TTL doesn't apply if the entry is touched with a shorter lifetime
+	cache.Touch("Menu", time.Second*30)
+	if _, stale, _ := cache.GetStale("Menu"); stale {
+		t.Errorf("Expected an entry touched with a lifetime shorter than its soft TTL to be fresh")
+	}
+}
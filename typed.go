@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// TypeError is the error GetAs returns when the value of a key can't be assigned to the
+// destination it is given.
+type TypeError struct {
+	Key interface{}
+
+	// Stored is the type the value was stored with by StoreTyped, or otherwise the type of the
+	// value, and Want is the type of the destination.
+	Stored reflect.Type
+	Want   reflect.Type
+}
+
+func (e *TypeError) Error() string {
+	return fmt.Sprintf("cache: value of key %v is %v, not %v", e.Key, e.Stored, e.Want)
+}
+
+// StoreTyped is Store, recording the type of the value so that GetAs can report it when the value
+// is retrieved as another type, and Inspect can report it for the entry. The type is that of the
+// value as stored, so for instance a nil *User is recorded as *User.
+func (c *Cache) StoreTyped(key interface{}, value interface{}, lifetime time.Duration, opts ...EntryOption) {
+	entry := c.newEntry(lifetime, opts)
+	entry.typ = reflect.TypeOf(value)
+	s := c.shardFor(key)
+	s.Lock()
+	s.add(key, entry, value)
+	c.unlock(s)
+}
+
+// GetAs retrieves the value of a key as Get does, and assigns it to the variable ptr points to,
+// rather than leaving the caller to make a type assertion that could panic. It returns ErrNotFound
+// if there is no value for the key, including for entries stored with StoreNegative, and a
+// *TypeError if the value's type can't be assigned to the variable. A stored nil is assigned as
+// the zero value of variables that can be nil.
+func (c *Cache) GetAs(key interface{}, ptr interface{}) error {
+	dst, err := destination(ptr)
+	if err != nil {
+		return err
+	}
+	value, found, negative := c.Lookup(key)
+	return c.assign(key, dst, value, found, negative)
+}
+
+// destination returns the variable a pointer given to GetAs points to, or an error if it isn't a
+// non-nil pointer.
+func destination(ptr interface{}) (reflect.Value, error) {
+	dst := reflect.ValueOf(ptr)
+	if dst.Kind() != reflect.Pointer || dst.IsNil() {
+		return reflect.Value{}, fmt.Errorf("cache: GetAs given %T rather than a non-nil pointer", ptr)
+	}
+	return dst.Elem(), nil
+}
+
+// assign assigns the value found for a key by Lookup to dst for GetAs.
+func (c *Cache) assign(key interface{}, dst reflect.Value, value interface{}, found, negative bool) error {
+	if !found || negative {
+		return ErrNotFound
+	}
+	v := reflect.ValueOf(value)
+	switch {
+	case v.IsValid() && v.Type().AssignableTo(dst.Type()):
+		dst.Set(v)
+		return nil
+	case !v.IsValid() && nillable(dst.Kind()):
+		dst.SetZero()
+		return nil
+	}
+	stored := c.storedType(key)
+	if stored == nil && v.IsValid() {
+		stored = v.Type()
+	}
+	return &TypeError{Key: key, Stored: stored, Want: dst.Type()}
+}
+
+// nillable returns true if nil can be assigned to values of the kind.
+func nillable(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		return true
+	}
+	return false
+}
+
+// storedType returns the type recorded by StoreTyped for the entry for a key, or nil if there is
+// none.
+func (c *Cache) storedType(key interface{}) reflect.Type {
+	s := c.shardFor(key)
+	s.RLock()
+	defer s.RUnlock()
+	if entry := s.entries[key]; entry != nil {
+		return entry.typ
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type typedUser struct {
+	Name string
+}
+
+func TestGetAs(t *testing.T) {
+	cache := NewCache()
+	defer cache.Free()
+	cache.StoreTyped("User", &typedUser{Name: "fred"}, time.Minute)
+	cache.StoreTyped("Nobody", (*typedUser)(nil), time.Minute)
+	cache.Store("Count", 3, time.Minute)
+	cache.Store("Nil", nil, time.Minute)
+	cache.StoreNegative("Missing", time.Minute)
+
+	var user *typedUser
+	if err := cache.GetAs("User", &user); err != nil || user.Name != "fred" {
+		t.Errorf("Expected cache key 'User' to be assigned, but got %v, %v", user, err)
+	}
+	if err := cache.GetAs("Nobody", &user); err != nil || user != nil {
+		t.Errorf("Expected the nil *typedUser to be assigned, but got %v, %v", user, err)
+	}
+	var stringer fmt.Stringer
+	if err := cache.GetAs("Nil", &stringer); err != nil || stringer != nil {
+		t.Errorf("Expected a stored nil to be assigned to an interface, but got %v, %v", stringer, err)
+	}
+	var n int
+	if err := cache.GetAs("Count", &n); err != nil || n != 3 {
+		t.Errorf("Expected cache key 'Count' to be assigned 3, but got %v, %v", n, err)
+	}
+	var v interface{}
+	if err := cache.GetAs("User", &v); err != nil || v.(*typedUser).Name != "fred" {
+		t.Errorf("Expected the value to be assigned to an empty interface, but got %v, %v", v, err)
+	}
+
+	var te *TypeError
+	if err := cache.GetAs("User", &n); !errors.As(err, &te) || te.Stored != reflect.TypeOf(user) || te.Want != reflect.TypeOf(n) {
+		t.Errorf("Expected a TypeError for the wrong type, but got %v", err)
+	}
+	if err := cache.GetAs("Nil", &n); !errors.As(err, &te) {
+		t.Errorf("Expected a TypeError assigning nil to an int, but got %v", err)
+	}
+	for _, key := range []string{"Missing", "Absent"} {
+		if err := cache.GetAs(key, &n); err != ErrNotFound {
+			t.Errorf("Expected ErrNotFound for cache key '%s', but got %v", key, err)
+		}
+	}
+	if err := cache.GetAs("Count", n); err == nil {
+		t.Errorf("Expected an error when not given a pointer")
+	}
+
+	if info, _ := cache.Inspect("Nobody"); info.Type != reflect.TypeOf(user) {
+		t.Errorf("Expected Inspect to report the stored type, but got %v", info.Type)
+	}
+}
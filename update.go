@@ -0,0 +1,60 @@
+package cache
+
+import "time"
+
+// Add stores a key/value pair like Store, but only if there is no entry for the key, or it has
+// expired. It returns true if the value was stored, and false if an entry was already present, in
+// which case it is left unchanged.
+func (c *Cache) Add(key interface{}, value interface{}, lifetime time.Duration, opts ...EntryOption) bool {
+	entry := c.newEntry(lifetime, opts)
+	s := c.shardFor(key)
+	s.Lock()
+	defer c.unlock(s)
+
+	if s.unexpired(key, c.clock.Now()) != nil {
+		return false
+	}
+	s.add(key, entry, value)
+	return true
+}
+
+// Update atomically replaces the value of an entry with the result of calling fn with the current
+// value, and returns the new value. If there is no entry for the key, or it has expired, fn is
+// called with nil and an entry is created with the result and the given lifetime; otherwise the
+// entry keeps its expiry. fn is called while the key's shard is locked, so it must be quick and
+// must not use the cache.
+func (c *Cache) Update(key interface{}, fn func(old interface{}) interface{}, lifetime time.Duration) interface{} {
+	now := c.clock.Now()
+	s := c.shardFor(key)
+	s.Lock()
+	defer c.unlock(s)
+
+	entry := s.unexpired(key, now)
+	if entry == nil {
+		value := fn(nil)
+		s.add(key, c.newEntry(lifetime, nil), value)
+		return value
+	}
+	value := fn(s.value(entry))
+	s.setValue(entry, value, ChangeSet)
+	return value
+}
+
+// CompareAndSwap replaces the value of an entry with new if its current value is equal to old, and
+// returns true, or returns false without changing it if the value is different or there is no
+// entry for the key. The entry keeps its expiry. The values are compared with ==, so old must be
+// comparable. In a cache created with WithValueCodec, the current value is decoded afresh to be
+// compared, so a pointer value never equals old and CompareAndSwap always returns false for it.
+func (c *Cache) CompareAndSwap(key interface{}, old, new interface{}) bool {
+	now := c.clock.Now()
+	s := c.shardFor(key)
+	s.Lock()
+	defer c.unlock(s)
+
+	entry := s.entries[key]
+	if entry == nil || entry.expiredAt(now) || s.value(entry) != old {
+		return false
+	}
+	s.setValue(entry, new, ChangeSet)
+	return true
+}
@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAdd(t *testing.T) {
+	cache := NewCache()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	added := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if cache.Add("lock", i, time.Minute) {
+				mu.Lock()
+				added++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if added != 1 {
+		t.Errorf("Expected exactly one Add to succeed, but %d did", added)
+	}
+
+	cache.Store("expired", 1, time.Millisecond)
+	time.Sleep(time.Millisecond * 5)
+	if !cache.Add("expired", 2, time.Minute) {
+		t.Errorf("Expected Add to succeed over an expired entry")
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	cache := NewCache()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			cache.Update("list", func(old interface{}) interface{} {
+				list, _ := old.([]int)
+				return append(list, i)
+			}, time.Minute)
+		}(i)
+	}
+	wg.Wait()
+
+	if list := cache.Get("list").([]int); len(list) != 10 {
+		t.Errorf("Expected all 10 updates to be kept, but got %v", list)
+	}
+}
+
+func TestAddUpdateExpired(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cache := NewCache(WithClock(clock), WithSweepInterval(time.Hour))
+	defer cache.Free()
+	reasons := map[interface{}][]EvictionReason{}
+	cache.OnEvict(func(key, value interface{}, reason EvictionReason) {
+		reasons[key] = append(reasons[key], reason)
+	})
+
+	cache.Store("added", 1, time.Minute)
+	cache.Store("updated", 1, time.Minute)
+	clock.Add(2 * time.Minute)
+	if !cache.Add("added", 2, time.Minute) {
+		t.Errorf("Expected Add to succeed over an expired entry")
+	}
+	if v := cache.Update("updated", func(old interface{}) interface{} { return old }, time.Minute); v != nil {
+		t.Errorf("Expected Update to be called with nil for an expired entry, but got '%v'", v)
+	}
+
+	// the expired entries are removed as expired, rather than replaced by the new ones
+	for _, key := range []string{"added", "updated"} {
+		if r := reasons[key]; len(r) != 1 || r[0] != ReasonExpired {
+			t.Errorf("Expected cache key '%s' to be removed as expired, but got %v", key, r)
+		}
+	}
+	if st := cache.Stats(); st.Expirations != 2 {
+		t.Errorf("Expected 2 expirations, but got %d", st.Expirations)
+	}
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	cache := NewCache()
+
+	if cache.CompareAndSwap("key", nil, "new") {
+		t.Errorf("Did not expect CompareAndSwap to succeed for a missing key")
+	}
+
+	cache.Store("key", "old", time.Minute)
+	if cache.CompareAndSwap("key", "other", "new") {
+		t.Errorf("Did not expect CompareAndSwap to succeed when the value differs")
+	}
+	if !cache.CompareAndSwap("key", "old", "new") {
+		t.Errorf("Expected CompareAndSwap to succeed when the value matches")
+	}
+	if v := cache.Get("key"); v != "new" {
+		t.Errorf("Expected cache key 'key' to have value 'new', but got '%v'", v)
+	}
+}
@@ -0,0 +1,121 @@
+package cache
+
+import "reflect"
+
+// WithValueCodec has the cache keep values encoded with codec, and decode them each time they are
+// retrieved, so that each caller gets its own copy of a value rather than sharing it with the
+// cache and every other caller. Changes a caller makes to a value it retrieved, such as setting a
+// field of a cached struct, then don't affect the cached value.
+//
+// Values are decoded into a new value of the type that was stored, so a struct is returned as the
+// same struct type, including with JSONCodec, but only the fields the codec encodes are copied.
+// Encoding and decoding cost time and allocations on every Store and Get, and values the codec
+// can't encode are kept as they are. Values that can't be decoded are returned as nil, and counted
+// in the DecodeErrors of Stats. As each retrieval decodes a new value, CompareAndSwap never
+// succeeds for a pointer value, since the decoded pointer is never equal to old.
+//
+// Encoded values count towards the limit set by SetMaxBytes with the size of their encoding,
+// unless they were stored with WithSize or the cache has a CostFunc, which is given the value as it
+// was stored. The codec has no effect on a cache given a Store with WithStore, which must encode
+// values itself if they are to leave the process.
+func WithValueCodec(codec Codec) Option {
+	return func(c *Cache) {
+		c.valueCodec = codec
+	}
+}
+
+// WithCloneOnGet has the cache return a copy of each value it returns, made by calling cloner with
+// the cached value, so that callers can't change the cached value or each other's copies. This is
+// cheaper than WithValueCodec when the values' types have efficient ways to copy themselves. The
+// copies are made by Get, GetOK, Lookup, GetMulti and Range, without the cache locked. cloner is not
+// called for nil values.
+func WithCloneOnGet(cloner func(interface{}) interface{}) Option {
+	return func(c *Cache) {
+		c.cloner = cloner
+	}
+}
+
+// clone returns a copy of a value being returned to a caller, if the cache was created with
+// WithCloneOnGet, or the value itself otherwise.
+func (c *Cache) clone(value interface{}) interface{} {
+	if c.cloner == nil || value == nil {
+		return value
+	}
+	return c.cloner(value)
+}
+
+// packed is how a value that has been encoded or compressed is kept in a shard's store.
+type packed struct {
+	data []byte
+
+	// the type of the value encoded in data by the cache's value codec, or nil if the value was not
+	// encoded, in which case it was a byte slice, or a string if str is true.
+	typ reflect.Type
+	str bool
+
+	// true if data is compressed.
+	compressed bool
+}
+
+// pack returns how a value being stored is kept in the shard's store: encoded with the cache's
+// value codec and compressed if the cache is configured to, or as it is otherwise.
+func (s *shard) pack(value interface{}) interface{} {
+	var p packed
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		p.data = v
+	case string:
+		p.data, p.str = []byte(v), true
+	}
+	if s.valueCodec != nil {
+		data, err := s.valueCodec.Marshal(value)
+		if err != nil {
+			return value
+		}
+		p = packed{data: data, typ: reflect.TypeOf(value)}
+	}
+	if p.data == nil {
+		return value
+	}
+	if c := s.compression; c != nil && len(p.data) >= c.threshold {
+		if data, err := c.compressor.Compress(p.data); err == nil {
+			p.data, p.compressed = data, true
+		}
+	}
+	if p.typ == nil && !p.compressed {
+		return value
+	}
+	return p
+}
+
+// unpack returns a value kept in the shard's store as it was stored, decompressing and decoding it
+// if necessary. A value that can't be unpacked is returned as nil, and counted in the cache's
+// DecodeErrors. It uses only the shard's configuration, so it may be called without the lock.
+func (s *shard) unpack(stored interface{}) interface{} {
+	p, ok := stored.(packed)
+	if !ok {
+		return stored
+	}
+	data := p.data
+	if p.compressed {
+		var err error
+		if data, err = s.compression.compressor.Decompress(data); err != nil {
+			s.decodeErrors.Add(1)
+			return nil
+		}
+	}
+	if p.typ != nil {
+		v := reflect.New(p.typ)
+		if err := s.valueCodec.Unmarshal(data, v.Interface()); err != nil {
+			s.decodeErrors.Add(1)
+			return nil
+		}
+		return v.Elem().Interface()
+	}
+	if p.str {
+		return string(data)
+	}
+	return data
+}
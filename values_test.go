@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+type user struct {
+	Name  string
+	Roles []string
+}
+
+func TestValueCodec(t *testing.T) {
+	for _, codec := range []Codec{GobCodec{}, JSONCodec{}} {
+		cache := NewCache(WithValueCodec(codec), WithSyncMap())
+		cache.Store("user", &user{Name: "fred", Roles: []string{"admin"}}, time.Minute)
+		cache.Store("count", 3, time.Minute)
+
+		u := cache.Get("user").(*user)
+		u.Name = "changed"
+		u.Roles[0] = "changed"
+		if u := cache.Get("user").(*user); u.Name != "fred" || u.Roles[0] != "admin" {
+			t.Errorf("Expected changes to a retrieved value not to affect the cached value using %T, but got %+v", codec, u)
+		}
+		if v := cache.Get("count"); v != 3 {
+			t.Errorf("Expected cache key 'count' to be decoded as int 3 using %T, but got %T %v", codec, v, v)
+		}
+		cache.Free()
+	}
+}
+
+func TestValueCodecCompressed(t *testing.T) {
+	cache := NewCache(WithValueCodec(GobCodec{}), WithCompression(1024, nil))
+	defer cache.Free()
+
+	big := user{Name: strings.Repeat("fred", 1000)}
+	cache.Store("user", big, time.Minute)
+	if v, ok := cache.Get("user").(user); !ok || v.Name != big.Name {
+		t.Errorf("Expected cache key 'user' to be decompressed and decoded, but got %T", cache.Get("user"))
+	}
+	if st := cache.Stats(); st.Bytes >= int64(len(big.Name)) {
+		t.Errorf("Expected the encoded value to be compressed to less than %d bytes, but got %d", len(big.Name), st.Bytes)
+	}
+}
+
+func TestCloneOnGet(t *testing.T) {
+	clones := 0
+	cache := NewCache(WithCloneOnGet(func(v interface{}) interface{} {
+		clones++
+		u := *v.(*user)
+		u.Roles = append([]string(nil), u.Roles...)
+		return &u
+	}))
+	defer cache.Free()
+	cache.Store("user", &user{Name: "fred", Roles: []string{"admin"}}, time.Minute)
+	cache.Store("nil", nil, time.Minute)
+
+	u := cache.Get("user").(*user)
+	u.Roles[0] = "changed"
+	if u := cache.Get("user").(*user); u.Roles[0] != "admin" {
+		t.Errorf("Expected changes to a retrieved value not to affect the cached value, but got %+v", u)
+	}
+	if u := cache.GetMulti([]interface{}{"user"})["user"].(*user); u.Roles[0] != "admin" {
+		t.Errorf("Expected GetMulti to return a copy, but got %+v", u)
+	}
+	if v, ok := cache.GetOK("nil"); !ok || v != nil {
+		t.Errorf("Expected cache key 'nil' to have a nil value, but got '%v'", v)
+	}
+	cache.Keys()
+	if clones != 3 {
+		t.Errorf("Expected 3 clones, but got %d", clones)
+	}
+}
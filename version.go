@@ -0,0 +1,42 @@
+package cache
+
+import "time"
+
+// GetVersioned is like GetOK, but also returns the version of the value, for use with
+// StoreIfVersion. Each time a value is stored for a key, by Store, Update, the regeneration of a
+// perpetual entry or any other means, it gets a higher version than the key had before, even if
+// the key was deleted in between. The version is 0 if there is no entry for the key.
+func (c *Cache) GetVersioned(key interface{}) (value interface{}, version uint64, ok bool) {
+	now := c.clock.Now()
+	s := c.shardFor(key)
+	s.Lock()
+	entry := c.lookup(s, key, now)
+	if entry != nil {
+		value, version, ok = s.value(entry), entry.version, true
+	}
+	c.unlock(s)
+	return c.clone(value), version, ok
+}
+
+// StoreIfVersion stores a value for a key like Store, but only if the key's current version is
+// expectedVersion, as returned by GetVersioned, so that a writer doesn't overwrite a value that was
+// stored after it read the one it is replacing. An expectedVersion of 0 stores the value only if
+// there is no entry for the key. It returns the new version and true if the value was stored, or
+// the current version and false if it was not.
+func (c *Cache) StoreIfVersion(key, value interface{}, expectedVersion uint64, lifetime time.Duration, opts ...EntryOption) (uint64, bool) {
+	entry := c.newEntry(lifetime, opts)
+	now := c.clock.Now()
+	s := c.shardFor(key)
+	s.Lock()
+	defer c.unlock(s)
+
+	var current uint64
+	if old := s.entries[key]; old != nil && !old.expiredAt(now) {
+		current = old.version
+	}
+	if current != expectedVersion {
+		return current, false
+	}
+	s.add(key, entry, value)
+	return entry.version, true
+}
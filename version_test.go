@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStoreIfVersion(t *testing.T) {
+	cache := NewCache()
+	defer cache.Free()
+
+	if _, ok := cache.StoreIfVersion("Key", "first", 1, time.Minute); ok {
+		t.Errorf("Did not expect a value to be stored with a version for a missing key")
+	}
+	v1, ok := cache.StoreIfVersion("Key", "first", 0, time.Minute)
+	if !ok || v1 == 0 {
+		t.Fatalf("Expected the value to be stored for a missing key with version 0, but got %d, %v", v1, ok)
+	}
+
+	value, version, ok := cache.GetVersioned("Key")
+	if !ok || value != "first" || version != v1 {
+		t.Errorf("Expected value 'first' with version %d, but got '%v' with version %d", v1, value, version)
+	}
+
+	// another writer gets in first
+	cache.Update("Key", func(old interface{}) interface{} { return "other" }, time.Minute)
+	if current, ok := cache.StoreIfVersion("Key", "second", v1, time.Minute); ok || current <= v1 {
+		t.Errorf("Expected a stale version not to overwrite the newer value, but got version %d, %v", current, ok)
+	}
+	if v := cache.Get("Key"); v != "other" {
+		t.Errorf("Expected cache key 'Key' to keep value 'other', but got '%v'", v)
+	}
+
+	_, version, _ = cache.GetVersioned("Key")
+	if v2, ok := cache.StoreIfVersion("Key", "second", version, time.Minute); !ok || v2 <= version {
+		t.Errorf("Expected the current version to store a newer version, but got %d, %v", v2, ok)
+	}
+
+	// versions keep increasing when a key is deleted and stored again
+	_, last, _ := cache.GetVersioned("Key")
+	cache.Delete("Key")
+	if _, version, ok := cache.GetVersioned("Key"); ok || version != 0 {
+		t.Errorf("Expected no version for a deleted key, but got %d", version)
+	}
+	cache.Store("Key", "again", time.Minute)
+	if _, version, _ := cache.GetVersioned("Key"); version <= last {
+		t.Errorf("Expected the version to increase after storing the key again, but got %d after %d", version, last)
+	}
+}
@@ -0,0 +1,67 @@
+package cache
+
+import "context"
+
+// waiter is a channel closed when a key is stored, and the number of GetWait calls waiting on it.
+type waiter struct {
+	ch chan struct{}
+	n  int
+}
+
+// GetWait is like Get, but if there is no value for the key it waits until one is stored, for
+// instance by another goroutine that has started loading it, rather than returning nil. It returns
+// the context's error if the context is done first, and ErrClosed if the cache is freed. Entries
+// stored with StoreNegative count as stored, and are returned as nil.
+func (c *Cache) GetWait(ctx context.Context, key interface{}) (interface{}, error) {
+	if c.closed.Load() {
+		return nil, ErrClosed
+	}
+	s := c.shardFor(key)
+	for {
+		s.Lock()
+		if entry := c.lookup(s, key, c.clock.Now()); entry != nil {
+			value := s.value(entry)
+			c.unlock(s)
+			return c.clone(value), nil
+		}
+		w := s.waiters[key]
+		if w == nil {
+			if s.waiters == nil {
+				s.waiters = make(map[interface{}]*waiter)
+			}
+			w = &waiter{ch: make(chan struct{})}
+			s.waiters[key] = w
+		}
+		w.n++
+		c.unlock(s)
+
+		select {
+		case <-w.ch:
+			continue
+		case <-ctx.Done():
+			s.release(key, w)
+			return nil, ctx.Err()
+		case <-c.ctx.Done():
+			s.release(key, w)
+			return nil, ErrClosed
+		}
+	}
+}
+
+// release stops a GetWait call that has given up waiting on w from counting as waiting for key.
+func (s *shard) release(key interface{}, w *waiter) {
+	s.Lock()
+	defer s.Unlock()
+	w.n--
+	if w.n == 0 && s.waiters[key] == w {
+		delete(s.waiters, key)
+	}
+}
+
+// wake releases the GetWait calls waiting for a key to be stored. The caller must hold the lock.
+func (s *shard) wake(key interface{}) {
+	if w := s.waiters[key]; w != nil {
+		close(w.ch)
+		delete(s.waiters, key)
+	}
+}
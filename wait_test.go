@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGetWait(t *testing.T) {
+	cache := NewCache()
+	defer cache.Free()
+
+	cache.Store("Ready", "Value", time.Minute)
+	if v, err := cache.GetWait(context.Background(), "Ready"); err != nil || v != "Value" {
+		t.Errorf("Expected GetWait to return a stored value at once, but got '%v', %v", v, err)
+	}
+
+	results := make(chan interface{}, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			v, _ := cache.GetWait(context.Background(), "Loading")
+			results <- v
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	cache.Store("Loading", "Loaded", time.Minute)
+	for i := 0; i < 3; i++ {
+		select {
+		case v := <-results:
+			if v != "Loaded" {
+				t.Errorf("Expected the waiting GetWait to return 'Loaded', but got '%v'", v)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Expected GetWait to return once the key was stored")
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := cache.GetWait(ctx, "Never"); err != context.DeadlineExceeded {
+		t.Errorf("Expected GetWait to give up when the context is done, but got %v", err)
+	}
+	s := cache.shardFor("Never")
+	s.RLock()
+	n := len(s.waiters)
+	s.RUnlock()
+	if n != 0 {
+		t.Errorf("Expected no waiters to be left after giving up, but there are %d", n)
+	}
+}
+
+func TestGetWaitFree(t *testing.T) {
+	cache := NewCache()
+	errs := make(chan error)
+	go func() {
+		_, err := cache.GetWait(context.Background(), "Key")
+		errs <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cache.Free()
+	if err := <-errs; err != ErrClosed {
+		t.Errorf("Expected GetWait to return ErrClosed when the cache is freed, but got %v", err)
+	}
+}
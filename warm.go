@@ -0,0 +1,101 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// WarmSpec describes an entry for Warm to load.
+type WarmSpec struct {
+	// Key is the key to store the value under.
+	Key interface{}
+
+	// Load loads the value. Its context is cancelled if the cache is freed.
+	Load ContextValueGenerator
+
+	// Lifetime and Options are as they would be given to Store, or with Perpetual set, to
+	// StorePerpetualContext, in which case Load is the entry's generator. Either way the options
+	// for generators, such as WithGeneratorTimeout, apply to Load.
+	Lifetime  time.Duration
+	Options   []EntryOption
+	Perpetual bool
+}
+
+// WarmError is the error Warm reports for an entry that failed to load.
+type WarmError struct {
+	Key interface{}
+	Err error
+}
+
+func (e *WarmError) Error() string {
+	return fmt.Sprintf("cache: warming %v: %v", e.Key, e.Err)
+}
+
+func (e *WarmError) Unwrap() error {
+	return e.Err
+}
+
+// WithWarmConcurrency sets how many entries Warm loads at once. The default is GOMAXPROCS.
+func WithWarmConcurrency(n int) Option {
+	return func(c *Cache) {
+		c.warmConcurrency = n
+	}
+}
+
+// Warm loads and stores the given entries, several at once up to the limit set by
+// WithWarmConcurrency, and returns once they have all been loaded. It is meant for filling the
+// cache with entries that are needed straight away before a service starts taking requests. Entries
+// that fail to load are not stored, and their errors are returned together, each as a *WarmError,
+// once the rest have been loaded. A panic in a loader is recovered, and reported as a
+// *PanicError. Entries loaded after the cache is freed fail with ErrClosed.
+func (c *Cache) Warm(entries []WarmSpec) error {
+	if c.closed.Load() {
+		return ErrClosed
+	}
+	n := c.warmConcurrency
+	if n <= 0 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	errs := make([]error, len(entries))
+	sem := make(chan struct{}, n)
+	var wg sync.WaitGroup
+	for i, spec := range entries {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := c.warm(spec); err != nil {
+				errs[i] = &WarmError{Key: spec.Key, Err: err}
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// warm loads and stores one entry for Warm.
+func (c *Cache) warm(spec WarmSpec) error {
+	if spec.Perpetual {
+		return c.storePerpetual(c.ctx, spec.Key, spec.Load, spec.Lifetime, spec.Options)
+	}
+	entry := &CacheEntry{key: spec.Key, fn: spec.Load}
+	for _, opt := range spec.Options {
+		opt(entry)
+	}
+	value, err := c.generate(c.ctx, entry)
+	if err != nil {
+		return err
+	}
+	if c.closed.Load() {
+		// the cache was freed while the value was being loaded
+		return ErrClosed
+	}
+	c.Store(spec.Key, value, spec.Lifetime, spec.Options...)
+	return nil
+}
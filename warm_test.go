@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWarm(t *testing.T) {
+	cache := NewCache(WithWarmConcurrency(2))
+	defer cache.Free()
+
+	var running, most atomic.Int32
+	load := func(value interface{}, err error) ContextValueGenerator {
+		return func(context.Context) (interface{}, error) {
+			n := running.Add(1)
+			for m := most.Load(); n > m && !most.CompareAndSwap(m, n); m = most.Load() {
+			}
+			time.Sleep(5 * time.Millisecond)
+			running.Add(-1)
+			return value, err
+		}
+	}
+	fail := errors.New("database down")
+	err := cache.Warm([]WarmSpec{
+		{Key: "SiteConfig", Load: load("config", nil), Lifetime: time.Hour, Perpetual: true},
+		{Key: "Menu", Load: load("menu", nil), Lifetime: time.Minute},
+		{Key: "Routes", Load: load(nil, fail), Lifetime: time.Minute},
+		{Key: "Footer", Load: load("footer", nil), Lifetime: time.Minute},
+	})
+
+	var werr *WarmError
+	if !errors.As(err, &werr) || werr.Key != "Routes" || !errors.Is(err, fail) {
+		t.Errorf("Expected Warm to report the failure to load 'Routes', but got %v", err)
+	}
+	for key, want := range map[string]string{"SiteConfig": "config", "Menu": "menu", "Footer": "footer"} {
+		if v := cache.Get(key); v != want {
+			t.Errorf("Expected cache key '%s' to be warmed with '%s', but got '%v'", key, want, v)
+		}
+	}
+	if v, ok := cache.GetOK("Routes"); ok {
+		t.Errorf("Did not expect the failed key to be stored, but has value '%v'", v)
+	}
+	if info, _ := cache.Inspect("SiteConfig"); !info.Perpetual {
+		t.Errorf("Expected 'SiteConfig' to be stored as a perpetual entry")
+	}
+	if m := most.Load(); m > 2 {
+		t.Errorf("Expected at most 2 loads at once, but %d ran together", m)
+	}
+}
+
+func TestWarmGeneratorTimeout(t *testing.T) {
+	cache := NewCache()
+	defer cache.Free()
+
+	slow := func(ctx context.Context) (interface{}, error) {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Second):
+			return "slow", nil
+		}
+	}
+	err := cache.Warm([]WarmSpec{
+		{Key: "Slow", Load: slow, Lifetime: time.Minute, Options: []EntryOption{WithGeneratorTimeout(10 * time.Millisecond)}},
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the load to time out, but got %v", err)
+	}
+	if v, ok := cache.GetOK("Slow"); ok {
+		t.Errorf("Did not expect the key that timed out to be stored, but has value '%v'", v)
+	}
+}
+
+func TestWarmFree(t *testing.T) {
+	cache := NewCache()
+	release := make(chan struct{})
+	started := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- cache.Warm([]WarmSpec{{Key: "Menu", Lifetime: time.Minute, Load: func(context.Context) (interface{}, error) {
+			close(started)
+			<-release
+			return "menu", nil
+		}}})
+	}()
+	<-started
+	cache.Free()
+	close(release)
+
+	var werr *WarmError
+	if err := <-done; !errors.As(err, &werr) || !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed for an entry loaded after Free, but got %v", err)
+	}
+}
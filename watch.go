@@ -0,0 +1,194 @@
+package cache
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ChangeKind is the kind of a ValueChange.
+type ChangeKind int
+
+const (
+	// ChangeSet means a value was stored for the key, replacing any value it had, or the value of
+	// its entry was changed with Update, CompareAndSwap or Increment.
+	ChangeSet ChangeKind = iota
+
+	// ChangeRefresh means a perpetual entry was regenerated with a new value.
+	ChangeRefresh
+
+	// ChangeRemove means the entry was removed, for the reason given by the change's Reason.
+	ChangeRemove
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeSet:
+		return "set"
+	case ChangeRefresh:
+		return "refresh"
+	case ChangeRemove:
+		return "remove"
+	}
+	return "unknown"
+}
+
+// ValueChange is a change to the value of a key, as sent to the channels returned by Watch,
+// WatchPrefix and WatchFunc. Value is the new value, or for ChangeRemove the value that was
+// removed, and Reason is only set for ChangeRemove. Replacing a value is sent as a single
+// ChangeSet, rather than the removal of the old value followed by the new one.
+type ValueChange struct {
+	Kind   ChangeKind
+	Key    interface{}
+	Value  interface{}
+	Reason EvictionReason
+}
+
+// watchBuffer is the capacity of the channels returned by Watch, WatchPrefix and WatchFunc.
+const watchBuffer = 64
+
+// watches are the channels returned by Watch, WatchPrefix and WatchFunc. They are shared with the
+// shards, and so must not refer to the cache.
+type watches struct {
+	// the number of channels, so that shards only record changes while something is watching.
+	active atomic.Int32
+
+	mu       sync.Mutex
+	keys     map[interface{}]map[*watch]struct{}
+	matchers map[*watch]struct{}
+	chans    map[<-chan ValueChange]*watch
+	closed   bool
+}
+
+// watch is a channel returned by Watch, for a key, or by WatchPrefix or WatchFunc, for the keys
+// match returns true for.
+type watch struct {
+	ch    chan ValueChange
+	key   interface{}
+	match func(key interface{}) bool
+}
+
+// Watch returns a channel to which a ValueChange is sent each time the value of key is stored,
+// changed or refreshed, or its entry is removed, so that state derived from it can be rebuilt as
+// soon as it changes. Changes are sent once the shard holding the key has been unlocked, but
+// without blocking, so if the channel's buffer is full because changes are not received quickly
+// enough, they are dropped; a watcher that must not miss the latest value can read it from the
+// cache after receiving a change. The channel is closed by Unwatch, or when the cache is freed.
+func (c *Cache) Watch(key interface{}) <-chan ValueChange {
+	return c.watches.add(&watch{key: key})
+}
+
+// WatchPrefix is like Watch, but for the entries whose keys are strings in the subtree of path, as
+// deleted by DeletePrefix: the key equal to path, and the keys that start with path followed by
+// PathSeparator.
+func (c *Cache) WatchPrefix(path string) <-chan ValueChange {
+	path = strings.TrimSuffix(path, PathSeparator)
+	return c.WatchFunc(func(key interface{}) bool {
+		k, ok := key.(string)
+		return ok && (k == path || strings.HasPrefix(k, path+PathSeparator))
+	})
+}
+
+// WatchFunc is like Watch, but for the entries whose keys match returns true for, such as every key
+// in a namespace. match is called for each change while the cache's watches are locked, so it must
+// be quick, and must not use the cache.
+func (c *Cache) WatchFunc(match func(key interface{}) bool) <-chan ValueChange {
+	return c.watches.add(&watch{match: match})
+}
+
+// Unwatch stops changes being sent to a channel returned by Watch, WatchPrefix or WatchFunc, and
+// closes it.
+func (c *Cache) Unwatch(ch <-chan ValueChange) {
+	w := c.watches
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if wt := w.chans[ch]; wt != nil {
+		w.remove(wt)
+		close(wt.ch)
+	}
+}
+
+// add registers a watch and returns its channel, which is already closed if the cache has been
+// freed.
+func (w *watches) add(wt *watch) <-chan ValueChange {
+	wt.ch = make(chan ValueChange, watchBuffer)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		close(wt.ch)
+		return wt.ch
+	}
+	if w.chans == nil {
+		w.chans = make(map[<-chan ValueChange]*watch)
+		w.keys = make(map[interface{}]map[*watch]struct{})
+		w.matchers = make(map[*watch]struct{})
+	}
+	w.chans[wt.ch] = wt
+	if wt.match != nil {
+		w.matchers[wt] = struct{}{}
+	} else {
+		if w.keys[wt.key] == nil {
+			w.keys[wt.key] = make(map[*watch]struct{})
+		}
+		w.keys[wt.key][wt] = struct{}{}
+	}
+	w.active.Add(1)
+	return wt.ch
+}
+
+// remove unregisters a watch. The caller must hold the lock.
+func (w *watches) remove(wt *watch) {
+	delete(w.chans, wt.ch)
+	if wt.match != nil {
+		delete(w.matchers, wt)
+	} else {
+		delete(w.keys[wt.key], wt)
+		if len(w.keys[wt.key]) == 0 {
+			delete(w.keys, wt.key)
+		}
+	}
+	w.active.Add(-1)
+}
+
+// notify sends changes to the channels watching their keys. The caller must hold the lock.
+func (w *watches) notify(changes []ValueChange) {
+	for _, change := range changes {
+		for wt := range w.keys[change.Key] {
+			wt.send(change)
+		}
+		for wt := range w.matchers {
+			if wt.match(change.Key) {
+				wt.send(change)
+			}
+		}
+	}
+}
+
+// send sends a change to the watch's channel without blocking.
+func (wt *watch) send(change ValueChange) {
+	select {
+	case wt.ch <- change:
+	default:
+	}
+}
+
+// close closes every channel, and any returned by Watch, WatchPrefix and WatchFunc after it is
+// called.
+func (w *watches) close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	for _, wt := range w.chans {
+		w.remove(wt)
+		close(wt.ch)
+	}
+}
+
+// watch records a change to a key while the shard is locked, if anything is watching the cache,
+// to be sent once the shard is unlocked. The caller must hold the lock.
+func (s *shard) watch(kind ChangeKind, key, value interface{}, reason EvictionReason) {
+	if s.watches.active.Load() == 0 {
+		return
+	}
+	s.watched = append(s.watched, ValueChange{Kind: kind, Key: key, Value: value, Reason: reason})
+}
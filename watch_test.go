@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+// receiveChanges returns the changes waiting on a channel returned by Watch.
+func receiveChanges(ch <-chan ValueChange) []ValueChange {
+	var changes []ValueChange
+	for {
+		select {
+		case change, ok := <-ch:
+			if !ok {
+				return changes
+			}
+			changes = append(changes, change)
+		default:
+			return changes
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cache := NewCache(WithClock(clock))
+	defer cache.Free()
+
+	ch := cache.Watch("Key")
+	cache.Store("Other", "Value", time.Minute)
+	cache.Store("Key", "Value", time.Minute)
+	cache.Store("Key", "Changed", time.Minute)
+	cache.Update("Key", func(old interface{}) interface{} { return old.(string) + "!" }, time.Minute)
+	cache.Delete("Key")
+	cache.Store("Key", "Value", time.Second)
+	clock.Add(2 * time.Second)
+	cache.Get("Key")
+
+	want := []ValueChange{
+		{Kind: ChangeSet, Key: "Key", Value: "Value"},
+		{Kind: ChangeSet, Key: "Key", Value: "Changed"},
+		{Kind: ChangeSet, Key: "Key", Value: "Changed!"},
+		{Kind: ChangeRemove, Key: "Key", Value: "Changed!", Reason: ReasonDeleted},
+		{Kind: ChangeSet, Key: "Key", Value: "Value"},
+		{Kind: ChangeRemove, Key: "Key", Value: "Value", Reason: ReasonExpired},
+	}
+	changes := receiveChanges(ch)
+	if len(changes) != len(want) {
+		t.Fatalf("Expected %d changes, but got %+v", len(want), changes)
+	}
+	for i, change := range changes {
+		if change != want[i] {
+			t.Errorf("Expected change %d to be %+v, but got %+v", i, want[i], change)
+		}
+	}
+
+	cache.Unwatch(ch)
+	cache.Store("Key", "Value", time.Minute)
+	if _, ok := <-ch; ok {
+		t.Errorf("Expected the channel to be closed by Unwatch")
+	}
+}
+
+func TestWatchRefresh(t *testing.T) {
+	cache := NewCache()
+	defer cache.Free()
+
+	ch := cache.Watch("Perpetual")
+	cache.StorePerpetual("Perpetual", counter(0), time.Hour)
+	cache.Refresh("Perpetual")
+
+	changes := receiveChanges(ch)
+	if len(changes) != 2 || changes[0].Kind != ChangeSet || changes[1].Kind != ChangeRefresh || changes[1].Value != 2 {
+		t.Errorf("Expected the perpetual entry to be stored and then refreshed with value 2, but got %+v", changes)
+	}
+}
+
+func TestWatchPrefix(t *testing.T) {
+	cache := NewCache()
+	ch := cache.WatchPrefix("sitetree/5/")
+	cache.Store("sitetree/5", "Page", time.Minute)
+	cache.Store("sitetree/5/children", "Children", time.Minute)
+	cache.Store("sitetree/50", "Other", time.Minute)
+	cache.Store(5, "Other", time.Minute)
+	cache.DeletePrefix("sitetree")
+
+	var keys []interface{}
+	for _, change := range receiveChanges(ch) {
+		keys = append(keys, change.Key)
+	}
+	if len(keys) != 4 || keys[0] != "sitetree/5" || keys[1] != "sitetree/5/children" {
+		t.Errorf("Expected changes to the keys in the subtree of 'sitetree/5' only, but got %v", keys)
+	}
+
+	cache.Free()
+	if _, ok := <-ch; ok {
+		t.Errorf("Expected the channel to be closed when the cache is freed")
+	}
+	if _, ok := <-cache.Watch("Key"); ok {
+		t.Errorf("Expected Watch to return a closed channel once the cache is freed")
+	}
+}
+
+func TestWatchFunc(t *testing.T) {
+	cache := NewCache()
+	defer cache.Free()
+	ch := cache.WatchFunc(func(key interface{}) bool {
+		nk, ok := key.(NamespacedKey)
+		return ok && nk.Namespace == "users"
+	})
+	cache.Namespace("users").Store("42", "User", time.Minute)
+	cache.Namespace("pages").Store("42", "Page", time.Minute)
+	cache.Store("42", "Other", time.Minute)
+	cache.Namespace("users").Delete("42")
+
+	changes := receiveChanges(ch)
+	if len(changes) != 2 || changes[0].Kind != ChangeSet || changes[1].Kind != ChangeRemove {
+		t.Errorf("Expected a set and a remove in namespace 'users' only, but got %v", changes)
+	}
+	cache.Unwatch(ch)
+	if _, ok := <-ch; ok {
+		t.Errorf("Expected Unwatch to close the channel")
+	}
+}